@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/config"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/handlers"
+	"github.com/jikku/command-center/internal/hosting"
+)
+
+// Credentials of the admin account in the integration test config
+const (
+	testAdminUser     = "admin"
+	testAdminPassword = "integration-password"
+)
+
+// testServer is the full server (config, database, hosting and the complete
+// middleware chain) running on an httptest.Server
+type testServer struct {
+	*httptest.Server
+	t      *testing.T
+	client *http.Client // keeps the session cookie between requests
+}
+
+// startTestServer boots the server against a temp database the way
+// handleStartCommand does, minus the listeners and background jobs.
+// The configuration can only be loaded once per process, so every
+// integration test shares it.
+func startTestServer(t *testing.T) *testServer {
+	t.Helper()
+	dir := t.TempDir()
+
+	cfg, err := loadTestConfig(dir)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if err := applyCookieConfig(cfg); err != nil {
+		t.Fatalf("Invalid auth config: %v", err)
+	}
+
+	if err := database.Init(filepath.Join(dir, "data.db")); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if err := audit.Init(database.GetDB()); err != nil {
+		t.Fatalf("Failed to initialize audit logging: %v", err)
+	}
+	if err := hosting.Init(database.GetDB()); err != nil {
+		t.Fatalf("Failed to initialize hosting: %v", err)
+	}
+
+	sessionStore := auth.NewSessionStore(auth.SessionTTL)
+	t.Cleanup(sessionStore.Stop)
+	handlers.InitAuth(sessionStore, auth.NewRateLimiter(), auth.NewAccountRateLimiter())
+	handlers.SetSiteBaseURL(cfg.SiteBaseURL())
+
+	srv := httptest.NewServer(newServerHandler(cfg, sessionStore))
+	t.Cleanup(srv.Close)
+
+	jar, _ := cookiejar.New(nil)
+	return &testServer{Server: srv, t: t, client: &http.Client{Jar: jar, Timeout: 10 * time.Second}}
+}
+
+// loadTestConfig writes a development config with known credentials and loads it
+func loadTestConfig(dir string) (*config.Config, error) {
+	if config.Path() != "" {
+		return config.Get(), nil
+	}
+
+	hash, err := auth.HashPassword(testAdminPassword)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.CreateDefaultConfig()
+	cfg.Server.Domain = "localhost"
+	cfg.Server.Env = "development"
+	cfg.Auth.Username = testAdminUser
+	cfg.Auth.PasswordHash = hash
+	mockData := false
+	cfg.Server.MockData = &mockData
+
+	path := filepath.Join(dir, "config.json")
+	if err := config.SaveToFile(cfg, path); err != nil {
+		return nil, err
+	}
+	return config.Load(&config.CLIFlags{ConfigPath: path})
+}
+
+// do sends a request to host (the dashboard when empty) and returns the
+// response with its body read
+func (s *testServer) do(host, method, path string, body io.Reader, header http.Header) (*http.Response, []byte) {
+	s.t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, body)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if host != "" {
+		req.Host = host
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("%s %s: reading body: %v", method, path, err)
+	}
+	return resp, data
+}
+
+// postJSON sends v as JSON to the dashboard and decodes the JSON response into out
+func (s *testServer) postJSON(path string, v, out interface{}) *http.Response {
+	s.t.Helper()
+	body, _ := json.Marshal(v)
+	resp, data := s.do("", http.MethodPost, path, bytes.NewReader(body), http.Header{"Content-Type": {"application/json"}})
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			s.t.Fatalf("POST %s: invalid JSON %q: %v", path, data, err)
+		}
+	}
+	return resp
+}
+
+// login signs in as the test admin, storing the session cookie
+func (s *testServer) login() {
+	s.t.Helper()
+	resp := s.postJSON("/api/login", map[string]string{"username": testAdminUser, "password": testAdminPassword}, nil)
+	if resp.StatusCode != http.StatusOK {
+		s.t.Fatalf("login: status %d", resp.StatusCode)
+	}
+}
+
+// deploy uploads the files in dir as siteName with an API key
+func (s *testServer) deploy(token, siteName string, files map[string]string) *http.Response {
+	s.t.Helper()
+	dir := s.t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	zipData, _, err := createDeployZip(dir)
+	if err != nil {
+		s.t.Fatalf("createDeployZip: %v", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("site_name", siteName)
+	part, _ := mw.CreateFormFile("file", "deploy.zip")
+	part.Write(zipData.Bytes())
+	mw.Close()
+
+	resp, data := s.do("", http.MethodPost, "/api/deploy", &body, http.Header{
+		"Content-Type":  {mw.FormDataContentType()},
+		"Authorization": {"Bearer " + token},
+	})
+	if resp.StatusCode != http.StatusOK {
+		s.t.Fatalf("deploy %s: status %d %q", siteName, resp.StatusCode, data)
+	}
+	return resp
+}
+
+func TestIntegration_DeployAndTrack(t *testing.T) {
+	s := startTestServer(t)
+
+	// The API needs a session
+	resp, _ := s.do("", http.MethodGet, "/api/keys", nil, nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GET /api/keys before login: status %d, want 401", resp.StatusCode)
+	}
+
+	s.login()
+
+	var key struct {
+		Success bool   `json:"success"`
+		Token   string `json:"token"`
+	}
+	if resp := s.postJSON("/api/keys", map[string]string{"name": "ci", "scopes": "deploy"}, &key); resp.StatusCode != http.StatusOK || key.Token == "" {
+		t.Fatalf("create key: status %d, token %q", resp.StatusCode, key.Token)
+	}
+
+	s.deploy(key.Token, "blog", map[string]string{"index.html": "<h1>Hello from blog</h1>"})
+
+	// The site is served by host, with the lighter site headers
+	resp, body := s.do("blog.localhost", http.MethodGet, "/", nil, nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "Hello from blog") {
+		t.Fatalf("GET blog.localhost/: status %d, body %q", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Frame-Options") != "" {
+		t.Error("hosted site got the dashboard's X-Frame-Options header")
+	}
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("request tracing middleware didn't run")
+	}
+
+	resp, _ = s.do("missing.localhost", http.MethodGet, "/", nil, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET missing.localhost/: status %d, want 404", resp.StatusCode)
+	}
+
+	// Serverless sites are run rather than served as files
+	s.deploy(key.Token, "api", map[string]string{
+		"main.js": `res.json({ok: true, path: req.path})`,
+	})
+	resp, body = s.do("api.localhost", http.MethodGet, "/ping", nil, nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `"ok":true`) {
+		t.Errorf("GET api.localhost/ping: status %d, body %q", resp.StatusCode, body)
+	}
+
+	// Tracking is public and cross-origin
+	resp, _ = s.do("", http.MethodPost, "/track", strings.NewReader(`{"h":"blog.localhost","p":"/","e":"click"}`),
+		http.Header{"Content-Type": {"text/plain"}, "Origin": {"http://blog.localhost"}})
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("POST /track: status %d, want 204", resp.StatusCode)
+	}
+
+	var count int
+	database.GetDB().QueryRow("SELECT COUNT(*) FROM events WHERE domain = 'blog.localhost' AND event_type = 'click'").Scan(&count)
+	if count != 1 {
+		t.Errorf("recorded %d click events, want 1", count)
+	}
+	database.GetDB().QueryRow("SELECT COUNT(*) FROM events WHERE domain = 'blog' AND source_type = 'hosting'").Scan(&count)
+	if count != 1 {
+		t.Errorf("recorded %d site visits, want 1", count)
+	}
+}
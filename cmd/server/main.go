@@ -4,6 +4,10 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -11,6 +15,7 @@ import (
 	"io"
 	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,24 +24,37 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/audit"
 	"github.com/jikku/command-center/internal/auth"
 	"github.com/jikku/command-center/internal/config"
 	"github.com/jikku/command-center/internal/database"
 	"github.com/jikku/command-center/internal/handlers"
 	"github.com/jikku/command-center/internal/hosting"
+	"github.com/jikku/command-center/internal/logging"
 	"github.com/jikku/command-center/internal/middleware"
+	"github.com/jikku/command-center/internal/notifier"
 	"github.com/jikku/command-center/internal/provision"
 	"github.com/jikku/command-center/internal/security"
-	"golang.org/x/crypto/bcrypt"
 	_ "modernc.org/sqlite"
 	"github.com/caddyserver/certmagic"
 )
 
-const Version = "v0.4.0"
+// Build information. Release builds set these with -ldflags, e.g.
+// -X main.Version=v0.4.1 -X main.Commit=$(git rev-parse --short HEAD) -X main.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+// (see the Makefile). This is the one place the version lives.
+var (
+	Version   = "v0.4.0"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// dataDirUsage describes the --data-dir flag shared by the server commands
+const dataDirUsage = "Directory for config.json, data.db and the PID file (default: $XDG_CONFIG_HOME/fazt and $XDG_DATA_HOME/fazt, else ~/.config/fazt)"
 
 var (
 	showVersion = flag.Bool("version", false, "Show version and exit")
@@ -63,6 +81,15 @@ func main() {
 		return
 	}
 
+	// Older versions kept their config in ~/.config/cc
+	if command != "service" {
+		if legacyDir, err := config.MigrateLegacyDir(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else if legacyDir != "" {
+			fmt.Fprintf(os.Stderr, "Moved config from %s to %s\n", legacyDir, config.ResolvePaths("").ConfigDir)
+		}
+	}
+
 	// Handle top-level subcommands
 	switch command {
 	case "server":
@@ -84,8 +111,9 @@ func main() {
 // CLI Command Functions (v0.4.0)
 // ===================================================================================
 
-// initCommand initializes server configuration for first-time setup
-func initCommand(username, password, domain, port, env, configPath string) error {
+// initCommand initializes server configuration for first-time setup.
+// The database goes in dataDir, or beside the config file if dataDir is empty.
+func initCommand(username, password, domain, port, env, configPath, dataDir string) error {
 	// Check if config already exists
 	if _, err := os.Stat(configPath); err == nil {
 		return fmt.Errorf("Error: Server already initialized\nConfig exists at: %s", configPath)
@@ -107,17 +135,24 @@ func initCommand(username, password, domain, port, env, configPath string) error
 		return fmt.Errorf("Error: invalid environment '%s' (must be 'development' or 'production')", env)
 	}
 
-	// Hash password with bcrypt cost 12
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	// Hash password with the default algorithm (bcrypt cost 12)
+	passwordHash, err := auth.Hash(password)
 	if err != nil {
 		return fmt.Errorf("Error: failed to hash password: %v", err)
 	}
 
-	// Create config directory with secure permissions
+	// Create config and data directories with secure permissions
 	configDir := filepath.Dir(configPath)
 	if err := os.MkdirAll(configDir, 0700); err != nil {
 		return fmt.Errorf("Error: failed to create config directory: %v", err)
 	}
+	dbPath := filepath.Join(configDir, "data.db")
+	if dataDir != "" {
+		dbPath = filepath.Join(dataDir, "data.db")
+		if err := os.MkdirAll(dataDir, 0700); err != nil {
+			return fmt.Errorf("Error: failed to create data directory: %v", err)
+		}
+	}
 
 	// Create config
 	cfg := &config.Config{
@@ -127,11 +162,11 @@ func initCommand(username, password, domain, port, env, configPath string) error
 			Env:    env,
 		},
 		Database: config.DatabaseConfig{
-			Path: filepath.Join(configDir, "data.db"),
+			Path: dbPath,
 		},
 		Auth: config.AuthConfig{
 			Username:     username,
-			PasswordHash: string(passwordHash),
+			PasswordHash: passwordHash,
 		},
 		Ntfy: config.NtfyConfig{
 			Topic: "",
@@ -168,11 +203,14 @@ func setCredentialsCommand(username, password, configPath string) error {
 		cfg.Auth.Username = username
 	}
 	if password != "" {
-		passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err := applyHashConfig(cfg); err != nil {
+			return fmt.Errorf("Error: Invalid hash settings: %v", err)
+		}
+		passwordHash, err := auth.Hash(password)
 		if err != nil {
 			return fmt.Errorf("Error: Failed to hash password: %v", err)
 		}
-		cfg.Auth.PasswordHash = string(passwordHash)
+		cfg.Auth.PasswordHash = passwordHash
 	}
 
 	// Save config
@@ -180,9 +218,61 @@ func setCredentialsCommand(username, password, configPath string) error {
 		return fmt.Errorf("Error: Failed to save config: %v", err)
 	}
 
+	auditCLIAction(cfg, "credentials_change", configPath)
+
+	return nil
+}
+
+// applyHashConfig sets the algorithm used for new password and API key hashes
+func applyHashConfig(cfg *config.Config) error {
+	return auth.SetHashParams(auth.HashParams{
+		Algorithm:     cfg.Auth.HashAlgorithm,
+		BcryptCost:    cfg.Auth.BcryptCost,
+		Argon2Time:    cfg.Auth.Argon2.Time,
+		Argon2Memory:  cfg.Auth.Argon2.Memory,
+		Argon2Threads: cfg.Auth.Argon2.Threads,
+	})
+}
+
+// applyCookieConfig sets the session cookie attributes from config
+func applyCookieConfig(cfg *config.Config) error {
+	opts := auth.DefaultCookieOptions(cfg.IsProduction())
+	if cfg.Auth.Cookie.Name != "" {
+		opts.Name = cfg.Auth.Cookie.Name
+	}
+	if cfg.Auth.Cookie.Path != "" {
+		opts.Path = cfg.Auth.Cookie.Path
+	}
+	opts.Domain = cfg.Auth.Cookie.Domain
+	sameSite, err := auth.ParseSameSite(cfg.Auth.Cookie.SameSite)
+	if err != nil {
+		return err
+	}
+	opts.SameSite = sameSite
+	opts.Secure = cfg.SecureCookie()
+
+	auth.SetCookieOptions(opts)
 	return nil
 }
 
+// auditCLIAction records a CLI change in the audit log if the server database exists
+func auditCLIAction(cfg *config.Config, action, resource string) {
+	dbPath := config.ExpandPath(cfg.Database.Path)
+	if _, err := os.Stat(dbPath); err != nil {
+		return
+	}
+
+	if err := database.Init(dbPath); err != nil {
+		return
+	}
+	defer database.Close()
+
+	if err := audit.Init(database.GetDB()); err != nil {
+		return
+	}
+	audit.LogSuccess(cfg.Auth.Username, "cli", action, resource)
+}
+
 // setConfigCommand updates server configuration settings
 func setConfigCommand(domain, port, env, configPath string) error {
 	// Validate at least one field is provided
@@ -235,7 +325,7 @@ func setConfigCommand(domain, port, env, configPath string) error {
 }
 
 // statusCommand displays current configuration and server status
-func statusCommand(configPath, configDir string) (string, error) {
+func statusCommand(configPath string) (string, error) {
 	// Load config
 	cfg, err := config.LoadFromFile(configPath)
 	if err != nil {
@@ -260,7 +350,7 @@ func statusCommand(configPath, configDir string) (string, error) {
 	}
 
 	// Check sites directory
-	sitesDir := filepath.Join(configDir, "sites")
+	sitesDir := filepath.Join(filepath.Dir(cfg.Database.Path), "sites")
 	if stat, err := os.Stat(sitesDir); err == nil && stat.IsDir() {
 		if entries, err := os.ReadDir(sitesDir); err == nil {
 			output.WriteString(fmt.Sprintf("Sites:        %s/ (%d sites)\n", sitesDir, len(entries)))
@@ -272,9 +362,12 @@ func statusCommand(configPath, configDir string) (string, error) {
 	}
 
 	// Check PID file for server status
-	// FIX: Use correct path ~/.config/fazt/cc-server.pid
-	pidFile := filepath.Join(configDir, "cc-server.pid")
-	if pidData, err := os.ReadFile(pidFile); err == nil {
+	pidData, err := os.ReadFile(config.PIDFile(cfg.Database.Path))
+	if err != nil {
+		// Started by a version from before the rename
+		pidData, err = os.ReadFile(config.LegacyPIDFile(cfg.Database.Path))
+	}
+	if err == nil {
 		pidStr := strings.TrimSpace(string(pidData))
 		output.WriteString(fmt.Sprintf("\nServer:       ● Running (PID: %s)\n", pidStr))
 	} else {
@@ -284,6 +377,350 @@ func statusCommand(configPath, configDir string) (string, error) {
 	return output.String(), nil
 }
 
+// openDatabase loads the config at configPath and initializes the database and hosting system
+func openDatabase(configPath string) (*config.Config, error) {
+	cfg, err := config.LoadFromFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Error: Config not found at %s\nRun 'fazt server init' first", configPath)
+		}
+		return nil, fmt.Errorf("Error: Failed to load config: %v", err)
+	}
+
+	if err := database.Init(config.ExpandPath(cfg.Database.Path)); err != nil {
+		return nil, fmt.Errorf("Error: Failed to open database: %v", err)
+	}
+
+	if err := hosting.Init(database.GetDB()); err != nil {
+		return nil, fmt.Errorf("Error: Failed to initialize hosting: %v", err)
+	}
+	hosting.SetCompression(cfg.Hosting.GzipLevel, cfg.Hosting.GzipMinBytes)
+
+	if err := applyHashConfig(cfg); err != nil {
+		return nil, fmt.Errorf("Error: Invalid hash settings: %v", err)
+	}
+
+	return cfg, nil
+}
+
+// domainsCommand lists, adds or removes custom domain mappings
+func domainsCommand(action, hostname, site, configPath string) (string, error) {
+	if action != "list" && action != "add" && action != "remove" {
+		return "", fmt.Errorf("Error: unknown action '%s' (must be list, add or remove)", action)
+	}
+	if action == "add" && (hostname == "" || site == "") {
+		return "", errors.New("Error: --hostname and --site are required")
+	}
+	if action == "remove" && hostname == "" {
+		return "", errors.New("Error: --hostname is required")
+	}
+
+	if _, err := openDatabase(configPath); err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	switch action {
+	case "add":
+		if err := hosting.AddCustomDomain(hostname, site); err != nil {
+			return "", fmt.Errorf("Error: %v", err)
+		}
+		return fmt.Sprintf("✓ %s now serves site '%s'\n", hosting.NormalizeHostname(hostname), strings.ToLower(site)), nil
+	case "remove":
+		if err := hosting.RemoveCustomDomain(hostname); err != nil {
+			return "", fmt.Errorf("Error: %v", err)
+		}
+		return fmt.Sprintf("✓ Removed %s\n", hosting.NormalizeHostname(hostname)), nil
+	}
+
+	domains, err := hosting.ListCustomDomains()
+	if err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+	if len(domains) == 0 {
+		return "No custom domains configured\n", nil
+	}
+
+	var output strings.Builder
+	for _, d := range domains {
+		output.WriteString(fmt.Sprintf("%-40s → %s\n", d.Hostname, d.SiteID))
+	}
+	return output.String(), nil
+}
+
+// aliasesCommand lists, adds or removes site aliases
+func aliasesCommand(action, alias, site, configPath string) (string, error) {
+	if action != "list" && action != "add" && action != "remove" {
+		return "", fmt.Errorf("Error: unknown action '%s' (must be list, add or remove)", action)
+	}
+	if action == "add" && (alias == "" || site == "") {
+		return "", errors.New("Error: --alias and --site are required")
+	}
+	if action == "remove" && alias == "" {
+		return "", errors.New("Error: --alias is required")
+	}
+
+	if _, err := openDatabase(configPath); err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	switch action {
+	case "add":
+		if err := hosting.AddSiteAlias(alias, site); err != nil {
+			return "", fmt.Errorf("Error: %v", err)
+		}
+		return fmt.Sprintf("✓ %s now serves site '%s'\n", strings.ToLower(alias), strings.ToLower(site)), nil
+	case "remove":
+		if err := hosting.RemoveSiteAlias(alias); err != nil {
+			return "", fmt.Errorf("Error: %v", err)
+		}
+		return fmt.Sprintf("✓ Removed alias %s\n", strings.ToLower(alias)), nil
+	}
+
+	aliases, err := hosting.ListSiteAliases()
+	if err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+	if len(aliases) == 0 {
+		return "No site aliases configured\n", nil
+	}
+
+	var output strings.Builder
+	for _, a := range aliases {
+		output.WriteString(fmt.Sprintf("%-30s → %s\n", a.Alias, a.SiteID))
+	}
+	return output.String(), nil
+}
+
+// exportCommand writes the server's data as portable JSON to out
+func exportCommand(out, configPath string) (string, error) {
+	if out == "" {
+		return "", errors.New("Error: --out is required")
+	}
+
+	if _, err := openDatabase(configPath); err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	dump, err := database.Export()
+	if err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+	// Webhook secrets are included, so keep the dump private
+	if err := os.WriteFile(out, append(data, '\n'), 0600); err != nil {
+		return "", fmt.Errorf("Error: Failed to write %s: %v", out, err)
+	}
+
+	return fmt.Sprintf("✓ Exported %d events, %d redirects, %d webhooks, %d sites and %d env var names to %s\n",
+		len(dump.Events), len(dump.Redirects), len(dump.Webhooks), len(dump.Sites), len(dump.EnvVars), out), nil
+}
+
+// importCommand restores a dump written by exportCommand into a fresh database
+func importCommand(in, configPath string) (string, error) {
+	if in == "" {
+		return "", errors.New("Error: --in is required")
+	}
+
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return "", fmt.Errorf("Error: Failed to read %s: %v", in, err)
+	}
+	var dump database.Dump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return "", fmt.Errorf("Error: %s is not a valid export: %v", in, err)
+	}
+
+	if _, err := openDatabase(configPath); err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	if err := database.Import(&dump); err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("✓ Imported %d events, %d redirects, %d webhooks and %d sites from %s\n",
+		len(dump.Events), len(dump.Redirects), len(dump.Webhooks), len(dump.Sites), in))
+	if len(dump.Sites) > 0 {
+		output.WriteString("\nSite files aren't part of the export; redeploy each site:\n")
+		for _, site := range dump.Sites {
+			output.WriteString(fmt.Sprintf("  %-30s %d files\n", site.SiteID, len(site.Files)))
+		}
+	}
+	if len(dump.EnvVars) > 0 {
+		output.WriteString("\nEnv var values aren't part of the export; set them again:\n")
+		for _, v := range dump.EnvVars {
+			output.WriteString(fmt.Sprintf("  %s: %s\n", v.SiteID, v.Name))
+		}
+	}
+	return output.String(), nil
+}
+
+// sitesCommand lists sites or toggles whether a site is served
+func sitesCommand(action, site, configPath string) (string, error) {
+	if action != "list" && action != "enable" && action != "disable" {
+		return "", fmt.Errorf("Error: unknown action '%s' (must be list, enable or disable)", action)
+	}
+	if action != "list" && site == "" {
+		return "", errors.New("Error: --site is required")
+	}
+
+	if _, err := openDatabase(configPath); err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	if action != "list" {
+		if !hosting.SiteExists(site) {
+			return "", fmt.Errorf("Error: site '%s' not found", site)
+		}
+		enabled := action == "enable"
+		if err := hosting.SetSiteEnabled(site, enabled); err != nil {
+			return "", fmt.Errorf("Error: %v", err)
+		}
+		return fmt.Sprintf("✓ Site '%s' %sd\n", site, action), nil
+	}
+
+	sites, err := hosting.ListSites()
+	if err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+	if len(sites) == 0 {
+		return "No sites deployed\n", nil
+	}
+
+	var output strings.Builder
+	for _, s := range sites {
+		state := "enabled"
+		if !s.Enabled {
+			state = "disabled"
+		}
+		if s.AliasOf != "" {
+			output.WriteString(fmt.Sprintf("%-30s alias of %-28s %s\n", s.Name, s.AliasOf, state))
+			continue
+		}
+		output.WriteString(fmt.Sprintf("%-30s %5d files  %10d bytes  %s\n", s.Name, s.FileCount, s.SizeBytes, state))
+	}
+	return output.String(), nil
+}
+
+// statsCommand reads quick metrics straight from the database. It opens the
+// database read-only, so it works whether or not the server is running.
+func statsCommand(configPath string) (string, error) {
+	cfg, err := config.LoadFromFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("Error: Config not found at %s\nRun 'fazt server init' first", configPath)
+		}
+		return "", fmt.Errorf("Error: Failed to load config: %v", err)
+	}
+
+	dbPath := config.ExpandPath(cfg.Database.Path)
+	db, err := database.OpenReadOnly(dbPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("Error: Database not found at %s", dbPath)
+		}
+		return "", fmt.Errorf("Error: %v", err)
+	}
+	defer db.Close()
+
+	var totalEvents, eventsToday, sites int64
+	var topDomain sql.NullString
+	var topDomainEvents int64
+	queries := []struct {
+		query string
+		dest  []interface{}
+	}{
+		{`SELECT COUNT(*) FROM events`, []interface{}{&totalEvents}},
+		{`SELECT COUNT(*) FROM events WHERE created_at >= DATE('now')`, []interface{}{&eventsToday}},
+		{`SELECT COUNT(DISTINCT site_id) FROM files`, []interface{}{&sites}},
+		{`SELECT domain, COUNT(*) AS count FROM events WHERE domain != '' GROUP BY domain ORDER BY count DESC LIMIT 1`, []interface{}{&topDomain, &topDomainEvents}},
+	}
+	for _, q := range queries {
+		if err := db.QueryRow(q.query).Scan(q.dest...); err != nil && err != sql.ErrNoRows {
+			if strings.Contains(err.Error(), "database is locked") {
+				return "", errors.New("Error: Database is locked by another process; try again in a moment")
+			}
+			return "", fmt.Errorf("Error: Failed to read stats: %v", err)
+		}
+	}
+
+	// The write-ahead log holds recent writes not yet checkpointed into the main file
+	var size int64
+	for _, path := range []string{dbPath, dbPath + "-wal"} {
+		if stat, err := os.Stat(path); err == nil {
+			size += stat.Size()
+		}
+	}
+
+	var output strings.Builder
+	output.WriteString("Server Stats\n")
+	output.WriteString("═══════════════════════════════════════════════════════════\n")
+	output.WriteString(fmt.Sprintf("Database:     %s (%.1f MB)\n", dbPath, float64(size)/(1024*1024)))
+	output.WriteString(fmt.Sprintf("Events:       %d total, %d today\n", totalEvents, eventsToday))
+	output.WriteString(fmt.Sprintf("Sites:        %d\n", sites))
+	if topDomain.Valid {
+		output.WriteString(fmt.Sprintf("Top domain:   %s (%d events)\n", topDomain.String, topDomainEvents))
+	} else {
+		output.WriteString("Top domain:   none\n")
+	}
+	return output.String(), nil
+}
+
+// serverLogFile returns the log file configured in configPath for `fazt server logs`
+func serverLogFile(configPath string) (string, error) {
+	cfg, err := config.LoadFromFile(configPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("Error: Config not found at %s\nRun 'fazt server init' first", configPath)
+		}
+		return "", fmt.Errorf("Error: Failed to load config: %v", err)
+	}
+	if cfg.Server.LogFile == "" {
+		return "", errors.New("Error: No log file configured\nSet server.log_file in the config and restart the server, or use 'fazt service logs' under systemd")
+	}
+
+	path := config.ExpandPath(cfg.Server.LogFile)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("Error: Cannot read log file %s: %v", path, err)
+	}
+	return path, nil
+}
+
+// resetTokenCommand issues a one-time token for resetting the admin password
+func resetTokenCommand(configPath string) (string, error) {
+	cfg, err := openDatabase(configPath)
+	if err != nil {
+		return "", err
+	}
+	defer database.Close()
+
+	token, expiresAt, err := auth.CreateResetToken(database.GetDB(), auth.ResetTokenTTL)
+	if err != nil {
+		return "", fmt.Errorf("Error: %v", err)
+	}
+
+	if err := audit.Init(database.GetDB()); err == nil {
+		audit.LogSuccess(cfg.Auth.Username, "cli", "password_reset_token", configPath)
+	}
+
+	var output strings.Builder
+	output.WriteString(fmt.Sprintf("Reset token: %s\n", token))
+	output.WriteString(fmt.Sprintf("Expires:     %s (single use)\n\n", expiresAt.Local().Format(time.RFC1123)))
+	output.WriteString("Set a new password with:\n")
+	output.WriteString(fmt.Sprintf("  curl -X POST %s/api/reset-password \\\n", cfg.Server.Domain))
+	output.WriteString(fmt.Sprintf("    -d '{\"token\":\"%s\",\"password\":\"<new password>\"}'\n", token))
+	return output.String(), nil
+}
+
 // handleServerCommand handles server-related subcommands
 func handleServerCommand(args []string) {
 	if len(args) < 1 {
@@ -303,6 +740,22 @@ func handleServerCommand(args []string) {
 		handleSetConfigCommand()
 	case "status":
 		handleStatusCommand()
+	case "stats":
+		handleStatsCommand()
+	case "domains":
+		handleDomainsCommand()
+	case "sites":
+		handleSitesCommand()
+	case "aliases":
+		handleAliasesCommand()
+	case "reset-token":
+		handleResetTokenCommand()
+	case "logs":
+		handleLogsCommand()
+	case "export":
+		handleExportCommand()
+	case "import":
+		handleImportCommand()
 	case "start":
 		handleStartCommand()
 	case "--help", "-h", "help":
@@ -328,24 +781,24 @@ func handleServiceCommand(args []string) {
 	case "install":
 		handleInstallCommand() // Reuse the install logic but moved here
 	case "start":
-		if err := provision.Systemctl("start", "fazt"); err != nil {
+		if err := provision.Systemctl("start", config.AppName); err != nil {
 			fmt.Printf("Error starting service: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Println("Service started.")
 	case "stop":
-		if err := provision.Systemctl("stop", "fazt"); err != nil {
+		if err := provision.Systemctl("stop", config.AppName); err != nil {
 			fmt.Printf("Error stopping service: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Println("Service stopped.")
 	case "status":
-		if err := provision.Systemctl("status", "fazt"); err != nil {
+		if err := provision.Systemctl("status", config.AppName); err != nil {
 			// Systemctl status returns non-zero if service is not running, which is fine to show
 			// os.Exit(1) 
 		}
 	case "logs":
-		if err := provision.ServiceLogs("fazt"); err != nil {
+		if err := provision.ServiceLogs(config.AppName); err != nil {
 			fmt.Printf("Error reading logs: %v\n", err)
 			os.Exit(1)
 		}
@@ -392,13 +845,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
-		duration := time.Since(start)
-		requestID := r.Header.Get("X-Request-ID")
-		if requestID != "" {
-			log.Printf("[%s] %s %s %d %v", requestID, r.Method, r.URL.Path, wrapped.statusCode, duration)
-		} else {
-			log.Printf("%s %s %d %v", r.Method, r.URL.Path, wrapped.statusCode, duration)
-		}
+		logging.Request(r.Header.Get("X-Request-ID"), r.Method, r.URL.Path, wrapped.statusCode, time.Since(start))
 	})
 }
 
@@ -413,8 +860,9 @@ func corsMiddleware(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		}
 
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
+		// Handle preflight requests; tracking endpoints answer their own with
+		// CORS headers in every environment (middleware.TrackingCORS)
+		if r.Method == "OPTIONS" && !middleware.IsTrackingPath(r.URL.Path) {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
@@ -428,7 +876,11 @@ func recoveryMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("PANIC: %v", err)
+				logging.Errorf("PANIC: %v", err)
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					middleware.JSONError(w, "Internal Server Error", http.StatusInternalServerError)
+					return
+				}
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
@@ -447,79 +899,369 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController (flushing, hijacking)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // printVersion displays version information
 func printVersion() {
 	fmt.Printf("fazt.sh %s\n", Version)
+	if Commit != "" {
+		fmt.Printf("Commit: %s\n", Commit)
+	}
+	if BuildDate != "" {
+		fmt.Printf("Built: %s\n", BuildDate)
+	}
 	fmt.Printf("Go version: %s\n", runtime.Version())
 	fmt.Printf("OS/Arch: %s/%s\n", runtime.GOOS, runtime.GOARCH)
 }
 
-// createRootHandler creates a handler that routes based on the Host header
-// - Requests to the main domain (or localhost) go to the dashboard
-// - Requests to subdomains (*.domain.com or *.localhost) go to the site handler
-func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, sessionStore *auth.SessionStore) http.Handler {
-	// Parse the main domain from config
-	mainDomain := extractDomain(cfg.Server.Domain)
+// maxStartupRetryDelay caps the backoff between database startup attempts
+const maxStartupRetryDelay = time.Minute
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		host := r.Host
+// degraded is set while the server runs without its database
+var degraded atomic.Bool
 
-		// Remove port from host if present
-		if colonIdx := strings.LastIndex(host, ":"); colonIdx != -1 {
-			// Check if this is IPv6 (has brackets)
-			if !strings.Contains(host, "]") || strings.LastIndex(host, "]") < colonIdx {
-				host = host[:colonIdx]
-			}
-		}
+// initDatabaseWithRetry opens the database, retrying up to retries more times
+// with exponential backoff from delay
+func initDatabaseWithRetry(path string, retries int, delay time.Duration) error {
+	err := database.Init(path)
+	for attempt := 1; err != nil && attempt <= retries; attempt++ {
+		logging.Warnf("Database unavailable (attempt %d of %d, retrying in %v): %v", attempt, retries+1, delay, err)
+		time.Sleep(delay)
+		delay = min(delay*2, maxStartupRetryDelay)
+		err = database.Init(path)
+	}
+	return err
+}
 
-		// Check if this is the main domain or localhost (no subdomain)
-		if isDashboardHost(host, mainDomain, cfg.Server.Port) {
-			// Apply auth middleware only to dashboard routes
-			middleware.AuthMiddleware(sessionStore)(dashboardMux).ServeHTTP(w, r)
-			return
+// recoverDatabase retries the database until it opens, then starts the
+// services that need it and leaves degraded mode
+func recoverDatabase(cfg *config.Config) {
+	delay := cfg.Database.StartupRetryDelayDuration()
+	for {
+		time.Sleep(delay)
+		delay = min(delay*2, maxStartupRetryDelay)
+		if err := database.Init(cfg.Database.Path); err != nil {
+			logging.Warnf("Database still unavailable (retrying in %v): %v", delay, err)
+			continue
 		}
 
-		// Extract subdomain and serve the site
-		subdomain := extractSubdomain(host, mainDomain)
-		if subdomain != "" {
-			siteHandler(w, r, subdomain)
+		// The cleanup jobs then run for the life of the process
+		if _, err := startDataServices(cfg); err != nil {
+			logging.Errorf("Database recovered but startup failed, staying degraded: %v", err)
 			return
 		}
-
-		// Fallback to dashboard
-		middleware.AuthMiddleware(sessionStore)(dashboardMux).ServeHTTP(w, r)
-	})
+		degraded.Store(false)
+		handlers.SetReady(true)
+		logging.Infof("Database recovered, leaving degraded mode")
+		return
+	}
 }
 
-// extractDomain extracts the domain from a URL (removes protocol and path)
-func extractDomain(rawURL string) string {
-	// Handle URLs with protocol
-	if strings.Contains(rawURL, "://") {
-		if parsed, err := url.Parse(rawURL); err == nil {
-			return parsed.Hostname()
-		}
-	}
-	// Handle bare domains
-	if colonIdx := strings.Index(rawURL, ":"); colonIdx != -1 {
-		return rawURL[:colonIdx]
+// startDataServices initializes everything that needs the database: audit
+// logging, hosting, the analytics writer, cleanup jobs and mock data.
+// The returned func stops the background jobs.
+func startDataServices(cfg *config.Config) (func(), error) {
+	// Initialize audit logging
+	if err := audit.Init(database.GetDB()); err != nil {
+		return nil, fmt.Errorf("failed to initialize audit logging: %w", err)
 	}
-	return rawURL
-}
 
-// isDashboardHost checks if the host should be routed to the dashboard
-func isDashboardHost(host, mainDomain, port string) bool {
-	// Exact match with main domain
-	if host == mainDomain {
-		return true
+	// Initialize hosting system
+	if err := hosting.Init(database.GetDB()); err != nil {
+		return nil, fmt.Errorf("failed to initialize hosting: %w", err)
 	}
+	hosting.SetCompression(cfg.Hosting.GzipLevel, cfg.Hosting.GzipMinBytes)
+	hosting.SetMaxConcurrentVMs(cfg.Hosting.MaxConcurrentVMs)
+	hosting.SetVMMemoryLimit(cfg.Hosting.MaxVMMemoryBytes)
+	hosting.SetFetchLimits(cfg.Hosting.FetchMaxRequests, cfg.Hosting.FetchMaxBytes, cfg.Hosting.FetchRatePerMinute)
+	hosting.SetStatementCache(database.Stmt)
+	if cfg.Hosting.ReservedSubdomains != nil {
+		hosting.SetReservedSubdomains(*cfg.Hosting.ReservedSubdomains)
+	}
+	if err := hosting.SetDisabledCapabilities(cfg.Hosting.DisabledCapabilities, cfg.Hosting.SiteDisabledCapabilities); err != nil {
+		return nil, fmt.Errorf("invalid hosting capabilities: %w", err)
+	}
+	logging.Infof("Hosting initialized (VFS Mode)")
 
-	// localhost without subdomain
-	if host == "localhost" || host == "127.0.0.1" {
-		return true
+	// Analytics events are written in batches off the request path
+	analytics.SetRequireToken(cfg.Analytics.RequireToken)
+	analytics.SetAnonymizeIP(cfg.Privacy.AnonymizeIP)
+	if err := analytics.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid server.trusted_proxies: %w", err)
+	}
+	if err := analytics.SetCustomEventTypes(cfg.Analytics.EventTypes); err != nil {
+		return nil, fmt.Errorf("invalid analytics event types: %w", err)
 	}
+	analytics.Start()
 
-	return false
-}
+	// Preview deploys with a TTL are deleted once they expire, and env vars,
+	// KV data and logs left by deleted sites and rotated API keys past their
+	// grace period are swept up
+	stopPreviewCleanup := hosting.StartPreviewCleanup(hosting.PreviewCleanupInterval)
+	stopOrphanCleanup := hosting.StartOrphanCleanup(hosting.OrphanCleanupInterval)
+
+	// Generate mock data (by default only in development)
+	if cfg.MockDataEnabled() {
+		logging.Infof("Mock data enabled: Checking for existing data...")
+		// Only generate mock data if database is empty
+		db := database.GetDB()
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count)
+		if err == nil && count == 0 {
+			logging.Infof("Database is empty, generating mock data...")
+			if err := database.GenerateMockData(); err != nil {
+				logging.Warnf("Warning: Failed to generate mock data: %v", err)
+			}
+		} else {
+			logging.Infof("Database already has %d events, skipping mock data generation", count)
+		}
+	} else if cfg.IsDevelopment() {
+		logging.Infof("Mock data disabled by config (server.mock_data, FAZT_MOCK_DATA or --no-mock-data), skipping generation")
+	}
+
+	return func() {
+		stopPreviewCleanup()
+		stopOrphanCleanup()
+	}, nil
+}
+
+// degradedHandler answers in place of next while the database is down:
+// liveness passes, readiness fails and everything else gets a maintenance page
+func degradedHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !degraded.Load() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		switch r.URL.Path {
+		case "/livez":
+			handlers.LivezHandler(w, r)
+		case "/readyz", "/health":
+			handlers.ReadyzHandler(w, r)
+		default:
+			serveMaintenance(w)
+		}
+	})
+}
+
+// serveMaintenance serves the page shown while the server is degraded
+func serveMaintenance(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", "30")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprint(w, `<!DOCTYPE html>
+<html>
+<head>
+    <title>Maintenance</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+               display: flex; justify-content: center; align-items: center;
+               height: 100vh; margin: 0; background: #f5f5f5; }
+        .container { text-align: center; padding: 40px; background: white;
+                     border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+        h1 { color: #333; margin-bottom: 10px; }
+        p { color: #666; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>503 - Down for Maintenance</h1>
+        <p>The server is starting up. Please try again shortly.</p>
+    </div>
+</body>
+</html>`)
+}
+
+// newDashboardMux registers the dashboard, API and tracking routes
+func newDashboardMux() *http.ServeMux {
+	// Create dashboard router (existing dashboard functionality)
+	dashboardMux := http.NewServeMux()
+
+	// Authentication routes
+	dashboardMux.HandleFunc("/login", handlers.LoginPageHandler)
+	dashboardMux.HandleFunc("/api/login", handlers.LoginHandler)
+	dashboardMux.HandleFunc("/api/logout", handlers.LogoutHandler)
+	dashboardMux.HandleFunc("/api/reset-password", handlers.ResetPasswordHandler)
+	dashboardMux.HandleFunc("/api/auth/status", handlers.AuthStatusHandler)
+
+	// API routes - Tracking
+	// Tracking endpoints are called cross-origin by tracked sites
+	dashboardMux.Handle("/track", middleware.TrackingCORS(http.HandlerFunc(handlers.TrackHandler)))
+	dashboardMux.Handle("/track.js", middleware.TrackingCORS(http.HandlerFunc(handlers.TrackScriptHandler)))
+	dashboardMux.Handle("/pixel.gif", middleware.TrackingCORS(http.HandlerFunc(handlers.PixelHandler)))
+	dashboardMux.HandleFunc("/r/", handlers.RedirectHandler)
+	dashboardMux.HandleFunc("/webhook/", handlers.WebhookHandler)
+
+	// API routes - Dashboard
+	dashboardMux.HandleFunc("/api/stats", handlers.StatsHandler)
+	dashboardMux.HandleFunc("/api/events", handlers.EventsHandler)
+	dashboardMux.HandleFunc("/api/redirects", handlers.RedirectsHandler)
+	dashboardMux.HandleFunc("/api/redirects/", handlers.RedirectActionsHandler)
+	dashboardMux.HandleFunc("/api/domains", handlers.DomainsHandler)
+	dashboardMux.HandleFunc("/api/tags", handlers.TagsHandler)
+	dashboardMux.HandleFunc("/api/tags/", handlers.TagActionsHandler)
+	dashboardMux.HandleFunc("/api/tracking-tokens", handlers.TrackingTokensHandler)
+	dashboardMux.HandleFunc("/api/webhooks", handlers.WebhooksHandler)
+	dashboardMux.HandleFunc("/api/config", handlers.ConfigHandler)
+	dashboardMux.HandleFunc("/api/audit", handlers.AuditHandler)
+	dashboardMux.HandleFunc("/api/certs", handlers.CertsHandler)
+
+	// API routes - Hosting/Deploy
+	dashboardMux.HandleFunc("/api/deploy", handlers.DeployHandler)
+	dashboardMux.HandleFunc("/api/sites", handlers.SitesHandler)
+	dashboardMux.HandleFunc("/api/sites/", handlers.SiteActionsHandler)
+	dashboardMux.HandleFunc("/api/hosting/usage", handlers.HostingUsageHandler)
+	dashboardMux.HandleFunc("/api/keys", handlers.APIKeysHandler)
+	dashboardMux.HandleFunc("/api/keys/", handlers.APIKeyActionsHandler)
+	dashboardMux.HandleFunc("/api/deployments", handlers.DeploymentsHandler)
+	dashboardMux.HandleFunc("/api/envvars", handlers.EnvVarsHandler)
+	dashboardMux.HandleFunc("/api/custom-domains", handlers.CustomDomainsHandler)
+	dashboardMux.HandleFunc("/api/site-aliases", handlers.SiteAliasesHandler)
+	dashboardMux.HandleFunc("/api/admin/vfs/rehash", handlers.VFSRehashHandler)
+
+	// Hosting management page
+	dashboardMux.HandleFunc("/hosting", handlers.HostingPageHandler)
+
+	// Static files
+	fs := http.FileServer(http.Dir("./web/static"))
+	dashboardMux.Handle("/static/", http.StripPrefix("/static/", fs))
+
+	// API description and build info
+	dashboardMux.HandleFunc("/api/openapi.json", handlers.OpenAPIHandler)
+	dashboardMux.HandleFunc("/api/version", handlers.VersionHandler)
+
+	// Dashboard (root)
+	dashboardMux.HandleFunc("/", handlers.DashboardHandler)
+
+	// Health checks: liveness, readiness, and /health as a readiness alias
+	dashboardMux.HandleFunc("/livez", handlers.LivezHandler)
+	dashboardMux.HandleFunc("/readyz", handlers.ReadyzHandler)
+	dashboardMux.HandleFunc("/health", handlers.ReadyzHandler)
+
+	return dashboardMux
+}
+
+// newServerHandler builds the full request handler: host-based routing
+// wrapped in the server middleware chain
+func newServerHandler(cfg *config.Config, sessionStore *auth.SessionStore) http.Handler {
+	dashboardMux := newDashboardMux()
+
+	// Create the root handler with host-based routing
+	rootHandler := createRootHandler(cfg, dashboardMux, sessionStore)
+
+	// Apply middleware (order: deadlines -> tracing -> logging -> timeout -> body limit -> cors -> recovery -> root).
+	// Security headers depend on the host, so the root handler adds them.
+	_, _, _, uploadTimeout := cfg.Server.HTTPTimeouts()
+	return middleware.ConnDeadlines(uploadTimeout)(
+		middleware.RequestTracing(
+			loggingMiddleware(
+				middleware.RequestTimeout(cfg.Server.RequestTimeoutDuration())(
+					middleware.BodySizeLimit(middleware.MaxBodySize, handlers.RouteBodyLimits())(
+						corsMiddleware(
+							recoveryMiddleware(degradedHandler(rootHandler)),
+						),
+					),
+				),
+			),
+		),
+	)
+}
+
+// createRootHandler creates a handler that routes based on the Host header
+// - Requests to the main domain (or localhost) go to the dashboard
+// - Requests to subdomains (*.domain.com or *.localhost) go to the site handler
+func createRootHandler(cfg *config.Config, dashboardMux *http.ServeMux, sessionStore *auth.SessionStore) http.Handler {
+	// Parse the main domain from config
+	mainDomain := extractDomain(cfg.Server.Domain)
+
+	// The dashboard and API get the strict security headers; hosted sites get
+	// the lighter set in siteHandler
+	dashboard := middleware.SecurityHeaders(middleware.AuthMiddleware(sessionStore)(dashboardMux))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+
+		// Remove port from host if present
+		if colonIdx := strings.LastIndex(host, ":"); colonIdx != -1 {
+			// Check if this is IPv6 (has brackets)
+			if !strings.Contains(host, "]") || strings.LastIndex(host, "]") < colonIdx {
+				host = host[:colonIdx]
+			}
+		}
+
+		// Check if this is the main domain or localhost (no subdomain)
+		if isDashboardHost(host, mainDomain, cfg.Server.Port) {
+			// Apply auth middleware only to dashboard routes
+			dashboard.ServeHTTP(w, r)
+			return
+		}
+
+		// Custom domains (e.g. www.mybrand.com CNAME'd to this server)
+		if siteID, ok := hosting.ResolveCustomDomain(host); ok {
+			siteHandler(w, r, siteID)
+			return
+		}
+
+		// Extract subdomain and serve the site
+		subdomain := extractSubdomain(host, mainDomain)
+		if subdomain != "" {
+			siteHandler(w, r, subdomain)
+			return
+		}
+
+		// Fallback to dashboard
+		dashboard.ServeHTTP(w, r)
+	})
+}
+
+// extractDomain extracts the domain from a URL (removes protocol and path)
+func extractDomain(rawURL string) string {
+	// Handle URLs with protocol
+	if strings.Contains(rawURL, "://") {
+		if parsed, err := url.Parse(rawURL); err == nil {
+			return parsed.Hostname()
+		}
+	}
+	// Handle bare domains
+	if colonIdx := strings.Index(rawURL, ":"); colonIdx != -1 {
+		return rawURL[:colonIdx]
+	}
+	return rawURL
+}
+
+// httpsRedirectHandler permanently redirects a plain HTTP request to the same host
+// and path over HTTPS
+func httpsRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6 literal
+	}
+
+	w.Header().Set("Connection", "close")
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+}
+
+// isDashboardHost checks if the host should be routed to the dashboard
+func isDashboardHost(host, mainDomain, port string) bool {
+	// Exact match with main domain
+	if host == mainDomain {
+		return true
+	}
+
+	// localhost without subdomain
+	if host == "localhost" || host == "127.0.0.1" {
+		return true
+	}
+
+	return false
+}
 
 // extractSubdomain extracts the subdomain from a host
 // e.g., "blog.example.com" with mainDomain "example.com" returns "blog"
@@ -556,60 +1298,51 @@ func extractSubdomain(host, mainDomain string) string {
 // If main.js exists, executes serverless JavaScript instead
 // WebSocket connections at /ws are handled by the WebSocket hub
 func siteHandler(w http.ResponseWriter, r *http.Request, subdomain string) {
+	middleware.SetSiteSecurityHeaders(w)
+
+	// Aliases serve the files of the site they point at
+	siteID := hosting.ResolveSite(subdomain)
+
 	// Check if site exists
-	if !hosting.SiteExists(subdomain) {
+	if !hosting.SiteExists(siteID) {
 		serveSiteNotFound(w, subdomain)
 		return
 	}
 
+	// Disabled sites keep their files but don't serve traffic
+	if !hosting.IsSiteEnabled(siteID) {
+		serveSiteDisabled(w, subdomain)
+		return
+	}
+
 	// Handle WebSocket connections at /ws
 	if r.URL.Path == "/ws" {
-		hosting.HandleWebSocket(w, r, subdomain)
+		hosting.HandleWebSocket(w, r, siteID)
 		return
 	}
 
-	// Log analytics event for site visits
+	// Log analytics event for site visits, under the name the visitor used
 	logSiteVisit(r, subdomain)
 
-	// Check for serverless (main.js)
-	// We check directly in VFS now
-	fs := hosting.GetFileSystem()
-	hasServerless, _ := fs.Exists(subdomain, "main.js")
-
-	if hasServerless {
-		db := database.GetDB()
-		if hosting.RunServerless(w, r, subdomain, db, subdomain) {
-			return // Serverless handled the request
-		}
+	// Sites with main.js are served by it; the rest are static files from the VFS
+	if hosting.RunServerless(w, r, siteID, database.GetDB()) {
+		return
 	}
-
-	// Serve from VFS
-	hosting.ServeVFS(w, r, subdomain)
+	hosting.ServeVFS(w, r, siteID)
 }
 
-// logSiteVisit logs an analytics event for a site visit
+// logSiteVisit queues an analytics event for a site visit
 func logSiteVisit(r *http.Request, subdomain string) {
-	db := database.GetDB()
-	if db == nil {
-		return
-	}
-
-	// Insert event into database
-	_, err := db.Exec(`
-		INSERT INTO events (domain, source_type, event_type, path, referrer, user_agent, ip_address, query_params)
-		VALUES (?, 'hosting', 'pageview', ?, ?, ?, ?, ?)
-	`,
-		subdomain,
-		r.URL.Path,
-		r.Referer(),
-		r.UserAgent(),
-		r.RemoteAddr,
-		r.URL.RawQuery,
-	)
-
-	if err != nil {
-		log.Printf("Failed to log site visit: %v", err)
-	}
+	analytics.Record(analytics.Event{
+		Domain:      subdomain,
+		SourceType:  "hosting",
+		EventType:   "pageview",
+		Path:        r.URL.Path,
+		Referrer:    r.Referer(),
+		UserAgent:   r.UserAgent(),
+		IPAddress:   analytics.ClientIP(r),
+		QueryParams: r.URL.RawQuery,
+	})
 }
 
 // serveSiteNotFound renders the 404 page for non-existent sites
@@ -640,6 +1373,34 @@ func serveSiteNotFound(w http.ResponseWriter, subdomain string) {
 </html>`, subdomain)
 }
 
+// serveSiteDisabled renders the 503 page for sites that have been switched off
+func serveSiteDisabled(w http.ResponseWriter, subdomain string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+    <title>Site Disabled</title>
+    <style>
+        body { font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+               display: flex; justify-content: center; align-items: center;
+               height: 100vh; margin: 0; background: #f5f5f5; }
+        .container { text-align: center; padding: 40px; background: white;
+                     border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1); }
+        h1 { color: #333; margin-bottom: 10px; }
+        p { color: #666; }
+        .subdomain { font-family: monospace; background: #f0f0f0; padding: 2px 8px; border-radius: 4px; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h1>503 - Site Disabled</h1>
+        <p>The site <span class="subdomain">%s</span> is temporarily offline.</p>
+    </div>
+</body>
+</html>`, subdomain)
+}
+
 // createDeployZip creates a ZIP archive of the directory
 func createDeployZip(dir string) (*bytes.Buffer, int, error) {
 	buf := new(bytes.Buffer)
@@ -730,6 +1491,7 @@ func handleSetCredentials() {
 	username := flags.String("username", "", "Username for authentication")
 	password := flags.String("password", "", "Password for authentication")
 	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
 
 	flags.Usage = func() {
 		fmt.Println("Usage: fazt server set-credentials [flags]")
@@ -752,8 +1514,7 @@ func handleSetCredentials() {
 
 	// Get config path
 	if *configPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		*configPath = filepath.Join(homeDir, ".config", "fazt", "config.json")
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
 	}
 
 	// Call command function
@@ -781,6 +1542,7 @@ func handleInitCommand() {
 	port := flags.String("port", "4698", "Server port")
 	env := flags.String("env", "development", "Environment (development|production)")
 	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
 
 	flags.Usage = func() {
 		fmt.Println("Usage: fazt server init [flags]")
@@ -801,45 +1563,373 @@ func handleInitCommand() {
 
 	// Get config path
 	if *configPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		*configPath = filepath.Join(homeDir, ".config", "fazt", "config.json")
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	// Call command function
+	if *dataDir != "" {
+		*dataDir = config.ResolvePaths(*dataDir).DataDir
+	}
+	if err := initCommand(*username, *password, *domain, *port, *env, *configPath, *dataDir); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✓ Server initialized successfully")
+	fmt.Printf("  Config saved to: %s\n", *configPath)
+	fmt.Println()
+	fmt.Println("To start the server:")
+	fmt.Println("  fazt server start")
+	fmt.Println()
+}
+
+// handleSetConfigCommand handles the set-config subcommand
+func handleSetConfigCommand() {
+	flags := flag.NewFlagSet("set-config", flag.ExitOnError)
+	domain := flags.String("domain", "", "Server domain")
+	port := flags.String("port", "", "Server port")
+	env := flags.String("env", "", "Environment (development|production)")
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server set-config [flags]")
+		fmt.Println()
+		fmt.Println("Update server configuration settings")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server set-config --domain https://newdomain.com")
+		fmt.Println("  fazt server set-config --port 8080")
+		fmt.Println("  fazt server set-config --env production")
+		fmt.Println("  fazt server set-config --domain https://prod.com --port 443 --env production")
+		fmt.Println("  fazt server set-config --domain https://prod.com --config /path/to/config.json")
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	// Call command function
+	if err := setConfigCommand(*domain, *port, *env, *configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✓ Configuration updated successfully")
+	if *domain != "" {
+		fmt.Printf("  Domain: %s\n", *domain)
+	}
+	if *port != "" {
+		fmt.Printf("  Port: %s\n", *port)
+	}
+	if *env != "" {
+		fmt.Printf("  Environment: %s\n", *env)
+	}
+	fmt.Println()
+}
+
+// handleStatusCommand handles the status subcommand
+func handleStatusCommand() {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server status [flags]")
+		fmt.Println()
+		fmt.Println("Display server configuration and status")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Shows:")
+		fmt.Println("  Configuration file location")
+		fmt.Println("  Server settings (domain, port, environment)")
+		fmt.Println("  Authentication status")
+		fmt.Println("  Database information")
+		fmt.Println("  Site deployment directory")
+		fmt.Println("  Server running status")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server status")
+		fmt.Println("  fazt server status --config /path/to/config.json")
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	// Call command function
+	output, err := statusCommand(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+}
+
+// handleStatsCommand handles the stats subcommand
+func handleStatsCommand() {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server stats [flags]")
+		fmt.Println()
+		fmt.Println("Show quick metrics read directly from the database")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Shows:")
+		fmt.Println("  Database size")
+		fmt.Println("  Total events and events today")
+		fmt.Println("  Number of sites")
+		fmt.Println("  Domain with the most events")
+		fmt.Println()
+		fmt.Println("The database is opened read-only, so this works while the server runs.")
+		fmt.Println("Sessions live in the server's memory and are not shown.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server stats")
+		fmt.Println("  fazt server stats --config /path/to/config.json")
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	output, err := statsCommand(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+}
+
+// handleDomainsCommand handles the domains subcommand
+func handleDomainsCommand() {
+	flags := flag.NewFlagSet("domains", flag.ExitOnError)
+	hostname := flags.String("hostname", "", "Custom hostname (e.g. www.mybrand.com)")
+	site := flags.String("site", "", "Site (subdomain) to serve on the hostname")
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server domains [list|add|remove] [flags]")
+		fmt.Println()
+		fmt.Println("Manage custom domains mapped to hosted sites.")
+		fmt.Println("Point the domain's DNS (A record or CNAME) at this server first.")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server domains list")
+		fmt.Println("  fazt server domains add --hostname www.mybrand.com --site blog")
+		fmt.Println("  fazt server domains remove --hostname www.mybrand.com")
+	}
+
+	// Action is optional and defaults to list
+	args := os.Args[3:]
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action = args[0]
+		args = args[1:]
+	}
+
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
 	}
 
 	// Call command function
-	if err := initCommand(*username, *password, *domain, *port, *env, *configPath); err != nil {
+	output, err := domainsCommand(action, *hostname, *site, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+}
+
+// handleAliasesCommand handles the aliases subcommand
+func handleAliasesCommand() {
+	flags := flag.NewFlagSet("aliases", flag.ExitOnError)
+	alias := flags.String("alias", "", "Subdomain that serves another site (e.g. staging)")
+	site := flags.String("site", "", "Site whose files the alias serves")
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server aliases [list|add|remove] [flags]")
+		fmt.Println()
+		fmt.Println("Manage site aliases: subdomains that serve another site's files without a copy.")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server aliases list")
+		fmt.Println("  fazt server aliases add --alias staging --site blog")
+		fmt.Println("  fazt server aliases remove --alias staging")
+	}
+
+	// Action is optional and defaults to list
+	args := os.Args[3:]
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action = args[0]
+		args = args[1:]
+	}
+
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	output, err := aliasesCommand(action, *alias, *site, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+}
+
+// handleSitesCommand handles the sites subcommand
+func handleSitesCommand() {
+	flags := flag.NewFlagSet("sites", flag.ExitOnError)
+	site := flags.String("site", "", "Site (subdomain) to enable or disable")
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server sites [list|enable|disable] [flags]")
+		fmt.Println()
+		fmt.Println("List hosted sites or take a site offline without deleting its files.")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server sites list")
+		fmt.Println("  fazt server sites disable --site blog")
+		fmt.Println("  fazt server sites enable --site blog")
+	}
+
+	// Action is optional and defaults to list
+	args := os.Args[3:]
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action = args[0]
+		args = args[1:]
+	}
+
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	// Call command function
+	output, err := sitesCommand(action, *site, *configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+}
+
+// handleExportCommand handles the export subcommand
+func handleExportCommand() {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	out := flags.String("out", "", "File to write the JSON export to")
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server export --out <file> [flags]")
+		fmt.Println()
+		fmt.Println("Write events, redirects, webhooks, sites and env var names as portable JSON,")
+		fmt.Println("for moving to another machine or database with 'fazt server import'.")
+		fmt.Println()
+		flags.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Sites are exported as metadata and a file manifest, without file contents.")
+		fmt.Println("Env var values are left out. Webhook secrets are included, so the file is")
+		fmt.Println("only readable by you.")
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  fazt server export --out dump.json")
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	output, err := exportCommand(*out, *configPath)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("✓ Server initialized successfully")
-	fmt.Printf("  Config saved to: %s\n", *configPath)
-	fmt.Println()
-	fmt.Println("To start the server:")
-	fmt.Println("  fazt server start")
-	fmt.Println()
+	fmt.Print(output)
 }
 
-// handleSetConfigCommand handles the set-config subcommand
-func handleSetConfigCommand() {
-	flags := flag.NewFlagSet("set-config", flag.ExitOnError)
-	domain := flags.String("domain", "", "Server domain")
-	port := flags.String("port", "", "Server port")
-	env := flags.String("env", "", "Environment (development|production)")
+// handleImportCommand handles the import subcommand
+func handleImportCommand() {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	in := flags.String("in", "", "JSON export to read")
 	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt server set-config [flags]")
+		fmt.Println("Usage: fazt server import --in <file> [flags]")
 		fmt.Println()
-		fmt.Println("Update server configuration settings")
+		fmt.Println("Restore a 'fazt server export' file into a fresh database.")
+		fmt.Println("Stop the server first; the import fails if the database already has data.")
 		fmt.Println()
 		flags.PrintDefaults()
 		fmt.Println()
+		fmt.Println("Sites are restored without their files, so redeploy them afterwards,")
+		fmt.Println("and set their env vars again.")
+		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  fazt server set-config --domain https://newdomain.com")
-		fmt.Println("  fazt server set-config --port 8080")
-		fmt.Println("  fazt server set-config --env production")
-		fmt.Println("  fazt server set-config --domain https://prod.com --port 443 --env production")
-		fmt.Println("  fazt server set-config --domain https://prod.com --config /path/to/config.json")
+		fmt.Println("  fazt server init --username admin --password secret --domain https://fazt.example.com")
+		fmt.Println("  fazt server import --in dump.json")
 	}
 
 	if err := flags.Parse(os.Args[3:]); err != nil {
@@ -848,73 +1938,82 @@ func handleSetConfigCommand() {
 
 	// Get config path
 	if *configPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		*configPath = filepath.Join(homeDir, ".config", "fazt", "config.json")
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
 	}
 
-	// Call command function
-	if err := setConfigCommand(*domain, *port, *env, *configPath); err != nil {
+	output, err := importCommand(*in, *configPath)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Println("✓ Configuration updated successfully")
-	if *domain != "" {
-		fmt.Printf("  Domain: %s\n", *domain)
-	}
-	if *port != "" {
-		fmt.Printf("  Port: %s\n", *port)
-	}
-	if *env != "" {
-		fmt.Printf("  Environment: %s\n", *env)
-	}
-	fmt.Println()
+	fmt.Print(output)
 }
 
-// handleStatusCommand handles the status subcommand
-func handleStatusCommand() {
-	flags := flag.NewFlagSet("status", flag.ExitOnError)
+// handleLogsCommand handles the logs subcommand
+func handleLogsCommand() {
+	flags := flag.NewFlagSet("logs", flag.ExitOnError)
+	follow := flags.Bool("follow", false, "Keep printing new lines as they are written")
+	lines := flags.Int("lines", 50, "Number of recent lines to show")
+	level := flags.String("level", "info", "Minimum level to show (debug, info, warn, error)")
 	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt server status [flags]")
+		fmt.Println("Usage: fazt server logs [flags]")
 		fmt.Println()
-		fmt.Println("Display server configuration and status")
+		fmt.Println("Show the server log file (server.log_file in the config).")
 		fmt.Println()
 		flags.PrintDefaults()
 		fmt.Println()
-		fmt.Println("Shows:")
-		fmt.Println("  Configuration file location")
-		fmt.Println("  Server settings (domain, port, environment)")
-		fmt.Println("  Authentication status")
-		fmt.Println("  Database information")
-		fmt.Println("  Site deployment directory")
-		fmt.Println("  Server running status")
-		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  fazt server status")
-		fmt.Println("  fazt server status --config /path/to/config.json")
+		fmt.Println("  fazt server logs")
+		fmt.Println("  fazt server logs --follow --level warn")
+		fmt.Println("  fazt server logs --lines 200")
 	}
 
 	if err := flags.Parse(os.Args[3:]); err != nil {
 		os.Exit(1)
 	}
 
-	// Get config path and directory
+	minLevel, err := logging.ParseLevel(*level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *lines < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --lines must not be negative")
+		os.Exit(1)
+	}
+
+	// Get config path
 	if *configPath == "" {
-		homeDir, _ := os.UserHomeDir()
-		*configPath = filepath.Join(homeDir, ".config", "fazt", "config.json")
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
 	}
-	configDir := filepath.Dir(*configPath)
 
-	// Call command function
-	output, err := statusCommand(*configPath, configDir)
+	path, err := serverLogFile(*configPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
-	fmt.Print(output)
+	recent, offset, err := logging.Tail(path, *lines, minLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Failed to read %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	for _, line := range recent {
+		fmt.Println(line)
+	}
+
+	if *follow {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := logging.Follow(ctx, path, offset, minLevel, func(line string) { fmt.Println(line) }); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to follow %s: %v\n", path, err)
+			os.Exit(1)
+		}
+	}
 }
 
 // handleSetAuthToken handles the set-auth-token subcommand
@@ -930,7 +2029,7 @@ func handleSetAuthToken() {
 		fmt.Println("then configure it with this command.")
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  cc-server set-auth-token --token abc123def456789")
+		fmt.Println("  fazt set-auth-token --token abc123def456789")
 		fmt.Println()
 		flags.PrintDefaults()
 	}
@@ -976,7 +2075,7 @@ func handleSetAuthToken() {
 	fmt.Println("✓ Authentication token configured successfully!")
 	fmt.Println()
 	fmt.Printf("Token:  %s...%s (truncated)\n", (*token)[:4], (*token)[len(*token)-4:])
-	fmt.Println("Config: ~/.config/fazt/config.json")
+	fmt.Printf("Config: %s\n", configPath)
 	fmt.Println()
 	fmt.Println("You can now deploy sites:")
 	fmt.Println("  fazt client deploy --path . --domain my-site")
@@ -987,18 +2086,23 @@ func handleDeployCommand() {
 	path := flags.String("path", "", "Directory to deploy (required)")
 	domain := flags.String("domain", "", "Domain/subdomain for the site (required)")
 	server := flags.String("server", "http://localhost:4698", "fazt.sh server URL")
+	retries := flags.Int("retries", 3, "Times to retry the upload after a network error or server error")
+	preview := flags.Bool("preview", false, "Deploy to a generated preview subdomain instead of --domain")
+	ttl := flags.Duration("ttl", 0, "With --preview, delete the preview after this long (e.g. 72h); 0 keeps it")
 
 	flags.Usage = func() {
-		fmt.Println("Usage: fazt client deploy --path <PATH> --domain <SUBDOMAIN>")
+		fmt.Println("Usage: fazt client deploy --path <PATH> (--domain <SUBDOMAIN> | --preview [--ttl <DURATION>])")
 		fmt.Println()
 		fmt.Println("Deploys a directory to a fazt.sh server.")
 		fmt.Println()
 		flags.PrintDefaults()
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  cc-server deploy --path . --domain my-site")
-		fmt.Println("  cc-server deploy --path ~/Desktop/site --domain example --server https://cc.example.com")
-		fmt.Println("  cc-server deploy --domain my-site --path .")
+		fmt.Println("  fazt deploy --path . --domain my-site")
+		fmt.Println("  fazt deploy --path ~/Desktop/site --domain example --server https://cc.example.com")
+		fmt.Println("  fazt deploy --domain my-site --path .")
+		fmt.Println("  fazt deploy --path dist --domain my-site --retries 5")
+		fmt.Println("  fazt deploy --path dist --preview --ttl 72h")
 	}
 
 	// Determine args offset based on whether this is "deploy" or "client deploy"
@@ -1018,11 +2122,24 @@ func handleDeployCommand() {
 		os.Exit(1)
 	}
 
-	if *domain == "" {
+	if *preview && *domain != "" {
+		fmt.Println("Error: --domain and --preview can't be combined")
+		os.Exit(1)
+	}
+	if !*preview && *domain == "" {
 		fmt.Println("Error: --domain is required")
 		flags.Usage()
 		os.Exit(1)
 	}
+	if *ttl != 0 && (!*preview || *ttl < 0) {
+		fmt.Println("Error: --ttl must be positive and needs --preview")
+		os.Exit(1)
+	}
+
+	if *retries < 0 {
+		fmt.Println("Error: --retries must not be negative")
+		os.Exit(1)
+	}
 
 	// Validate the path exists
 	if _, err := os.Stat(deployPath); os.IsNotExist(err) {
@@ -1042,11 +2159,15 @@ func handleDeployCommand() {
 	token := cfg.GetAPIKey()
 	if token == "" {
 		fmt.Println("Error: No API key found in config")
-		fmt.Println("Please ensure you have an API key configured in ~/.config/fazt/config.json")
+		fmt.Printf("Please ensure you have an API key configured in %s\n", config.Path())
 		os.Exit(1)
 	}
 
-	fmt.Printf("Deploying %s to %s as '%s'...\n", deployPath, *server, *domain)
+	if *preview {
+		fmt.Printf("Deploying %s to %s as a preview...\n", deployPath, *server)
+	} else {
+		fmt.Printf("Deploying %s to %s as '%s'...\n", deployPath, *server, *domain)
+	}
 
 	// Change to the deploy directory
 	originalDir, _ := os.Getwd()
@@ -1069,10 +2190,19 @@ func handleDeployCommand() {
 	var body bytes.Buffer
 	writer := multipart.NewWriter(&body)
 
-	// Add domain field
-	if err := writer.WriteField("site_name", *domain); err != nil {
-		fmt.Printf("Error creating form: %v\n", err)
-		os.Exit(1)
+	// Add domain field, or ask for a generated preview subdomain
+	fields := map[string]string{"site_name": *domain}
+	if *preview {
+		fields = map[string]string{"preview": "true"}
+		if *ttl > 0 {
+			fields["ttl"] = ttl.String()
+		}
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			fmt.Printf("Error creating form: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// Add file field
@@ -1087,30 +2217,21 @@ func handleDeployCommand() {
 	}
 	writer.Close()
 
-	// Make request
-	req, err := http.NewRequest("POST", *server+"/api/deploy", &body)
-	if err != nil {
-		fmt.Printf("Error creating request: %v\n", err)
-		os.Exit(1)
+	// Send it, retrying transient failures with the same Idempotency-Key so a
+	// deploy that did land isn't run twice
+	upload := deployUpload{
+		URL:            *server + "/api/deploy",
+		ContentType:    writer.FormDataContentType(),
+		Token:          token,
+		IdempotencyKey: newIdempotencyKey(),
+		Body:           body.Bytes(),
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	// Send request
 	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, respBody, err := upload.send(client, *retries, deployRetryBackoff)
 	if err != nil {
 		fmt.Printf("Error deploying: %v\n", err)
 		os.Exit(1)
 	}
-	defer resp.Body.Close()
-
-	// Read response
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Printf("Error reading response: %v\n", err)
-		os.Exit(1)
-	}
 
 	// Check response
 	if resp.StatusCode != http.StatusOK {
@@ -1125,8 +2246,10 @@ func handleDeployCommand() {
 	if err := json.Unmarshal(respBody, &result); err == nil {
 		if success, ok := result["success"].(bool); ok && success {
 			fmt.Printf("✓ Deployment successful!\n")
-			if site, ok := result["site"].(string); ok {
-				// Extract server URL for display
+			if siteURL, ok := result["url"].(string); ok {
+				fmt.Printf("  Site: %s\n", siteURL)
+			} else if site, ok := result["site"].(string); ok {
+				// Older servers don't send the URL, so guess it from the server's
 				serverURL := *server
 				serverURL = strings.TrimPrefix(serverURL, "http://")
 				serverURL = strings.TrimPrefix(serverURL, "https://")
@@ -1138,6 +2261,9 @@ func handleDeployCommand() {
 			if sizeBytes, ok := result["size_bytes"].(float64); ok {
 				fmt.Printf("  Size: %.0f bytes\n", sizeBytes)
 			}
+			if expiresAt, ok := result["expires_at"].(string); ok {
+				fmt.Printf("  Expires: %s\n", expiresAt)
+			}
 			return
 		}
 	}
@@ -1145,13 +2271,85 @@ func handleDeployCommand() {
 	fmt.Printf("✓ Deployment completed! (Status: %s)\n", resp.Status)
 }
 
+// deployRetryBackoff is the wait before the first deploy retry; it doubles each time
+const deployRetryBackoff = time.Second
+
+// deployUpload is a prepared /api/deploy request that can be sent more than once
+type deployUpload struct {
+	URL            string
+	ContentType    string
+	Token          string
+	IdempotencyKey string
+	Body           []byte
+}
+
+// send posts the upload, retrying up to retries times with exponential backoff
+// after network errors, 5xx responses and 409 (the same deploy still running).
+// Other 4xx responses are final. It returns the last response and its body.
+func (u deployUpload) send(client *http.Client, retries int, backoff time.Duration) (*http.Response, []byte, error) {
+	for attempt := 1; ; attempt++ {
+		resp, body, err := u.post(client)
+
+		retryable := err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusConflict
+		if !retryable || attempt > retries {
+			return resp, body, err
+		}
+
+		reason := fmt.Sprintf("%v", err)
+		if err == nil {
+			reason = resp.Status
+		}
+		fmt.Printf("Attempt %d of %d failed (%s), retrying in %s...\n", attempt, retries+1, reason, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one attempt
+func (u deployUpload) post(client *http.Client) (*http.Response, []byte, error) {
+	req, err := http.NewRequest("POST", u.URL, bytes.NewReader(u.Body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", u.ContentType)
+	req.Header.Set("Authorization", "Bearer "+u.Token)
+	// One key per invocation: a resent upload is answered with the first result
+	req.Header.Set("Idempotency-Key", u.IdempotencyKey)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return resp, body, nil
+}
+
+// newIdempotencyKey returns a random key identifying one deploy invocation
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("deploy-%d", time.Now().UnixNano())
+	}
+	return "deploy-" + hex.EncodeToString(b)
+}
+
 // handleStartCommand handles the start subcommand
 func handleStartCommand() {
 	flags := flag.NewFlagSet("start", flag.ExitOnError)
 	port := flags.String("port", "", "Server port (overrides config)")
 	db := flags.String("db", "", "Database file path (overrides config)")
 	configFile := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
 	domain := flags.String("domain", "", "Server domain (overrides config)")
+	flags.BoolVar(verbose, "verbose", false, "Enable verbose (debug) logging")
+	flags.BoolVar(quiet, "quiet", false, "Quiet mode (errors only)")
+	noMockData := flags.Bool("no-mock-data", false, "Never generate mock data, even in development")
+	devTLS := flags.Bool("dev-tls", false, "Serve HTTPS with a throwaway self-signed certificate (local development)")
 
 	flags.Usage = func() {
 		fmt.Println("Usage: fazt server start [options]")
@@ -1166,23 +2364,25 @@ func handleStartCommand() {
 		flags.PrintDefaults()
 		fmt.Println()
 		fmt.Println("Examples:")
-		fmt.Println("  cc-server server start")
-		fmt.Println("  cc-server server start --port 8080")
-		fmt.Println("  cc-server server start --domain mysite.com")
-		fmt.Println("  cc-server server start --config /path/to/config.json")
+		fmt.Println("  fazt server start")
+		fmt.Println("  fazt server start --port 8080")
+		fmt.Println("  fazt server start --domain mysite.com")
+		fmt.Println("  fazt server start --config /path/to/config.json")
+		fmt.Println("  fazt server start --quiet")
+		fmt.Println("  fazt server start --dev-tls")
 		fmt.Println()
 		fmt.Println("Environment Variables:")
-		fmt.Println("  FAZT_DOMAIN=fazt.sh cc-server server start")
+		fmt.Println("  FAZT_DOMAIN=fazt.sh fazt server start")
 	}
 
 	if err := flags.Parse(os.Args[3:]); err != nil {
 		os.Exit(1)
 	}
 
+	logging.SetLevel(logging.LevelFromFlags(*verbose, *quiet))
+
 	// Set up configuration
-	if !*quiet {
-		log.Println("Starting fazt.sh...")
-	}
+	logging.Infof("Starting fazt.sh...")
 
 	// Use default flags structure but override with our specific flags
 	cliFlags := config.ParseFlags()
@@ -1192,6 +2392,13 @@ func handleStartCommand() {
 	if *db != "" {
 		cliFlags.DBPath = *db
 	}
+	if *dataDir != "" {
+		paths := config.ResolvePaths(*dataDir)
+		cliFlags.ConfigPath = paths.ConfigFile()
+		if *db == "" {
+			cliFlags.DBPath = paths.DatabaseFile()
+		}
+	}
 	if *configFile != "" {
 		cliFlags.ConfigPath = *configFile
 	}
@@ -1205,14 +2412,45 @@ func handleStartCommand() {
 	if *domain != "" {
 		cfg.Server.Domain = *domain
 	}
+	if *noMockData {
+		disabled := false
+		cfg.Server.MockData = &disabled
+	}
+	if *devTLS {
+		if cfg.HTTPS.Enabled {
+			log.Fatalf("--dev-tls cannot be combined with https.enabled")
+		}
+		// Exercise the production cookie path unless the config says otherwise
+		if cfg.Auth.Cookie.Secure == nil {
+			secure := true
+			cfg.Auth.Cookie.Secure = &secure
+		}
+	}
+
+	// Switch to JSON logs before anything else is logged
+	if err := logging.Setup(cfg.Server.LogFormat); err != nil {
+		log.Fatalf("Invalid log config: %v", err)
+	}
+	if cfg.Server.LogFile != "" {
+		logFile, err := logging.OpenFile(config.ExpandPath(cfg.Server.LogFile))
+		if err != nil {
+			log.Fatalf("Invalid log config: %v", err)
+		}
+		defer logFile.Close()
+	}
 
 	// Ensure secure file permissions
 	security.EnsureSecurePermissions(config.ExpandPath(cliFlags.ConfigPath), cfg.Database.Path)
 
+	// Configure hashing for new passwords and API keys
+	if err := applyHashConfig(cfg); err != nil {
+		log.Fatalf("Invalid hash settings: %v", err)
+	}
+
 	// Display startup information
 	fmt.Println()
 	fmt.Println("═══════════════════════════════════════════════════════════")
-	fmt.Println("             fazt.sh v0.4.0 - Starting Up")
+	fmt.Printf("             fazt.sh %s - Starting Up\n", Version)
 	fmt.Println("═══════════════════════════════════════════════════════════")
 	fmt.Println()
 	fmt.Printf("  Environment:  %s\n", cfg.Server.Env)
@@ -1223,190 +2461,185 @@ func handleStartCommand() {
 	fmt.Println()
 
 	// Initialize session store
-	sessionStore := auth.NewSessionStore(auth.SessionTTL)
+	sessionTTL, sessionMaxLifetime := cfg.Auth.SessionDurations()
+	if sessionTTL == 0 {
+		sessionTTL = auth.SessionTTL
+	}
+	sessionStore := auth.NewSessionStore(sessionTTL)
 	defer sessionStore.Stop()
+	if sessionMaxLifetime > 0 {
+		sessionStore.SetMaxLifetime(sessionMaxLifetime)
+	}
 
-	// Initialize rate limiter
+	if err := applyCookieConfig(cfg); err != nil {
+		log.Fatalf("Invalid auth config: %v", err)
+	}
+
+	fingerprintMode, err := auth.ParseFingerprintMode(cfg.Auth.SessionFingerprint)
+	if err != nil {
+		log.Fatalf("Invalid auth config: %v", err)
+	}
+	sessionStore.SetFingerprintMode(fingerprintMode)
+
+	// Initialize rate limiters (per IP, then per username)
 	rateLimiter := auth.NewRateLimiter()
+	accountLimiter := auth.NewAccountRateLimiter()
 
-	// Initialize auth handlers with session store and rate limiter
-	handlers.InitAuth(sessionStore, rateLimiter)
+	// Initialize auth handlers with session store and rate limiters
+	handlers.InitAuth(sessionStore, rateLimiter, accountLimiter)
+	handlers.SetWebhookReplayTolerance(cfg.Webhooks.ReplayToleranceDuration())
+	handlers.SetCertExpiryWindow(cfg.HTTPS.ExpiryAlertWindow())
+	handlers.SetMaxDeployBytes(cfg.Hosting.MaxDeployBytes)
+	handlers.SetSiteBaseURL(cfg.SiteBaseURL())
+	handlers.SetBuildInfo(Version, Commit, BuildDate)
+	handlers.ApplyRateLimits(cfg.RateLimit)
 
 	// Display auth status (v0.4.0: auth always required)
 	fmt.Printf("  Authentication: ✓ Enabled (user: %s)\n", cfg.Auth.Username)
 	fmt.Println("═══════════════════════════════════════════════════════════")
 	fmt.Println()
 
-	// Initialize database
-	if err := database.Init(cfg.Database.Path); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Initialize database, retrying with backoff if configured. With
+	// database.degraded_start, a database that stays down doesn't stop the server:
+	// it serves a maintenance page and keeps retrying in the background.
+	dbErr := initDatabaseWithRetry(cfg.Database.Path, cfg.Database.StartupRetries, cfg.Database.StartupRetryDelayDuration())
+	if dbErr != nil && !cfg.Database.DegradedStart {
+		log.Fatalf("Failed to initialize database: %v", dbErr)
 	}
 	defer database.Close()
 
-	// Initialize audit logging
-	if err := audit.Init(database.GetDB()); err != nil {
-		log.Fatalf("Failed to initialize audit logging: %v", err)
-	}
-
-	// Initialize hosting system
-	if err := hosting.Init(database.GetDB()); err != nil {
-		log.Fatalf("Failed to initialize hosting: %v", err)
-	}
-	log.Printf("Hosting initialized (VFS Mode)")
-
-	// Generate mock data in development mode
-	if cfg.IsDevelopment() {
-		log.Println("Development mode: Checking for existing data...")
-		// Only generate mock data if database is empty
-		db := database.GetDB()
-		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM events").Scan(&count)
-		if err == nil && count == 0 {
-			log.Println("Database is empty, generating mock data...")
-			if err := database.GenerateMockData(); err != nil {
-				log.Printf("Warning: Failed to generate mock data: %v", err)
-			}
-		} else {
-			log.Printf("Database already has %d events, skipping mock data generation", count)
+	if dbErr == nil {
+		stopDataServices, err := startDataServices(cfg)
+		if err != nil {
+			log.Fatalf("Startup failed: %v", err)
 		}
+		defer stopDataServices()
+	} else {
+		logging.Errorf("Database unavailable, starting in degraded mode: %v", dbErr)
+		degraded.Store(true)
+		go recoverDatabase(cfg)
 	}
 
-	// Create dashboard router (existing dashboard functionality)
-	dashboardMux := http.NewServeMux()
-
-	// Authentication routes
-	dashboardMux.HandleFunc("/login", handlers.LoginPageHandler)
-	dashboardMux.HandleFunc("/api/login", handlers.LoginHandler)
-	dashboardMux.HandleFunc("/api/logout", handlers.LogoutHandler)
-	dashboardMux.HandleFunc("/api/auth/status", handlers.AuthStatusHandler)
-
-	// API routes - Tracking
-	dashboardMux.HandleFunc("/track", handlers.TrackHandler)
-	dashboardMux.HandleFunc("/pixel.gif", handlers.PixelHandler)
-	dashboardMux.HandleFunc("/r/", handlers.RedirectHandler)
-	dashboardMux.HandleFunc("/webhook/", handlers.WebhookHandler)
-
-	// API routes - Dashboard
-	dashboardMux.HandleFunc("/api/stats", handlers.StatsHandler)
-	dashboardMux.HandleFunc("/api/events", handlers.EventsHandler)
-	dashboardMux.HandleFunc("/api/redirects", handlers.RedirectsHandler)
-	dashboardMux.HandleFunc("/api/domains", handlers.DomainsHandler)
-	dashboardMux.HandleFunc("/api/tags", handlers.TagsHandler)
-	dashboardMux.HandleFunc("/api/webhooks", handlers.WebhooksHandler)
-	dashboardMux.HandleFunc("/api/config", handlers.ConfigHandler)
+	handler := newServerHandler(cfg, sessionStore)
 
-	// API routes - Hosting/Deploy
-	dashboardMux.HandleFunc("/api/deploy", handlers.DeployHandler)
-	dashboardMux.HandleFunc("/api/sites", handlers.SitesHandler)
-	dashboardMux.HandleFunc("/api/keys", handlers.APIKeysHandler)
-	dashboardMux.HandleFunc("/api/deployments", handlers.DeploymentsHandler)
-	dashboardMux.HandleFunc("/api/envvars", handlers.EnvVarsHandler)
+	// Create server; uploads and streams get their own deadlines (middleware.ConnDeadlines)
+	readTimeout, writeTimeout, idleTimeout, _ := cfg.Server.HTTPTimeouts()
+	srv := &http.Server{
+		Addr:         ":" + cfg.Server.Port,
+		Handler:      handler,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+		IdleTimeout:  idleTimeout,
+	}
 
-	// Hosting management page
-	dashboardMux.HandleFunc("/hosting", handlers.HostingPageHandler)
+	// Write PID file for stop command
+	pidFile := config.PIDFile(cfg.Database.Path)
+	if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0644); err != nil {
+		logging.Warnf("Warning: Failed to write PID file: %v", err)
+	}
 
-	// Static files
-	fs := http.FileServer(http.Dir("./web/static"))
-	dashboardMux.Handle("/static/", http.StripPrefix("/static/", fs))
+	// With HTTPS on, certificates are managed by CertMagic and a plain HTTP listener
+	// runs alongside the TLS server for ACME HTTP-01 challenges and redirects
+	var magic *certmagic.Config
+	var httpSrv *http.Server
+	if cfg.HTTPS.Enabled {
+		logging.Infof("HTTPS Enabled: Using CertMagic")
 
-	// Dashboard (root)
-	dashboardMux.HandleFunc("/", handlers.DashboardHandler)
+		certmagic.DefaultACME.Email = cfg.HTTPS.Email
+		certmagic.DefaultACME.Agreed = true
+		if cfg.HTTPS.Staging {
+			certmagic.DefaultACME.CA = certmagic.LetsEncryptStagingCA
+		}
+		if port := cfg.HTTPS.HTTPListenPort(); port != certmagic.HTTPPort {
+			// Port 80 is forwarded to us; the challenge solver must know where
+			certmagic.DefaultACME.AltHTTPPort = port
+		}
 
-	// Health check (available on both dashboard and sites)
-	dashboardMux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		if err := database.HealthCheck(); err != nil {
-			http.Error(w, "Database unhealthy", http.StatusServiceUnavailable)
-			return
+		// Use our SQL storage
+		certStorage := database.NewSQLCertStorage(database.GetDB())
+		certmagic.Default.Storage = certStorage
+
+		// Safety net for stuck renewals
+		stopCertCheck := notifier.StartCertExpiryCheck(certStorage, cfg.HTTPS.ExpiryAlertWindow(), notifier.CertExpiryCheckInterval)
+		defer stopCertCheck()
+
+		// Configure OnDemand TLS: only issue certificates for hosts we actually serve
+		cfgDomain := extractDomain(cfg.Server.Domain)
+		certmagic.Default.OnDemand = &certmagic.OnDemandConfig{
+			DecisionFunc: func(ctx context.Context, name string) error {
+				if err := hosting.AllowCertificate(name, cfgDomain); err != nil {
+					logging.Debugf("On-demand TLS refused: %v", err)
+					return err
+				}
+				return nil
+			},
 		}
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
 
-	// Create the root handler with host-based routing
-	rootHandler := createRootHandler(cfg, dashboardMux, sessionStore)
+		magic = certmagic.NewDefault()
+		acmeIssuer := certmagic.NewACMEIssuer(magic, certmagic.DefaultACME)
+		magic.Issuers = []certmagic.Issuer{acmeIssuer}
 
-	// Apply middleware (order: tracing -> logging -> body limit -> security -> cors -> recovery -> root)
-	handler := middleware.RequestTracing(
-		loggingMiddleware(
-			middleware.BodySizeLimit(middleware.MaxBodySize)(
-				middleware.SecurityHeaders(
-					corsMiddleware(
-						recoveryMiddleware(rootHandler),
-					),
-				),
-			),
-		),
-	)
+		// Production redirects everything to HTTPS; elsewhere the site stays reachable over HTTP
+		var httpHandler http.Handler = handler
+		if cfg.IsProduction() {
+			httpHandler = http.HandlerFunc(httpsRedirectHandler)
+		}
+		httpSrv = &http.Server{
+			Addr:         fmt.Sprintf(":%d", cfg.HTTPS.HTTPListenPort()),
+			Handler:      acmeIssuer.HTTPChallengeHandler(httpHandler),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+			IdleTimeout:  5 * time.Second,
+		}
 
-	// Create server
-	srv := &http.Server{
-		Addr:         ":" + cfg.Server.Port,
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		tlsConfig := magic.TLSConfig()
+		tlsConfig.NextProtos = append([]string{"h2", "http/1.1"}, tlsConfig.NextProtos...)
+		srv.Addr = fmt.Sprintf(":%d", certmagic.HTTPSPort)
+		srv.TLSConfig = tlsConfig
 	}
 
-	// Write PID file for stop command
-	pidFile := filepath.Join(filepath.Dir(cfg.Database.Path), "cc-server.pid")
-	if err := os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0644); err != nil {
-		log.Printf("Warning: Failed to write PID file: %v", err)
+	// Database, migrations and hosting are up: /readyz may report ready.
+	// In degraded mode recoverDatabase does this once the database is back.
+	if !degraded.Load() {
+		handlers.SetReady(true)
 	}
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Server starting on :%s", cfg.Server.Port)
-		log.Printf("Dashboard: %s", cfg.Server.Domain)
+		logging.Infof("Server starting on %s", srv.Addr)
+		logging.Infof("Dashboard: %s", cfg.Server.Domain)
 
 		if cfg.HTTPS.Enabled {
-			// Configure CertMagic
-			log.Println("HTTPS Enabled: Using CertMagic")
-			
-			// Initialize SQL Storage
-			certStorage := database.NewSQLCertStorage(database.GetDB())
-			
-			certmagic.DefaultACME.Email = cfg.HTTPS.Email
-			if cfg.HTTPS.Staging {
-				certmagic.DefaultACME.CA = certmagic.LetsEncryptStagingCA
-			}
-			
-			// Use our SQL storage
-			certmagic.Default.Storage = certStorage
-
-			// Get allowed domains
-			// We want to serve the main domain and any hosted subdomains.
-			// CertMagic OnDemand allows serving any domain we have permission for.
-			// But we need to restrict it to prevent abuse.
-			// For personal PaaS, we might only allow *.domain.com and domain.com.
-			
-			// Configure OnDemand TLS
-			cfgDomain := extractDomain(cfg.Server.Domain)
-			certmagic.Default.OnDemand = &certmagic.OnDemandConfig{
-				DecisionFunc: func(ctx context.Context, name string) error {
-					// Allow main domain
-					if name == cfgDomain {
-						return nil
-					}
-					// Allow subdomains of main domain
-					if strings.HasSuffix(name, "."+cfgDomain) {
-						// Optionally check if site exists in DB?
-						// if !hosting.SiteExists(extractSubdomain(name, cfgDomain)) { return fmt.Errorf("unknown site") }
-						return nil
-					}
-					return fmt.Errorf("domain not allowed")
-				},
+			// The HTTP listener must be up before certificates are requested
+			go func() {
+				logging.Infof("HTTP listener on %s", httpSrv.Addr)
+				if err := httpSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Fatalf("HTTP listener failed to start: %v", err)
+				}
+			}()
+
+			if err := magic.ManageSync(context.Background(), []string{extractDomain(cfg.Server.Domain)}); err != nil {
+				log.Fatalf("Failed to obtain certificate: %v", err)
 			}
 
-			// Start HTTPS server
-			// certmagic.HTTPS blocks, so we wrap the handler
-			// Note: CertMagic listens on :80 and :443 by default.
-			// If cfg.Server.Port is not 443, this might be confusing.
-			// CertMagic manages the listeners.
-			
-			err := certmagic.HTTPS([]string{cfgDomain}, handler)
-			if err != nil {
+			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
 				log.Fatalf("HTTPS Server failed: %v", err)
 			}
+		} else if *devTLS {
+			// Self-signed HTTPS for local development, regenerated on every start
+			mainDomain := extractDomain(cfg.Server.Domain)
+			cert, err := security.SelfSignedCertificate([]string{
+				"localhost", "*.localhost", "127.0.0.1", "::1", mainDomain, "*." + mainDomain,
+			})
+			if err != nil {
+				log.Fatalf("Failed to generate dev TLS certificate: %v", err)
+			}
+			srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+			logging.Infof("Dev TLS: serving HTTPS with a self-signed certificate (browsers will warn)")
+
+			if err := srv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Server failed to start: %v", err)
+			}
 		} else {
 			// Standard HTTP
 			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -1420,20 +2653,39 @@ func handleStartCommand() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	logging.Infof("Shutting down server...")
+
+	// Fail readiness so load balancers stop routing here while we drain
+	handlers.SetReady(false)
 
 	// Clean up PID file
 	os.Remove(pidFile)
 
 	// Graceful shutdown with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
 	defer cancel()
 
+	// Drain WebSocket clients first; srv.Shutdown doesn't track hijacked connections
+	drainCtx, cancelDrain := context.WithTimeout(ctx, cfg.Server.WSDrainDuration())
+	hosting.DrainAllHubs(drainCtx)
+	cancelDrain()
+
+	// End live log streams so srv.Shutdown isn't held open by them
+	hosting.StopLogStreams()
+
+	if httpSrv != nil {
+		httpSrv.Shutdown(ctx)
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	log.Println("Server stopped")
+	// Write events still buffered now that no handler can add more
+	if err := analytics.Stop(ctx); err != nil {
+		logging.Warnf("Analytics events not flushed before shutdown: %v", err)
+	}
+
+	logging.Infof("Server stopped")
 }
 
 // handleInstallCommand handles the install subcommand
@@ -1441,7 +2693,7 @@ func handleInstallCommand() {
 	flags := flag.NewFlagSet("install", flag.ExitOnError)
 	domain := flags.String("domain", "", "Domain for the server (required)")
 	email := flags.String("email", "", "Email for Let's Encrypt (required for HTTPS)")
-	user := flags.String("user", "fazt", "System user to run as")
+	user := flags.String("user", config.AppName, "System user to run as")
 	https := flags.Bool("https", false, "Enable automatic HTTPS")
 	adminUser := flags.String("username", "admin", "Admin username")
 	adminPass := flags.String("password", "", "Admin password (will generate if empty)")
@@ -1499,7 +2751,7 @@ func handleInstallCommand() {
 
 // printUsage displays the usage information
 func printUsage() {
-	fmt.Println("fazt.sh v0.5.0 - Personal Cloud Platform")
+	fmt.Printf("fazt.sh %s - Personal Cloud Platform\n", Version)
 	fmt.Println()
 	fmt.Println("USAGE:")
 	fmt.Println("  fazt <command> [options]")
@@ -1530,7 +2782,7 @@ func printUsage() {
 
 // printServiceHelp displays service-specific help
 func printServiceHelp() {
-	fmt.Println("fazt.sh v0.5.0 - Service Commands")
+	fmt.Printf("fazt.sh %s - Service Commands\n", Version)
 	fmt.Println()
 	fmt.Println("USAGE:")
 	fmt.Println("  fazt service <command> [options]")
@@ -1552,9 +2804,43 @@ func printServiceHelp() {
 	fmt.Println()
 }
 
+// handleResetTokenCommand handles the reset-token subcommand
+func handleResetTokenCommand() {
+	flags := flag.NewFlagSet("reset-token", flag.ExitOnError)
+	configPath := flags.String("config", "", "Config file path")
+	dataDir := flags.String("data-dir", "", dataDirUsage)
+
+	flags.Usage = func() {
+		fmt.Println("Usage: fazt server reset-token [flags]")
+		fmt.Println()
+		fmt.Printf("Issue a one-time token for resetting the admin password (valid %d minutes).\n", int(auth.ResetTokenTTL.Minutes()))
+		fmt.Println("Redeeming it sets the new password and logs out all sessions.")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(os.Args[3:]); err != nil {
+		os.Exit(1)
+	}
+
+	// Get config path
+	if *configPath == "" {
+		*configPath = config.ResolvePaths(*dataDir).ConfigFile()
+	}
+
+	// Call command function
+	output, err := resetTokenCommand(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Print(output)
+}
+
 // printServerHelp displays server-specific help
 func printServerHelp() {
-	fmt.Println("fazt.sh v0.5.0 - Server Commands")
+	fmt.Printf("fazt.sh %s - Server Commands\n", Version)
 	fmt.Println()
 	fmt.Println("USAGE:")
 	fmt.Println("  fazt server <command> [options]")
@@ -1562,9 +2848,17 @@ func printServerHelp() {
 	fmt.Println("SERVER COMMANDS:")
 	fmt.Println("  init             Initialize server (creates config & db)")
 	fmt.Println("  status           Show configuration and server status")
+	fmt.Println("  stats            Show event, site and database metrics")
 	fmt.Println("  start            Start the server manually (HTTP or HTTPS)")
 	fmt.Println("  set-credentials  Update admin credentials")
 	fmt.Println("  set-config       Update settings (domain, port, env)")
+	fmt.Println("  domains          Manage custom domains (list, add, remove)")
+	fmt.Println("  sites            List sites, enable or disable a site")
+	fmt.Println("  aliases          Manage site aliases (list, add, remove)")
+	fmt.Println("  reset-token      Issue a one-time admin password reset token")
+	fmt.Println("  logs             Show or follow the server log file")
+	fmt.Println("  export           Write all data to a portable JSON file")
+	fmt.Println("  import           Restore an export into a fresh database")
 	fmt.Println("  --help, -h       Show this help")
 	fmt.Println()
 	fmt.Println("EXAMPLES:")
@@ -1578,7 +2872,7 @@ func printServerHelp() {
 
 // printClientHelp displays client-specific help
 func printClientHelp() {
-	fmt.Println("fazt.sh v0.5.0 - Client Commands")
+	fmt.Printf("fazt.sh %s - Client Commands\n", Version)
 	fmt.Println()
 	fmt.Println("USAGE:")
 	fmt.Println("  fazt client <command> [options]")
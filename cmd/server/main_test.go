@@ -3,12 +3,16 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jikku/command-center/internal/config"
+	"github.com/jikku/command-center/internal/database"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -28,7 +32,7 @@ Implementation Guide:
 
 To implement these features, create the following functions in main.go:
 
-1. initCommand(username, password, domain, port, env, configPath string) error
+1. initCommand(username, password, domain, port, env, configPath, dataDir string) error
    - Checks if config already exists (return error if it does)
    - Creates config with provided values
    - Hashes password with bcrypt (cost 12)
@@ -51,7 +55,7 @@ To implement these features, create the following functions in main.go:
    - Saves config back
    - Returns nil on success
 
-4. statusCommand(configPath, configDir string) (string, error)
+4. statusCommand(configPath string) (string, error)
    - Loads config (return error if not found)
    - Checks if server is running (reads PID file)
    - Formats and returns status string
@@ -125,7 +129,7 @@ func TestInitCommand_Success(t *testing.T) {
 	env := "development"
 
 	// Execute init command
-	err := initCommand(username, password, domain, port, env, configPath)
+	err := initCommand(username, password, domain, port, env, configPath, "")
 	if err != nil {
 		t.Fatalf("initCommand failed: %v", err)
 	}
@@ -201,7 +205,7 @@ func TestInitCommand_ConfigAlreadyExists(t *testing.T) {
 	createTestConfig(t, tmpDir, existingConfig)
 
 	// Try to init again
-	err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath)
+	err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath, "")
 	if err == nil {
 		t.Fatal("initCommand should fail when config exists")
 	}
@@ -239,7 +243,7 @@ func TestInitCommand_MissingRequiredFlags(t *testing.T) {
 			tmpDir := createTempConfigDir(t)
 			configPath := filepath.Join(tmpDir, "config.json")
 
-			err := initCommand(tt.username, tt.password, tt.domain, "4698", "development", configPath)
+			err := initCommand(tt.username, tt.password, tt.domain, "4698", "development", configPath, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("initCommand() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -274,7 +278,7 @@ func TestInitCommand_InvalidPort(t *testing.T) {
 			tmpDir := createTempConfigDir(t)
 			configPath := filepath.Join(tmpDir, "config.json")
 
-			err := initCommand("admin", "pass123", "https://test.com", tt.port, "development", configPath)
+			err := initCommand("admin", "pass123", "https://test.com", tt.port, "development", configPath, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("initCommand() with port %s: error = %v, wantErr %v", tt.port, err, tt.wantErr)
 			}
@@ -300,7 +304,7 @@ func TestInitCommand_InvalidEnvironment(t *testing.T) {
 			tmpDir := createTempConfigDir(t)
 			configPath := filepath.Join(tmpDir, "config.json")
 
-			err := initCommand("admin", "pass123", "https://test.com", "4698", tt.env, configPath)
+			err := initCommand("admin", "pass123", "https://test.com", "4698", tt.env, configPath, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("initCommand() with env %s: error = %v, wantErr %v", tt.env, err, tt.wantErr)
 			}
@@ -308,6 +312,24 @@ func TestInitCommand_InvalidEnvironment(t *testing.T) {
 	}
 }
 
+func TestInitCommand_DataDir(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config", "config.json")
+	dataDir := filepath.Join(tmpDir, "data")
+
+	if err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath, dataDir); err != nil {
+		t.Fatalf("initCommand failed: %v", err)
+	}
+
+	cfg := loadConfigFromFile(t, configPath)
+	if want := filepath.Join(dataDir, "data.db"); cfg.Database.Path != want {
+		t.Errorf("Database.Path = %q, want %q", cfg.Database.Path, want)
+	}
+	if info, err := os.Stat(dataDir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("data directory should exist with 0700 permissions: %v", err)
+	}
+}
+
 func TestInitCommand_SecurePermissions(t *testing.T) {
 	tmpDir := createTempConfigDir(t)
 	configPath := filepath.Join(tmpDir, "config.json")
@@ -315,7 +337,7 @@ func TestInitCommand_SecurePermissions(t *testing.T) {
 	// Ensure directory has secure permissions
 	os.MkdirAll(tmpDir, 0700)
 
-	err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath)
+	err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath, "")
 	if err != nil {
 		t.Fatalf("initCommand failed: %v", err)
 	}
@@ -716,7 +738,7 @@ func TestStatus_OutputFormat(t *testing.T) {
 	// Create a fake database file
 	os.WriteFile(cfg.Database.Path, []byte("fake db"), 0600)
 
-	output, err := statusCommand(configPath, tmpDir)
+	output, err := statusCommand(configPath)
 	if err != nil {
 		t.Fatalf("statusCommand failed: %v", err)
 	}
@@ -758,7 +780,7 @@ func TestStatus_ServerRunning(t *testing.T) {
 	pidFile := filepath.Join(tmpDir, "cc-server.pid")
 	os.WriteFile(pidFile, []byte(fmt.Sprintf("%d", os.Getpid())), 0600)
 
-	output, err := statusCommand(configPath, tmpDir)
+	output, err := statusCommand(configPath)
 	if err != nil {
 		t.Fatalf("statusCommand failed: %v", err)
 	}
@@ -781,7 +803,7 @@ func TestStatus_ServerStopped(t *testing.T) {
 
 	// No PID file = server not running
 
-	output, err := statusCommand(configPath, tmpDir)
+	output, err := statusCommand(configPath)
 	if err != nil {
 		t.Fatalf("statusCommand failed: %v", err)
 	}
@@ -795,12 +817,176 @@ func TestStatus_NoConfigExists(t *testing.T) {
 	tmpDir := createTempConfigDir(t)
 	configPath := filepath.Join(tmpDir, "config.json")
 
-	_, err := statusCommand(configPath, tmpDir)
+	_, err := statusCommand(configPath)
 	if err == nil {
 		t.Fatal("statusCommand should fail when config doesn't exist")
 	}
 }
 
+// ===================================================================================
+// Stats Command Tests
+// ===================================================================================
+
+func TestStats_OutputFormat(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Port: "4698", Domain: "https://test.com", Env: "development"},
+		Database: config.DatabaseConfig{Path: filepath.Join(tmpDir, "data.db")},
+		Auth:     config.AuthConfig{Username: "admin", PasswordHash: "hash"},
+	}
+	configPath := createTestConfig(t, tmpDir, cfg)
+
+	if err := database.Init(cfg.Database.Path); err != nil {
+		t.Fatalf("database.Init failed: %v", err)
+	}
+	db := database.GetDB()
+	for _, domain := range []string{"a.example.com", "b.example.com", "b.example.com"} {
+		db.Exec("INSERT INTO events (domain, source_type, event_type) VALUES (?, 'web', 'pageview')", domain)
+	}
+	db.Exec("INSERT INTO files (site_id, path, content, size_bytes, mime_type, hash) VALUES ('blog', 'index.html', 'hi', 2, 'text/html', 'x')")
+
+	// The server's connection stays open, as it would while running
+	defer database.Close()
+
+	output, err := statsCommand(configPath)
+	if err != nil {
+		t.Fatalf("statsCommand failed: %v", err)
+	}
+	for _, expected := range []string{"Server Stats", "3 total, 3 today", "Sites:        1", "b.example.com (2 events)"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Stats output missing '%s'\nGot output:\n%s", expected, output)
+		}
+	}
+}
+
+func TestStats_DatabaseMissing(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Port: "4698", Domain: "https://test.com", Env: "development"},
+		Database: config.DatabaseConfig{Path: filepath.Join(tmpDir, "data.db")},
+		Auth:     config.AuthConfig{Username: "admin", PasswordHash: "hash"},
+	}
+	configPath := createTestConfig(t, tmpDir, cfg)
+
+	_, err := statsCommand(configPath)
+	if err == nil || !strings.Contains(err.Error(), "Database not found") {
+		t.Fatalf("statsCommand error = %v, want Database not found", err)
+	}
+	if _, statErr := os.Stat(cfg.Database.Path); !os.IsNotExist(statErr) {
+		t.Error("statsCommand should not create the database")
+	}
+}
+
+func TestStats_NoConfigExists(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+
+	if _, err := statsCommand(filepath.Join(tmpDir, "config.json")); err == nil {
+		t.Fatal("statsCommand should fail when config doesn't exist")
+	}
+}
+
+// ===================================================================================
+// Domains Command Tests
+// ===================================================================================
+
+func TestDomains_InvalidAction(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	_, err := domainsCommand("rename", "www.example.com", "blog", configPath)
+	if err == nil {
+		t.Fatal("domainsCommand should fail with unknown action")
+	}
+}
+
+func TestDomains_AddRequiresHostnameAndSite(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if _, err := domainsCommand("add", "", "blog", configPath); err == nil {
+		t.Error("domainsCommand add should fail without hostname")
+	}
+	if _, err := domainsCommand("add", "www.example.com", "", configPath); err == nil {
+		t.Error("domainsCommand add should fail without site")
+	}
+	if _, err := domainsCommand("remove", "", "", configPath); err == nil {
+		t.Error("domainsCommand remove should fail without hostname")
+	}
+}
+
+func TestDomains_NoConfigExists(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	_, err := domainsCommand("list", "", "", configPath)
+	if err == nil {
+		t.Fatal("domainsCommand should fail when config doesn't exist")
+	}
+}
+
+// ===================================================================================
+// Aliases Command Tests
+// ===================================================================================
+
+func TestAliases_InvalidAction(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if _, err := aliasesCommand("rename", "staging", "blog", configPath); err == nil {
+		t.Fatal("aliasesCommand should fail with unknown action")
+	}
+}
+
+func TestAliases_RequiredFlags(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if _, err := aliasesCommand("add", "", "blog", configPath); err == nil {
+		t.Error("aliasesCommand add should fail without alias")
+	}
+	if _, err := aliasesCommand("add", "staging", "", configPath); err == nil {
+		t.Error("aliasesCommand add should fail without site")
+	}
+	if _, err := aliasesCommand("remove", "", "", configPath); err == nil {
+		t.Error("aliasesCommand remove should fail without alias")
+	}
+}
+
+// ===================================================================================
+// Sites Command Tests
+// ===================================================================================
+
+func TestSites_InvalidAction(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if _, err := sitesCommand("pause", "blog", configPath); err == nil {
+		t.Fatal("sitesCommand should fail with unknown action")
+	}
+}
+
+func TestSites_ToggleRequiresSite(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if _, err := sitesCommand("enable", "", configPath); err == nil {
+		t.Error("sitesCommand enable should fail without site")
+	}
+	if _, err := sitesCommand("disable", "", configPath); err == nil {
+		t.Error("sitesCommand disable should fail without site")
+	}
+}
+
+func TestResetToken_MissingConfig(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	_, err := resetTokenCommand(configPath)
+	if err == nil || !strings.Contains(err.Error(), "Config not found") {
+		t.Errorf("resetTokenCommand error = %v, want config not found", err)
+	}
+}
+
 // ===================================================================================
 // Integration-like Tests
 // ===================================================================================
@@ -810,7 +996,7 @@ func TestFullWorkflow_InitSetConfigStatus(t *testing.T) {
 	configPath := filepath.Join(tmpDir, "config.json")
 
 	// 1. Init
-	err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath)
+	err := initCommand("admin", "pass123", "https://test.com", "4698", "development", configPath, "")
 	if err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
@@ -849,7 +1035,7 @@ func TestFullWorkflow_InitSetConfigStatus(t *testing.T) {
 	}
 
 	// 6. Check status
-	output, err := statusCommand(configPath, tmpDir)
+	output, err := statusCommand(configPath)
 	if err != nil {
 		t.Fatalf("status failed: %v", err)
 	}
@@ -864,6 +1050,33 @@ func TestFullWorkflow_InitSetConfigStatus(t *testing.T) {
 	}
 }
 
+func TestHTTPSRedirectHandler(t *testing.T) {
+	tests := []struct {
+		host string
+		path string
+		want string
+	}{
+		{"fazt.sh", "/", "https://fazt.sh/"},
+		{"app.fazt.sh:80", "/docs/page?x=1&y=2", "https://app.fazt.sh/docs/page?x=1&y=2"},
+		{"[::1]:8080", "/a", "https://[::1]/a"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://"+tt.host+tt.path, nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+
+		httpsRedirectHandler(rec, req)
+
+		if rec.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s: status = %d, want 301", tt.host, tt.path, rec.Code)
+		}
+		if got := rec.Header().Get("Location"); got != tt.want {
+			t.Errorf("%s%s: Location = %q, want %q", tt.host, tt.path, got, tt.want)
+		}
+	}
+}
+
 // ===================================================================================
 // Run Instructions
 // ===================================================================================
@@ -892,3 +1105,162 @@ Each function should:
 
 Good luck!
 */
+
+func TestServerLogFile(t *testing.T) {
+	tmpDir := createTempConfigDir(t)
+	configPath := filepath.Join(tmpDir, "config.json")
+
+	if _, err := serverLogFile(configPath); err == nil {
+		t.Error("serverLogFile should fail without a config")
+	}
+
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Port: "4698", Domain: "https://test.com", Env: "development"},
+		Database: config.DatabaseConfig{Path: filepath.Join(tmpDir, "data.db")},
+	}
+	createTestConfig(t, tmpDir, cfg)
+	if _, err := serverLogFile(configPath); err == nil || !strings.Contains(err.Error(), "No log file configured") {
+		t.Errorf("serverLogFile without log_file error = %v, want a hint to configure it", err)
+	}
+
+	logPath := filepath.Join(tmpDir, "server.log")
+	cfg.Server.LogFile = logPath
+	createTestConfig(t, tmpDir, cfg)
+	if _, err := serverLogFile(configPath); err == nil {
+		t.Error("serverLogFile should fail when the log file doesn't exist yet")
+	}
+
+	os.WriteFile(logPath, nil, 0600)
+	if got, err := serverLogFile(configPath); err != nil || got != logPath {
+		t.Errorf("serverLogFile() = %q, %v; want %q", got, err, logPath)
+	}
+}
+
+func TestNewIdempotencyKey(t *testing.T) {
+	a, b := newIdempotencyKey(), newIdempotencyKey()
+	if a == b {
+		t.Errorf("newIdempotencyKey() returned %q twice", a)
+	}
+	if !strings.HasPrefix(a, "deploy-") || len(a) != len("deploy-")+32 {
+		t.Errorf("newIdempotencyKey() = %q, want deploy-<32 hex chars>", a)
+	}
+}
+
+func TestDeployUpload_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int // answered in turn; the last repeats
+		retries  int
+		want     int
+		attempts int
+	}{
+		{"success", []int{200}, 3, 200, 1},
+		{"server errors then success", []int{503, 500, 200}, 3, 200, 3},
+		{"in progress then success", []int{409, 200}, 3, 200, 2},
+		{"gives up", []int{502}, 2, 502, 3},
+		{"client error is final", []int{401}, 3, 401, 1},
+		{"no retries", []int{500}, 0, 500, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int
+			var keys []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				keys = append(keys, r.Header.Get("Idempotency-Key"))
+				body := make([]byte, 4)
+				if n, _ := r.Body.Read(body); string(body[:n]) != "data" {
+					t.Errorf("attempt %d body = %q, want the full upload", attempts+1, body[:n])
+				}
+				status := tt.statuses[min(attempts, len(tt.statuses)-1)]
+				attempts++
+				w.WriteHeader(status)
+			}))
+			defer srv.Close()
+
+			upload := deployUpload{URL: srv.URL, ContentType: "application/zip", Token: "t", IdempotencyKey: "deploy-1", Body: []byte("data")}
+			resp, _, err := upload.send(srv.Client(), tt.retries, time.Millisecond)
+			if err != nil {
+				t.Fatalf("send() error = %v", err)
+			}
+			if resp.StatusCode != tt.want || attempts != tt.attempts {
+				t.Errorf("got %d after %d attempts, want %d after %d", resp.StatusCode, attempts, tt.want, tt.attempts)
+			}
+			for _, key := range keys {
+				if key != "deploy-1" {
+					t.Errorf("attempt sent Idempotency-Key %q, want the same key every time", key)
+				}
+			}
+		})
+	}
+}
+
+func TestDeployUpload_RetriesNetworkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close() // nothing listening
+
+	upload := deployUpload{URL: url, Body: []byte("data")}
+	if _, _, err := upload.send(http.DefaultClient, 2, time.Millisecond); err == nil {
+		t.Error("send() to a closed server should fail after its retries")
+	}
+}
+
+func TestInitDatabaseWithRetry(t *testing.T) {
+	// A regular file where the database directory should be can never open
+	blocker := filepath.Join(t.TempDir(), "blocker")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err := initDatabaseWithRetry(filepath.Join(blocker, "data.db"), 2, 10*time.Millisecond)
+	if err == nil {
+		t.Fatal("initDatabaseWithRetry() should fail")
+	}
+	// Two retries back off 10ms, then 20ms
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("returned after %v, want at least 30ms of backoff", elapsed)
+	}
+}
+
+func TestDegradedHandler(t *testing.T) {
+	defer degraded.Store(false)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("served"))
+	})
+	h := degradedHandler(next)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/stats", nil))
+	if rec.Body.String() != "served" {
+		t.Fatalf("healthy server: body %q, want the wrapped handler", rec.Body.String())
+	}
+
+	degraded.Store(true)
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/livez", http.StatusOK},
+		{"/readyz", http.StatusServiceUnavailable},
+		{"/health", http.StatusServiceUnavailable},
+		{"/api/stats", http.StatusServiceUnavailable},
+		{"/", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("degraded %s: status %d, want %d", tt.path, rec.Code, tt.want)
+		}
+		if strings.Contains(rec.Body.String(), "served") {
+			t.Errorf("degraded %s reached the wrapped handler", tt.path)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), "Maintenance") {
+		t.Errorf("maintenance page: headers %v, body %q", rec.Header(), rec.Body.String())
+	}
+}
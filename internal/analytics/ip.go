@@ -0,0 +1,150 @@
+package analytics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// anonymizeIP truncates visitor IPs before they are stored
+var anonymizeIP atomic.Bool
+
+// SetAnonymizeIP makes ClientIP truncate addresses (privacy.anonymize_ip).
+// Call it before serving requests.
+func SetAnonymizeIP(on bool) {
+	anonymizeIP.Store(on)
+}
+
+// ClientIP returns the visitor's IP address for an event: the first
+// X-Forwarded-For entry, then X-Real-IP, then the connection's address.
+// With anonymization on, it is truncated by AnonymizeIP.
+func ClientIP(r *http.Request) string {
+	ip := resolveIP(r)
+	if anonymizeIP.Load() {
+		return AnonymizeIP(ip)
+	}
+	return ip
+}
+
+// resolveIP gets the client's IP address from the request
+func resolveIP(r *http.Request) string {
+	// Check X-Forwarded-For header first (for proxies/load balancers)
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		return strings.TrimSpace(strings.Split(xff, ",")[0])
+	}
+
+	if xri := r.Header.Get("X-Real-IP"); xri != "" {
+		return xri
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// trustedProxies holds the networks whose forwarding headers RemoteIP believes
+// (server.trusted_proxies); nil means loopback only
+var trustedProxies atomic.Pointer[[]*net.IPNet]
+
+// loopbackProxies is the default: a reverse proxy on the same host
+var loopbackProxies = []*net.IPNet{
+	{IP: net.IPv4(127, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+}
+
+// SetTrustedProxies sets the proxies, as IPs or CIDR ranges, whose
+// X-Forwarded-For and X-Real-IP headers RemoteIP believes. A list replaces
+// the default, loopback only. Call it before serving requests.
+func SetTrustedProxies(proxies []string) error {
+	if len(proxies) == 0 {
+		trustedProxies.Store(nil)
+		return nil
+	}
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("invalid trusted proxy %q", p)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("invalid trusted proxy %q", p)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies.Store(&nets)
+	return nil
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	nets := loopbackProxies
+	if p := trustedProxies.Load(); p != nil {
+		nets = *p
+	}
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RemoteIP returns the address of the client behind a request for rate limits
+// and audit records. Forwarding headers are only believed when the connection
+// comes from a trusted proxy, and then the closest untrusted X-Forwarded-For
+// hop is used, so a client can't pick its own address. It is never anonymized.
+func RemoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if i == 0 || !isTrustedProxy(hop) {
+				return hop
+			}
+		}
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return xri
+	}
+	return ip
+}
+
+// AnonymizeIP zeroes the last octet of an IPv4 address and the last 80 bits of
+// an IPv6 address, so it still locates a network but not a person. Anything that
+// doesn't parse as an IP is dropped.
+func AnonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,86 @@
+package analytics
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.42":                           "203.0.113.0",
+		"::ffff:203.0.113.42":                    "203.0.113.0",
+		"2001:db8:85a3:1234:5678:8a2e:370:7334":  "2001:db8:85a3::",
+		"2001:db8:85a3:ffff:ffff:ffff:ffff:ffff": "2001:db8:85a3::",
+		"::1":                                    "::",
+		"unknown":                                "",
+		"":                                       "",
+	}
+	for in, want := range tests {
+		if got := AnonymizeIP(in); got != want {
+			t.Errorf("AnonymizeIP(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer SetAnonymizeIP(false)
+
+	r := httptest.NewRequest("GET", "/track", nil)
+	r.RemoteAddr = "198.51.100.7:53211"
+	if got := ClientIP(r); got != "198.51.100.7" {
+		t.Errorf("ClientIP() = %q, want the full address by default", got)
+	}
+
+	SetAnonymizeIP(true)
+	if got := ClientIP(r); got != "198.51.100.0" {
+		t.Errorf("anonymized ClientIP() = %q, want 198.51.100.0", got)
+	}
+
+	r.Header.Set("X-Forwarded-For", "2001:db8::abcd, 10.0.0.1")
+	if got := ClientIP(r); got != "2001:db8::" {
+		t.Errorf("anonymized X-Forwarded-For ClientIP() = %q, want 2001:db8::", got)
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	defer SetTrustedProxies(nil)
+
+	tests := []struct {
+		name    string
+		proxies []string
+		remote  string
+		xff     string
+		xri     string
+		want    string
+	}{
+		{"direct", nil, "198.51.100.7:53211", "", "", "198.51.100.7"},
+		{"spoofed header ignored", nil, "198.51.100.7:53211", "203.0.113.9", "203.0.113.9", "198.51.100.7"},
+		{"loopback proxy", nil, "127.0.0.1:40000", "203.0.113.9", "", "203.0.113.9"},
+		{"loopback proxy, real IP", nil, "[::1]:40000", "", "203.0.113.9", "203.0.113.9"},
+		{"closest untrusted hop", nil, "127.0.0.1:40000", "10.9.9.9, 203.0.113.9", "", "203.0.113.9"},
+		{"configured proxy", []string{"10.0.0.0/8"}, "10.0.0.2:40000", "6.6.6.6, 203.0.113.9, 10.0.0.3", "", "203.0.113.9"},
+		{"loopback untrusted once configured", []string{"10.0.0.2"}, "127.0.0.1:40000", "203.0.113.9", "", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatalf("SetTrustedProxies failed: %v", err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				r.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xri != "" {
+				r.Header.Set("X-Real-IP", tt.xri)
+			}
+			if got := RemoteIP(r); got != tt.want {
+				t.Errorf("RemoteIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("SetTrustedProxies should reject a malformed entry")
+	}
+}
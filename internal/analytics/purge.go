@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+)
+
+// PurgeBatchSize is how many rows a purge deletes per statement, so a large purge
+// doesn't hold the write lock long enough to stall tracking
+const PurgeBatchSize = 1000
+
+// PurgeResult counts what a purge deleted
+type PurgeResult struct {
+	Events    int64 `json:"events_deleted"`
+	Redirects int64 `json:"redirects_deleted"`
+}
+
+// PurgeDomain deletes every event recorded for domain, batchSize rows at a time.
+// With redirects, it also deletes the redirects whose destination is on domain,
+// along with their click events.
+func PurgeDomain(db *sql.DB, domain string, redirects bool, batchSize int) (PurgeResult, error) {
+	var result PurgeResult
+
+	n, err := deleteEventsInBatches(db, "domain = ?", []interface{}{domain}, batchSize)
+	result.Events += n
+	if err != nil {
+		return result, err
+	}
+	if !redirects {
+		return result, nil
+	}
+
+	slugs, err := redirectsTo(db, NormalizeDomain(domain))
+	if err != nil {
+		return result, err
+	}
+	for _, slug := range slugs {
+		n, err := deleteEventsInBatches(db, "source_type = 'redirect' AND domain = ?", []interface{}{slug}, batchSize)
+		result.Events += n
+		if err != nil {
+			return result, err
+		}
+		res, err := db.Exec("DELETE FROM redirects WHERE slug = ?", slug)
+		if err != nil {
+			return result, fmt.Errorf("failed to delete redirect %s: %w", slug, err)
+		}
+		n, _ = res.RowsAffected()
+		result.Redirects += n
+	}
+	return result, nil
+}
+
+// deleteEventsInBatches deletes the events matching where until none are left
+func deleteEventsInBatches(db *sql.DB, where string, args []interface{}, batchSize int) (int64, error) {
+	query := "DELETE FROM events WHERE id IN (SELECT id FROM events WHERE " + where + " LIMIT ?)"
+	var total int64
+	for {
+		res, err := db.Exec(query, append(args, batchSize)...)
+		if err != nil {
+			return total, fmt.Errorf("failed to delete events: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		total += n
+		if n < int64(batchSize) {
+			return total, nil
+		}
+	}
+}
+
+// redirectsTo returns the slugs of redirects whose destination host is domain
+func redirectsTo(db *sql.DB, domain string) ([]string, error) {
+	rows, err := db.Query("SELECT slug, destination FROM redirects")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slugs []string
+	for rows.Next() {
+		var slug, destination string
+		if err := rows.Scan(&slug, &destination); err != nil {
+			return nil, err
+		}
+		if u, err := url.Parse(destination); err == nil && NormalizeDomain(u.Hostname()) == domain {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs, rows.Err()
+}
@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jikku/command-center/internal/database"
+)
+
+func TestPurgeDomain(t *testing.T) {
+	if err := database.Init(filepath.Join(t.TempDir(), "purge.db")); err != nil {
+		t.Fatalf("database.Init() error = %v", err)
+	}
+	defer database.Close()
+	db := database.GetDB()
+
+	var events []Event
+	for i := 0; i < 25; i++ {
+		events = append(events, Event{Domain: "blog.example.com", SourceType: "web", EventType: "pageview"})
+	}
+	events = append(events,
+		Event{Domain: "shop.example.com", SourceType: "web", EventType: "pageview"},
+		Event{Domain: "post", SourceType: "redirect", EventType: "click"},
+		Event{Domain: "deal", SourceType: "redirect", EventType: "click"},
+	)
+	if err := InsertEvents(db, events); err != nil {
+		t.Fatalf("InsertEvents() error = %v", err)
+	}
+	db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('post', 'https://Blog.Example.com/post/1', '')`)
+	db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('deal', 'https://shop.example.com/deal', '')`)
+
+	countEvents := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM events").Scan(&n)
+		return n
+	}
+
+	// Small batches exercise the loop
+	result, err := PurgeDomain(db, "blog.example.com", false, 10)
+	if err != nil {
+		t.Fatalf("PurgeDomain() error = %v", err)
+	}
+	if result.Events != 25 || result.Redirects != 0 || countEvents() != 3 {
+		t.Errorf("PurgeDomain() = %+v, %d events left; want 25 deleted and 3 left", result, countEvents())
+	}
+
+	// With redirects, the redirect to the domain goes too, with its clicks
+	result, err = PurgeDomain(db, "blog.example.com", true, 10)
+	if err != nil {
+		t.Fatalf("PurgeDomain(redirects) error = %v", err)
+	}
+	if result.Events != 1 || result.Redirects != 1 || countEvents() != 2 {
+		t.Errorf("PurgeDomain(redirects) = %+v, %d events left; want 1 event and 1 redirect deleted", result, countEvents())
+	}
+	var slug string
+	db.QueryRow("SELECT slug FROM redirects").Scan(&slug)
+	if slug != "deal" {
+		t.Errorf("remaining redirect = %q, want deal", slug)
+	}
+}
@@ -0,0 +1,127 @@
+package analytics
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// trackingTokenPrefix marks public tracking tokens, so they aren't mistaken for deploy keys
+const trackingTokenPrefix = "fzt_pub_"
+
+// ErrTokenNotFound is returned when deleting a tracking token that doesn't exist
+var ErrTokenNotFound = errors.New("tracking token not found")
+
+// Token is a public tracking token bound to one domain
+type Token struct {
+	ID        int64     `json:"id"`
+	Token     string    `json:"token"`
+	Domain    string    `json:"domain"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// requireToken switches /track from open mode to token mode
+var requireToken atomic.Bool
+
+// SetRequireToken makes tracking need a token for the event's domain
+// (analytics.require_token). Call it before serving requests.
+func SetRequireToken(on bool) {
+	requireToken.Store(on)
+}
+
+// RequireToken reports whether tracking needs a token
+func RequireToken() bool {
+	return requireToken.Load()
+}
+
+// NormalizeDomain lowercases domain and drops any port, so tokens match
+// however a page reports its host
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if host, _, err := net.SplitHostPort(domain); err == nil {
+		domain = host
+	}
+	return strings.TrimSuffix(domain, ".")
+}
+
+// CreateToken issues a tracking token for domain
+func CreateToken(db *sql.DB, domain, name string) (*Token, error) {
+	domain = NormalizeDomain(domain)
+	if domain == "" {
+		return nil, errors.New("domain is required")
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	t := &Token{Token: trackingTokenPrefix + hex.EncodeToString(b), Domain: domain, Name: name}
+
+	result, err := db.Exec("INSERT INTO tracking_tokens (token, domain, name) VALUES (?, ?, ?)", t.Token, t.Domain, t.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store tracking token: %w", err)
+	}
+	t.ID, _ = result.LastInsertId()
+	t.CreatedAt = time.Now().UTC()
+	return t, nil
+}
+
+// ListTokens returns tracking tokens, newest first, for one domain or all when domain is empty
+func ListTokens(db *sql.DB, domain string) ([]Token, error) {
+	query := "SELECT id, token, domain, name, created_at FROM tracking_tokens"
+	var args []interface{}
+	if domain != "" {
+		query += " WHERE domain = ?"
+		args = append(args, NormalizeDomain(domain))
+	}
+	rows, err := db.Query(query+" ORDER BY id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []Token{}
+	for rows.Next() {
+		var t Token
+		if err := rows.Scan(&t.ID, &t.Token, &t.Domain, &t.Name, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteToken revokes a tracking token
+func DeleteToken(db *sql.DB, id int64) error {
+	result, err := db.Exec("DELETE FROM tracking_tokens WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// TokenAllows reports whether token may record events for domain
+func TokenAllows(db *sql.DB, token, domain string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	var owner string
+	err := db.QueryRow("SELECT domain FROM tracking_tokens WHERE token = ?", token).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return owner == NormalizeDomain(domain), nil
+}
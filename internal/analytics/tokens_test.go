@@ -0,0 +1,79 @@
+package analytics
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jikku/command-center/internal/database"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := map[string]string{
+		"Blog.Example.com":    "blog.example.com",
+		" blog.example.com ":  "blog.example.com",
+		"blog.example.com:80": "blog.example.com",
+		"blog.example.com.":   "blog.example.com",
+		"":                    "",
+	}
+	for in, want := range tests {
+		if got := NormalizeDomain(in); got != want {
+			t.Errorf("NormalizeDomain(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTrackingTokens(t *testing.T) {
+	if err := database.Init(filepath.Join(t.TempDir(), "tokens.db")); err != nil {
+		t.Fatalf("database.Init() error = %v", err)
+	}
+	defer database.Close()
+	db := database.GetDB()
+
+	blog, err := CreateToken(db, "Blog.Example.com", "blog")
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	if !strings.HasPrefix(blog.Token, trackingTokenPrefix) || blog.Domain != "blog.example.com" {
+		t.Errorf("CreateToken() = %+v", blog)
+	}
+	shop, _ := CreateToken(db, "shop.example.com", "")
+	if _, err := CreateToken(db, " ", ""); err == nil {
+		t.Error("CreateToken() without a domain should fail")
+	}
+
+	tests := []struct {
+		token, domain string
+		want          bool
+	}{
+		{blog.Token, "blog.example.com", true},
+		{blog.Token, "BLOG.example.com:443", true},
+		{blog.Token, "shop.example.com", false},
+		{shop.Token, "shop.example.com", true},
+		{"", "blog.example.com", false},
+		{"fzt_pub_unknown", "blog.example.com", false},
+	}
+	for _, tt := range tests {
+		got, err := TokenAllows(db, tt.token, tt.domain)
+		if err != nil || got != tt.want {
+			t.Errorf("TokenAllows(%q, %q) = %v, %v; want %v", tt.token, tt.domain, got, err, tt.want)
+		}
+	}
+
+	if tokens, _ := ListTokens(db, ""); len(tokens) != 2 {
+		t.Errorf("ListTokens() returned %d tokens, want 2", len(tokens))
+	}
+	if tokens, _ := ListTokens(db, "blog.example.com"); len(tokens) != 1 || tokens[0].Name != "blog" {
+		t.Errorf("ListTokens(blog) = %+v", tokens)
+	}
+
+	if err := DeleteToken(db, blog.ID); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+	if ok, _ := TokenAllows(db, blog.Token, "blog.example.com"); ok {
+		t.Error("deleted token still allowed")
+	}
+	if err := DeleteToken(db, blog.ID); err != ErrTokenNotFound {
+		t.Errorf("DeleteToken() twice = %v, want ErrTokenNotFound", err)
+	}
+}
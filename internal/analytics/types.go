@@ -0,0 +1,95 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// OtherType is recorded in place of a source or event type that isn't known,
+// so typos don't become stats dimensions of their own
+const OtherType = "other"
+
+// SourceTypes are where events come from; the server sets these itself
+var SourceTypes = []string{"web", "pixel", "redirect", "webhook", "hosting"}
+
+// EventTypes are the event types accepted without configuration
+var EventTypes = []string{"pageview", "click", "open", "pixel", "redirect", "webhook"}
+
+// ErrInvalidEventType is returned for an event type that isn't a short name
+var ErrInvalidEventType = errors.New("invalid event type: use up to 64 letters, digits, '_', '-', '.' or ':'")
+
+// eventTypePattern is a well-formed event type, after lowercasing
+var eventTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]{0,63}$`)
+
+// customEventTypes holds the extra accepted event types (analytics.event_types)
+var customEventTypes atomic.Pointer[eventTypeSet]
+
+type eventTypeSet struct {
+	names map[string]bool
+	any   bool
+}
+
+// SetCustomEventTypes accepts these event types as well as EventTypes; "*"
+// accepts any well-formed type. Call it before serving requests.
+func SetCustomEventTypes(types []string) error {
+	set := &eventTypeSet{names: make(map[string]bool, len(types))}
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch {
+		case t == "*":
+			set.any = true
+		case eventTypePattern.MatchString(t):
+			set.names[t] = true
+		default:
+			return fmt.Errorf("invalid event type %q", t)
+		}
+	}
+	customEventTypes.Store(set)
+	return nil
+}
+
+// ParseEventType lowercases a client-supplied event type and checks it's well
+// formed. Types that aren't built in or configured come back as OtherType.
+func ParseEventType(t string) (string, error) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if !eventTypePattern.MatchString(t) {
+		return "", ErrInvalidEventType
+	}
+	if contains(EventTypes, t) || t == OtherType {
+		return t, nil
+	}
+	if set := customEventTypes.Load(); set != nil && (set.any || set.names[t]) {
+		return t, nil
+	}
+	return OtherType, nil
+}
+
+// NormalizeEventType is ParseEventType for sources that can't be refused
+// (pixels, third-party webhooks): malformed types are OtherType too
+func NormalizeEventType(t string) string {
+	t, err := ParseEventType(t)
+	if err != nil {
+		return OtherType
+	}
+	return t
+}
+
+// NormalizeSourceType returns t if it's one of SourceTypes, or OtherType
+func NormalizeSourceType(t string) string {
+	if contains(SourceTypes, t) {
+		return t
+	}
+	return OtherType
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package analytics
+
+import "testing"
+
+func TestParseEventType(t *testing.T) {
+	if err := SetCustomEventTypes([]string{"Signup"}); err != nil {
+		t.Fatalf("SetCustomEventTypes() error = %v", err)
+	}
+	defer SetCustomEventTypes(nil)
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"pageview", "pageview", false},
+		{" Click ", "click", false},
+		{"signup", "signup", false},
+		{"pagevew", OtherType, false},
+		{"other", OtherType, false},
+		{"", "", true},
+		{"<script>", "", true},
+		{"has space", "", true},
+		{"-leading", "", true},
+		{string(make([]byte, 65)), "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseEventType(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseEventType(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if tt.wantErr && NormalizeEventType(tt.in) != OtherType {
+			t.Errorf("NormalizeEventType(%q) = %q, want %q", tt.in, NormalizeEventType(tt.in), OtherType)
+		}
+	}
+}
+
+func TestSetCustomEventTypes(t *testing.T) {
+	defer SetCustomEventTypes(nil)
+
+	if err := SetCustomEventTypes([]string{"ok", "not ok"}); err == nil {
+		t.Error("SetCustomEventTypes() accepted a malformed type")
+	}
+
+	SetCustomEventTypes([]string{"*"})
+	if got, _ := ParseEventType("issue.opened"); got != "issue.opened" {
+		t.Errorf("with \"*\", ParseEventType(issue.opened) = %q", got)
+	}
+	SetCustomEventTypes(nil)
+	if got, _ := ParseEventType("issue.opened"); got != OtherType {
+		t.Errorf("without custom types, ParseEventType(issue.opened) = %q, want %q", got, OtherType)
+	}
+}
+
+func TestNormalizeSourceType(t *testing.T) {
+	for in, want := range map[string]string{"web": "web", "hosting": "hosting", "Web": OtherType, "wbe": OtherType, "": OtherType} {
+		if got := NormalizeSourceType(in); got != want {
+			t.Errorf("NormalizeSourceType(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
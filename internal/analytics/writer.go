@@ -0,0 +1,227 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// Defaults for the background writer
+const (
+	DefaultBufferSize    = 10000
+	DefaultBatchSize     = 200
+	DefaultFlushInterval = time.Second
+)
+
+// Event is one row of the events table. Empty QueryParams and Country are stored as NULL.
+type Event struct {
+	Domain      string
+	Tags        string
+	SourceType  string
+	EventType   string
+	Path        string
+	Referrer    string
+	UserAgent   string
+	IPAddress   string
+	QueryParams string
+	Country     string
+}
+
+const insertEventSQL = `
+	INSERT INTO events (domain, tags, source_type, event_type, path, referrer, user_agent, ip_address, query_params, country)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// InsertEvents writes events in a single transaction. Unknown source and
+// event types are stored as OtherType.
+func InsertEvents(db *sql.DB, events []Event) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(insertEventSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range events {
+		// Handlers check types at ingest; this keeps anything else out of the stats
+		e.SourceType, e.EventType = NormalizeSourceType(e.SourceType), NormalizeEventType(e.EventType)
+		if _, err := stmt.Exec(e.Domain, e.Tags, e.SourceType, e.EventType, e.Path, e.Referrer,
+			e.UserAgent, e.IPAddress, nullIfEmpty(e.QueryParams), nullIfEmpty(e.Country)); err != nil {
+			return fmt.Errorf("failed to insert event: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// Writer buffers events and writes them in batches from a background goroutine,
+// whenever a batch fills or the flush interval passes. When the buffer is full,
+// events are dropped and counted rather than blocking the request.
+type Writer struct {
+	events    chan Event
+	flush     func([]Event) error
+	batchSize int
+	interval  time.Duration
+
+	dropped  atomic.Int64
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWriter starts a writer for db. Call Close to flush what's buffered.
+func NewWriter(db *sql.DB, bufferSize, batchSize int, interval time.Duration) *Writer {
+	return newWriter(func(batch []Event) error { return InsertEvents(db, batch) }, bufferSize, batchSize, interval)
+}
+
+// newWriter starts a writer that hands batches to flush; flush must not keep the slice
+func newWriter(flush func([]Event) error, bufferSize, batchSize int, interval time.Duration) *Writer {
+	w := &Writer{
+		events:    make(chan Event, bufferSize),
+		flush:     flush,
+		batchSize: batchSize,
+		interval:  interval,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Record queues e without blocking. It returns false if the buffer was full and e was dropped.
+func (w *Writer) Record(e Event) bool {
+	select {
+	case w.events <- e:
+		return true
+	default:
+		w.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns how many events were dropped because the buffer was full
+func (w *Writer) Dropped() int64 {
+	return w.dropped.Load()
+}
+
+// Close writes the buffered events and stops the writer, giving up when ctx is done
+func (w *Writer) Close(ctx context.Context) error {
+	w.stopOnce.Do(func() { close(w.stop) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, w.batchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.flush(batch); err != nil {
+			logging.Errorf("Failed to write %d analytics events: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	add := func(e Event) {
+		batch = append(batch, e)
+		if len(batch) >= w.batchSize {
+			write()
+		}
+	}
+
+	var reported int64
+	for {
+		select {
+		case e := <-w.events:
+			add(e)
+		case <-ticker.C:
+			write()
+			if dropped := w.Dropped(); dropped > reported {
+				logging.Warnf("Analytics buffer full: dropped %d events (%d total)", dropped-reported, dropped)
+				reported = dropped
+			}
+		case <-w.stop:
+			for {
+				select {
+				case e := <-w.events:
+					add(e)
+				default:
+					write()
+					return
+				}
+			}
+		}
+	}
+}
+
+// writer is the shared writer started by Start
+var writer *Writer
+
+// Start begins batching events recorded with Record into the shared database.
+// Call it before serving requests, and Stop on shutdown.
+func Start() {
+	writer = NewWriter(database.GetDB(), DefaultBufferSize, DefaultBatchSize, DefaultFlushInterval)
+}
+
+// Stop flushes buffered events and stops the shared writer
+func Stop(ctx context.Context) error {
+	if writer == nil {
+		return nil
+	}
+	err := writer.Close(ctx)
+	if dropped := writer.Dropped(); dropped > 0 {
+		logging.Warnf("Analytics writer dropped %d events since startup", dropped)
+	}
+	writer = nil
+	return err
+}
+
+// Record queues e for the shared writer. Without Start (tools, tests) it is
+// written immediately instead.
+func Record(e Event) {
+	if writer != nil {
+		writer.Record(e)
+		return
+	}
+	db := database.GetDB()
+	if db == nil {
+		return
+	}
+	if err := InsertEvents(db, []Event{e}); err != nil {
+		logging.Errorf("Failed to record %s event: %v", e.SourceType, err)
+	}
+}
+
+// Dropped returns how many events the shared writer has dropped
+func Dropped() int64 {
+	if writer == nil {
+		return 0
+	}
+	return writer.Dropped()
+}
@@ -0,0 +1,114 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects flushed batches
+type recorder struct {
+	mu      sync.Mutex
+	batches [][]Event
+}
+
+func (r *recorder) flush(batch []Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]Event(nil), batch...))
+	return nil
+}
+
+func (r *recorder) total() (events, batches int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.batches {
+		events += len(b)
+	}
+	return events, len(r.batches)
+}
+
+func TestWriter_FlushesFullBatches(t *testing.T) {
+	rec := &recorder{}
+	w := newWriter(rec.flush, 100, 10, time.Hour)
+
+	for i := 0; i < 25; i++ {
+		w.Record(Event{Domain: "example.com"})
+	}
+
+	// Two full batches go out without waiting for the interval
+	deadline := time.Now().Add(time.Second)
+	for {
+		if events, _ := rec.total(); events >= 20 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if events, batches := rec.total(); events != 20 || batches != 2 {
+		t.Errorf("before Close: %d events in %d batches, want 20 in 2", events, batches)
+	}
+
+	// Close writes the remainder
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if events, _ := rec.total(); events != 25 {
+		t.Errorf("after Close: %d events, want 25", events)
+	}
+}
+
+func TestWriter_FlushesOnInterval(t *testing.T) {
+	rec := &recorder{}
+	w := newWriter(rec.flush, 100, 50, 10*time.Millisecond)
+	defer w.Close(context.Background())
+
+	w.Record(Event{Domain: "example.com"})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if events, _ := rec.total(); events == 1 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("event not flushed by the interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWriter_DropsWhenFull(t *testing.T) {
+	block := make(chan struct{})
+	w := newWriter(func([]Event) error { <-block; return nil }, 2, 1, time.Hour)
+
+	// The first event is taken by the blocked flush; two more fill the buffer
+	w.Record(Event{})
+	time.Sleep(20 * time.Millisecond)
+	w.Record(Event{})
+	w.Record(Event{})
+
+	if w.Record(Event{}) {
+		t.Error("Record() should report a drop when the buffer is full")
+	}
+	if got := w.Dropped(); got != 1 {
+		t.Errorf("Dropped() = %d, want 1", got)
+	}
+
+	close(block)
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+}
+
+func TestWriter_CloseHonorsContext(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	w := newWriter(func([]Event) error { <-block; return nil }, 10, 1, time.Hour)
+	w.Record(Event{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := w.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Close() error = %v, want context.DeadlineExceeded", err)
+	}
+}
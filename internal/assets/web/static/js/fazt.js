@@ -0,0 +1,78 @@
+// fazt.sh analytics snippet. Load it with:
+//   <script async src="https://your-fazt-domain/track.js"></script>
+// Custom events: fazt('event', {name: 'signup', tags: ['beta'], query: {plan: 'pro'}})
+(function() {
+  'use strict';
+
+  var script = document.currentScript;
+  var origin = script && script.src ? new URL(script.src).origin : '';
+  var config = window.FAZT_CONFIG || {};
+  var domain = config.domain || window.location.hostname;
+  var tags = config.tags || [];
+  // Public tracking token, needed when the server requires one
+  var token = config.token || (script && script.getAttribute('data-token')) || '';
+
+  // Query values must be strings
+  function strings(obj) {
+    var out = {};
+    for (var key in obj || {}) {
+      if (Object.prototype.hasOwnProperty.call(obj, key)) {
+        out[key] = String(obj[key]);
+      }
+    }
+    return out;
+  }
+
+  function send(eventType, data) {
+    data = data || {};
+    var payload = {
+      h: domain,
+      p: data.path || window.location.pathname,
+      e: eventType,
+      t: tags.concat(data.tags || []),
+      ref: data.referrer !== undefined ? data.referrer : document.referrer,
+      q: strings(data.query),
+      k: token
+    };
+    var body = JSON.stringify(payload);
+
+    // sendBeacon posts text/plain, which /track accepts
+    if (navigator.sendBeacon && navigator.sendBeacon(origin + '/track', body)) {
+      return;
+    }
+    if (window.fetch) {
+      fetch(origin + '/track', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: body,
+        keepalive: true
+      }).catch(function() {});
+      return;
+    }
+    new Image().src = origin + '/pixel.gif?domain=' + encodeURIComponent(domain) +
+      '&source=' + encodeURIComponent(eventType) +
+      '&tags=' + encodeURIComponent(payload.t.join(',')) +
+      '&k=' + encodeURIComponent(token);
+  }
+
+  // fazt('event', {name: ..., path, tags, query, referrer}) or fazt('pageview')
+  function fazt(command, data) {
+    data = data || {};
+    if (command === 'event') {
+      send(data.name || 'event', data);
+    } else if (command === 'pageview') {
+      send('pageview', data);
+    }
+  }
+
+  // Calls queued before the script loaded: window.fazt = window.fazt || function() { (fazt.q = fazt.q || []).push(arguments) }
+  var queued = (window.fazt && window.fazt.q) || [];
+  window.fazt = fazt;
+
+  if (config.autoTrack !== false) {
+    send('pageview');
+  }
+  for (var i = 0; i < queued.length; i++) {
+    fazt.apply(null, queued[i]);
+  }
+})();
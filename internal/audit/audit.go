@@ -3,8 +3,10 @@ package audit
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"strings"
 	"time"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 var db *sql.DB
@@ -40,14 +42,25 @@ func Init(database *sql.DB) error {
 
 // LogEntry represents an audit log entry
 type LogEntry struct {
-	ID        int64
-	Timestamp time.Time
-	Username  string
-	IPAddress string
-	Action    string
-	Resource  string
-	Result    string
-	Details   string
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Username  string    `json:"username"`
+	IPAddress string    `json:"ip_address"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+	Result    string    `json:"result"`
+	Details   string    `json:"details"`
+}
+
+// QueryOptions filters audit log queries. Zero values are ignored.
+type QueryOptions struct {
+	Action   string
+	Username string
+	Result   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Offset   int
 }
 
 // Log writes an audit log entry
@@ -61,9 +74,18 @@ func Log(username, ipAddress, action, resource, result, details string) error {
 		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
+	logging.Event("audit",
+		"action", action,
+		"user", username,
+		"ip", ipAddress,
+		"resource", resource,
+		"result", result,
+		"details", details,
+	)
+
 	_, err := db.Exec(query, username, ipAddress, action, resource, result, details)
 	if err != nil {
-		log.Printf("Failed to write audit log: %v", err)
+		logging.Errorf("Failed to write audit log: %v", err)
 		return err
 	}
 
@@ -113,7 +135,7 @@ func GetRecent(limit int) ([]LogEntry, error) {
 			&entry.Details,
 		)
 		if err != nil {
-			log.Printf("Error scanning audit log: %v", err)
+			logging.Errorf("Error scanning audit log: %v", err)
 			continue
 		}
 		entries = append(entries, entry)
@@ -122,6 +144,88 @@ func GetRecent(limit int) ([]LogEntry, error) {
 	return entries, nil
 }
 
+// Query retrieves audit log entries matching opts, newest first,
+// along with the total number of matching entries
+func Query(opts QueryOptions) ([]LogEntry, int, error) {
+	if db == nil {
+		return nil, 0, fmt.Errorf("audit logging not initialized")
+	}
+
+	where := []string{"1=1"}
+	args := []interface{}{}
+
+	if opts.Action != "" {
+		where = append(where, "action = ?")
+		args = append(args, opts.Action)
+	}
+	if opts.Username != "" {
+		where = append(where, "username = ?")
+		args = append(args, opts.Username)
+	}
+	if opts.Result != "" {
+		where = append(where, "result = ?")
+		args = append(args, opts.Result)
+	}
+	if !opts.Since.IsZero() {
+		where = append(where, "timestamp >= ?")
+		args = append(args, opts.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if !opts.Until.IsZero() {
+		where = append(where, "timestamp < ?")
+		args = append(args, opts.Until.UTC().Format("2006-01-02 15:04:05"))
+	}
+
+	whereClause := strings.Join(where, " AND ")
+
+	var total int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_logs WHERE "+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, timestamp, COALESCE(username, ''), COALESCE(ip_address, ''), action,
+			COALESCE(resource, ''), COALESCE(result, ''), COALESCE(details, '')
+		FROM audit_logs
+		WHERE ` + whereClause + `
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+	args = append(args, limit, opts.Offset)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []LogEntry{}
+	for rows.Next() {
+		var entry LogEntry
+		err := rows.Scan(
+			&entry.ID,
+			&entry.Timestamp,
+			&entry.Username,
+			&entry.IPAddress,
+			&entry.Action,
+			&entry.Resource,
+			&entry.Result,
+			&entry.Details,
+		)
+		if err != nil {
+			logging.Errorf("Error scanning audit log: %v", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, total, nil
+}
+
 // Cleanup removes audit logs older than the specified number of days
 func Cleanup(daysToKeep int) error {
 	if db == nil {
@@ -137,7 +241,7 @@ func Cleanup(daysToKeep int) error {
 
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected > 0 {
-		log.Printf("Cleaned up %d old audit log entries", rowsAffected)
+		logging.Infof("Cleaned up %d old audit log entries", rowsAffected)
 	}
 
 	return nil
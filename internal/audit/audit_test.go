@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// setupAuditDB initializes audit logging on a temp database seeded with
+// entries an hour apart, starting at base
+func setupAuditDB(t *testing.T, base time.Time) {
+	t.Helper()
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		db = nil
+	})
+	if err := Init(conn); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	seed := []struct{ user, action, result string }{
+		{"admin", "login", "success"},
+		{"admin", "deploy", "success"},
+		{"", "login", "failure"},
+		{"ci", "deploy", "success"},
+		{"ci", "deploy", "failure"},
+		{"admin", "config_update", "success"},
+	}
+	for i, e := range seed {
+		ts := base.Add(time.Duration(i) * time.Hour).UTC().Format("2006-01-02 15:04:05")
+		_, err := conn.Exec(`INSERT INTO audit_logs (timestamp, username, ip_address, action, resource, result)
+			VALUES (?, ?, '203.0.113.1', ?, '/api', ?)`, ts, e.user, e.action, e.result)
+		if err != nil {
+			t.Fatalf("Failed to seed audit log: %v", err)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	setupAuditDB(t, base)
+
+	tests := []struct {
+		name      string
+		opts      QueryOptions
+		wantIDs   []int64
+		wantTotal int
+	}{
+		{"everything, newest first", QueryOptions{}, []int64{6, 5, 4, 3, 2, 1}, 6},
+		{"actor", QueryOptions{Username: "ci"}, []int64{5, 4}, 2},
+		{"action", QueryOptions{Action: "login"}, []int64{3, 1}, 2},
+		{"result", QueryOptions{Result: "failure"}, []int64{5, 3}, 2},
+		{"actor and action", QueryOptions{Username: "admin", Action: "deploy"}, []int64{2}, 1},
+		{"since is inclusive", QueryOptions{Since: base.Add(4 * time.Hour)}, []int64{6, 5}, 2},
+		{"until is exclusive", QueryOptions{Until: base.Add(2 * time.Hour)}, []int64{2, 1}, 2},
+		{"time range", QueryOptions{Since: base.Add(time.Hour), Until: base.Add(4 * time.Hour)}, []int64{4, 3, 2}, 3},
+		{"time range in another zone", QueryOptions{Since: base.Add(time.Hour).In(time.FixedZone("UTC+5", 5*3600))}, []int64{6, 5, 4, 3, 2}, 5},
+		{"first page", QueryOptions{Limit: 2}, []int64{6, 5}, 6},
+		{"second page", QueryOptions{Limit: 2, Offset: 2}, []int64{4, 3}, 6},
+		{"past the end", QueryOptions{Limit: 2, Offset: 6}, []int64{}, 6},
+		{"filtered page", QueryOptions{Action: "deploy", Limit: 1, Offset: 1}, []int64{4}, 3},
+		{"no match", QueryOptions{Username: "nobody"}, []int64{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, total, err := Query(tt.opts)
+			if err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total = %d, want %d", total, tt.wantTotal)
+			}
+			ids := []int64{}
+			for _, e := range entries {
+				ids = append(ids, e.ID)
+			}
+			if len(ids) != len(tt.wantIDs) {
+				t.Fatalf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Fatalf("ids = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestQuery_Entry(t *testing.T) {
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	setupAuditDB(t, base)
+
+	entries, _, err := Query(QueryOptions{Action: "login", Result: "failure"})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Query = %v, %v; want one entry", entries, err)
+	}
+	e := entries[0]
+	if e.Username != "" || e.IPAddress != "203.0.113.1" || e.Resource != "/api" || !e.Timestamp.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestQuery_NotInitialized(t *testing.T) {
+	if _, _, err := Query(QueryOptions{}); err == nil {
+		t.Error("Query without Init should fail")
+	}
+}
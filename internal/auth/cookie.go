@@ -1,53 +1,108 @@
 package auth
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	// SessionCookieName is the name of the session cookie
+	// SessionCookieName is the default name of the session cookie
 	SessionCookieName = "cc_session"
 
 	// SessionTTL is the default session time-to-live (24 hours)
 	SessionTTL = 24 * time.Hour
 
-	// RememberMeTTL is the extended session time for "remember me" (7 days)
-	RememberMeTTL = 7 * 24 * time.Hour
+	// RememberMeTTL is the extended session time for "remember me" (30 days)
+	RememberMeTTL = 30 * 24 * time.Hour
+
+	// SessionMaxLifetime is the default absolute session lifetime (7 days), however active
+	SessionMaxLifetime = 7 * 24 * time.Hour
 )
 
-// SetSessionCookie sets a secure session cookie
-func SetSessionCookie(w http.ResponseWriter, sessionID string, ttl time.Duration, isProduction bool) {
-	cookie := &http.Cookie{
+// CookieOptions controls the attributes of the session cookie
+type CookieOptions struct {
+	Name     string
+	Path     string
+	Domain   string // empty for a host-only cookie
+	SameSite http.SameSite
+	Secure   bool // HTTPS only
+}
+
+// DefaultCookieOptions returns strict, HttpOnly cookie settings that are Secure in production
+func DefaultCookieOptions(isProduction bool) CookieOptions {
+	return CookieOptions{
 		Name:     SessionCookieName,
-		Value:    sessionID,
 		Path:     "/",
-		MaxAge:   int(ttl.Seconds()),
-		HttpOnly: true,         // Prevent JavaScript access
-		Secure:   isProduction, // HTTPS only in production
-		SameSite: 3,            // SameSiteStrictMode (3) for CSRF protection
+		SameSite: http.SameSiteStrictMode, // CSRF protection
+		Secure:   isProduction,
 	}
+}
+
+var (
+	cookieOptions   = DefaultCookieOptions(false)
+	cookieOptionsMu sync.RWMutex
+)
 
-	http.SetCookie(w, cookie)
+// SetCookieOptions sets the attributes used for the session cookie
+func SetCookieOptions(opts CookieOptions) {
+	cookieOptionsMu.Lock()
+	cookieOptions = opts
+	cookieOptionsMu.Unlock()
 }
 
-// ClearSessionCookie removes the session cookie
-func ClearSessionCookie(w http.ResponseWriter) {
-	cookie := &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1, // Delete cookie
-		HttpOnly: true,
-		SameSite: 3, // SameSiteStrictMode (3)
+// GetCookieOptions returns the attributes used for the session cookie
+func GetCookieOptions() CookieOptions {
+	cookieOptionsMu.RLock()
+	defer cookieOptionsMu.RUnlock()
+	return cookieOptions
+}
+
+// ParseSameSite parses "strict", "lax" or "none"; "" means strict
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "", "strict":
+		return http.SameSiteStrictMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("invalid SameSite mode: %s (must be 'strict', 'lax' or 'none')", s)
+	}
+}
+
+// newSessionCookie builds the session cookie with the configured attributes
+func newSessionCookie(value string, maxAge int) *http.Cookie {
+	opts := GetCookieOptions()
+	return &http.Cookie{
+		Name:     opts.Name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   maxAge,
+		HttpOnly: true, // Prevent JavaScript access
+		Secure:   opts.Secure,
+		SameSite: opts.SameSite,
 	}
+}
+
+// SetSessionCookie sets a secure session cookie.
+// A zero ttl sets a browser-session cookie that is dropped when the browser closes.
+func SetSessionCookie(w http.ResponseWriter, sessionID string, ttl time.Duration) {
+	http.SetCookie(w, newSessionCookie(sessionID, int(ttl.Seconds())))
+}
 
-	http.SetCookie(w, cookie)
+// ClearSessionCookie removes the session cookie
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, newSessionCookie("", -1))
 }
 
 // GetSessionCookie retrieves the session cookie from a request
 func GetSessionCookie(r *http.Request) (string, error) {
-	cookie, err := r.Cookie(SessionCookieName)
+	cookie, err := r.Cookie(GetCookieOptions().Name)
 	if err != nil {
 		return "", err
 	}
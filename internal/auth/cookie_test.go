@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSetSessionCookie_Persistent(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetSessionCookie(w, "abc", RememberMeTTL)
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	if cookies[0].MaxAge != int(RememberMeTTL.Seconds()) {
+		t.Errorf("MaxAge = %d, want %d", cookies[0].MaxAge, int(RememberMeTTL.Seconds()))
+	}
+}
+
+func TestSetSessionCookie_BrowserSession(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetSessionCookie(w, "abc", 0)
+
+	header := w.Header().Get("Set-Cookie")
+	if header == "" {
+		t.Fatal("no Set-Cookie header")
+	}
+	cookie := w.Result().Cookies()[0]
+	if cookie.MaxAge != 0 || !cookie.Expires.IsZero() {
+		t.Errorf("cookie should have no Max-Age or Expires, got %q", header)
+	}
+}
+
+func TestCookieOptions(t *testing.T) {
+	defer SetCookieOptions(GetCookieOptions())
+
+	opts := DefaultCookieOptions(true)
+	if !opts.Secure || opts.SameSite != http.SameSiteStrictMode || opts.Name != SessionCookieName || opts.Path != "/" {
+		t.Errorf("DefaultCookieOptions(true) = %+v", opts)
+	}
+	if DefaultCookieOptions(false).Secure {
+		t.Error("cookies should not be Secure by default outside production")
+	}
+
+	if DefaultCookieOptions(true).Domain != "" {
+		t.Error("cookies should be host-only by default")
+	}
+
+	SetCookieOptions(CookieOptions{Name: "fazt_admin", Path: "/admin", Domain: ".example.com", SameSite: http.SameSiteLaxMode, Secure: true})
+
+	w := httptest.NewRecorder()
+	SetSessionCookie(w, "abc", 0)
+	c := w.Result().Cookies()[0]
+	if c.Name != "fazt_admin" || c.Path != "/admin" || c.Domain != "example.com" || c.SameSite != http.SameSiteLaxMode || !c.Secure || !c.HttpOnly {
+		t.Errorf("cookie = %+v, want configured attributes", c)
+	}
+
+	// Clearing uses the same name, path and domain so the browser drops the right cookie
+	w = httptest.NewRecorder()
+	ClearSessionCookie(w)
+	c = w.Result().Cookies()[0]
+	if c.Name != "fazt_admin" || c.Path != "/admin" || c.Domain != "example.com" || c.MaxAge != -1 {
+		t.Errorf("cleared cookie = %+v", c)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "fazt_admin", Value: "session-id"})
+	if got, err := GetSessionCookie(r); err != nil || got != "session-id" {
+		t.Errorf("GetSessionCookie = %q, %v", got, err)
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	tests := map[string]http.SameSite{
+		"":       http.SameSiteStrictMode,
+		"strict": http.SameSiteStrictMode,
+		"Lax":    http.SameSiteLaxMode,
+		"none":   http.SameSiteNoneMode,
+	}
+	for input, want := range tests {
+		if got, err := ParseSameSite(input); err != nil || got != want {
+			t.Errorf("ParseSameSite(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseSameSite("loose"); err == nil {
+		t.Error("ParseSameSite should reject unknown modes")
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/jikku/command-center/internal/analytics"
+)
+
+// FingerprintMode controls what happens when a session is used from a different client
+type FingerprintMode string
+
+const (
+	// FingerprintOff ignores fingerprints
+	FingerprintOff FingerprintMode = "off"
+
+	// FingerprintLog logs mismatches but keeps the session
+	FingerprintLog FingerprintMode = "log"
+
+	// FingerprintEnforce invalidates the session on mismatch
+	FingerprintEnforce FingerprintMode = "enforce"
+)
+
+// ParseFingerprintMode parses a configured mode; "" means off
+func ParseFingerprintMode(s string) (FingerprintMode, error) {
+	switch mode := FingerprintMode(s); mode {
+	case "":
+		return FingerprintOff, nil
+	case FingerprintOff, FingerprintLog, FingerprintEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid session fingerprint mode: %s (must be 'off', 'log' or 'enforce')", s)
+	}
+}
+
+// Fingerprint returns a coarse client fingerprint: the IP's subnet (/24 for IPv4,
+// /48 for IPv6) plus a hash of the user agent. It tolerates address changes within
+// a network but not a cookie replayed from elsewhere.
+func Fingerprint(ip, userAgent string) string {
+	subnet := ip
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if v4 := parsed.To4(); v4 != nil {
+			subnet = v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+		} else {
+			subnet = parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+		}
+	}
+
+	sum := sha256.Sum256([]byte(userAgent))
+	return subnet + "|" + hex.EncodeToString(sum[:8])
+}
+
+// RequestFingerprint returns the Fingerprint of a request's client. Forwarding
+// headers only count from a trusted proxy, so a replayed cookie can't claim the
+// victim's network.
+func RequestFingerprint(r *http.Request) string {
+	return Fingerprint(analytics.RemoteIP(r), r.UserAgent())
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Supported hash algorithms
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrHashMismatch is returned when a secret does not match its hash
+var ErrHashMismatch = errors.New("hash mismatch")
+
+// HashParams controls how new passwords are hashed.
+// Existing hashes are always verified with the parameters encoded in them.
+type HashParams struct {
+	Algorithm     string
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32 // KiB
+	Argon2Threads uint8
+}
+
+// DefaultHashParams returns bcrypt at BcryptCost and the RFC 9106 recommended argon2id settings
+func DefaultHashParams() HashParams {
+	return HashParams{
+		Algorithm:     AlgorithmBcrypt,
+		BcryptCost:    BcryptCost,
+		Argon2Time:    1,
+		Argon2Memory:  64 * 1024,
+		Argon2Threads: 4,
+	}
+}
+
+var (
+	hashParams   = DefaultHashParams()
+	hashParamsMu sync.RWMutex
+)
+
+// SetHashParams sets the parameters for new hashes. Zero fields keep their defaults.
+func SetHashParams(p HashParams) error {
+	defaults := DefaultHashParams()
+	if p.Algorithm == "" {
+		p.Algorithm = defaults.Algorithm
+	}
+	if p.BcryptCost == 0 {
+		p.BcryptCost = defaults.BcryptCost
+	}
+	if p.Argon2Time == 0 {
+		p.Argon2Time = defaults.Argon2Time
+	}
+	if p.Argon2Memory == 0 {
+		p.Argon2Memory = defaults.Argon2Memory
+	}
+	if p.Argon2Threads == 0 {
+		p.Argon2Threads = defaults.Argon2Threads
+	}
+
+	if p.Algorithm != AlgorithmBcrypt && p.Algorithm != AlgorithmArgon2id {
+		return fmt.Errorf("unknown hash algorithm: %s (must be '%s' or '%s')", p.Algorithm, AlgorithmBcrypt, AlgorithmArgon2id)
+	}
+	if p.BcryptCost < bcrypt.MinCost || p.BcryptCost > bcrypt.MaxCost {
+		return fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	hashParamsMu.Lock()
+	hashParams = p
+	hashParamsMu.Unlock()
+	return nil
+}
+
+// GetHashParams returns the parameters used for new hashes
+func GetHashParams() HashParams {
+	hashParamsMu.RLock()
+	defer hashParamsMu.RUnlock()
+	return hashParams
+}
+
+// Hash hashes a secret with the configured algorithm
+func Hash(secret string) (string, error) {
+	p := GetHashParams()
+
+	if p.Algorithm == AlgorithmArgon2id {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(secret), salt, p.Argon2Time, p.Argon2Memory, p.Argon2Threads, 32)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Argon2Memory, p.Argon2Time, p.Argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), p.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// VerifyHash checks a secret against a bcrypt or argon2id hash.
+// It returns ErrHashMismatch if the secret is wrong.
+func VerifyHash(secret, hash string) error {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		return verifyArgon2id(secret, hash)
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrHashMismatch
+	}
+	return err
+}
+
+// tokenHashPrefix marks SHA-256 hashes made by HashToken
+const tokenHashPrefix = "$sha256$"
+
+// HashToken hashes a randomly generated token such as an API key secret.
+// Unlike a password it has too much entropy to guess, so a slow KDF buys
+// nothing and SHA-256 is used regardless of the configured algorithm.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return tokenHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// VerifyToken checks a token against a HashToken hash, or against the bcrypt
+// or argon2id hash of a token issued before tokens were hashed with SHA-256.
+// It returns ErrHashMismatch if the token is wrong.
+func VerifyToken(token, hash string) error {
+	if !strings.HasPrefix(hash, tokenHashPrefix) {
+		return VerifyHash(token, hash)
+	}
+	if subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hash)) != 1 {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// verifyArgon2id checks a secret against a PHC-formatted argon2id hash
+func verifyArgon2id(secret, hash string) error {
+	// $argon2id$v=19$m=65536,t=1,p=4$<salt>$<key>
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return errors.New("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return errors.New("unsupported argon2id version")
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return errors.New("malformed argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return errors.New("malformed argon2id salt")
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return errors.New("malformed argon2id key")
+	}
+
+	got := argon2.IDKey([]byte(secret), salt, time, memory, threads, uint32(len(want)))
+	if subtle.ConstantTimeCompare(got, want) != 1 {
+		return ErrHashMismatch
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// useHashParams sets p for the duration of the test
+func useHashParams(t *testing.T, p HashParams) {
+	t.Helper()
+	prev := GetHashParams()
+	if err := SetHashParams(p); err != nil {
+		t.Fatalf("SetHashParams failed: %v", err)
+	}
+	t.Cleanup(func() { SetHashParams(prev) })
+}
+
+func TestHash_Bcrypt(t *testing.T) {
+	useHashParams(t, HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: 5})
+
+	hash, err := Hash("secret-value")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$2a$05$") {
+		t.Errorf("hash = %q, want bcrypt cost 5", hash)
+	}
+
+	if err := VerifyHash("secret-value", hash); err != nil {
+		t.Errorf("VerifyHash failed for correct secret: %v", err)
+	}
+	if err := VerifyHash("wrong", hash); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("VerifyHash error = %v, want ErrHashMismatch", err)
+	}
+}
+
+func TestHash_Argon2id(t *testing.T) {
+	useHashParams(t, HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024, Argon2Time: 2, Argon2Threads: 1})
+
+	hash, err := Hash("secret-value")
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=2,p=1$") {
+		t.Errorf("hash = %q, want argon2id with configured parameters", hash)
+	}
+
+	if err := VerifyHash("secret-value", hash); err != nil {
+		t.Errorf("VerifyHash failed for correct secret: %v", err)
+	}
+	if err := VerifyHash("wrong", hash); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("VerifyHash error = %v, want ErrHashMismatch", err)
+	}
+
+	// Salts are random
+	other, _ := Hash("secret-value")
+	if other == hash {
+		t.Error("two hashes of the same secret should differ")
+	}
+}
+
+func TestVerifyHash_MixedFormats(t *testing.T) {
+	// A bcrypt hash created before switching to argon2id still verifies
+	useHashParams(t, HashParams{Algorithm: AlgorithmBcrypt, BcryptCost: 4})
+	bcryptHash, _ := Hash("password123")
+
+	useHashParams(t, HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024})
+	argonHash, _ := Hash("password123")
+
+	for _, hash := range []string{bcryptHash, argonHash} {
+		if err := VerifyPassword("password123", hash); err != nil {
+			t.Errorf("VerifyPassword(%q) failed: %v", hash[:10], err)
+		}
+		if err := VerifyPassword("password124", hash); err == nil || err.Error() != "invalid password" {
+			t.Errorf("VerifyPassword with wrong password error = %v, want invalid password", err)
+		}
+	}
+}
+
+func TestVerifyHash_Malformed(t *testing.T) {
+	tests := []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$onlysalt",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+		"$argon2id$v=19$garbage$c2FsdA$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+		"not-a-hash",
+	}
+
+	for _, hash := range tests {
+		err := VerifyHash("secret", hash)
+		if err == nil || errors.Is(err, ErrHashMismatch) {
+			t.Errorf("VerifyHash(%q) error = %v, want a format error", hash, err)
+		}
+	}
+}
+
+func TestSetHashParams(t *testing.T) {
+	useHashParams(t, HashParams{})
+
+	if err := SetHashParams(HashParams{Algorithm: "md5"}); err == nil {
+		t.Error("SetHashParams should reject unknown algorithms")
+	}
+	if err := SetHashParams(HashParams{BcryptCost: 3}); err == nil {
+		t.Error("SetHashParams should reject bcrypt cost below the minimum")
+	}
+
+	// Zero values fall back to defaults
+	if err := SetHashParams(HashParams{Algorithm: AlgorithmArgon2id}); err != nil {
+		t.Fatalf("SetHashParams failed: %v", err)
+	}
+	p := GetHashParams()
+	d := DefaultHashParams()
+	if p.BcryptCost != d.BcryptCost || p.Argon2Memory != d.Argon2Memory || p.Argon2Threads != d.Argon2Threads {
+		t.Errorf("params = %+v, want defaults for unset fields", p)
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	// Tokens ignore the configured password algorithm
+	useHashParams(t, HashParams{Algorithm: AlgorithmArgon2id, Argon2Memory: 1024})
+
+	hash := HashToken("fazt_abc123_secret")
+	if !strings.HasPrefix(hash, "$sha256$") || hash != HashToken("fazt_abc123_secret") {
+		t.Errorf("hash = %q, want a deterministic $sha256$ hash", hash)
+	}
+	if err := VerifyToken("fazt_abc123_secret", hash); err != nil {
+		t.Errorf("VerifyToken failed for correct token: %v", err)
+	}
+	if err := VerifyToken("fazt_abc123_wrong", hash); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("VerifyToken error = %v, want ErrHashMismatch", err)
+	}
+
+	// Tokens hashed with the password KDF before the switch still verify
+	kdfHash, _ := Hash("fazt_abc123_secret")
+	if err := VerifyToken("fazt_abc123_secret", kdfHash); err != nil {
+		t.Errorf("VerifyToken failed for an argon2id hash: %v", err)
+	}
+}
@@ -3,8 +3,6 @@ package auth
 import (
 	"errors"
 	"fmt"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
@@ -15,17 +13,13 @@ const (
 	MinPasswordLength = 8
 )
 
-// HashPassword hashes a password using bcrypt
+// HashPassword hashes a password using the configured algorithm (see SetHashParams)
 func HashPassword(password string) (string, error) {
-	if password == "" {
-		return "", errors.New("password cannot be empty")
-	}
-
-	if len(password) < MinPasswordLength {
-		return "", fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	if err := ValidatePassword(password); err != nil {
+		return "", err
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), BcryptCost)
+	hash, err := Hash(password)
 	if err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -33,7 +27,20 @@ func HashPassword(password string) (string, error) {
 	return string(hash), nil
 }
 
-// VerifyPassword compares a password with a hash
+// ValidatePassword checks a new password is acceptable, without hashing it
+func ValidatePassword(password string) error {
+	if password == "" {
+		return errors.New("password cannot be empty")
+	}
+
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+
+	return nil
+}
+
+// VerifyPassword compares a password with a bcrypt or argon2id hash
 func VerifyPassword(password, hash string) error {
 	if password == "" {
 		return errors.New("password cannot be empty")
@@ -43,9 +50,9 @@ func VerifyPassword(password, hash string) error {
 		return errors.New("hash cannot be empty")
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	err := VerifyHash(password, hash)
 	if err != nil {
-		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		if errors.Is(err, ErrHashMismatch) {
 			return errors.New("invalid password")
 		}
 		return fmt.Errorf("failed to verify password: %w", err)
@@ -5,22 +5,52 @@ import (
 	"time"
 )
 
-// RateLimiter tracks failed login attempts by IP address
+const (
+	// MaxLoginAttempts is the number of failed logins allowed per IP per window
+	MaxLoginAttempts = 5
+
+	// LoginLockoutWindow is how long failed attempts count against an IP
+	LoginLockoutWindow = 15 * time.Minute
+
+	// MaxAccountLoginAttempts is the number of failed logins allowed per username per window,
+	// across all IPs. Higher than the IP limit so one attacker can't lock the admin out as fast.
+	MaxAccountLoginAttempts = 10
+
+	// AccountLockoutWindow is how long failed attempts count against a username
+	AccountLockoutWindow = 15 * time.Minute
+)
+
+// RateLimiter tracks failed login attempts by key (an IP address or a username)
 type RateLimiter struct {
-	attempts map[string]*loginAttempts
-	mu       sync.RWMutex
+	attempts    map[string]*loginAttempts
+	mu          sync.RWMutex
+	maxAttempts int
+	window      time.Duration
 }
 
 type loginAttempts struct {
 	count      int
 	firstAttempt time.Time
 	lastAttempt  time.Time
+	alerted    bool // lockout already reported for this window
 }
 
-// NewRateLimiter creates a new rate limiter
+// NewRateLimiter creates a per-IP login rate limiter
 func NewRateLimiter() *RateLimiter {
+	return NewRateLimiterWithLimits(MaxLoginAttempts, LoginLockoutWindow)
+}
+
+// NewAccountRateLimiter creates a per-username login rate limiter
+func NewAccountRateLimiter() *RateLimiter {
+	return NewRateLimiterWithLimits(MaxAccountLoginAttempts, AccountLockoutWindow)
+}
+
+// NewRateLimiterWithLimits creates a rate limiter allowing maxAttempts failures per window
+func NewRateLimiterWithLimits(maxAttempts int, window time.Duration) *RateLimiter {
 	limiter := &RateLimiter{
-		attempts: make(map[string]*loginAttempts),
+		attempts:    make(map[string]*loginAttempts),
+		maxAttempts: maxAttempts,
+		window:      window,
 	}
 
 	// Start cleanup goroutine
@@ -33,20 +63,34 @@ func NewRateLimiter() *RateLimiter {
 func (rl *RateLimiter) AllowLogin(ip string) bool {
 	rl.mu.RLock()
 	attempts, exists := rl.attempts[ip]
+	maxAttempts := rl.maxAttempts
 	rl.mu.RUnlock()
 
 	if !exists {
 		return true
 	}
 
-	// Reset if more than 15 minutes have passed
-	if time.Since(attempts.firstAttempt) > 15*time.Minute {
+	// Reset if the window has passed
+	if time.Since(attempts.firstAttempt) > rl.window {
 		rl.Reset(ip)
 		return true
 	}
 
-	// Allow if less than 5 attempts
-	return attempts.count < 5
+	return attempts.count < maxAttempts
+}
+
+// SetMaxAttempts changes how many failures are allowed per window; it applies immediately
+func (rl *RateLimiter) SetMaxAttempts(n int) {
+	rl.mu.Lock()
+	rl.maxAttempts = n
+	rl.mu.Unlock()
+}
+
+// MaxAttempts returns how many failures are allowed per window
+func (rl *RateLimiter) MaxAttempts() int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+	return rl.maxAttempts
 }
 
 // RecordAttempt records a failed login attempt
@@ -64,10 +108,11 @@ func (rl *RateLimiter) RecordAttempt(ip string) {
 		return
 	}
 
-	// Reset if more than 15 minutes have passed
-	if time.Since(attempts.firstAttempt) > 15*time.Minute {
+	// Reset if the window has passed
+	if time.Since(attempts.firstAttempt) > rl.window {
 		attempts.count = 1
 		attempts.firstAttempt = time.Now()
+		attempts.alerted = false
 	} else {
 		attempts.count++
 	}
@@ -82,6 +127,59 @@ func (rl *RateLimiter) Reset(ip string) {
 	rl.mu.Unlock()
 }
 
+// MarkLockoutAlerted reports whether ip is locked out and its lockout has not
+// been reported yet in the current window. It returns true at most once per window.
+func (rl *RateLimiter) MarkLockoutAlerted(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	attempts, exists := rl.attempts[ip]
+	if !exists || attempts.count < rl.maxAttempts || attempts.alerted {
+		return false
+	}
+	if time.Since(attempts.firstAttempt) > rl.window {
+		return false
+	}
+
+	attempts.alerted = true
+	return true
+}
+
+// Remaining returns how many more failed attempts a key may make before lockout
+func (rl *RateLimiter) Remaining(ip string) int {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	attempts, exists := rl.attempts[ip]
+	if !exists || time.Since(attempts.firstAttempt) > rl.window {
+		return rl.maxAttempts
+	}
+	if attempts.count >= rl.maxAttempts {
+		return 0
+	}
+	return rl.maxAttempts - attempts.count
+}
+
+// RetryAfter returns how long until a locked-out key may try again, or 0 if it isn't locked out
+func (rl *RateLimiter) RetryAfter(ip string) time.Duration {
+	rl.mu.RLock()
+	defer rl.mu.RUnlock()
+
+	attempts, exists := rl.attempts[ip]
+	if !exists || attempts.count < rl.maxAttempts {
+		return 0
+	}
+	if wait := rl.window - time.Since(attempts.firstAttempt); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Window returns how long failed attempts count against a key
+func (rl *RateLimiter) Window() time.Duration {
+	return rl.window
+}
+
 // GetAttempts returns the number of attempts for an IP
 func (rl *RateLimiter) GetAttempts(ip string) int {
 	rl.mu.RLock()
@@ -103,7 +201,7 @@ func (rl *RateLimiter) cleanup() {
 	for range ticker.C {
 		rl.mu.Lock()
 		for ip, attempts := range rl.attempts {
-			if time.Since(attempts.firstAttempt) > 15*time.Minute {
+			if time.Since(attempts.firstAttempt) > rl.window {
 				delete(rl.attempts, ip)
 			}
 		}
@@ -161,6 +259,13 @@ func (dl *DeployLimiter) RecordDeploy(ip string) {
 	dl.deploys[ip] = append(dl.deploys[ip], time.Now())
 }
 
+// Reset clears all recorded deploys
+func (dl *DeployLimiter) Reset() {
+	dl.mu.Lock()
+	dl.deploys = make(map[string][]time.Time)
+	dl.mu.Unlock()
+}
+
 // cleanup removes old entries periodically
 func (dl *DeployLimiter) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
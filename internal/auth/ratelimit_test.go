@@ -108,3 +108,109 @@ func TestDeployLimiter_SlidingWindow(t *testing.T) {
 		t.Error("Should be allowed after old deploys expired")
 	}
 }
+
+func TestRateLimiter_MarkLockoutAlerted(t *testing.T) {
+	limiter := NewRateLimiter()
+	ip := "192.168.1.1"
+
+	// Not locked out yet
+	for i := 0; i < MaxLoginAttempts-1; i++ {
+		limiter.RecordAttempt(ip)
+	}
+	if limiter.MarkLockoutAlerted(ip) {
+		t.Error("Should not alert before lockout")
+	}
+
+	// Lockout alerts once per window
+	limiter.RecordAttempt(ip)
+	if !limiter.MarkLockoutAlerted(ip) {
+		t.Error("Should alert on lockout")
+	}
+	limiter.RecordAttempt(ip)
+	if limiter.MarkLockoutAlerted(ip) {
+		t.Error("Should not alert twice in the same window")
+	}
+
+	// A new window alerts again
+	limiter.Reset(ip)
+	for i := 0; i < MaxLoginAttempts; i++ {
+		limiter.RecordAttempt(ip)
+	}
+	if !limiter.MarkLockoutAlerted(ip) {
+		t.Error("Should alert again after reset")
+	}
+}
+
+func TestAccountRateLimiter(t *testing.T) {
+	limiter := NewAccountRateLimiter()
+	if limiter.Window() != AccountLockoutWindow {
+		t.Errorf("Window() = %v, want %v", limiter.Window(), AccountLockoutWindow)
+	}
+
+	// Failures from many IPs all count against the one username
+	for i := 0; i < MaxAccountLoginAttempts-1; i++ {
+		limiter.RecordAttempt("admin")
+	}
+	if !limiter.AllowLogin("admin") {
+		t.Error("Account should be allowed below the limit")
+	}
+
+	limiter.RecordAttempt("admin")
+	if limiter.AllowLogin("admin") {
+		t.Error("Account should be locked at the limit")
+	}
+	if !limiter.AllowLogin("someone-else") {
+		t.Error("Other accounts should not be affected")
+	}
+
+	limiter.Reset("admin")
+	if !limiter.AllowLogin("admin") {
+		t.Error("Account should be allowed after reset")
+	}
+}
+
+func TestRateLimiterWithLimits_WindowExpiry(t *testing.T) {
+	limiter := NewRateLimiterWithLimits(2, 50*time.Millisecond)
+
+	limiter.RecordAttempt("admin")
+	limiter.RecordAttempt("admin")
+	if limiter.AllowLogin("admin") {
+		t.Error("Should be locked after 2 attempts")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !limiter.AllowLogin("admin") {
+		t.Error("Lockout should expire after the window")
+	}
+}
+
+func TestRateLimiter_RemainingAndRetryAfter(t *testing.T) {
+	limiter := NewRateLimiter()
+	ip := "192.168.1.1"
+
+	if got := limiter.Remaining(ip); got != MaxLoginAttempts {
+		t.Errorf("Remaining() = %d, want %d", got, MaxLoginAttempts)
+	}
+	if got := limiter.RetryAfter(ip); got != 0 {
+		t.Errorf("RetryAfter() = %v, want 0 for unknown IP", got)
+	}
+
+	limiter.RecordAttempt(ip)
+	limiter.RecordAttempt(ip)
+	if got := limiter.Remaining(ip); got != MaxLoginAttempts-2 {
+		t.Errorf("Remaining() = %d, want %d", got, MaxLoginAttempts-2)
+	}
+	if got := limiter.RetryAfter(ip); got != 0 {
+		t.Errorf("RetryAfter() = %v, want 0 before lockout", got)
+	}
+
+	for i := 0; i < MaxLoginAttempts; i++ {
+		limiter.RecordAttempt(ip)
+	}
+	if got := limiter.Remaining(ip); got != 0 {
+		t.Errorf("Remaining() = %d, want 0 when locked out", got)
+	}
+	if got := limiter.RetryAfter(ip); got <= LoginLockoutWindow-time.Minute || got > LoginLockoutWindow {
+		t.Errorf("RetryAfter() = %v, want about %v", got, LoginLockoutWindow)
+	}
+}
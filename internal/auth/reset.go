@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ResetTokenTTL is how long a password reset token stays valid
+const ResetTokenTTL = 15 * time.Minute
+
+// ErrInvalidResetToken is returned for unknown, expired or already used reset tokens
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// resetTimeFormat matches SQLite's CURRENT_TIMESTAMP so stored times compare as text
+const resetTimeFormat = "2006-01-02 15:04:05"
+
+// CreateResetToken stores a new single-use password reset token and returns it with its expiry.
+// Only the token's SHA-256 is stored.
+func CreateResetToken(db *sql.DB, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	token := hex.EncodeToString(b)
+	expiresAt := time.Now().UTC().Add(ttl)
+
+	_, err := db.Exec(
+		"INSERT INTO password_reset_tokens (token_hash, expires_at) VALUES (?, ?)",
+		hashResetToken(token), expiresAt.Format(resetTimeFormat),
+	)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to store reset token: %w", err)
+	}
+
+	return token, expiresAt, nil
+}
+
+// ConsumeResetToken marks a reset token as used.
+// It returns ErrInvalidResetToken if the token is unknown, expired or already used.
+func ConsumeResetToken(db *sql.DB, token string) error {
+	return RedeemResetToken(db, token, func() error { return nil })
+}
+
+// RedeemResetToken marks a reset token as used and runs apply while holding
+// it. The token is only spent if apply succeeds; otherwise apply's error is
+// returned and the token can be tried again. It returns ErrInvalidResetToken
+// if the token is unknown, expired or already used, without calling apply.
+func RedeemResetToken(db *sql.DB, token string, apply func() error) error {
+	if token == "" {
+		return ErrInvalidResetToken
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to redeem reset token: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Single UPDATE so two concurrent requests can't both redeem the token
+	result, err := tx.Exec(
+		"UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		hashResetToken(token), time.Now().UTC().Format(resetTimeFormat),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to redeem reset token: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n != 1 {
+		return ErrInvalidResetToken
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to redeem reset token: %w", err)
+	}
+	return nil
+}
+
+// hashResetToken returns the hex SHA-256 of a reset token
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func setupResetDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`
+		CREATE TABLE password_reset_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return db
+}
+
+func TestResetToken_SingleUse(t *testing.T) {
+	db := setupResetDB(t)
+
+	token, expiresAt, err := CreateResetToken(db, ResetTokenTTL)
+	if err != nil {
+		t.Fatalf("CreateResetToken failed: %v", err)
+	}
+	if time.Until(expiresAt) > ResetTokenTTL || time.Until(expiresAt) < ResetTokenTTL-time.Minute {
+		t.Errorf("expiresAt = %v, want about %v from now", expiresAt, ResetTokenTTL)
+	}
+
+	// Raw token is never stored
+	var stored string
+	db.QueryRow("SELECT token_hash FROM password_reset_tokens").Scan(&stored)
+	if stored == token {
+		t.Error("reset token should be stored hashed")
+	}
+
+	if err := ConsumeResetToken(db, token); err != nil {
+		t.Fatalf("ConsumeResetToken failed: %v", err)
+	}
+	if err := ConsumeResetToken(db, token); !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("second ConsumeResetToken error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestRedeemResetToken_FailedApply(t *testing.T) {
+	db := setupResetDB(t)
+
+	token, _, err := CreateResetToken(db, ResetTokenTTL)
+	if err != nil {
+		t.Fatalf("CreateResetToken failed: %v", err)
+	}
+
+	// A failure while the token is held leaves it unspent
+	saveErr := errors.New("disk full")
+	if err := RedeemResetToken(db, token, func() error { return saveErr }); !errors.Is(err, saveErr) {
+		t.Fatalf("RedeemResetToken error = %v, want the apply error", err)
+	}
+
+	applied := false
+	if err := RedeemResetToken(db, token, func() error { applied = true; return nil }); err != nil || !applied {
+		t.Fatalf("retry: err = %v, applied = %v", err, applied)
+	}
+
+	// Spent tokens never reach apply
+	err = RedeemResetToken(db, token, func() error {
+		t.Error("apply called for a spent token")
+		return nil
+	})
+	if !errors.Is(err, ErrInvalidResetToken) {
+		t.Errorf("third RedeemResetToken error = %v, want ErrInvalidResetToken", err)
+	}
+}
+
+func TestResetToken_Invalid(t *testing.T) {
+	db := setupResetDB(t)
+
+	expired, _, err := CreateResetToken(db, -time.Minute)
+	if err != nil {
+		t.Fatalf("CreateResetToken failed: %v", err)
+	}
+
+	for _, token := range []string{expired, "unknown", ""} {
+		if err := ConsumeResetToken(db, token); !errors.Is(err, ErrInvalidResetToken) {
+			t.Errorf("ConsumeResetToken(%q) error = %v, want ErrInvalidResetToken", token, err)
+		}
+	}
+}
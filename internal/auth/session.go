@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // Session represents an authenticated user session
@@ -16,6 +18,15 @@ type Session struct {
 	CreatedAt time.Time
 	ExpiresAt time.Time
 	LastSeen  time.Time
+
+	// AbsoluteExpiresAt caps ExpiresAt no matter how often the session is refreshed
+	AbsoluteExpiresAt time.Time
+
+	// TTL is the idle timeout each refresh extends the session by
+	TTL time.Duration
+
+	// Fingerprint of the client that created the session (see Fingerprint)
+	Fingerprint string
 }
 
 // SessionStore manages active sessions
@@ -24,6 +35,9 @@ type SessionStore struct {
 	mu       sync.RWMutex
 	ttl      time.Duration
 	stopChan chan struct{}
+
+	maxLifetime     time.Duration
+	fingerprintMode FingerprintMode
 }
 
 // NewSessionStore creates a new session store
@@ -32,6 +46,9 @@ func NewSessionStore(ttl time.Duration) *SessionStore {
 		sessions: make(map[string]*Session),
 		ttl:      ttl,
 		stopChan: make(chan struct{}),
+
+		maxLifetime:     SessionMaxLifetime,
+		fingerprintMode: FingerprintOff,
 	}
 
 	// Start cleanup goroutine
@@ -40,8 +57,42 @@ func NewSessionStore(ttl time.Duration) *SessionStore {
 	return store
 }
 
+// SetMaxLifetime sets the absolute lifetime of new sessions, regardless of activity
+func (s *SessionStore) SetMaxLifetime(d time.Duration) {
+	s.mu.Lock()
+	s.maxLifetime = d
+	s.mu.Unlock()
+}
+
+// TTL returns the idle timeout of the store
+func (s *SessionStore) TTL() time.Duration {
+	return s.ttl
+}
+
+// SetFingerprintMode sets how ValidateSessionFingerprint treats a client mismatch
+func (s *SessionStore) SetFingerprintMode(mode FingerprintMode) {
+	s.mu.Lock()
+	s.fingerprintMode = mode
+	s.mu.Unlock()
+}
+
+// SessionOptions customizes a new session
+type SessionOptions struct {
+	// TTL overrides the store's idle timeout, e.g. for "remember me" sessions
+	TTL time.Duration
+
+	// Fingerprint binds the session to a client (see Fingerprint)
+	Fingerprint string
+}
+
 // CreateSession creates a new session for a user
 func (s *SessionStore) CreateSession(username string) (string, error) {
+	return s.CreateSessionWithOptions(username, SessionOptions{})
+}
+
+// CreateSessionWithOptions creates a new session for a user.
+// A TTL longer than the store's max lifetime also extends the absolute cap.
+func (s *SessionStore) CreateSessionWithOptions(username string, opts SessionOptions) (string, error) {
 	if username == "" {
 		return "", errors.New("username cannot be empty")
 	}
@@ -57,19 +108,38 @@ func (s *SessionStore) CreateSession(username string) (string, error) {
 		ID:        sessionID,
 		Username:  username,
 		CreatedAt: now,
-		ExpiresAt: now.Add(s.ttl),
 		LastSeen:  now,
+
+		Fingerprint: opts.Fingerprint,
 	}
 
 	s.mu.Lock()
+	session.TTL = s.ttl
+	if opts.TTL > 0 {
+		session.TTL = opts.TTL
+	}
+	lifetime := s.maxLifetime
+	if session.TTL > lifetime {
+		lifetime = session.TTL
+	}
+	session.AbsoluteExpiresAt = now.Add(lifetime)
+	session.ExpiresAt = session.expiry(now)
 	s.sessions[sessionID] = session
 	s.mu.Unlock()
 
+	logging.Debugf("Session created for %s (expires %s)", username, session.ExpiresAt.Format(time.RFC3339))
 	return sessionID, nil
 }
 
 // ValidateSession checks if a session is valid and not expired
 func (s *SessionStore) ValidateSession(sessionID string) (bool, error) {
+	return s.ValidateSessionFingerprint(sessionID, "")
+}
+
+// ValidateSessionFingerprint checks a session and compares the caller's fingerprint with
+// the one captured at login. In enforce mode a mismatch deletes the session; in log mode
+// it is logged and the new fingerprint is remembered. An empty fingerprint skips the check.
+func (s *SessionStore) ValidateSessionFingerprint(sessionID, fingerprint string) (bool, error) {
 	if sessionID == "" {
 		return false, errors.New("session ID cannot be empty")
 	}
@@ -89,12 +159,49 @@ func (s *SessionStore) ValidateSession(sessionID string) (bool, error) {
 		return false, nil
 	}
 
+	if !s.checkFingerprint(session, fingerprint) {
+		s.DeleteSession(sessionID)
+		return false, nil
+	}
+
 	// Session is valid, refresh it
 	s.RefreshSession(sessionID)
 
 	return true, nil
 }
 
+// checkFingerprint reports whether a session may be used by a client with fingerprint
+func (s *SessionStore) checkFingerprint(session *Session, fingerprint string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fingerprintMode == FingerprintOff || fingerprint == "" || session.Fingerprint == "" {
+		return true
+	}
+	if session.Fingerprint == fingerprint {
+		return true
+	}
+
+	if s.fingerprintMode == FingerprintEnforce {
+		logging.Warnf("Session fingerprint mismatch for %s: invalidating session", session.Username)
+		return false
+	}
+
+	logging.Debugf("Session fingerprint changed for %s: %s -> %s", session.Username, session.Fingerprint, fingerprint)
+	session.Fingerprint = fingerprint
+	return true
+}
+
+// MatchesFingerprint reports whether a session would pass the fingerprint check,
+// without logging or modifying it
+func (s *SessionStore) MatchesFingerprint(session *Session, fingerprint string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fingerprintMode != FingerprintEnforce || fingerprint == "" ||
+		session.Fingerprint == "" || session.Fingerprint == fingerprint
+}
+
 // GetSession retrieves a session by ID
 func (s *SessionStore) GetSession(sessionID string) (*Session, error) {
 	if sessionID == "" {
@@ -134,11 +241,20 @@ func (s *SessionStore) RefreshSession(sessionID string) error {
 
 	now := time.Now()
 	session.LastSeen = now
-	session.ExpiresAt = now.Add(s.ttl)
+	session.ExpiresAt = session.expiry(now)
 
 	return nil
 }
 
+// expiry returns the sliding expiry for a session used at now, capped at its absolute expiry
+func (session *Session) expiry(now time.Time) time.Time {
+	expiresAt := now.Add(session.TTL)
+	if expiresAt.After(session.AbsoluteExpiresAt) {
+		return session.AbsoluteExpiresAt
+	}
+	return expiresAt
+}
+
 // DeleteSession removes a session from the store
 func (s *SessionStore) DeleteSession(sessionID string) {
 	if sessionID == "" {
@@ -146,8 +262,13 @@ func (s *SessionStore) DeleteSession(sessionID string) {
 	}
 
 	s.mu.Lock()
+	_, existed := s.sessions[sessionID]
 	delete(s.sessions, sessionID)
 	s.mu.Unlock()
+
+	if existed {
+		logging.Debugf("Session deleted")
+	}
 }
 
 // DeleteUserSessions removes all sessions for a specific user
@@ -167,6 +288,7 @@ func (s *SessionStore) DeleteUserSessions(username string) int {
 		}
 	}
 
+	logging.Debugf("Deleted %d sessions for %s", count, username)
 	return count
 }
 
@@ -211,11 +333,17 @@ func (s *SessionStore) cleanup() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	expired := 0
 	for id, session := range s.sessions {
 		if now.After(session.ExpiresAt) {
 			delete(s.sessions, id)
+			expired++
 		}
 	}
+
+	if expired > 0 {
+		logging.Debugf("Removed %d expired sessions (%d active)", expired, len(s.sessions))
+	}
 }
 
 // Stop stops the cleanup goroutine
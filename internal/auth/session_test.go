@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -328,3 +329,181 @@ func TestMultipleSessions(t *testing.T) {
 		seen[id] = true
 	}
 }
+
+func TestFingerprint(t *testing.T) {
+	ua := "Mozilla/5.0 (X11; Linux x86_64)"
+	base := Fingerprint("203.0.113.10", ua)
+
+	if Fingerprint("203.0.113.200", ua) != base {
+		t.Error("addresses in the same /24 should share a fingerprint")
+	}
+	if Fingerprint("198.51.100.10", ua) == base {
+		t.Error("a different subnet should change the fingerprint")
+	}
+	if Fingerprint("203.0.113.10", "curl/8.0") == base {
+		t.Error("a different user agent should change the fingerprint")
+	}
+	if Fingerprint("2001:db8:1:2::1", ua) != Fingerprint("2001:db8:1:ffff::9", ua) {
+		t.Error("IPv6 addresses in the same /48 should share a fingerprint")
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "203.0.113.10:51234"
+	r.Header.Set("User-Agent", "test-agent")
+
+	if got, want := RequestFingerprint(r), Fingerprint("203.0.113.10", "test-agent"); got != want {
+		t.Errorf("RequestFingerprint = %q, want %q", got, want)
+	}
+
+	// A client can't pick its network with a forged header
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	r.Header.Set("X-Real-IP", "198.51.100.7")
+	if got, want := RequestFingerprint(r), Fingerprint("203.0.113.10", "test-agent"); got != want {
+		t.Errorf("RequestFingerprint with a forged X-Forwarded-For = %q, want %q", got, want)
+	}
+
+	// Behind a trusted proxy the forwarded client counts
+	r.RemoteAddr = "127.0.0.1:51234"
+	if got, want := RequestFingerprint(r), Fingerprint("198.51.100.7", "test-agent"); got != want {
+		t.Errorf("RequestFingerprint behind a proxy = %q, want %q", got, want)
+	}
+}
+
+func TestValidateSessionFingerprint_Enforce(t *testing.T) {
+	store := NewSessionStore(time.Hour)
+	defer store.Stop()
+	store.SetFingerprintMode(FingerprintEnforce)
+
+	home := Fingerprint("203.0.113.10", "browser")
+	sessionID, err := store.CreateSessionWithOptions("admin", SessionOptions{Fingerprint: home})
+	if err != nil {
+		t.Fatalf("CreateSessionWithOptions() error: %v", err)
+	}
+
+	// Same subnet and browser is accepted
+	if valid, _ := store.ValidateSessionFingerprint(sessionID, Fingerprint("203.0.113.99", "browser")); !valid {
+		t.Fatal("session should be valid from the same subnet")
+	}
+
+	// Stolen cookie replayed from elsewhere invalidates the session
+	if valid, _ := store.ValidateSessionFingerprint(sessionID, Fingerprint("198.51.100.10", "browser")); valid {
+		t.Fatal("session should be rejected from a different subnet")
+	}
+
+	// ...including for the legitimate client afterwards
+	if valid, _ := store.ValidateSessionFingerprint(sessionID, home); valid {
+		t.Error("session should be deleted after a mismatch")
+	}
+	if store.Count() != 0 {
+		t.Errorf("Store should have 0 sessions, got %d", store.Count())
+	}
+}
+
+func TestValidateSessionFingerprint_Enforce_UserAgent(t *testing.T) {
+	store := NewSessionStore(time.Hour)
+	defer store.Stop()
+	store.SetFingerprintMode(FingerprintEnforce)
+
+	sessionID, _ := store.CreateSessionWithOptions("admin", SessionOptions{Fingerprint: Fingerprint("203.0.113.10", "browser")})
+	if valid, _ := store.ValidateSessionFingerprint(sessionID, Fingerprint("203.0.113.10", "other-browser")); valid {
+		t.Error("session should be rejected for a different user agent")
+	}
+}
+
+func TestValidateSessionFingerprint_LogAndOff(t *testing.T) {
+	for _, mode := range []FingerprintMode{FingerprintLog, FingerprintOff} {
+		store := NewSessionStore(time.Hour)
+		store.SetFingerprintMode(mode)
+
+		sessionID, _ := store.CreateSessionWithOptions("admin", SessionOptions{Fingerprint: Fingerprint("203.0.113.10", "browser")})
+		if valid, _ := store.ValidateSessionFingerprint(sessionID, Fingerprint("198.51.100.10", "browser")); !valid {
+			t.Errorf("mode %s: session should stay valid on mismatch", mode)
+		}
+		store.Stop()
+	}
+}
+
+func TestParseFingerprintMode(t *testing.T) {
+	tests := map[string]FingerprintMode{
+		"":        FingerprintOff,
+		"off":     FingerprintOff,
+		"log":     FingerprintLog,
+		"enforce": FingerprintEnforce,
+	}
+	for input, want := range tests {
+		got, err := ParseFingerprintMode(input)
+		if err != nil || got != want {
+			t.Errorf("ParseFingerprintMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	if _, err := ParseFingerprintMode("strict"); err == nil {
+		t.Error("ParseFingerprintMode should reject unknown modes")
+	}
+}
+
+func TestSessionMaxLifetime(t *testing.T) {
+	store := NewSessionStore(time.Hour)
+	defer store.Stop()
+	store.SetMaxLifetime(90 * time.Minute)
+
+	sessionID, _ := store.CreateSession("admin")
+	session, _ := store.GetSession(sessionID)
+	if !session.AbsoluteExpiresAt.Equal(session.CreatedAt.Add(90 * time.Minute)) {
+		t.Errorf("AbsoluteExpiresAt = %v, want CreatedAt + 90m", session.AbsoluteExpiresAt)
+	}
+
+	// Refreshing never extends past the absolute cap
+	session.AbsoluteExpiresAt = time.Now().Add(10 * time.Minute)
+	store.RefreshSession(sessionID)
+	if session.ExpiresAt.After(session.AbsoluteExpiresAt) {
+		t.Errorf("ExpiresAt %v is past AbsoluteExpiresAt %v", session.ExpiresAt, session.AbsoluteExpiresAt)
+	}
+
+	// A recently active session past its cap is rejected
+	session.AbsoluteExpiresAt = time.Now().Add(-time.Second)
+	session.LastSeen = time.Now()
+	store.RefreshSession(sessionID)
+	if valid, _ := store.ValidateSession(sessionID); valid {
+		t.Error("session past its absolute lifetime should be invalid")
+	}
+}
+
+func TestSessionMaxLifetime_Default(t *testing.T) {
+	store := NewSessionStore(time.Hour)
+	defer store.Stop()
+
+	sessionID, _ := store.CreateSession("admin")
+	session, _ := store.GetSession(sessionID)
+	if got := session.AbsoluteExpiresAt.Sub(session.CreatedAt); got != SessionMaxLifetime {
+		t.Errorf("default absolute lifetime = %v, want %v", got, SessionMaxLifetime)
+	}
+}
+
+func TestCreateSessionWithOptions_TTL(t *testing.T) {
+	store := NewSessionStore(time.Hour)
+	defer store.Stop()
+	store.SetMaxLifetime(24 * time.Hour)
+
+	// Default sessions use the store TTL
+	shortID, _ := store.CreateSession("admin")
+	short, _ := store.GetSession(shortID)
+	if got := short.ExpiresAt.Sub(short.CreatedAt); got != time.Hour {
+		t.Errorf("default session TTL = %v, want 1h", got)
+	}
+
+	// Remember-me sessions outlive both the idle TTL and the default cap
+	longID, _ := store.CreateSessionWithOptions("admin", SessionOptions{TTL: RememberMeTTL})
+	long, _ := store.GetSession(longID)
+	if got := long.ExpiresAt.Sub(long.CreatedAt); got != RememberMeTTL {
+		t.Errorf("remember-me session TTL = %v, want %v", got, RememberMeTTL)
+	}
+
+	// Refresh keeps the per-session TTL
+	store.RefreshSession(longID)
+	if time.Until(long.ExpiresAt) < RememberMeTTL-time.Minute {
+		t.Errorf("refreshed remember-me session expires in %v, want about %v", time.Until(long.ExpiresAt), RememberMeTTL)
+	}
+}
@@ -6,10 +6,15 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // Config holds all configuration for the application
@@ -20,6 +25,18 @@ type Config struct {
 	Ntfy NtfyConfig     `json:"ntfy"`
 	APIKey APIKeyConfig `json:"api_key,omitempty"`
 	HTTPS  HTTPSConfig  `json:"https"`
+
+	Hosting HostingConfig `json:"hosting,omitempty"`
+
+	Analytics AnalyticsConfig `json:"analytics,omitempty"`
+
+	Privacy PrivacyConfig `json:"privacy,omitempty"`
+
+	Webhooks WebhooksConfig `json:"webhooks,omitempty"`
+
+	RateLimit RateLimitConfig `json:"rate_limit,omitempty"`
+
+	Security SecurityConfig `json:"security,omitempty"`
 }
 
 // ServerConfig holds server-specific configuration
@@ -27,24 +44,423 @@ type ServerConfig struct {
 	Port   string `json:"port"`
 	Domain string `json:"domain"`
 	Env    string `json:"env"` // development/production
+
+	LogFormat string `json:"log_format,omitempty"` // "text" (default) or "json"
+
+	// LogFile, if set, also receives every log message as JSON lines (see `fazt server logs`)
+	LogFile string `json:"log_file,omitempty"`
+
+	// WSDrainTimeout is how long WebSocket clients get to disconnect on shutdown, e.g. "5s"
+	WSDrainTimeout string `json:"ws_drain_timeout,omitempty"`
+
+	// RequestTimeout cancels a request's context after this long, e.g. "10s"; "0s" disables it
+	RequestTimeout string `json:"request_timeout,omitempty"`
+
+	// Connection timeouts of the HTTP server, e.g. "15s"; "0s" disables one.
+	// Uploads get UploadTimeout instead, and streams aren't limited.
+	ReadTimeout   string `json:"read_timeout,omitempty"`
+	WriteTimeout  string `json:"write_timeout,omitempty"`
+	IdleTimeout   string `json:"idle_timeout,omitempty"`
+	UploadTimeout string `json:"upload_timeout,omitempty"`
+
+	// TrustedProxies are the IPs or CIDR ranges whose X-Forwarded-For is believed
+	// for rate limits and audit records; empty means loopback only
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+
+	MockData *bool `json:"mock_data,omitempty"` // default true in development
+}
+
+// ShutdownTimeout bounds the whole graceful shutdown, including the WebSocket drain
+const ShutdownTimeout = 30 * time.Second
+
+// DefaultWSDrainTimeout is used when server.ws_drain_timeout is unset
+const DefaultWSDrainTimeout = 5 * time.Second
+
+// WSDrainDuration returns the configured WebSocket drain window
+func (s ServerConfig) WSDrainDuration() time.Duration {
+	if s.WSDrainTimeout == "" {
+		return DefaultWSDrainTimeout
+	}
+	d, _ := time.ParseDuration(s.WSDrainTimeout)
+	return d
+}
+
+// DefaultRequestTimeout is used when server.request_timeout is unset
+const DefaultRequestTimeout = 10 * time.Second
+
+// RequestTimeoutDuration returns how long a request may run before it is cancelled
+func (s ServerConfig) RequestTimeoutDuration() time.Duration {
+	if s.RequestTimeout == "" {
+		return DefaultRequestTimeout
+	}
+	d, _ := time.ParseDuration(s.RequestTimeout)
+	return d
+}
+
+// Defaults for the HTTP server's connection timeouts
+const (
+	DefaultReadTimeout   = 15 * time.Second
+	DefaultWriteTimeout  = 15 * time.Second
+	DefaultIdleTimeout   = 60 * time.Second
+	DefaultUploadTimeout = 10 * time.Minute
+)
+
+// HTTPTimeouts returns the configured read, write, idle and upload timeouts
+func (s ServerConfig) HTTPTimeouts() (read, write, idle, upload time.Duration) {
+	return durationOr(s.ReadTimeout, DefaultReadTimeout),
+		durationOr(s.WriteTimeout, DefaultWriteTimeout),
+		durationOr(s.IdleTimeout, DefaultIdleTimeout),
+		durationOr(s.UploadTimeout, DefaultUploadTimeout)
+}
+
+// durationOr parses a validated duration setting, or returns def when it's unset
+func durationOr(v string, def time.Duration) time.Duration {
+	if v == "" {
+		return def
+	}
+	d, _ := time.ParseDuration(v)
+	return d
 }
 
 // HTTPSConfig holds automatic HTTPS configuration
 type HTTPSConfig struct {
-	Enabled bool   `json:"enabled"`
-	Email   string `json:"email"` // ACME contact email
-	Staging bool   `json:"staging"` // Use Let's Encrypt Staging
+	Enabled  bool   `json:"enabled"`
+	Email    string `json:"email"`     // ACME contact email
+	Staging  bool   `json:"staging"`   // Use Let's Encrypt Staging
+	HTTPPort int    `json:"http_port"` // Plain HTTP listener for redirects and ACME challenges
+
+	ExpiryAlertDays int `json:"expiry_alert_days,omitempty"` // Alert when a stored cert expires this soon, default 14
+}
+
+// DefaultHTTPPort is used when https.http_port is unset
+const DefaultHTTPPort = 80
+
+// HTTPListenPort returns the port of the plain HTTP listener run alongside HTTPS
+func (h HTTPSConfig) HTTPListenPort() int {
+	if h.HTTPPort == 0 {
+		return DefaultHTTPPort
+	}
+	return h.HTTPPort
+}
+
+// DefaultExpiryAlertDays is used when https.expiry_alert_days is unset. CertMagic
+// renews Let's Encrypt certs with about 30 days left, so a cert inside this window
+// has missed several renewal attempts.
+const DefaultExpiryAlertDays = 14
+
+// ExpiryAlertWindow returns how close to expiry a stored certificate triggers an alert
+func (h HTTPSConfig) ExpiryAlertWindow() time.Duration {
+	days := h.ExpiryAlertDays
+	if days == 0 {
+		days = DefaultExpiryAlertDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// HostingConfig holds hosted site storage settings (zero values use defaults)
+type HostingConfig struct {
+	GzipLevel    int   `json:"gzip_level,omitempty"`     // 1-9, default 5
+	GzipMinBytes int64 `json:"gzip_min_bytes,omitempty"` // files smaller than this are stored raw, default 1024
+
+	MaxConcurrentVMs int   `json:"max_concurrent_vms,omitempty"`  // simultaneous serverless executions, default 32
+	MaxVMMemoryBytes int64 `json:"max_vm_memory_bytes,omitempty"` // heap growth allowed per script, default 50MB
+
+	// Outbound fetch() limits for serverless scripts; 0 means the default
+	FetchMaxRequests   int   `json:"fetch_max_requests,omitempty"`    // calls per invocation, default 20
+	FetchMaxBytes      int64 `json:"fetch_max_bytes,omitempty"`       // bytes downloaded per invocation, default 5MB
+	FetchRatePerMinute int   `json:"fetch_rate_per_minute,omitempty"` // calls per site per minute, default 300
+
+	// Largest deploy ZIP or site file upload; 0 means the default (100MB)
+	MaxDeployBytes int64 `json:"max_deploy_bytes,omitempty"`
+
+	// Storage limits reported by /api/hosting/usage; 0 means none
+	SiteQuotaBytes  int64 `json:"site_quota_bytes,omitempty"`
+	MaxStorageBytes int64 `json:"max_storage_bytes,omitempty"`
+
+	// Extra site names refused at deploy ("localhost" always is)
+	ReservedSubdomains *[]string `json:"reserved_subdomains,omitempty"`
+
+	// Serverless capabilities ("db", "fetch", "socket", "process") turned off for
+	// every site, and per site ID
+	DisabledCapabilities     []string            `json:"disabled_capabilities,omitempty"`
+	SiteDisabledCapabilities map[string][]string `json:"site_disabled_capabilities,omitempty"`
+}
+
+// DefaultMaxDeployBytes is the largest deploy accepted when hosting.max_deploy_bytes is unset
+const DefaultMaxDeployBytes = 100 << 20
+
+// Validate checks the hosting settings are in range
+func (h HostingConfig) Validate() error {
+	if h.GzipLevel < 0 || h.GzipLevel > 9 {
+		return fmt.Errorf("invalid hosting gzip_level: %d (must be 1-9, or 0 for the default)", h.GzipLevel)
+	}
+	if h.GzipMinBytes < 0 {
+		return fmt.Errorf("invalid hosting gzip_min_bytes: %d (must not be negative)", h.GzipMinBytes)
+	}
+	if h.MaxConcurrentVMs < 0 || h.MaxConcurrentVMs > 10000 {
+		return fmt.Errorf("invalid hosting max_concurrent_vms: %d (must be 1-10000, or 0 for the default)", h.MaxConcurrentVMs)
+	}
+	if h.MaxVMMemoryBytes < 0 {
+		return fmt.Errorf("invalid hosting max_vm_memory_bytes: %d (must not be negative)", h.MaxVMMemoryBytes)
+	}
+	if h.FetchMaxRequests < 0 || h.FetchMaxBytes < 0 || h.FetchRatePerMinute < 0 {
+		return errors.New("invalid hosting fetch limits: fetch_max_requests, fetch_max_bytes and fetch_rate_per_minute must not be negative")
+	}
+	if h.MaxDeployBytes != 0 && (h.MaxDeployBytes < 1<<20 || h.MaxDeployBytes > 10<<30) {
+		return fmt.Errorf("invalid hosting max_deploy_bytes: %d (must be 1MB-10GB, or 0 for the default)", h.MaxDeployBytes)
+	}
+	if h.SiteQuotaBytes < 0 || h.MaxStorageBytes < 0 {
+		return errors.New("invalid hosting storage limits: site_quota_bytes and max_storage_bytes must not be negative")
+	}
+	if h.ReservedSubdomains != nil {
+		for _, name := range *h.ReservedSubdomains {
+			if strings.TrimSpace(name) == "" {
+				return errors.New("invalid hosting reserved_subdomains: entries must not be empty")
+			}
+		}
+	}
+	return nil
 }
 
 // DatabaseConfig holds database configuration
 type DatabaseConfig struct {
 	Path string `json:"path"`
+
+	// If the database can't be opened at startup, retry this many times with
+	// exponential backoff from StartupRetryDelay (default 1s), then exit or,
+	// with DegradedStart, serve a maintenance page while retrying in the background
+	StartupRetries    int    `json:"startup_retries,omitempty"`
+	StartupRetryDelay string `json:"startup_retry_delay,omitempty"`
+	DegradedStart     bool   `json:"degraded_start,omitempty"`
+}
+
+// DefaultStartupRetryDelay is the first backoff between database startup attempts
+const DefaultStartupRetryDelay = time.Second
+
+// StartupRetryDelayDuration returns the first backoff between startup attempts
+func (d DatabaseConfig) StartupRetryDelayDuration() time.Duration {
+	if v, err := time.ParseDuration(d.StartupRetryDelay); err == nil && v > 0 {
+		return v
+	}
+	return DefaultStartupRetryDelay
+}
+
+// Validate checks the database startup settings
+func (d DatabaseConfig) Validate() error {
+	if d.StartupRetries < 0 || d.StartupRetries > 100 {
+		return fmt.Errorf("invalid database startup_retries: %d (must be 0-100)", d.StartupRetries)
+	}
+	if v := d.StartupRetryDelay; v != "" {
+		if delay, err := time.ParseDuration(v); err != nil || delay <= 0 {
+			return fmt.Errorf("invalid database startup_retry_delay: %s (must be a positive duration such as 2s)", v)
+		}
+	}
+	return nil
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	Username     string `json:"username"`
-	PasswordHash string `json:"password_hash"` // bcrypt hash
+	PasswordHash string `json:"password_hash"` // bcrypt or argon2id hash
+
+	// Hashing for new passwords; existing hashes keep working
+	HashAlgorithm string       `json:"hash_algorithm,omitempty"` // "bcrypt" (default) or "argon2id"
+	BcryptCost    int          `json:"bcrypt_cost,omitempty"`    // 4-31, default 12
+	Argon2        Argon2Config `json:"argon2,omitempty"`
+
+	// Bind sessions to the client's subnet and user agent: "off" (default), "log" or "enforce"
+	SessionFingerprint string `json:"session_fingerprint,omitempty"`
+
+	// Session lifetimes as Go durations: idle timeout (default "24h") and
+	// absolute cap however active the session is (default "168h")
+	SessionTTL         string `json:"session_ttl,omitempty"`
+	SessionMaxLifetime string `json:"session_max_lifetime,omitempty"`
+
+	// Idle timeout for "remember me" logins (default "720h")
+	RememberMeTTL string `json:"remember_me_ttl,omitempty"`
+
+	Cookie CookieConfig `json:"cookie,omitempty"`
+}
+
+// CookieConfig holds session cookie attributes (zero values use defaults)
+type CookieConfig struct {
+	Name     string `json:"name,omitempty"`      // default "cc_session"
+	Path     string `json:"path,omitempty"`      // default "/"
+	SameSite string `json:"same_site,omitempty"` // "strict" (default), "lax" or "none"
+	Domain   string `json:"domain,omitempty"`    // e.g. ".example.com" to share with subdomains; default host-only
+	Secure   *bool  `json:"secure,omitempty"`    // default true in production
+}
+
+// SecureCookie reports whether the session cookie should be HTTPS-only
+func (c *Config) SecureCookie() bool {
+	if c.Auth.Cookie.Secure != nil {
+		return *c.Auth.Cookie.Secure
+	}
+	return c.IsProduction()
+}
+
+// SiteBaseURL returns the scheme and host hosted sites are subdomains of,
+// e.g. "https://example.com". With HTTPS enabled that's https on the default
+// port; otherwise server.domain's scheme (http if it has none) and port are
+// kept, and a bare domain gets server.port.
+func (c *Config) SiteBaseURL() string {
+	scheme, host := "http", c.Server.Domain
+	if i := strings.Index(host, "://"); i >= 0 {
+		scheme, host = strings.ToLower(host[:i]), host[i+3:]
+	} else if c.Server.Port != "" && c.Server.Port != "80" && !strings.Contains(host, ":") {
+		host = net.JoinHostPort(host, c.Server.Port)
+	}
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+	if c.HTTPS.Enabled {
+		scheme = "https"
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	return scheme + "://" + host
+}
+
+// MockDataEnabled reports whether an empty database gets mock data at startup
+func (c *Config) MockDataEnabled() bool {
+	if c.Server.MockData != nil {
+		return *c.Server.MockData
+	}
+	return c.IsDevelopment()
+}
+
+// SessionDurations returns the configured idle TTL and absolute lifetime; zero means unset
+func (a AuthConfig) SessionDurations() (ttl, maxLifetime time.Duration) {
+	ttl, _ = time.ParseDuration(a.SessionTTL)
+	maxLifetime, _ = time.ParseDuration(a.SessionMaxLifetime)
+	return ttl, maxLifetime
+}
+
+// RememberMeDuration returns the configured "remember me" TTL; zero means unset
+func (a AuthConfig) RememberMeDuration() time.Duration {
+	d, _ := time.ParseDuration(a.RememberMeTTL)
+	return d
+}
+
+// Argon2Config holds argon2id parameters (zero values use defaults)
+type Argon2Config struct {
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory_kib,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// AnalyticsConfig holds event ingestion settings
+type AnalyticsConfig struct {
+	// RequireToken makes /track and /pixel.gif accept only events carrying a
+	// tracking token issued for the event's domain (see /api/tracking-tokens)
+	RequireToken bool `json:"require_token,omitempty"`
+
+	// EventTypes are accepted as well as the built-in event types; others are
+	// recorded as "other". "*" accepts any well-formed type.
+	EventTypes []string `json:"event_types,omitempty"`
+}
+
+// PrivacyConfig holds visitor data settings
+type PrivacyConfig struct {
+	// AnonymizeIP truncates visitor IPs before events are stored: the last octet
+	// of IPv4 and the last 80 bits of IPv6 are zeroed
+	AnonymizeIP bool `json:"anonymize_ip,omitempty"`
+}
+
+// WebhooksConfig holds incoming webhook settings
+type WebhooksConfig struct {
+	// ReplayTolerance turns on replay protection for signed webhooks, e.g. "5m":
+	// the X-Webhook-Timestamp header must be signed and within this long of now
+	ReplayTolerance string `json:"replay_tolerance,omitempty"`
+}
+
+// ReplayToleranceDuration returns the replay window, or 0 when protection is off
+func (w WebhooksConfig) ReplayToleranceDuration() time.Duration {
+	d, _ := time.ParseDuration(w.ReplayTolerance)
+	return d
+}
+
+// Validate checks the webhook settings
+func (w WebhooksConfig) Validate() error {
+	if v := w.ReplayTolerance; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("invalid webhooks replay_tolerance: %s (must be a duration such as 5m, or 0s to disable)", v)
+		}
+	}
+	return nil
+}
+
+// DefaultDashboardCSP is the dashboard's Content-Security-Policy. Its templates use
+// inline scripts and load libraries from jsDelivr.
+const DefaultDashboardCSP = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline' 'unsafe-eval' https://cdn.jsdelivr.net; " +
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
+	"img-src 'self' data: https:; " +
+	"font-src 'self' data: https://cdn.jsdelivr.net; " +
+	"connect-src 'self'"
+
+// DefaultSiteCSP is the Content-Security-Policy for hosted sites: same-origin
+// resources only, inline code allowed (common in static sites), no eval or plugins
+const DefaultSiteCSP = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline'; " +
+	"style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data: https:; " +
+	"font-src 'self' data:; " +
+	"connect-src 'self'; " +
+	"object-src 'none'; " +
+	"base-uri 'self'"
+
+// SecurityConfig holds HTTP security header settings
+type SecurityConfig struct {
+	DashboardCSP string  `json:"dashboard_csp,omitempty"` // default DefaultDashboardCSP
+	SiteCSP      *string `json:"site_csp,omitempty"`      // hosted sites; default DefaultSiteCSP, "" sends none
+
+	// SiteHeaders adds or overrides response headers for hosted sites, e.g.
+	// {"X-Frame-Options": "SAMEORIGIN"}; an empty value removes a default header
+	SiteHeaders map[string]string `json:"site_headers,omitempty"`
+}
+
+// DashboardPolicy returns the Content-Security-Policy for the dashboard and API
+func (s SecurityConfig) DashboardPolicy() string {
+	if s.DashboardCSP == "" {
+		return DefaultDashboardCSP
+	}
+	return s.DashboardCSP
+}
+
+// SitePolicy returns the Content-Security-Policy for hosted sites; empty means none
+func (s SecurityConfig) SitePolicy() string {
+	if s.SiteCSP == nil {
+		return DefaultSiteCSP
+	}
+	return *s.SiteCSP
+}
+
+// Validate checks the policies and site headers can be sent as header values
+func (s SecurityConfig) Validate() error {
+	if strings.ContainsAny(s.DashboardCSP, "\r\n") {
+		return errors.New("invalid security dashboard_csp: must be a single line")
+	}
+	if s.SiteCSP != nil && strings.ContainsAny(*s.SiteCSP, "\r\n") {
+		return errors.New("invalid security site_csp: must be a single line")
+	}
+	for name, value := range s.SiteHeaders {
+		if name == "" || strings.ContainsAny(name, " :\t\r\n") {
+			return fmt.Errorf("invalid security site_headers name: %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid security site_headers value for %s: must be a single line", name)
+		}
+	}
+	return nil
+}
+
+// RateLimitConfig holds login rate limits (zero values use the built-in defaults).
+// These can be changed at runtime through PUT /api/config.
+type RateLimitConfig struct {
+	LoginAttempts   int `json:"login_attempts,omitempty"`   // failed logins per IP per 15 minutes
+	AccountAttempts int `json:"account_attempts,omitempty"` // failed logins per username per 15 minutes
 }
 
 // NtfyConfig holds notification configuration
@@ -59,7 +475,12 @@ type APIKeyConfig struct {
 	Name  string `json:"name,omitempty"`
 }
 
-var appConfig *Config
+// appConfig is the running configuration. It is never written in place:
+// Update swaps in a changed copy, so readers can't race a runtime change.
+var appConfig atomic.Pointer[Config]
+
+// appConfigPath is the file the loaded configuration came from
+var appConfigPath string
 
 // CLIFlags holds command-line flags
 type CLIFlags struct {
@@ -74,14 +495,7 @@ type CLIFlags struct {
 func ParseFlags() *CLIFlags {
 	flags := &CLIFlags{}
 
-	// Get default config path
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		homeDir = "."
-	}
-	defaultConfigPath := filepath.Join(homeDir, ".config", "fazt", "config.json")
-
-	flag.StringVar(&flags.ConfigPath, "config", defaultConfigPath, "Path to config file")
+	flag.StringVar(&flags.ConfigPath, "config", ResolvePaths("").ConfigFile(), "Path to config file")
 	flag.StringVar(&flags.DBPath, "db", "", "Database file path (overrides config)")
 	flag.StringVar(&flags.Port, "port", "", "Server port (overrides config)")
 	flag.StringVar(&flags.Username, "username", "", "Set/update username (updates config)")
@@ -98,8 +512,8 @@ func ParseFlags() *CLIFlags {
 // 3. Environment variables
 // 4. Built-in defaults (lowest)
 func Load(flags *CLIFlags) (*Config, error) {
-	if appConfig != nil {
-		return appConfig, nil
+	if cfg := appConfig.Load(); cfg != nil {
+		return cfg, nil
 	}
 
 	// Expand home directory in paths
@@ -110,7 +524,7 @@ func Load(flags *CLIFlags) (*Config, error) {
 	if err != nil {
 		// If file doesn't exist, create default config
 		if os.IsNotExist(err) {
-			log.Printf("Config file not found at %s, creating default config...", configPath)
+			logging.Infof("Config file not found at %s, creating default config...", configPath)
 			cfg = CreateDefaultConfig()
 		} else {
 			return nil, fmt.Errorf("failed to load config: %w", err)
@@ -128,11 +542,12 @@ func Load(flags *CLIFlags) (*Config, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	appConfig = cfg
-	log.Printf("Configuration loaded: Environment=%s, Port=%s, Auth=required",
+	appConfig.Store(cfg)
+	appConfigPath = configPath
+	logging.Infof("Configuration loaded: Environment=%s, Port=%s, Auth=required",
 		cfg.Server.Env, cfg.Server.Port)
 
-	return appConfig, nil
+	return cfg, nil
 }
 
 // LoadFromFile loads configuration from a JSON file (exported for use in main.go)
@@ -169,15 +584,12 @@ func SaveToFile(cfg *Config, path string) error {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
-	log.Printf("Config saved to %s", path)
+	logging.Debugf("Config saved to %s", path)
 	return nil
 }
 
 // CreateDefaultConfig creates a default configuration (exported for use in main.go)
 func CreateDefaultConfig() *Config {
-	homeDir, _ := os.UserHomeDir()
-	defaultDBPath := filepath.Join(homeDir, ".config", "fazt", "data.db")
-
 	return &Config{
 		Server: ServerConfig{
 			Port:   "4698",
@@ -185,7 +597,7 @@ func CreateDefaultConfig() *Config {
 			Env:    "development",
 		},
 		Database: DatabaseConfig{
-			Path: defaultDBPath,
+			Path: ResolvePaths("").DatabaseFile(),
 		},
 		Auth: AuthConfig{
 			Username:     "",
@@ -223,6 +635,19 @@ func applyEnvVars(cfg *Config) {
 	if ntfyURL := os.Getenv("NTFY_URL"); ntfyURL != "" {
 		cfg.Ntfy.URL = ntfyURL
 	}
+	if logFormat := os.Getenv("LOG_FORMAT"); logFormat != "" {
+		cfg.Server.LogFormat = logFormat
+	}
+	if caps := os.Getenv("FAZT_DISABLED_CAPABILITIES"); caps != "" {
+		cfg.Hosting.DisabledCapabilities = strings.Split(caps, ",")
+	}
+	if mockData := os.Getenv("FAZT_MOCK_DATA"); mockData != "" {
+		if enabled, err := strconv.ParseBool(mockData); err == nil {
+			cfg.Server.MockData = &enabled
+		} else {
+			logging.Warnf("Warning: ignoring invalid FAZT_MOCK_DATA=%q", mockData)
+		}
+	}
 }
 
 // applyCLIFlags applies CLI flags to config (highest priority)
@@ -262,6 +687,40 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid environment: %s (must be 'development' or 'production')", c.Server.Env)
 	}
 
+	// Validate log format
+	if f := c.Server.LogFormat; f != "" && f != "text" && f != "json" {
+		return fmt.Errorf("invalid log_format: %s (must be 'text' or 'json')", f)
+	}
+
+	// Validate WebSocket drain window
+	if v := c.Server.WSDrainTimeout; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 || d > ShutdownTimeout {
+			return fmt.Errorf("invalid ws_drain_timeout: %s (must be a duration between 0s and %s)", v, ShutdownTimeout)
+		}
+	}
+
+	// Validate request timeout
+	if v := c.Server.RequestTimeout; v != "" {
+		if d, err := time.ParseDuration(v); err != nil || d < 0 {
+			return fmt.Errorf("invalid request_timeout: %s (must be a duration such as 10s, or 0s to disable)", v)
+		}
+	}
+
+	// Validate HTTP server timeouts
+	for _, t := range []struct{ name, value string }{
+		{"read_timeout", c.Server.ReadTimeout},
+		{"write_timeout", c.Server.WriteTimeout},
+		{"idle_timeout", c.Server.IdleTimeout},
+		{"upload_timeout", c.Server.UploadTimeout},
+	} {
+		if t.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(t.value); err != nil || d < 0 {
+			return fmt.Errorf("invalid %s: %s (must be a duration such as 15s, or 0s to disable)", t.name, t.value)
+		}
+	}
+
 	// Ensure DB path is set
 	if c.Database.Path == "" {
 		return errors.New("database path cannot be empty")
@@ -269,6 +728,9 @@ func (c *Config) Validate() error {
 
 	// Expand database path
 	c.Database.Path = ExpandPath(c.Database.Path)
+	if err := c.Database.Validate(); err != nil {
+		return err
+	}
 
 	// Validate auth config (v0.4.0: auth always required)
 	if c.Auth.Username == "" {
@@ -277,23 +739,137 @@ func (c *Config) Validate() error {
 	if c.Auth.PasswordHash == "" {
 		return errors.New("auth password hash is required")
 	}
+	if a := c.Auth.HashAlgorithm; a != "" && a != "bcrypt" && a != "argon2id" {
+		return fmt.Errorf("invalid auth hash_algorithm: %s (must be 'bcrypt' or 'argon2id')", a)
+	}
+	if c.Auth.BcryptCost != 0 && (c.Auth.BcryptCost < 4 || c.Auth.BcryptCost > 31) {
+		return fmt.Errorf("invalid auth bcrypt_cost: %d (must be 4-31)", c.Auth.BcryptCost)
+	}
+	switch c.Auth.SessionFingerprint {
+	case "", "off", "log", "enforce":
+	default:
+		return fmt.Errorf("invalid auth session_fingerprint: %s (must be 'off', 'log' or 'enforce')", c.Auth.SessionFingerprint)
+	}
+	if p := c.Auth.Cookie.Path; p != "" && !strings.HasPrefix(p, "/") {
+		return fmt.Errorf("invalid auth cookie path: %s (must start with '/')", p)
+	}
+	if err := c.validateCookieDomain(); err != nil {
+		return err
+	}
+	switch strings.ToLower(c.Auth.Cookie.SameSite) {
+	case "", "strict", "lax":
+	case "none":
+		// Browsers reject SameSite=None cookies that aren't Secure
+		if !c.SecureCookie() {
+			return errors.New("auth cookie same_site 'none' requires a secure cookie")
+		}
+	default:
+		return fmt.Errorf("invalid auth cookie same_site: %s (must be 'strict', 'lax' or 'none')", c.Auth.Cookie.SameSite)
+	}
+	for name, value := range map[string]string{
+		"session_ttl":          c.Auth.SessionTTL,
+		"session_max_lifetime": c.Auth.SessionMaxLifetime,
+		"remember_me_ttl":      c.Auth.RememberMeTTL,
+	} {
+		if value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+			return fmt.Errorf("invalid auth %s: %s (must be a positive duration like \"24h\")", name, value)
+		}
+	}
+
+	if err := c.RateLimit.Validate(); err != nil {
+		return err
+	}
+
+	if err := c.Hosting.Validate(); err != nil {
+		return err
+	}
+	if err := c.Webhooks.Validate(); err != nil {
+		return err
+	}
+	if err := c.Security.Validate(); err != nil {
+		return err
+	}
 
 	// Validate HTTPS
 	if c.HTTPS.Enabled {
 		if c.HTTPS.Email == "" {
 			return errors.New("https email is required when https is enabled")
 		}
+		// Certificates are stored in the database, so TLS can't start without it
+		if c.Database.DegradedStart {
+			return errors.New("database degraded_start can't be used with https enabled")
+		}
 	}
+	if c.HTTPS.HTTPPort < 0 || c.HTTPS.HTTPPort > 65535 {
+		return fmt.Errorf("invalid https http_port: %d (must be 1-65535, or 0 for the default)", c.HTTPS.HTTPPort)
+	}
+	if c.HTTPS.ExpiryAlertDays < 0 || c.HTTPS.ExpiryAlertDays > 60 {
+		return fmt.Errorf("invalid https expiry_alert_days: %d (must be 1-60, or 0 for the default)", c.HTTPS.ExpiryAlertDays)
+	}
+
+	return nil
+}
 
+// Validate checks the rate limits are in range
+func (r RateLimitConfig) Validate() error {
+	for name, n := range map[string]int{"login_attempts": r.LoginAttempts, "account_attempts": r.AccountAttempts} {
+		if n < 0 || n > 1000 {
+			return fmt.Errorf("invalid rate_limit %s: %d (must be 1-1000, or 0 for the default)", name, n)
+		}
+	}
 	return nil
 }
 
 // Get returns the loaded configuration
 func Get() *Config {
-	if appConfig == nil {
+	cfg := appConfig.Load()
+	if cfg == nil {
 		log.Fatal("Configuration not loaded. Call Load() first.")
 	}
-	return appConfig
+	return cfg
+}
+
+// Update applies change to a copy of the running configuration and makes the
+// copy current, returning it. Anyone still holding an earlier Get keeps a
+// consistent, if stale, view. Callers must serialize their Updates.
+func Update(change func(*Config)) *Config {
+	next := *Get()
+	change(&next)
+	appConfig.Store(&next)
+	return &next
+}
+
+// Path returns the config file path used by Load
+func Path() string {
+	return appConfigPath
+}
+
+// validateCookieDomain checks that auth.cookie.domain is a bare host that
+// covers the server domain, since browsers ignore cookies for other domains
+func (c *Config) validateCookieDomain() error {
+	d := c.Auth.Cookie.Domain
+	if d == "" {
+		return nil
+	}
+	host := strings.ToLower(strings.TrimPrefix(d, "."))
+	if host == "" || strings.ContainsAny(host, ":/ \t") {
+		return fmt.Errorf("invalid auth cookie domain: %s (must be a host such as '.example.com')", d)
+	}
+
+	server := strings.ToLower(c.Server.Domain)
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		server = h
+	}
+	if server != "" && server != host && !strings.HasSuffix(server, "."+host) {
+		return fmt.Errorf("invalid auth cookie domain: %s (must cover server domain %s)", d, server)
+	}
+	return nil
 }
 
 // IsDevelopment returns true if running in development mode
@@ -4,7 +4,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestExpandPath(t *testing.T) {
@@ -137,6 +139,283 @@ func TestConfigValidate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "database path cannot be empty",
 		},
+		{
+			name: "valid argon2id hashing",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", HashAlgorithm: "argon2id"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid hash algorithm",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", HashAlgorithm: "md5"},
+			},
+			wantErr: true,
+			errMsg:  "hash_algorithm",
+		},
+		{
+			name: "invalid bcrypt cost",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", BcryptCost: 40},
+			},
+			wantErr: true,
+			errMsg:  "bcrypt_cost",
+		},
+		{
+			name: "invalid session fingerprint mode",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", SessionFingerprint: "strict"},
+			},
+			wantErr: true,
+			errMsg:  "session_fingerprint",
+		},
+		{
+			name: "valid session lifetimes",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", SessionTTL: "30m", SessionMaxLifetime: "12h"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid session max lifetime",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", SessionMaxLifetime: "forever"},
+			},
+			wantErr: true,
+			errMsg:  "session_max_lifetime",
+		},
+		{
+			name: "invalid log format",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development", LogFormat: "xml"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+			},
+			wantErr: true,
+			errMsg:  "log_format",
+		},
+		{
+			name: "ws drain timeout beyond shutdown timeout",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development", WSDrainTimeout: "1m"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+			},
+			wantErr: true,
+			errMsg:  "ws_drain_timeout",
+		},
+		{
+			name: "negative request timeout",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development", RequestTimeout: "-1s"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+			},
+			wantErr: true,
+			errMsg:  "request_timeout",
+		},
+		{
+			name: "invalid cookie same_site",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "production"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", Cookie: CookieConfig{SameSite: "loose"}},
+			},
+			wantErr: true,
+			errMsg:  "same_site",
+		},
+		{
+			name: "same_site none requires secure cookie",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", Cookie: CookieConfig{SameSite: "none"}},
+			},
+			wantErr: true,
+			errMsg:  "requires a secure cookie",
+		},
+		{
+			name: "invalid cookie path",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", Cookie: CookieConfig{Path: "admin"}},
+			},
+			wantErr: true,
+			errMsg:  "cookie path",
+		},
+		{
+			name: "cookie domain with scheme",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://example.com", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", Cookie: CookieConfig{Domain: "https://example.com"}},
+			},
+			wantErr: true,
+			errMsg:  "cookie domain",
+		},
+		{
+			name: "cookie domain must cover server domain",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://fazt.example.com", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", Cookie: CookieConfig{Domain: ".other.com"}},
+			},
+			wantErr: true,
+			errMsg:  "must cover server domain",
+		},
+		{
+			name: "cookie domain for subdomains",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://example.com:8443", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash", Cookie: CookieConfig{Domain: ".example.com"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid database startup_retry_delay",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db", StartupRetries: 3, StartupRetryDelay: "soon"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+			},
+			wantErr: true,
+			errMsg:  "startup_retry_delay",
+		},
+		{
+			name: "degraded start with https",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://example.com", Env: "production"},
+				Database: DatabaseConfig{Path: "/tmp/test.db", DegradedStart: true},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				HTTPS:    HTTPSConfig{Enabled: true, Email: "admin@example.com"},
+			},
+			wantErr: true,
+			errMsg:  "degraded_start",
+		},
+		{
+			name: "invalid https http_port",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "production"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				HTTPS:    HTTPSConfig{Enabled: true, Email: "admin@example.com", HTTPPort: 70000},
+			},
+			wantErr: true,
+			errMsg:  "http_port",
+		},
+		{
+			name: "invalid https expiry_alert_days",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "production"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				HTTPS:    HTTPSConfig{Enabled: true, Email: "admin@example.com", ExpiryAlertDays: 90},
+			},
+			wantErr: true,
+			errMsg:  "expiry_alert_days",
+		},
+		{
+			name: "invalid hosting gzip_level",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Hosting:  HostingConfig{GzipLevel: 10},
+			},
+			wantErr: true,
+			errMsg:  "gzip_level",
+		},
+		{
+			name: "empty reserved subdomain",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Hosting:  HostingConfig{ReservedSubdomains: &[]string{"www", " "}},
+			},
+			wantErr: true,
+			errMsg:  "reserved_subdomains",
+		},
+		{
+			name: "negative fetch limit",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Hosting:  HostingConfig{FetchMaxRequests: -1},
+			},
+			wantErr: true,
+			errMsg:  "fetch limits",
+		},
+		{
+			name: "invalid write timeout",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development", WriteTimeout: "-1s"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+			},
+			wantErr: true,
+			errMsg:  "write_timeout",
+		},
+		{
+			name: "max deploy bytes too small",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Hosting:  HostingConfig{MaxDeployBytes: 1024},
+			},
+			wantErr: true,
+			errMsg:  "max_deploy_bytes",
+		},
+		{
+			name: "invalid webhook replay tolerance",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Webhooks: WebhooksConfig{ReplayTolerance: "soon"},
+			},
+			wantErr: true,
+			errMsg:  "replay_tolerance",
+		},
+		{
+			name: "multi-line site csp",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Security: SecurityConfig{SiteCSP: &multiLineCSP},
+			},
+			wantErr: true,
+			errMsg:  "site_csp",
+		},
+		{
+			name: "invalid site header name",
+			config: Config{
+				Server:   ServerConfig{Port: "8080", Domain: "https://localhost", Env: "development"},
+				Database: DatabaseConfig{Path: "/tmp/test.db"},
+				Auth:     AuthConfig{Username: "admin", PasswordHash: "hash"},
+				Security: SecurityConfig{SiteHeaders: map[string]string{"X-Frame-Options: DENY": "x"}},
+			},
+			wantErr: true,
+			errMsg:  "site_headers",
+		},
 	}
 
 	for _, tt := range tests {
@@ -157,6 +436,85 @@ func TestConfigValidate(t *testing.T) {
 	}
 }
 
+func TestSecureCookie(t *testing.T) {
+	prod := &Config{Server: ServerConfig{Env: "production"}}
+	if !prod.SecureCookie() {
+		t.Error("cookies should be secure in production by default")
+	}
+
+	dev := &Config{Server: ServerConfig{Env: "development"}}
+	if dev.SecureCookie() {
+		t.Error("cookies should not be secure in development by default")
+	}
+
+	secure := true
+	dev.Auth.Cookie.Secure = &secure
+	if !dev.SecureCookie() {
+		t.Error("explicit secure setting should override the environment default")
+	}
+}
+
+func TestHTTPTimeouts(t *testing.T) {
+	read, write, idle, upload := ServerConfig{}.HTTPTimeouts()
+	if read != DefaultReadTimeout || write != DefaultWriteTimeout || idle != DefaultIdleTimeout || upload != DefaultUploadTimeout {
+		t.Errorf("defaults = %v, %v, %v, %v", read, write, idle, upload)
+	}
+
+	_, write, _, upload = ServerConfig{WriteTimeout: "0s", UploadTimeout: "30m"}.HTTPTimeouts()
+	if write != 0 || upload != 30*time.Minute {
+		t.Errorf("write = %v, upload = %v, want 0s and 30m", write, upload)
+	}
+}
+
+func TestSiteBaseURL(t *testing.T) {
+	tests := []struct {
+		domain string
+		port   string
+		https  bool
+		want   string
+	}{
+		{"https://fazt.sh", "8080", false, "https://fazt.sh"},
+		{"http://localhost:8080/", "8080", false, "http://localhost:8080"},
+		{"localhost", "8080", false, "http://localhost:8080"},
+		{"example.com", "80", false, "http://example.com"},
+		{"example.com", "443", true, "https://example.com"},
+		{"http://example.com:8443", "8443", true, "https://example.com"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{
+			Server: ServerConfig{Domain: tt.domain, Port: tt.port},
+			HTTPS:  HTTPSConfig{Enabled: tt.https},
+		}
+		if got := cfg.SiteBaseURL(); got != tt.want {
+			t.Errorf("SiteBaseURL(%q, port %s, https %v) = %q, want %q", tt.domain, tt.port, tt.https, got, tt.want)
+		}
+	}
+}
+
+func TestMockDataEnabled(t *testing.T) {
+	dev := &Config{Server: ServerConfig{Env: "development"}}
+	if !dev.MockDataEnabled() {
+		t.Error("mock data should be enabled in development by default")
+	}
+
+	prod := &Config{Server: ServerConfig{Env: "production"}}
+	if prod.MockDataEnabled() {
+		t.Error("mock data should be disabled in production by default")
+	}
+
+	t.Setenv("FAZT_MOCK_DATA", "0")
+	applyEnvVars(dev)
+	if dev.MockDataEnabled() {
+		t.Error("FAZT_MOCK_DATA=0 should disable mock data in development")
+	}
+
+	t.Setenv("FAZT_MOCK_DATA", "1")
+	applyEnvVars(prod)
+	if !prod.MockDataEnabled() {
+		t.Error("FAZT_MOCK_DATA=1 should force mock data in production")
+	}
+}
+
 func TestConfigEnvironmentMethods(t *testing.T) {
 	devConfig := &Config{Server: ServerConfig{Env: "development"}}
 	prodConfig := &Config{Server: ServerConfig{Env: "production"}}
@@ -383,3 +741,57 @@ func TestAuthConfig_RequiresBothUsernameAndPassword(t *testing.T) {
 		})
 	}
 }
+
+var multiLineCSP = "default-src 'self';\r\nX-Injected: 1"
+
+func TestSecurityConfig_Policies(t *testing.T) {
+	var s SecurityConfig
+	if s.DashboardPolicy() != DefaultDashboardCSP || s.SitePolicy() != DefaultSiteCSP {
+		t.Error("unset policies should use the defaults")
+	}
+
+	none := ""
+	custom := "default-src 'self' https://cdn.example.com"
+	s = SecurityConfig{DashboardCSP: custom, SiteCSP: &none}
+	if s.DashboardPolicy() != custom {
+		t.Errorf("DashboardPolicy() = %q, want %q", s.DashboardPolicy(), custom)
+	}
+	if s.SitePolicy() != "" {
+		t.Errorf("SitePolicy() = %q, want none when set to \"\"", s.SitePolicy())
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	prev := appConfig.Load()
+	defer appConfig.Store(prev)
+
+	initial := CreateDefaultConfig()
+	initial.Ntfy.Topic = "alerts"
+	appConfig.Store(initial)
+
+	// Readers run alongside the update; the race detector flags any in-place write
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = Get().Ntfy.Topic + Get().Ntfy.URL
+			}
+		}()
+	}
+	next := Update(func(c *Config) {
+		c.Ntfy.Topic = "deploys"
+	})
+	wg.Wait()
+
+	if Get() != next || next.Ntfy.Topic != "deploys" {
+		t.Errorf("Get().Ntfy.Topic = %q, want the updated copy", Get().Ntfy.Topic)
+	}
+	if initial.Ntfy.Topic != "alerts" {
+		t.Errorf("earlier snapshot changed to %q, want it left alone", initial.Ntfy.Topic)
+	}
+	if next.Server.Port != initial.Server.Port {
+		t.Errorf("Update dropped settings it didn't change: port %q, want %q", next.Server.Port, initial.Server.Port)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// AppName is the canonical name of the binary, the systemd service and the
+// directories under the config and data roots
+const AppName = "fazt"
+
+// legacyAppName is the config directory name used before the rename to fazt
+const legacyAppName = "cc"
+
+// Paths locates the files fazt keeps on disk. config.json lives in ConfigDir;
+// the database, sites, backups and PID file live in DataDir.
+type Paths struct {
+	ConfigDir string
+	DataDir   string
+}
+
+// ResolvePaths returns the paths every command uses. A non-empty dataDir (--data-dir)
+// holds everything. Otherwise Linux honors $XDG_CONFIG_HOME and $XDG_DATA_HOME, and
+// anything unset falls back to ~/.config/fazt, where earlier versions kept both.
+func ResolvePaths(dataDir string) Paths {
+	if dataDir != "" {
+		dir := ExpandPath(dataDir)
+		return Paths{ConfigDir: dir, DataDir: dir}
+	}
+
+	fallback := filepath.Join(homeDir(), ".config", AppName)
+	paths := Paths{ConfigDir: fallback, DataDir: fallback}
+	if runtime.GOOS == "linux" {
+		// The XDG spec says relative values are invalid and must be ignored
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(xdg) {
+			paths.ConfigDir = filepath.Join(xdg, AppName)
+		}
+		if xdg := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(xdg) {
+			paths.DataDir = filepath.Join(xdg, AppName)
+		}
+	}
+	return paths
+}
+
+// ConfigFile is the default config.json path
+func (p Paths) ConfigFile() string {
+	return filepath.Join(p.ConfigDir, "config.json")
+}
+
+// DatabaseFile is the default database path
+func (p Paths) DatabaseFile() string {
+	return filepath.Join(p.DataDir, "data.db")
+}
+
+// PIDFile is where a running server records its PID. It sits beside the
+// database, so it follows database.path when the config overrides it.
+func PIDFile(dbPath string) string {
+	return filepath.Join(filepath.Dir(ExpandPath(dbPath)), AppName+".pid")
+}
+
+// LegacyPIDFile is where servers before the rename recorded their PID
+func LegacyPIDFile(dbPath string) string {
+	return filepath.Join(filepath.Dir(ExpandPath(dbPath)), "cc-server.pid")
+}
+
+// MigrateLegacyDir moves a config directory left at ~/.config/cc by older
+// versions to the canonical config directory, so every command sees the same
+// files. A symlink is left behind and database.path is rewritten, so absolute
+// paths saved elsewhere keep working. It returns the old directory if it moved it.
+func MigrateLegacyDir() (string, error) {
+	return migrateLegacyDir(filepath.Join(homeDir(), ".config", legacyAppName), ResolvePaths("").ConfigDir)
+}
+
+func migrateLegacyDir(legacyDir, configDir string) (string, error) {
+	// Nothing to do if it's missing, already a symlink, or not one of our configs
+	info, err := os.Lstat(legacyDir)
+	if err != nil || !info.IsDir() {
+		return "", nil
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, "config.json")); err != nil {
+		return "", nil
+	}
+	if _, err := os.Stat(configDir); err == nil {
+		return "", fmt.Errorf("found configs in both %s and %s; using %s", legacyDir, configDir, configDir)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configDir), 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(configDir), err)
+	}
+	if err := os.Rename(legacyDir, configDir); err != nil {
+		return "", fmt.Errorf("failed to move %s to %s: %w", legacyDir, configDir, err)
+	}
+	if err := os.Symlink(configDir, legacyDir); err != nil {
+		logging.Warnf("Moved %s to %s but could not leave a symlink: %v", legacyDir, configDir, err)
+	}
+
+	configPath := filepath.Join(configDir, "config.json")
+	cfg, err := LoadFromFile(configPath)
+	if err != nil {
+		return legacyDir, fmt.Errorf("moved %s to %s but could not read the config: %w", legacyDir, configDir, err)
+	}
+	dbPath := ExpandPath(cfg.Database.Path)
+	if rel, err := filepath.Rel(legacyDir, dbPath); err == nil && !strings.HasPrefix(rel, "..") {
+		cfg.Database.Path = filepath.Join(configDir, rel)
+		if err := SaveToFile(cfg, configPath); err != nil {
+			return legacyDir, err
+		}
+	}
+	return legacyDir, nil
+}
+
+// homeDir returns the user's home directory, or "." if it can't be determined
+func homeDir() string {
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+	return dir
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestResolvePaths_DataDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+
+	paths := ResolvePaths("/srv/fazt")
+	if paths.ConfigFile() != "/srv/fazt/config.json" || paths.DatabaseFile() != "/srv/fazt/data.db" {
+		t.Errorf("ResolvePaths(/srv/fazt) = %+v, want both rooted at /srv/fazt", paths)
+	}
+
+	homeDir, _ := os.UserHomeDir()
+	if got := ResolvePaths("~/fazt").DataDir; got != filepath.Join(homeDir, "fazt") {
+		t.Errorf("ResolvePaths(~/fazt).DataDir = %q, want ~ expanded", got)
+	}
+}
+
+func TestResolvePaths_Defaults(t *testing.T) {
+	homeDir, _ := os.UserHomeDir()
+	legacy := filepath.Join(homeDir, ".config", "fazt")
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	if paths := ResolvePaths(""); paths.ConfigDir != legacy || paths.DataDir != legacy {
+		t.Errorf("ResolvePaths() without XDG = %+v, want both in %s", paths, legacy)
+	}
+
+	// Relative XDG values are invalid and ignored
+	t.Setenv("XDG_CONFIG_HOME", "relative/config")
+	if paths := ResolvePaths(""); paths.ConfigDir != legacy {
+		t.Errorf("ConfigDir with relative XDG_CONFIG_HOME = %q, want %q", paths.ConfigDir, legacy)
+	}
+
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG directories are only honored on Linux")
+	}
+	t.Setenv("XDG_CONFIG_HOME", "/xdg/config")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	paths := ResolvePaths("")
+	if paths.ConfigFile() != "/xdg/config/fazt/config.json" {
+		t.Errorf("ConfigFile() = %q, want /xdg/config/fazt/config.json", paths.ConfigFile())
+	}
+	if paths.DatabaseFile() != "/xdg/data/fazt/data.db" {
+		t.Errorf("DatabaseFile() = %q, want /xdg/data/fazt/data.db", paths.DatabaseFile())
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	if got := PIDFile("/srv/fazt/data.db"); got != "/srv/fazt/fazt.pid" {
+		t.Errorf("PIDFile() = %q, want /srv/fazt/fazt.pid", got)
+	}
+	if got := LegacyPIDFile("/srv/fazt/data.db"); got != "/srv/fazt/cc-server.pid" {
+		t.Errorf("LegacyPIDFile() = %q, want /srv/fazt/cc-server.pid", got)
+	}
+}
+
+func TestMigrateLegacyDir(t *testing.T) {
+	root := t.TempDir()
+	legacyDir := filepath.Join(root, "cc")
+	configDir := filepath.Join(root, "fazt")
+	if err := os.Mkdir(legacyDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := CreateDefaultConfig()
+	cfg.Database.Path = filepath.Join(legacyDir, "data.db")
+	if err := SaveToFile(cfg, filepath.Join(legacyDir, "config.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := migrateLegacyDir(legacyDir, configDir)
+	if err != nil {
+		t.Fatalf("migrateLegacyDir() error = %v", err)
+	}
+	if moved != legacyDir {
+		t.Errorf("migrateLegacyDir() = %q, want %q", moved, legacyDir)
+	}
+
+	migrated, err := LoadFromFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		t.Fatalf("config not moved: %v", err)
+	}
+	if want := filepath.Join(configDir, "data.db"); migrated.Database.Path != want {
+		t.Errorf("database.path = %q, want %q", migrated.Database.Path, want)
+	}
+	if target, err := os.Readlink(legacyDir); err != nil || target != configDir {
+		t.Errorf("legacy dir should be a symlink to %s, got %q (%v)", configDir, target, err)
+	}
+
+	// Once migrated, the symlink is left alone
+	if moved, err := migrateLegacyDir(legacyDir, configDir); moved != "" || err != nil {
+		t.Errorf("second migrateLegacyDir() = %q, %v; want no-op", moved, err)
+	}
+}
+
+func TestMigrateLegacyDir_NoOp(t *testing.T) {
+	root := t.TempDir()
+	legacyDir := filepath.Join(root, "cc")
+	configDir := filepath.Join(root, "fazt")
+
+	// Missing legacy dir
+	if moved, err := migrateLegacyDir(legacyDir, configDir); moved != "" || err != nil {
+		t.Errorf("migrateLegacyDir() without legacy dir = %q, %v; want no-op", moved, err)
+	}
+
+	// A cc directory that isn't ours
+	os.Mkdir(legacyDir, 0700)
+	if moved, err := migrateLegacyDir(legacyDir, configDir); moved != "" || err != nil {
+		t.Errorf("migrateLegacyDir() without config.json = %q, %v; want no-op", moved, err)
+	}
+	if _, err := os.Stat(configDir); !os.IsNotExist(err) {
+		t.Error("config dir should not be created")
+	}
+}
+
+func TestMigrateLegacyDir_BothExist(t *testing.T) {
+	root := t.TempDir()
+	legacyDir := filepath.Join(root, "cc")
+	configDir := filepath.Join(root, "fazt")
+	os.Mkdir(legacyDir, 0700)
+	os.Mkdir(configDir, 0700)
+	SaveToFile(CreateDefaultConfig(), filepath.Join(legacyDir, "config.json"))
+
+	if _, err := migrateLegacyDir(legacyDir, configDir); err == nil {
+		t.Error("migrateLegacyDir() with both dirs should return an error")
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, "config.json")); err != nil {
+		t.Error("legacy config should be left in place")
+	}
+}
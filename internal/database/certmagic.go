@@ -2,8 +2,12 @@ package database
 
 import (
 	"context"
+	"crypto/x509"
 	"database/sql"
+	"encoding/pem"
 	"io/fs"
+	"path"
+	"sort"
 	"strings"
 	"time"
 
@@ -158,3 +162,75 @@ func (s *SQLCertStorage) Lock(ctx context.Context, key string) error {
 func (s *SQLCertStorage) Unlock(ctx context.Context, key string) error {
 	return nil
 }
+
+// CertInfo describes a stored certificate
+type CertInfo struct {
+	Domain   string    `json:"domain"`
+	Names    []string  `json:"names"`
+	Issuer   string    `json:"issuer"` // CertMagic issuer key, e.g. acme-v02.api.letsencrypt.org-directory
+	NotAfter time.Time `json:"not_after"`
+}
+
+// Certificates parses the stored certificates, soonest expiry first. When a domain
+// has certificates from more than one issuer only the latest-expiring one is kept,
+// since that is the one being served.
+func (s *SQLCertStorage) Certificates(ctx context.Context) ([]CertInfo, error) {
+	// CertMagic stores certs as certificates/<issuer>/<domain>/<domain>.crt
+	rows, err := s.db.QueryContext(ctx, "SELECT key, value FROM certificates WHERE key LIKE 'certificates/%.crt'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[string]CertInfo)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+
+		// The leaf comes first in the bundle
+		block, _ := pem.Decode(value)
+		if block == nil {
+			continue
+		}
+		leaf, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		info := CertInfo{Names: leaf.DNSNames, NotAfter: leaf.NotAfter.UTC()}
+		if len(info.Names) == 0 && leaf.Subject.CommonName != "" {
+			info.Names = []string{leaf.Subject.CommonName}
+		}
+		if len(info.Names) > 0 {
+			info.Domain = info.Names[0]
+		} else {
+			info.Domain = path.Base(path.Dir(key))
+		}
+		if parts := strings.Split(key, "/"); len(parts) > 2 {
+			info.Issuer = parts[1]
+		}
+
+		if cur, ok := latest[info.Domain]; ok && !info.NotAfter.After(cur.NotAfter) {
+			continue
+		}
+		latest[info.Domain] = info
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	certs := make([]CertInfo, 0, len(latest))
+	for _, info := range latest {
+		certs = append(certs, info)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		if !certs[i].NotAfter.Equal(certs[j].NotAfter) {
+			return certs[i].NotAfter.Before(certs[j].NotAfter)
+		}
+		return certs[i].Domain < certs[j].Domain
+	})
+	return certs, nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testCertPEM returns a self-signed PEM certificate for name expiring at notAfter
+func testCertPEM(t *testing.T, name string, notAfter time.Time) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    notAfter.Add(-90 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSQLCertStorage_Certificates(t *testing.T) {
+	initTestDB(t)
+	storage := NewSQLCertStorage(db)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	soon := now.Add(5 * 24 * time.Hour)
+	later := now.Add(60 * 24 * time.Hour)
+	const staging = "acme-staging-v02.api.letsencrypt.org-directory"
+	const prod = "acme-v02.api.letsencrypt.org-directory"
+
+	storage.Store(ctx, "certificates/"+prod+"/blog.example.com/blog.example.com.crt", testCertPEM(t, "blog.example.com", later))
+	storage.Store(ctx, "certificates/"+prod+"/shop.example.com/shop.example.com.crt", testCertPEM(t, "shop.example.com", soon))
+	// An older cert for the same domain from another issuer isn't the one served
+	storage.Store(ctx, "certificates/"+staging+"/blog.example.com/blog.example.com.crt", testCertPEM(t, "blog.example.com", soon))
+	storage.Store(ctx, "certificates/"+prod+"/shop.example.com/shop.example.com.key", []byte("not a cert"))
+	storage.Store(ctx, "certificates/"+prod+"/bad.example.com/bad.example.com.crt", []byte("garbage"))
+
+	certs, err := storage.Certificates(ctx)
+	if err != nil {
+		t.Fatalf("Certificates() error = %v", err)
+	}
+	if len(certs) != 2 {
+		t.Fatalf("Certificates() = %+v, want 2 certs", certs)
+	}
+	if certs[0].Domain != "shop.example.com" || !certs[0].NotAfter.Equal(soon) {
+		t.Errorf("first cert = %+v, want shop.example.com expiring soonest", certs[0])
+	}
+	if certs[1].Domain != "blog.example.com" || !certs[1].NotAfter.Equal(later) || certs[1].Issuer != prod {
+		t.Errorf("second cert = %+v, want the production blog.example.com cert", certs[1])
+	}
+}
@@ -5,12 +5,13 @@ import (
 	"embed"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 //go:embed migrations/*.sql
@@ -18,8 +19,26 @@ var migrationFS embed.FS
 
 var db *sql.DB
 
-// Init initializes the database connection with WAL mode
+// stmts caches prepared statements on db (see Stmt)
+var stmts *StmtCache
+
+// Init initializes the database connection with WAL mode.
+// On failure the connection is closed, so Init can be retried.
 func Init(dbPath string) error {
+	if err := openAndMigrate(dbPath); err != nil {
+		if db != nil {
+			db.Close()
+			db = nil
+		}
+		return err
+	}
+
+	logging.Infof("Database initialized successfully")
+	return nil
+}
+
+// openAndMigrate connects to dbPath and migrates it
+func openAndMigrate(dbPath string) error {
 	var err error
 
 	// Ensure the directory exists
@@ -54,7 +73,7 @@ func Init(dbPath string) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
-	log.Println("Database initialized successfully")
+	stmts = NewStmtCache(db)
 	return nil
 }
 
@@ -83,6 +102,23 @@ func runMigrations() error {
 		{2, "paas_tables", "migrations/002_paas.sql"},
 		{3, "env_vars", "migrations/003_env_vars.sql"},
 		{4, "vfs_schema", "migrations/004_vfs.sql"},
+		{5, "custom_domains", "migrations/005_custom_domains.sql"},
+		{6, "sites", "migrations/006_sites.sql"},
+		{7, "site_metadata", "migrations/007_site_metadata.sql"},
+		{8, "redirect_schedule", "migrations/008_redirect_schedule.sql"},
+		{9, "event_country", "migrations/009_event_country.sql"},
+		{10, "redirect_status", "migrations/010_redirect_status.sql"},
+		{11, "api_key_expiry", "migrations/011_api_key_expiry.sql"},
+		{12, "api_key_usage", "migrations/012_api_key_usage.sql"},
+		{13, "api_key_prefix", "migrations/013_api_key_prefix.sql"},
+		{14, "password_reset_tokens", "migrations/014_password_reset_tokens.sql"},
+		{15, "file_encoding", "migrations/015_file_encoding.sql"},
+		{16, "event_indexes", "migrations/016_event_indexes.sql"},
+		{17, "tracking_tokens", "migrations/017_tracking_tokens.sql"},
+		{18, "deploy_idempotency", "migrations/018_deploy_idempotency.sql"},
+		{19, "site_aliases", "migrations/019_site_aliases.sql"},
+		{20, "site_previews", "migrations/020_site_previews.sql"},
+		{21, "redirect_versions", "migrations/021_redirect_versions.sql"},
 	}
 
 	// Run each migration if not already applied
@@ -95,7 +131,7 @@ func runMigrations() error {
 		}
 
 		if count > 0 {
-			log.Printf("Migration %d (%s) already applied, skipping", migration.version, migration.name)
+			logging.Debugf("Migration %d (%s) already applied, skipping", migration.version, migration.name)
 			continue
 		}
 
@@ -116,13 +152,32 @@ func runMigrations() error {
 			return fmt.Errorf("failed to record migration: %w", err)
 		}
 
-		log.Printf("Applied migration %d: %s", migration.version, migration.name)
+		logging.Infof("Applied migration %d: %s", migration.version, migration.name)
 	}
 
-	log.Println("Migrations completed successfully")
+	logging.Infof("Migrations completed successfully")
 	return nil
 }
 
+// OpenReadOnly opens an existing database read-only, without running migrations,
+// so it can be inspected while a server has it open. Queries wait briefly for a
+// writer's lock before failing with "database is locked".
+func OpenReadOnly(dbPath string) (*sql.DB, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("sqlite", "file:"+dbPath+"?mode=ro&_pragma=busy_timeout(2000)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	return conn, nil
+}
+
 // GetDB returns the database instance
 func GetDB() *sql.DB {
 	return db
@@ -130,6 +185,10 @@ func GetDB() *sql.DB {
 
 // Close closes the database connection
 func Close() error {
+	if stmts != nil {
+		stmts.Close()
+		stmts = nil
+	}
 	if db != nil {
 		return db.Close()
 	}
@@ -173,11 +232,11 @@ func Backup(dbPath string) (string, error) {
 		return "", fmt.Errorf("failed to copy database: %w", err)
 	}
 
-	log.Printf("Database backup created: %s", backupPath)
+	logging.Infof("Database backup created: %s", backupPath)
 
 	// Cleanup old backups (keep last 5)
 	if err := cleanupOldBackups(backupDir, 5); err != nil {
-		log.Printf("Warning: failed to cleanup old backups: %v", err)
+		logging.Warnf("Warning: failed to cleanup old backups: %v", err)
 	}
 
 	return backupPath, nil
@@ -223,9 +282,9 @@ func cleanupOldBackups(backupDir string, keep int) error {
 	deleteCount := len(fileInfos) - keep
 	for i := 0; i < deleteCount; i++ {
 		if err := os.Remove(fileInfos[i].path); err != nil {
-			log.Printf("Warning: failed to remove old backup %s: %v", fileInfos[i].path, err)
+			logging.Warnf("Warning: failed to remove old backup %s: %v", fileInfos[i].path, err)
 		} else {
-			log.Printf("Removed old backup: %s", fileInfos[i].path)
+			logging.Infof("Removed old backup: %s", fileInfos[i].path)
 		}
 	}
 
@@ -0,0 +1,347 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DumpVersion is the format written by Export; Import refuses newer dumps
+const DumpVersion = 1
+
+// dumpTimeFormat is how Import writes times, matching CURRENT_TIMESTAMP
+const dumpTimeFormat = "2006-01-02 15:04:05"
+
+// Dump is the portable JSON form of a server's data, for moving it between
+// machines or storage backends without copying the database file.
+// Sites carry a manifest of their files but not the contents, and env vars
+// carry names but not values.
+type Dump struct {
+	Version    int            `json:"version"`
+	ExportedAt time.Time      `json:"exported_at"`
+	Events     []DumpEvent    `json:"events"`
+	Redirects  []DumpRedirect `json:"redirects"`
+	Webhooks   []DumpWebhook  `json:"webhooks"`
+	Sites      []DumpSite     `json:"sites"`
+	EnvVars    []DumpEnvVar   `json:"env_vars"`
+}
+
+// DumpEvent is an analytics event
+type DumpEvent struct {
+	ID          int64     `json:"id"`
+	Domain      string    `json:"domain"`
+	Tags        *string   `json:"tags,omitempty"`
+	SourceType  string    `json:"source_type"`
+	EventType   string    `json:"event_type"`
+	Path        *string   `json:"path,omitempty"`
+	Referrer    *string   `json:"referrer,omitempty"`
+	UserAgent   *string   `json:"user_agent,omitempty"`
+	IPAddress   *string   `json:"ip_address,omitempty"`
+	QueryParams *string   `json:"query_params,omitempty"`
+	Country     *string   `json:"country,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DumpRedirect is a short link
+type DumpRedirect struct {
+	ID          int64      `json:"id"`
+	Slug        string     `json:"slug"`
+	Destination string     `json:"destination"`
+	Tags        *string    `json:"tags,omitempty"`
+	ClickCount  int64      `json:"click_count"`
+	StatusCode  int        `json:"status_code"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	FallbackURL *string    `json:"fallback_url,omitempty"`
+	Version     int64      `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// DumpWebhook is a webhook endpoint, with its signing secret so signed
+// webhooks keep verifying after an import
+type DumpWebhook struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Endpoint  string    `json:"endpoint"`
+	Secret    *string   `json:"secret,omitempty"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DumpSite is a hosted site's metadata and file manifest
+type DumpSite struct {
+	SiteID         string     `json:"site_id"`
+	Enabled        bool       `json:"enabled"`
+	Owner          *string    `json:"owner,omitempty"`
+	DeployCount    int64      `json:"deploy_count"`
+	LastDeployedAt *time.Time `json:"last_deployed_at,omitempty"`
+	Preview        bool       `json:"preview,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Files          []DumpFile `json:"files"`
+}
+
+// DumpFile describes a deployed file; size and hash are of the uncompressed content
+type DumpFile struct {
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	MimeType  *string   `json:"mime_type,omitempty"`
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DumpEnvVar names a site's environment variable; the value isn't exported
+type DumpEnvVar struct {
+	SiteID string `json:"site_id"`
+	Name   string `json:"name"`
+}
+
+// Export reads the events, redirects, webhooks, sites and env var names
+func Export() (*Dump, error) {
+	if db == nil {
+		return nil, errors.New("database not initialized")
+	}
+
+	d := &Dump{Version: DumpVersion, ExportedAt: time.Now().UTC()}
+	steps := []struct {
+		name string
+		fn   func(*Dump) error
+	}{
+		{"events", exportEvents},
+		{"redirects", exportRedirects},
+		{"webhooks", exportWebhooks},
+		{"sites", exportSites},
+		{"env vars", exportEnvVars},
+	}
+	for _, step := range steps {
+		if err := step.fn(d); err != nil {
+			return nil, fmt.Errorf("failed to export %s: %w", step.name, err)
+		}
+	}
+	return d, nil
+}
+
+func exportEvents(d *Dump) error {
+	rows, err := db.Query(`
+		SELECT id, domain, tags, source_type, event_type, path, referrer,
+		       user_agent, ip_address, query_params, country, created_at
+		FROM events ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	d.Events = []DumpEvent{}
+	for rows.Next() {
+		var e DumpEvent
+		if err := rows.Scan(&e.ID, &e.Domain, &e.Tags, &e.SourceType, &e.EventType, &e.Path, &e.Referrer,
+			&e.UserAgent, &e.IPAddress, &e.QueryParams, &e.Country, &e.CreatedAt); err != nil {
+			return err
+		}
+		d.Events = append(d.Events, e)
+	}
+	return rows.Err()
+}
+
+func exportRedirects(d *Dump) error {
+	rows, err := db.Query(`
+		SELECT id, slug, destination, tags, COALESCE(click_count, 0), status_code,
+		       starts_at, expires_at, fallback_url, version, created_at
+		FROM redirects ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	d.Redirects = []DumpRedirect{}
+	for rows.Next() {
+		var r DumpRedirect
+		var startsAt, expiresAt sql.NullTime
+		if err := rows.Scan(&r.ID, &r.Slug, &r.Destination, &r.Tags, &r.ClickCount, &r.StatusCode,
+			&startsAt, &expiresAt, &r.FallbackURL, &r.Version, &r.CreatedAt); err != nil {
+			return err
+		}
+		r.StartsAt, r.ExpiresAt = nullTimePtr(startsAt), nullTimePtr(expiresAt)
+		d.Redirects = append(d.Redirects, r)
+	}
+	return rows.Err()
+}
+
+func exportWebhooks(d *Dump) error {
+	rows, err := db.Query(`
+		SELECT id, name, endpoint, secret, COALESCE(is_active, 1), created_at
+		FROM webhooks ORDER BY id
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	d.Webhooks = []DumpWebhook{}
+	for rows.Next() {
+		var w DumpWebhook
+		if err := rows.Scan(&w.ID, &w.Name, &w.Endpoint, &w.Secret, &w.IsActive, &w.CreatedAt); err != nil {
+			return err
+		}
+		d.Webhooks = append(d.Webhooks, w)
+	}
+	return rows.Err()
+}
+
+func exportSites(d *Dump) error {
+	rows, err := db.Query(`
+		SELECT site_id, enabled, owner, deploy_count, last_deployed_at, preview, expires_at, created_at, updated_at
+		FROM sites ORDER BY site_id
+	`)
+	if err != nil {
+		return err
+	}
+
+	d.Sites = []DumpSite{}
+	index := map[string]int{}
+	for rows.Next() {
+		var s DumpSite
+		var lastDeployedAt, expiresAt sql.NullTime
+		if err := rows.Scan(&s.SiteID, &s.Enabled, &s.Owner, &s.DeployCount, &lastDeployedAt,
+			&s.Preview, &expiresAt, &s.CreatedAt, &s.UpdatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		s.LastDeployedAt, s.ExpiresAt = nullTimePtr(lastDeployedAt), nullTimePtr(expiresAt)
+		s.Files = []DumpFile{}
+		index[s.SiteID] = len(d.Sites)
+		d.Sites = append(d.Sites, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	files, err := db.Query(`SELECT site_id, path, size_bytes, mime_type, hash, updated_at FROM files ORDER BY site_id, path`)
+	if err != nil {
+		return err
+	}
+	defer files.Close()
+
+	for files.Next() {
+		var siteID string
+		var f DumpFile
+		if err := files.Scan(&siteID, &f.Path, &f.SizeBytes, &f.MimeType, &f.Hash, &f.UpdatedAt); err != nil {
+			return err
+		}
+		if i, ok := index[siteID]; ok {
+			d.Sites[i].Files = append(d.Sites[i].Files, f)
+		}
+	}
+	return files.Err()
+}
+
+func exportEnvVars(d *Dump) error {
+	rows, err := db.Query(`SELECT site_id, name FROM env_vars ORDER BY site_id, name`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	d.EnvVars = []DumpEnvVar{}
+	for rows.Next() {
+		var v DumpEnvVar
+		if err := rows.Scan(&v.SiteID, &v.Name); err != nil {
+			return err
+		}
+		d.EnvVars = append(d.EnvVars, v)
+	}
+	return rows.Err()
+}
+
+// Import restores a dump's events, redirects, webhooks and site metadata in
+// one transaction, keeping their IDs. The database must not have any of them
+// yet. Site files and env vars aren't in a dump, so sites need redeploying
+// and env vars setting again.
+func Import(d *Dump) error {
+	if db == nil {
+		return errors.New("database not initialized")
+	}
+	if d.Version < 1 || d.Version > DumpVersion {
+		return fmt.Errorf("unsupported dump version %d (this server reads up to %d)", d.Version, DumpVersion)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"events", "redirects", "webhooks", "sites"} {
+		var n int64
+		if err := tx.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+			return fmt.Errorf("database error: %w", err)
+		}
+		if n > 0 {
+			return fmt.Errorf("database already has %s; import into a fresh database", table)
+		}
+	}
+
+	for _, e := range d.Events {
+		if _, err := tx.Exec(`
+			INSERT INTO events (id, domain, tags, source_type, event_type, path, referrer,
+			                    user_agent, ip_address, query_params, country, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, e.ID, e.Domain, e.Tags, e.SourceType, e.EventType, e.Path, e.Referrer,
+			e.UserAgent, e.IPAddress, e.QueryParams, e.Country, dumpTime(&e.CreatedAt)); err != nil {
+			return fmt.Errorf("failed to import event %d: %w", e.ID, err)
+		}
+	}
+
+	for _, r := range d.Redirects {
+		if _, err := tx.Exec(`
+			INSERT INTO redirects (id, slug, destination, tags, click_count, status_code,
+			                       starts_at, expires_at, fallback_url, version, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, r.ID, r.Slug, r.Destination, r.Tags, r.ClickCount, r.StatusCode,
+			dumpTime(r.StartsAt), dumpTime(r.ExpiresAt), r.FallbackURL, r.Version, dumpTime(&r.CreatedAt)); err != nil {
+			return fmt.Errorf("failed to import redirect %s: %w", r.Slug, err)
+		}
+	}
+
+	for _, w := range d.Webhooks {
+		if _, err := tx.Exec(`
+			INSERT INTO webhooks (id, name, endpoint, secret, is_active, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, w.ID, w.Name, w.Endpoint, w.Secret, w.IsActive, dumpTime(&w.CreatedAt)); err != nil {
+			return fmt.Errorf("failed to import webhook %s: %w", w.Endpoint, err)
+		}
+	}
+
+	for _, s := range d.Sites {
+		if _, err := tx.Exec(`
+			INSERT INTO sites (site_id, enabled, owner, deploy_count, last_deployed_at, preview, expires_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, s.SiteID, s.Enabled, s.Owner, s.DeployCount, dumpTime(s.LastDeployedAt),
+			s.Preview, dumpTime(s.ExpiresAt), dumpTime(&s.CreatedAt), dumpTime(&s.UpdatedAt)); err != nil {
+			return fmt.Errorf("failed to import site %s: %w", s.SiteID, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// nullTimePtr returns the time, or nil for NULL
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// dumpTime formats a time for a DATETIME column; nil and zero times are NULL
+func dumpTime(t *time.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t.UTC().Format(dumpTimeFormat)
+}
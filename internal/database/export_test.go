@@ -0,0 +1,81 @@
+package database
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	initTestDB(t)
+	seed := []string{
+		`INSERT INTO events (domain, tags, source_type, event_type, path, country, created_at)
+		 VALUES ('blog.example.com', 'app', 'web', 'pageview', '/', 'NZ', '2026-01-02 03:04:05')`,
+		`INSERT INTO events (domain, source_type, event_type, created_at) VALUES ('a.com', 'pixel', 'pageview', '2026-01-03 00:00:00')`,
+		`INSERT INTO redirects (slug, destination, click_count, status_code, expires_at, fallback_url, version)
+		 VALUES ('launch', 'https://example.com/launch', 7, 301, '2027-01-01 00:00:00', 'https://example.com', 3)`,
+		`INSERT INTO webhooks (name, endpoint, secret, is_active) VALUES ('GitHub', 'github', 's3cret', 0)`,
+		`INSERT INTO sites (site_id, enabled, owner, deploy_count, last_deployed_at) VALUES ('blog', 0, 'ci', 2, '2026-01-04 00:00:00')`,
+		`INSERT INTO files (site_id, path, content, size_bytes, mime_type, hash) VALUES ('blog', 'index.html', 'hi', 2, 'text/html', 'abc')`,
+		`INSERT INTO env_vars (site_id, name, value) VALUES ('blog', 'API_KEY', 'do-not-export')`,
+	}
+	for _, stmt := range seed {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+
+	dump, err := Export()
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	data, _ := json.Marshal(dump)
+	if strings.Contains(string(data), "do-not-export") {
+		t.Error("export contains an env var value")
+	}
+	if len(dump.Sites) != 1 || len(dump.Sites[0].Files) != 1 || dump.Sites[0].Files[0].Hash != "abc" {
+		t.Errorf("sites = %+v, want blog with its file manifest", dump.Sites)
+	}
+
+	// Restore the JSON into a fresh database and export it again
+	var restored Dump
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	Close()
+	initTestDB(t)
+	if err := Import(&restored); err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+	again, err := Export()
+	if err != nil {
+		t.Fatalf("Export() after import error = %v", err)
+	}
+
+	for name, pair := range map[string][2]interface{}{
+		"events":    {dump.Events, again.Events},
+		"redirects": {dump.Redirects, again.Redirects},
+		"webhooks":  {dump.Webhooks, again.Webhooks},
+	} {
+		if !reflect.DeepEqual(pair[0], pair[1]) {
+			t.Errorf("%s after import = %+v, want %+v", name, pair[1], pair[0])
+		}
+	}
+	// Files and env vars aren't restored
+	if len(again.Sites) != 1 || again.Sites[0].Enabled || *again.Sites[0].Owner != "ci" || len(again.Sites[0].Files) != 0 {
+		t.Errorf("sites after import = %+v, want blog's metadata only", again.Sites)
+	}
+	if len(again.EnvVars) != 0 {
+		t.Errorf("env vars after import = %+v, want none", again.EnvVars)
+	}
+
+	// A database with data isn't overwritten
+	if err := Import(&restored); err == nil || !strings.Contains(err.Error(), "fresh database") {
+		t.Errorf("Import() into a used database error = %v", err)
+	}
+	restored.Version = DumpVersion + 1
+	if err := Import(&restored); err == nil || !strings.Contains(err.Error(), "version") {
+		t.Errorf("Import() of a newer dump error = %v", err)
+	}
+}
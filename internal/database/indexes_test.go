@@ -0,0 +1,117 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// timelineSQL is the hourly timeline query from StatsHandler
+const timelineSQL = `
+	SELECT strftime('%Y-%m-%d %H:00', created_at) as hour, COUNT(*) as count
+	FROM events
+	WHERE created_at >= DATETIME('now', '-24 hours')
+	GROUP BY hour
+	ORDER BY hour
+`
+
+// seedEvents inserts n events spread evenly over the last 90 days
+func seedEvents(tb testing.TB, n int) {
+	tb.Helper()
+	tx, err := db.Begin()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	stmt, err := tx.Prepare(`INSERT INTO events (domain, source_type, event_type, path, created_at) VALUES (?, ?, 'pageview', ?, ?)`)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	now := time.Now().UTC()
+	step := 90 * 24 * time.Hour / time.Duration(n)
+	for i := 0; i < n; i++ {
+		createdAt := now.Add(-time.Duration(i) * step).Format("2006-01-02 15:04:05")
+		if _, err := stmt.Exec(fmt.Sprintf("site%d.example.com", i%50), "web", fmt.Sprintf("/page/%d", i%20), createdAt); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	stmt.Close()
+	if err := tx.Commit(); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// queryPlan returns the EXPLAIN QUERY PLAN details for query
+func queryPlan(t *testing.T, query string, args ...interface{}) string {
+	t.Helper()
+	rows, err := db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		t.Fatalf("EXPLAIN failed: %v", err)
+	}
+	defer rows.Close()
+
+	var details []string
+	for rows.Next() {
+		var id, parent, notused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		details = append(details, detail)
+	}
+	return strings.Join(details, "; ")
+}
+
+func TestEventIndexes_QueryPlans(t *testing.T) {
+	initTestDB(t)
+	seedEvents(t, 1000)
+	db.Exec("ANALYZE")
+
+	tests := []struct {
+		name  string
+		query string
+		args  []interface{}
+		index string
+	}{
+		{"timeline", timelineSQL, nil, "idx_events_created_at"},
+		{"events by domain", "SELECT id FROM events WHERE domain = ? ORDER BY created_at DESC LIMIT 50", []interface{}{"site1.example.com"}, "idx_events_domain_created_at"},
+		{"redirect daily clicks", "SELECT DATE(created_at), COUNT(*) FROM events WHERE source_type = 'redirect' AND path = ? AND created_at >= DATE('now', '-30 days') GROUP BY 1", []interface{}{"/r/x"}, "idx_events_source_path_created_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if plan := queryPlan(t, tt.query, tt.args...); !strings.Contains(plan, tt.index) {
+				t.Errorf("plan = %q, want it to use %s", plan, tt.index)
+			}
+		})
+	}
+}
+
+// TestEventIndexes_Timeline times the timeline query on a large table with and
+// without its index. Timings are logged rather than asserted; run with -v.
+func TestEventIndexes_Timeline(t *testing.T) {
+	if testing.Short() {
+		t.Skip("seeds a large table")
+	}
+	initTestDB(t)
+	seedEvents(t, 200000)
+
+	timeQuery := func() time.Duration {
+		start := time.Now()
+		rows, err := db.Query(timelineSQL)
+		if err != nil {
+			t.Fatalf("timeline query failed: %v", err)
+		}
+		for rows.Next() {
+		}
+		rows.Close()
+		return time.Since(start)
+	}
+
+	indexed := timeQuery()
+	if _, err := db.Exec("DROP INDEX idx_events_created_at"); err != nil {
+		t.Fatal(err)
+	}
+	scanned := timeQuery()
+
+	t.Logf("timeline over 200k events: %v with idx_events_created_at, %v with a full scan", indexed, scanned)
+}
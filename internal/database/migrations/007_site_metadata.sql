@@ -0,0 +1,12 @@
+-- Migration 007: Site Deploy Metadata
+
+-- Track who owns a site and when it was last deployed
+ALTER TABLE sites ADD COLUMN owner_key_id INTEGER;
+ALTER TABLE sites ADD COLUMN owner TEXT;
+ALTER TABLE sites ADD COLUMN last_deployed_at DATETIME;
+ALTER TABLE sites ADD COLUMN deploy_count INTEGER NOT NULL DEFAULT 0;
+
+-- Backfill from deployment history
+UPDATE sites SET
+    last_deployed_at = (SELECT MAX(created_at) FROM deployments d WHERE d.site_id = sites.site_id),
+    deploy_count = (SELECT COUNT(*) FROM deployments d WHERE d.site_id = sites.site_id);
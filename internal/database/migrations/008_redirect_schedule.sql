@@ -0,0 +1,6 @@
+-- Migration 008: Redirect Scheduling
+
+-- Optional activation window; outside it the redirect 404s or sends visitors to fallback_url
+ALTER TABLE redirects ADD COLUMN starts_at DATETIME;
+ALTER TABLE redirects ADD COLUMN expires_at DATETIME;
+ALTER TABLE redirects ADD COLUMN fallback_url TEXT;
@@ -0,0 +1,4 @@
+-- Migration 009: Event Country
+
+-- Two-letter country code supplied by an upstream proxy (e.g. CF-IPCountry)
+ALTER TABLE events ADD COLUMN country TEXT;
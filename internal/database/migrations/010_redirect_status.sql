@@ -0,0 +1,4 @@
+-- Migration 010: Redirect Status Code
+
+-- 301/308 are permanent, 302/307 temporary
+ALTER TABLE redirects ADD COLUMN status_code INTEGER NOT NULL DEFAULT 302;
@@ -0,0 +1,4 @@
+-- Migration 011: API Key Expiry
+
+-- Set when a key is rotated; the old key keeps working until then and is revoked afterwards
+ALTER TABLE api_keys ADD COLUMN expires_at DATETIME;
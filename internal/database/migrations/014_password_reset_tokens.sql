@@ -0,0 +1,11 @@
+-- Migration 014: Password Reset Tokens
+
+-- One-time tokens issued by `fazt server reset-token`. Only the SHA-256 of the
+-- token is stored; used_at is set when the token is redeemed.
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
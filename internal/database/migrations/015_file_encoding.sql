@@ -0,0 +1,5 @@
+-- Migration 015: Compressed File Storage
+
+-- How files.content is stored: '' for raw bytes, 'gzip' for gzip-compressed.
+-- size_bytes and hash always describe the uncompressed file.
+ALTER TABLE files ADD COLUMN encoding TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- Migration 020: Preview Sites
+
+-- Preview deploys get a generated subdomain and may expire
+ALTER TABLE sites ADD COLUMN preview BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE sites ADD COLUMN expires_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_sites_expires_at ON sites(expires_at) WHERE expires_at IS NOT NULL;
@@ -2,14 +2,22 @@ package database
 
 import (
 	"fmt"
-	"log"
 	"math/rand"
 	"time"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
-// GenerateMockData inserts sample data for testing
+// GenerateMockData inserts sample data for testing, seeded from the current time
 func GenerateMockData() error {
-	log.Println("Generating mock data...")
+	return GenerateMockDataSeeded(time.Now().UnixNano())
+}
+
+// GenerateMockDataSeeded inserts sample data for testing.
+// The same seed always picks the same values, for reproducible demos and tests.
+func GenerateMockDataSeeded(seed int64) error {
+	logging.Infof("Generating mock data (seed %d)...", seed)
+	rng := rand.New(rand.NewSource(seed))
 
 	// Sample domains
 	domains := []string{
@@ -69,17 +77,17 @@ func GenerateMockData() error {
 
 	// Generate 100 events
 	for i := 0; i < 100; i++ {
-		domain := domains[rand.Intn(len(domains))]
-		tags := tagSets[rand.Intn(len(tagSets))]
-		sourceType := []string{"web", "pixel", "redirect"}[rand.Intn(3)]
-		eventType := []string{"pageview", "click", "redirect"}[rand.Intn(3)]
-		path := paths[rand.Intn(len(paths))]
-		referrer := referrers[rand.Intn(len(referrers))]
-		userAgent := userAgents[rand.Intn(len(userAgents))]
-		ipAddress := fmt.Sprintf("192.168.1.%d", rand.Intn(255))
+		domain := domains[rng.Intn(len(domains))]
+		tags := tagSets[rng.Intn(len(tagSets))]
+		sourceType := []string{"web", "pixel", "redirect"}[rng.Intn(3)]
+		eventType := []string{"pageview", "click", "redirect"}[rng.Intn(3)]
+		path := paths[rng.Intn(len(paths))]
+		referrer := referrers[rng.Intn(len(referrers))]
+		userAgent := userAgents[rng.Intn(len(userAgents))]
+		ipAddress := fmt.Sprintf("192.168.1.%d", rng.Intn(255))
 
 		// Random timestamp within last 7 days
-		hoursAgo := rand.Intn(168) // 7 days * 24 hours
+		hoursAgo := rng.Intn(168) // 7 days * 24 hours
 		createdAt := time.Now().Add(-time.Duration(hoursAgo) * time.Hour)
 
 		_, err := db.Exec(`
@@ -122,8 +130,8 @@ func GenerateMockData() error {
 	for i := 0; i < 10; i++ {
 		slug := redirectSlugs[i]
 		destination := destinations[i]
-		tags := tagSets[rand.Intn(len(tagSets))]
-		clickCount := rand.Intn(100)
+		tags := tagSets[rng.Intn(len(tagSets))]
+		clickCount := rng.Intn(100)
 
 		_, err := db.Exec(`
 			INSERT INTO redirects (slug, destination, tags, click_count)
@@ -158,7 +166,7 @@ func GenerateMockData() error {
 		// First 3 webhooks without secret for easy testing, last 2 with secret
 		secret := ""
 		if i >= 3 {
-			secret = fmt.Sprintf("secret_%d_%d", i, rand.Intn(10000))
+			secret = fmt.Sprintf("secret_%d_%d", i, rng.Intn(10000))
 		}
 		isActive := true
 
@@ -172,10 +180,10 @@ func GenerateMockData() error {
 		}
 	}
 
-	log.Println("Mock data generated successfully")
-	log.Println("- 100 events")
-	log.Println("- 10 redirects")
-	log.Println("- 5 webhooks")
+	logging.Infof("Mock data generated successfully")
+	logging.Infof("- 100 events")
+	logging.Infof("- 10 redirects")
+	logging.Infof("- 5 webhooks")
 
 	return nil
 }
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// mockSnapshot returns the generated rows that depend on the seed
+func mockSnapshot(t *testing.T, seed int64) []string {
+	t.Helper()
+	if err := Init(filepath.Join(t.TempDir(), "mock.db")); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer Close()
+
+	if err := GenerateMockDataSeeded(seed); err != nil {
+		t.Fatalf("GenerateMockDataSeeded failed: %v", err)
+	}
+
+	rows, err := db.Query("SELECT domain || path || ip_address FROM events ORDER BY id")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		var s string
+		rows.Scan(&s)
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestGenerateMockDataSeeded(t *testing.T) {
+	a := mockSnapshot(t, 42)
+	b := mockSnapshot(t, 42)
+	c := mockSnapshot(t, 7)
+
+	if len(a) != 100 {
+		t.Fatalf("got %d events, want 100", len(a))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("event %d differs for the same seed: %q vs %q", i, a[i], b[i])
+		}
+	}
+
+	same := true
+	for i := range a {
+		if a[i] != c[i] {
+			same = false
+			break
+		}
+	}
+	if same {
+		t.Error("different seeds produced identical events")
+	}
+}
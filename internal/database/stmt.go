@@ -0,0 +1,67 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// StmtCache prepares each SQL statement once, on first use, and hands out the
+// same *sql.Stmt afterwards. It is safe for concurrent use; *sql.Stmt itself
+// manages per-connection preparation.
+type StmtCache struct {
+	db    *sql.DB
+	mu    sync.RWMutex
+	stmts map[string]*sql.Stmt
+}
+
+// NewStmtCache returns an empty cache for db
+func NewStmtCache(db *sql.DB) *StmtCache {
+	return &StmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// Prepare returns the prepared statement for query, preparing it if needed
+func (c *StmtCache) Prepare(query string) (*sql.Stmt, error) {
+	c.mu.RLock()
+	stmt := c.stmts[query]
+	c.mu.RUnlock()
+	if stmt != nil {
+		return stmt, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if stmt := c.stmts[query]; stmt != nil {
+		return stmt, nil
+	}
+	stmt, err := c.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	c.stmts[query] = stmt
+	return stmt, nil
+}
+
+// Close closes every cached statement and empties the cache
+func (c *StmtCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var firstErr error
+	for query, stmt := range c.stmts {
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(c.stmts, query)
+	}
+	return firstErr
+}
+
+// Stmt returns a prepared statement for query on the shared database, for hot
+// paths that run the same SQL on every request
+func Stmt(query string) (*sql.Stmt, error) {
+	if stmts == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return stmts.Prepare(query)
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+const benchInsertSQL = `
+	INSERT INTO events (domain, source_type, event_type, path, referrer, user_agent, ip_address, query_params)
+	VALUES (?, 'hosting', 'pageview', ?, ?, ?, ?, ?)
+`
+
+func initTestDB(tb testing.TB) {
+	tb.Helper()
+	if err := Init(filepath.Join(tb.TempDir(), "stmt.db")); err != nil {
+		tb.Fatalf("Init failed: %v", err)
+	}
+	tb.Cleanup(func() { Close() })
+}
+
+func TestStmt_ReusesStatement(t *testing.T) {
+	initTestDB(t)
+
+	first, err := Stmt(benchInsertSQL)
+	if err != nil {
+		t.Fatalf("Stmt() error = %v", err)
+	}
+
+	// Concurrent lookups all get the statement prepared first
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stmt, err := Stmt(benchInsertSQL)
+			if err != nil || stmt != first {
+				t.Errorf("Stmt() = %p, %v; want the cached %p", stmt, err, first)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := first.Exec("blog", "/", "", "test", "127.0.0.1", ""); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM events WHERE domain = 'blog'").Scan(&count)
+	if count != 1 {
+		t.Errorf("got %d events, want 1", count)
+	}
+}
+
+func TestStmt_InvalidSQL(t *testing.T) {
+	initTestDB(t)
+
+	// The driver may defer compiling the statement until it first runs
+	stmt, err := Stmt("INSERT INTO nowhere VALUES (?)")
+	if err == nil {
+		_, err = stmt.Exec(1)
+	}
+	if err == nil {
+		t.Error("a statement on an unknown table should fail")
+	}
+}
+
+func TestStmt_AfterClose(t *testing.T) {
+	initTestDB(t)
+	Close()
+
+	if _, err := Stmt(benchInsertSQL); err == nil {
+		t.Error("Stmt() after Close should fail")
+	}
+}
+
+func BenchmarkInsert_Prepared(b *testing.B) {
+	initTestDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stmt, err := Stmt(benchInsertSQL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := stmt.Exec("blog", "/", "", "bench", "127.0.0.1", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInsert_AdHoc(b *testing.B) {
+	initTestDB(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.Exec(benchInsertSQL, "blog", "/", "", "bench", "127.0.0.1", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/hosting"
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// VFSRehashHandler recomputes the hash and MIME type of every stored file, for use
+// after a storage format change. With ?recompress=true files are also re-encoded
+// with the current gzip settings.
+// POST /api/admin/vfs/rehash
+func VFSRehashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	recompress := false
+	if v := r.URL.Query().Get("recompress"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			jsonError(w, "recompress must be true or false", http.StatusBadRequest)
+			return
+		}
+		recompress = b
+	}
+
+	result, err := hosting.RehashFiles(recompress)
+	if err != nil {
+		logging.Errorf("VFS rehash failed after %d files: %v", result.Scanned, err)
+		jsonError(w, "Rehash failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "vfs_rehash", "/api/admin/vfs/rehash")
+	logging.Infof("VFS rehash: %d of %d files updated (recompress=%t)", result.Updated, result.Scanned, recompress)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"scanned":    result.Scanned,
+		"updated":    result.Updated,
+		"recompress": recompress,
+	})
+}
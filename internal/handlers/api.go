@@ -1,59 +1,66 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/assets"
+	"github.com/jikku/command-center/internal/audit"
 	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
 	"github.com/jikku/command-center/internal/models"
 )
 
 // StatsHandler returns dashboard statistics
 func StatsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	// Queries are abandoned if the request times out (see middleware.RequestTimeout)
+	ctx := r.Context()
 	db := database.GetDB()
 	stats := models.Stats{
 		EventsBySourceType: make(map[string]int64),
 	}
 
-	// Total events today
-	db.QueryRow(`
+	// Total events today (a range on created_at, so idx_events_created_at applies)
+	db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM events
-		WHERE DATE(created_at) = DATE('now')
+		WHERE created_at >= DATE('now')
 	`).Scan(&stats.TotalEventsToday)
 
 	// Total events this week
-	db.QueryRow(`
+	db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM events
 		WHERE created_at >= DATE('now', '-7 days')
 	`).Scan(&stats.TotalEventsWeek)
 
 	// Total events this month
-	db.QueryRow(`
+	db.QueryRowContext(ctx, `
 		SELECT COUNT(*) FROM events
 		WHERE created_at >= DATE('now', '-30 days')
 	`).Scan(&stats.TotalEventsMonth)
 
 	// Total events all time
-	db.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&stats.TotalEventsAllTime)
+	db.QueryRowContext(ctx, `SELECT COUNT(*) FROM events`).Scan(&stats.TotalEventsAllTime)
 
 	// Events by source type
-	rows, _ := db.Query(`
+	rows, err := db.QueryContext(ctx, `
 		SELECT source_type, COUNT(*) as count
 		FROM events
 		GROUP BY source_type
 	`)
-	defer rows.Close()
-	for rows.Next() {
+	for err == nil && rows.Next() {
 		var sourceType string
 		var count int64
 		rows.Scan(&sourceType, &count)
@@ -61,7 +68,7 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Top 10 domains
-	rows, _ = db.Query(`
+	rows, err = db.QueryContext(ctx, `
 		SELECT domain, COUNT(*) as count
 		FROM events
 		WHERE domain != ''
@@ -69,15 +76,14 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 		ORDER BY count DESC
 		LIMIT 10
 	`)
-	defer rows.Close()
-	for rows.Next() {
+	for err == nil && rows.Next() {
 		var ds models.DomainStat
 		rows.Scan(&ds.Domain, &ds.Count)
 		stats.TopDomains = append(stats.TopDomains, ds)
 	}
 
 	// Top 10 tags
-	rows, _ = db.Query(`
+	rows, err = db.QueryContext(ctx, `
 		SELECT tags, COUNT(*) as count
 		FROM events
 		WHERE tags != ''
@@ -85,8 +91,7 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 		ORDER BY count DESC
 		LIMIT 10
 	`)
-	defer rows.Close()
-	for rows.Next() {
+	for err == nil && rows.Next() {
 		var tagsStr string
 		var count int64
 		rows.Scan(&tagsStr, &count)
@@ -98,34 +103,38 @@ func StatsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Events timeline (hourly for last 24 hours)
-	rows, _ = db.Query(`
+	rows, err = db.QueryContext(ctx, `
 		SELECT strftime('%Y-%m-%d %H:00', created_at) as hour, COUNT(*) as count
 		FROM events
 		WHERE created_at >= DATETIME('now', '-24 hours')
 		GROUP BY hour
 		ORDER BY hour
 	`)
-	defer rows.Close()
-	for rows.Next() {
+	for err == nil && rows.Next() {
 		var ts models.TimelineStat
 		rows.Scan(&ts.Timestamp, &ts.Count)
 		stats.EventsTimeline = append(stats.EventsTimeline, ts)
 	}
 
 	// Total unique domains
-	db.QueryRow(`SELECT COUNT(DISTINCT domain) FROM events`).Scan(&stats.TotalUniqueDomains)
+	db.QueryRowContext(ctx, `SELECT COUNT(DISTINCT domain) FROM events`).Scan(&stats.TotalUniqueDomains)
 
 	// Total redirect clicks
-	db.QueryRow(`SELECT COALESCE(SUM(click_count), 0) FROM redirects`).Scan(&stats.TotalRedirectClicks)
+	db.QueryRowContext(ctx, `SELECT COALESCE(SUM(click_count), 0) FROM redirects`).Scan(&stats.TotalRedirectClicks)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	writeCachedJSON(w, r, stats, time.Time{})
 }
 
 // EventsHandler returns paginated events with filtering
+// GET /api/events
+// DELETE /api/events?domain=&confirm=true - purge a domain's events (and, with redirects=true, redirects to it)
 func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		purgeEvents(w, r)
+		return
+	}
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -134,6 +143,7 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 	domain := query.Get("domain")
 	tags := query.Get("tags")
 	sourceType := query.Get("source_type")
+	search := strings.TrimSpace(query.Get("q"))
 	limit := parseInt(query.Get("limit"), 50)
 	offset := parseInt(query.Get("offset"), 0)
 
@@ -153,16 +163,23 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 		where = append(where, "source_type = ?")
 		args = append(args, sourceType)
 	}
+	if search != "" {
+		// Substring match across the free-text columns
+		where = append(where, `(domain LIKE ? ESCAPE '\' OR COALESCE(path, '') LIKE ? ESCAPE '\'
+			OR COALESCE(referrer, '') LIKE ? ESCAPE '\' OR COALESCE(user_agent, '') LIKE ? ESCAPE '\')`)
+		pattern := likeContains(search)
+		args = append(args, pattern, pattern, pattern, pattern)
+	}
 
 	whereClause := strings.Join(where, " AND ")
 	sql := "SELECT id, domain, tags, source_type, event_type, path, referrer, user_agent, ip_address, created_at FROM events WHERE " + whereClause + " ORDER BY created_at DESC LIMIT ? OFFSET ?"
 	args = append(args, limit, offset)
 
 	db := database.GetDB()
-	rows, err := db.Query(sql, args...)
+	rows, err := db.QueryContext(r.Context(), sql, args...)
 	if err != nil {
-		log.Printf("Error querying events: %v", err)
-		http.Error(w, "Failed to query events", http.StatusInternalServerError)
+		logging.Errorf("Error querying events: %v", err)
+		jsonError(w, "Failed to query events", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
@@ -189,19 +206,24 @@ func EventsHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(events)
+	writeCachedJSON(w, r, events, time.Time{})
+}
+
+// likeContains returns a LIKE pattern (for use with ESCAPE '\') matching s literally anywhere
+func likeContains(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+	return "%" + s + "%"
 }
 
 // DomainsHandler returns list of domains with event counts
 func DomainsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	db := database.GetDB()
-	rows, err := db.Query(`
+	rows, err := db.QueryContext(r.Context(), `
 		SELECT domain, COUNT(*) as count
 		FROM events
 		WHERE domain != ''
@@ -209,8 +231,8 @@ func DomainsHandler(w http.ResponseWriter, r *http.Request) {
 		ORDER BY count DESC
 	`)
 	if err != nil {
-		log.Printf("Error querying domains: %v", err)
-		http.Error(w, "Failed to query domains", http.StatusInternalServerError)
+		logging.Errorf("Error querying domains: %v", err)
+		jsonError(w, "Failed to query domains", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
@@ -222,19 +244,18 @@ func DomainsHandler(w http.ResponseWriter, r *http.Request) {
 		domains = append(domains, ds)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(domains)
+	writeCachedJSON(w, r, domains, time.Time{})
 }
 
 // TagsHandler returns list of tags with usage counts
 func TagsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	db := database.GetDB()
-	rows, err := db.Query(`
+	rows, err := db.QueryContext(r.Context(), `
 		SELECT tags, COUNT(*) as count
 		FROM events
 		WHERE tags != ''
@@ -242,8 +263,8 @@ func TagsHandler(w http.ResponseWriter, r *http.Request) {
 		ORDER BY count DESC
 	`)
 	if err != nil {
-		log.Printf("Error querying tags: %v", err)
-		http.Error(w, "Failed to query tags", http.StatusInternalServerError)
+		logging.Errorf("Error querying tags: %v", err)
+		jsonError(w, "Failed to query tags", http.StatusInternalServerError)
 		return
 	}
 	defer rows.Close()
@@ -255,23 +276,66 @@ func TagsHandler(w http.ResponseWriter, r *http.Request) {
 		var count int64
 		rows.Scan(&tagsStr, &count)
 
-		tags := strings.Split(tagsStr, ",")
-		for _, tag := range tags {
-			tag = strings.TrimSpace(tag)
-			if tag != "" {
-				tagCounts[tag] += count
-			}
+		// Older rows may predate normalization
+		for _, tag := range models.SplitTags(tagsStr) {
+			tagCounts[tag] += count
 		}
 	}
 
-	// Convert to array
+	// Convert to array, ordered so the ETag is stable
 	tags := []models.TagStat{}
 	for tag, count := range tagCounts {
 		tags = append(tags, models.TagStat{Tag: tag, Count: count})
 	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+
+	writeCachedJSON(w, r, tags, time.Time{})
+}
+
+// purgeEvents handles DELETE /api/events?domain=&confirm=true[&redirects=true],
+// deleting everything recorded for a domain (e.g. for a data deletion request)
+func purgeEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	domain := strings.TrimSpace(query.Get("domain"))
+	if domain == "" {
+		jsonError(w, "domain is required", http.StatusBadRequest)
+		return
+	}
+	if query.Get("confirm") != "true" {
+		jsonError(w, "Deleting events can't be undone; add confirm=true", http.StatusBadRequest)
+		return
+	}
+	redirects, err := parseBoolField(query.Get("redirects"))
+	if err != nil {
+		jsonError(w, "redirects must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	result, err := analytics.PurgeDomain(database.GetDB(), domain, redirects, analytics.PurgeBatchSize)
+	if err != nil {
+		// Batches already deleted stay deleted; report them so a retry's counts add up
+		logging.Errorf("Purge of %s failed after %d events: %v", domain, result.Events, err)
+		audit.Log(sessionUsername(r), analytics.RemoteIP(r), "events_purge", domain, "failure",
+			fmt.Sprintf("%d events, %d redirects deleted before: %v", result.Events, result.Redirects, err))
+		jsonError(w, "Purge failed; run it again to finish", http.StatusInternalServerError)
+		return
+	}
+
+	audit.Log(sessionUsername(r), analytics.RemoteIP(r), "events_purge", domain, "success",
+		fmt.Sprintf("%d events, %d redirects deleted", result.Events, result.Redirects))
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tags)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":           true,
+		"domain":            domain,
+		"events_deleted":    result.Events,
+		"redirects_deleted": result.Redirects,
+	})
 }
 
 // RedirectsHandler handles redirects CRUD
@@ -281,54 +345,73 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method == http.MethodGet {
 		// List all redirects
 		db := database.GetDB()
-		rows, err := db.Query(`
-			SELECT id, slug, destination, tags, click_count, created_at
-			FROM redirects
-			ORDER BY click_count DESC
-		`)
+		rows, err := db.Query(`SELECT ` + redirectColumns + ` FROM redirects ORDER BY click_count DESC`)
 		if err != nil {
-			log.Printf("Error querying redirects: %v", err)
-			http.Error(w, "Failed to query redirects", http.StatusInternalServerError)
+			logging.Errorf("Error querying redirects: %v", err)
+			jsonError(w, "Failed to query redirects", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
 
 		redirects := []map[string]interface{}{}
 		for rows.Next() {
-			var id, clickCount int64
-			var slug, destination, tags string
-			var createdAt time.Time
-
-			rows.Scan(&id, &slug, &destination, &tags, &clickCount, &createdAt)
-
-			redirects = append(redirects, map[string]interface{}{
-				"id":          id,
-				"slug":        slug,
-				"destination": destination,
-				"tags":        strings.Split(tags, ","),
-				"click_count": clickCount,
-				"created_at":  createdAt.Format(time.RFC3339),
-			})
+			redirect, _, err := scanRedirect(rows)
+			if err != nil {
+				logging.Errorf("Error scanning redirect: %v", err)
+				jsonError(w, "Failed to query redirects", http.StatusInternalServerError)
+				return
+			}
+			redirects = append(redirects, redirect)
+		}
+		if err := rows.Err(); err != nil {
+			logging.Errorf("Error reading redirects: %v", err)
+			jsonError(w, "Failed to query redirects", http.StatusInternalServerError)
+			return
 		}
 
-		json.NewEncoder(w).Encode(redirects)
+		writeCachedJSON(w, r, redirects, time.Time{})
 
 	} else if r.Method == http.MethodPost {
 		// Create new redirect
 		var req struct {
-			Slug        string   `json:"slug"`
-			Destination string   `json:"destination"`
-			Tags        []string `json:"tags"`
+			Slug        string     `json:"slug"`
+			Destination string     `json:"destination"`
+			Tags        []string   `json:"tags"`
+			StatusCode  int        `json:"status_code"`
+			StartsAt    *time.Time `json:"starts_at"`
+			ExpiresAt   *time.Time `json:"expires_at"`
+			FallbackURL string     `json:"fallback_url"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			jsonError(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 
 		// Validate
 		if req.Slug == "" || req.Destination == "" {
-			http.Error(w, "Slug and destination are required", http.StatusBadRequest)
+			jsonError(w, "Slug and destination are required", http.StatusBadRequest)
+			return
+		}
+		if err := validateRedirectURL("destination", req.Destination); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.FallbackURL != "" {
+			if err := validateRedirectURL("fallback_url", req.FallbackURL); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.StatusCode == 0 {
+			req.StatusCode = http.StatusFound
+		}
+		if !models.ValidRedirectStatus(req.StatusCode) {
+			jsonError(w, "status_code must be 301, 302, 307 or 308", http.StatusBadRequest)
+			return
+		}
+		if err := validateRedirectSchedule(req.StartsAt, req.ExpiresAt); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
@@ -337,20 +420,21 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 		var exists int
 		db.QueryRow("SELECT COUNT(*) FROM redirects WHERE slug = ?", req.Slug).Scan(&exists)
 		if exists > 0 {
-			http.Error(w, "Slug already exists", http.StatusConflict)
+			jsonError(w, "Slug already exists", http.StatusConflict)
 			return
 		}
 
 		// Insert
-		tagsStr := strings.Join(req.Tags, ",")
+		req.Tags = models.NormalizeTags(req.Tags)
+		tagsStr := models.JoinTags(req.Tags)
 		result, err := db.Exec(`
-			INSERT INTO redirects (slug, destination, tags)
-			VALUES (?, ?, ?)
-		`, req.Slug, req.Destination, tagsStr)
+			INSERT INTO redirects (slug, destination, tags, status_code, starts_at, expires_at, fallback_url)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, req.Slug, req.Destination, tagsStr, req.StatusCode, utcOrNil(req.StartsAt), utcOrNil(req.ExpiresAt), req.FallbackURL)
 
 		if err != nil {
-			log.Printf("Error creating redirect: %v", err)
-			http.Error(w, "Failed to create redirect", http.StatusInternalServerError)
+			logging.Errorf("Error creating redirect: %v", err)
+			jsonError(w, "Failed to create redirect", http.StatusInternalServerError)
 			return
 		}
 
@@ -358,16 +442,270 @@ func RedirectsHandler(w http.ResponseWriter, r *http.Request) {
 
 		w.WriteHeader(http.StatusCreated)
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"id":          id,
-			"slug":        req.Slug,
-			"destination": req.Destination,
-			"tags":        req.Tags,
-			"click_count": 0,
+			"id":           id,
+			"slug":         req.Slug,
+			"destination":  req.Destination,
+			"tags":         req.Tags,
+			"click_count":  0,
+			"status_code":  req.StatusCode,
+			"starts_at":    req.StartsAt,
+			"expires_at":   req.ExpiresAt,
+			"fallback_url": req.FallbackURL,
+			"version":      1,
+		})
+
+	} else if r.Method == http.MethodPut {
+		// Update an existing redirect. Fields left out keep their value; for the
+		// schedule and fallback_url, null (or "") clears them.
+		// If-Match must carry the redirect's ETag, its version as "N", from
+		// GET /api/redirects/{id} or a previous PUT; each update bumps it.
+		var req struct {
+			ID          int64               `json:"id"`
+			Destination string              `json:"destination"`
+			Tags        []string            `json:"tags"`
+			StatusCode  int                 `json:"status_code"`
+			StartsAt    nullable[time.Time] `json:"starts_at"`
+			ExpiresAt   nullable[time.Time] `json:"expires_at"`
+			FallbackURL nullable[string]    `json:"fallback_url"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.ID == 0 {
+			jsonError(w, "ID is required", http.StatusBadRequest)
+			return
+		}
+		if req.StatusCode != 0 && !models.ValidRedirectStatus(req.StatusCode) {
+			jsonError(w, "status_code must be 301, 302, 307 or 308", http.StatusBadRequest)
+			return
+		}
+		if req.Destination != "" {
+			if err := validateRedirectURL("destination", req.Destination); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		fallbackURL := ""
+		if req.FallbackURL.Value != nil {
+			fallbackURL = *req.FallbackURL.Value
+		}
+		if fallbackURL != "" {
+			if err := validateRedirectURL("fallback_url", fallbackURL); err != nil {
+				jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		db := database.GetDB()
+		var version int64
+		var startsAt, expiresAt sql.NullTime
+		err := db.QueryRow("SELECT version, starts_at, expires_at FROM redirects WHERE id = ?", req.ID).Scan(&version, &startsAt, &expiresAt)
+		if err == sql.ErrNoRows {
+			jsonError(w, "Redirect not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logging.Errorf("Error loading redirect: %v", err)
+			jsonError(w, "Failed to update redirect", http.StatusInternalServerError)
+			return
+		}
+		if !checkIfMatch(w, r, redirectETag(version)) {
+			return
+		}
+
+		// The window must stay non-empty with the bounds that aren't changing
+		if err := validateRedirectSchedule(scheduleBound(req.StartsAt, startsAt), scheduleBound(req.ExpiresAt, expiresAt)); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// The version check repeats in the UPDATE, so a concurrent edit between
+		// the read and the write still fails the precondition
+		result, err := db.Exec(`
+			UPDATE redirects SET
+				destination = COALESCE(NULLIF(?, ''), destination),
+				tags = CASE WHEN ? THEN ? ELSE tags END,
+				status_code = COALESCE(NULLIF(?, 0), status_code),
+				starts_at = CASE WHEN ? THEN ? ELSE starts_at END,
+				expires_at = CASE WHEN ? THEN ? ELSE expires_at END,
+				fallback_url = CASE WHEN ? THEN ? ELSE fallback_url END,
+				version = version + 1
+			WHERE id = ? AND version = ?
+		`, req.Destination, req.Tags != nil, models.JoinTags(req.Tags), req.StatusCode,
+			req.StartsAt.Set, utcOrNil(req.StartsAt.Value), req.ExpiresAt.Set, utcOrNil(req.ExpiresAt.Value),
+			req.FallbackURL.Set, fallbackURL, req.ID, version)
+		if err != nil {
+			logging.Errorf("Error updating redirect: %v", err)
+			jsonError(w, "Failed to update redirect", http.StatusInternalServerError)
+			return
+		}
+
+		if n, _ := result.RowsAffected(); n == 0 {
+			jsonError(w, "Resource was modified; reload and try again", http.StatusPreconditionFailed)
+			return
+		}
+
+		w.Header().Set("ETag", redirectETag(version+1))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"id":      req.ID,
+			"version": version + 1,
 		})
 
 	} else {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// redirectETag is the ETag for a redirect at version
+func redirectETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// redirectColumns are the columns scanRedirect reads, in order
+const redirectColumns = `id, slug, destination, tags, click_count, status_code, starts_at, expires_at,
+	COALESCE(fallback_url, ''), version, created_at`
+
+// scanRedirect reads a row of redirectColumns into the redirect's JSON form
+// and returns its version
+func scanRedirect(row interface{ Scan(...interface{}) error }) (map[string]interface{}, int64, error) {
+	var id, clickCount, version int64
+	var statusCode int
+	var slug, destination, tags, fallbackURL string
+	var startsAt, expiresAt sql.NullTime
+	var createdAt time.Time
+
+	if err := row.Scan(&id, &slug, &destination, &tags, &clickCount, &statusCode, &startsAt, &expiresAt, &fallbackURL, &version, &createdAt); err != nil {
+		return nil, 0, err
+	}
+
+	redirect := map[string]interface{}{
+		"id":          id,
+		"slug":        slug,
+		"destination": destination,
+		"tags":        models.SplitTags(tags),
+		"click_count": clickCount,
+		"status_code": statusCode,
+		"version":     version,
+		"created_at":  createdAt.Format(time.RFC3339),
+	}
+	if startsAt.Valid {
+		redirect["starts_at"] = startsAt.Time.Format(time.RFC3339)
+	}
+	if expiresAt.Valid {
+		redirect["expires_at"] = expiresAt.Time.Format(time.RFC3339)
+	}
+	if fallbackURL != "" {
+		redirect["fallback_url"] = fallbackURL
+	}
+	return redirect, version, nil
+}
+
+// RedirectDetailHandler returns one redirect with its version as the ETag, which
+// is what a PUT must send back in If-Match
+// GET /api/redirects/{id}
+func RedirectDetailHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	row := database.GetDB().QueryRowContext(r.Context(), `SELECT `+redirectColumns+` FROM redirects WHERE id = ?`, id)
+	redirect, version, err := scanRedirect(row)
+	if err == sql.ErrNoRows {
+		jsonError(w, "Redirect not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.Errorf("Error loading redirect: %v", err)
+		jsonError(w, "Failed to query redirect", http.StatusInternalServerError)
+		return
+	}
+
+	etag := redirectETag(version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if notModified(r, etag, time.Time{}) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redirect)
+}
+
+// RedirectActionsHandler routes endpoints under /api/redirects/...
+func RedirectActionsHandler(w http.ResponseWriter, r *http.Request) {
+	action := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/redirects/"), "/")
+
+	switch action {
+	case "":
+		RedirectsHandler(w, r)
+	case "import":
+		RedirectsImportHandler(w, r)
+	case "export":
+		RedirectsExportHandler(w, r)
+	default:
+		// {id} or {id}/stats
+		parts := strings.Split(action, "/")
+		id, err := strconv.ParseInt(parts[0], 10, 64)
+		switch {
+		case err != nil:
+			jsonError(w, "Not found", http.StatusNotFound)
+		case len(parts) == 1:
+			RedirectDetailHandler(w, r, id)
+		case len(parts) == 2 && parts[1] == "stats":
+			RedirectStatsHandler(w, r, id)
+		default:
+			jsonError(w, "Not found", http.StatusNotFound)
+		}
+	}
+}
+
+// validateRedirectSchedule checks that an activation window is not empty
+func validateRedirectSchedule(startsAt, expiresAt *time.Time) error {
+	if startsAt != nil && expiresAt != nil && !expiresAt.After(*startsAt) {
+		return errors.New("expires_at must be after starts_at")
+	}
+	return nil
+}
+
+// nullable is a JSON field that tells a missing field from null: Set is false
+// when the field was left out, and Value is nil when it was null
+type nullable[T any] struct {
+	Set   bool
+	Value *T
+}
+
+func (n *nullable[T]) UnmarshalJSON(data []byte) error {
+	n.Set = true
+	if string(data) == "null" {
+		n.Value = nil
+		return nil
+	}
+	return json.Unmarshal(data, &n.Value)
+}
+
+// scheduleBound returns a schedule field's new value if it was sent,
+// otherwise the stored one
+func scheduleBound(field nullable[time.Time], stored sql.NullTime) *time.Time {
+	if field.Set {
+		return field.Value
+	}
+	if stored.Valid {
+		return &stored.Time
+	}
+	return nil
+}
+
+// utcOrNil converts an optional time to UTC for storage, or nil for NULL
+func utcOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
 	}
+	return t.UTC()
 }
 
 // WebhooksHandler handles webhooks CRUD
@@ -383,8 +721,8 @@ func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
 			ORDER BY created_at DESC
 		`)
 		if err != nil {
-			log.Printf("Error querying webhooks: %v", err)
-			http.Error(w, "Failed to query webhooks", http.StatusInternalServerError)
+			logging.Errorf("Error querying webhooks: %v", err)
+			jsonError(w, "Failed to query webhooks", http.StatusInternalServerError)
 			return
 		}
 		defer rows.Close()
@@ -419,13 +757,13 @@ func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			jsonError(w, "Invalid JSON", http.StatusBadRequest)
 			return
 		}
 
 		// Validate
 		if req.Name == "" || req.Endpoint == "" {
-			http.Error(w, "Name and endpoint are required", http.StatusBadRequest)
+			jsonError(w, "Name and endpoint are required", http.StatusBadRequest)
 			return
 		}
 
@@ -434,7 +772,7 @@ func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
 		var exists int
 		db.QueryRow("SELECT COUNT(*) FROM webhooks WHERE endpoint = ?", req.Endpoint).Scan(&exists)
 		if exists > 0 {
-			http.Error(w, "Endpoint already exists", http.StatusConflict)
+			jsonError(w, "Endpoint already exists", http.StatusConflict)
 			return
 		}
 
@@ -445,8 +783,8 @@ func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
 		`, req.Name, req.Endpoint, req.Secret)
 
 		if err != nil {
-			log.Printf("Error creating webhook: %v", err)
-			http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+			logging.Errorf("Error creating webhook: %v", err)
+			jsonError(w, "Failed to create webhook", http.StatusInternalServerError)
 			return
 		}
 
@@ -462,7 +800,7 @@ func WebhooksHandler(w http.ResponseWriter, r *http.Request) {
 		})
 
 	} else {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -471,8 +809,8 @@ func DashboardHandler(w http.ResponseWriter, r *http.Request) {
 	// Read from embedded FS
 	content, err := assets.WebFS.ReadFile("web/templates/index.html")
 	if err != nil {
-		log.Printf("Error loading dashboard template: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.Errorf("Error loading dashboard template: %v", err)
+		jsonError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
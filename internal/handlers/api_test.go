@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jikku/command-center/internal/database"
+)
+
+func TestLikeContains(t *testing.T) {
+	tests := map[string]string{
+		"chrome":  "%chrome%",
+		"50%_off": `%50\%\_off%`,
+		`a\b`:     `%a\\b%`,
+	}
+	for in, want := range tests {
+		if got := likeContains(in); got != want {
+			t.Errorf("likeContains(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestEventsHandler_Search(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	db.Exec(`INSERT INTO events (domain, source_type, event_type, path, referrer, user_agent) VALUES ('a.com', 'web', 'pageview', '/pricing', 'https://news.ycombinator.com', 'Firefox')`)
+	db.Exec(`INSERT INTO events (domain, source_type, event_type, path, referrer, user_agent) VALUES ('b.com', 'web', 'pageview', '/blog', '', 'Mozilla/5.0 Chrome/120')`)
+	db.Exec(`INSERT INTO events (domain, source_type, event_type, path, referrer, user_agent) VALUES ('b.com', 'pixel', 'open', '/chrome-extension', '', 'Safari')`)
+	db.Exec(`INSERT INTO events (domain, source_type, event_type, path, referrer, user_agent) VALUES ('c.com', 'web', 'pageview', '/100%', '', 'curl')`)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"q=ycombinator", 1},
+		{"q=CHROME", 2},
+		{"q=chrome&source_type=web", 1},
+		{"q=chrome&domain=a.com", 0},
+		{"q=b.com", 2},
+		{"q=%25", 1},
+		{"q=nothing-matches", 0},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		EventsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/events?"+tt.query, nil))
+
+		var events []map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &events); err != nil {
+			t.Fatalf("%s: invalid JSON: %v", tt.query, err)
+		}
+		if len(events) != tt.want {
+			t.Errorf("%s: got %d events, want %d", tt.query, len(events), tt.want)
+		}
+	}
+}
+
+func TestEventsHandler_PurgeRequiresConfirm(t *testing.T) {
+	tests := map[string]int{
+		"":                         http.StatusBadRequest,
+		"domain=a.com":             http.StatusBadRequest,
+		"domain=a.com&confirm=yes": http.StatusBadRequest,
+		"domain=a.com&confirm=true&redirects=maybe": http.StatusBadRequest,
+	}
+	for query, want := range tests {
+		rec := httptest.NewRecorder()
+		EventsHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/events?"+query, nil))
+		if rec.Code != want {
+			t.Errorf("DELETE ?%s: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
+
+func TestEventsHandler_Purge(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	db.Exec(`INSERT INTO events (domain, source_type, event_type) VALUES ('a.com', 'web', 'pageview'), ('a.com', 'web', 'click'), ('b.com', 'web', 'pageview')`)
+
+	rec := httptest.NewRecorder()
+	EventsHandler(rec, httptest.NewRequest(http.MethodDelete, "/api/events?domain=a.com&confirm=true", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		EventsDeleted int64 `json:"events_deleted"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if body.EventsDeleted != 2 {
+		t.Errorf("events_deleted = %d, want 2", body.EventsDeleted)
+	}
+
+	var left int
+	db.QueryRow("SELECT COUNT(*) FROM events").Scan(&left)
+	if left != 1 {
+		t.Errorf("%d events left, want only b.com's", left)
+	}
+}
+
+func TestStatsHandler_ErrorIsJSON(t *testing.T) {
+	req := httptest.NewRequest("POST", "/api/stats", nil)
+	rec := httptest.NewRecorder()
+	rec.Header().Set("X-Request-ID", "req-42")
+
+	StatsHandler(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want 405", rec.Code)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error response is not JSON: %v", err)
+	}
+	if body["request_id"] != "req-42" || body["error"] != "Method not allowed" {
+		t.Errorf("body = %v, want the error with request_id req-42", body)
+	}
+}
+
+func TestStatsHandler_NotModified(t *testing.T) {
+	setupTestDatabase(t)
+
+	rec := httptest.NewRecorder()
+	StatsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q; want 200 with an ETag", rec.Code, etag)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	StatsHandler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("repeat status = %d, want 304", rec.Code)
+	}
+
+	// New data changes the ETag
+	database.GetDB().Exec(`INSERT INTO events (domain, source_type, event_type) VALUES ('a.com', 'web', 'pageview')`)
+	rec = httptest.NewRecorder()
+	StatsHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("status after new event = %d, want 200", rec.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jikku/command-center/internal/audit"
+)
+
+// AuditHandler returns paginated audit log entries
+// GET /api/audit?action=login&actor=admin&result=failure&since=2024-01-01&until=2024-02-01&limit=50&offset=0
+func AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := audit.QueryOptions{
+		Action:   query.Get("action"),
+		Username: query.Get("actor"),
+		Result:   query.Get("result"),
+		Limit:    parseInt(query.Get("limit"), 50),
+		Offset:   parseInt(query.Get("offset"), 0),
+	}
+	if opts.Limit > 500 {
+		opts.Limit = 500
+	}
+	if opts.Offset < 0 {
+		opts.Offset = 0
+	}
+
+	var err error
+	if opts.Since, err = parseAuditTime(query.Get("since")); err != nil {
+		jsonError(w, "Invalid since: use RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if opts.Until, err = parseAuditTime(query.Get("until")); err != nil {
+		jsonError(w, "Invalid until: use RFC3339 or YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	entries, total, err := audit.Query(opts)
+	if err != nil {
+		jsonError(w, "Failed to query audit log", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"entries": entries,
+		"total":   total,
+		"limit":   opts.Limit,
+		"offset":  opts.Offset,
+	})
+}
+
+// parseAuditTime accepts RFC3339 timestamps or plain dates; empty means no bound
+func parseAuditTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
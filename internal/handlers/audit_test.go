@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAuditTime(t *testing.T) {
+	if ts, err := parseAuditTime(""); err != nil || !ts.IsZero() {
+		t.Errorf("empty: got %v, %v; want zero time", ts, err)
+	}
+
+	ts, err := parseAuditTime("2024-03-01")
+	if err != nil || !ts.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("date: got %v, %v", ts, err)
+	}
+
+	ts, err = parseAuditTime("2024-03-01T12:30:00Z")
+	if err != nil || ts.Hour() != 12 || ts.Minute() != 30 {
+		t.Errorf("RFC3339: got %v, %v", ts, err)
+	}
+
+	if _, err := parseAuditTime("yesterday"); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
@@ -2,33 +2,43 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"html/template"
-	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/assets"
 	"github.com/jikku/command-center/internal/audit"
 	"github.com/jikku/command-center/internal/auth"
 	"github.com/jikku/command-center/internal/config"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/notifier"
 )
 
 var (
-	sessionStore *auth.SessionStore
-	rateLimiter  *auth.RateLimiter
+	sessionStore   *auth.SessionStore
+	rateLimiter    *auth.RateLimiter
+	accountLimiter *auth.RateLimiter
 )
 
-// InitAuth initializes the auth handlers with session store and rate limiter
-func InitAuth(store *auth.SessionStore, limiter *auth.RateLimiter) {
+// InitAuth initializes the auth handlers with the session store and the
+// per-IP and per-username login rate limiters
+func InitAuth(store *auth.SessionStore, limiter, accounts *auth.RateLimiter) {
 	sessionStore = store
 	rateLimiter = limiter
+	accountLimiter = accounts
 }
 
 // LoginPageHandler serves the login page
 func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
 	// If already logged in, redirect to dashboard
 	if sessionID, err := auth.GetSessionCookie(r); err == nil {
-		if valid, _ := sessionStore.ValidateSession(sessionID); valid {
+		if valid, _ := sessionStore.ValidateSessionFingerprint(sessionID, auth.RequestFingerprint(r)); valid {
 			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
 		}
@@ -37,8 +47,8 @@ func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
 	// Render login page
 	tmpl, err := template.ParseFS(assets.WebFS, "web/templates/login.html")
 	if err != nil {
-		log.Printf("Error loading login template: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.Errorf("Error loading login template: %v", err)
+		jsonError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
@@ -48,28 +58,25 @@ func LoginPageHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := tmpl.Execute(w, data); err != nil {
-		log.Printf("Error rendering login template: %v", err)
+		logging.Errorf("Error rendering login template: %v", err)
 	}
 }
 
 // LoginHandler handles login requests
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get client IP for rate limiting
-	ip := getClientIP(r)
+	// Rate limit on the connection's address, not a client-chosen header
+	ip := analytics.RemoteIP(r)
 
 	// Check rate limit
 	if !rateLimiter.AllowLogin(ip) {
-		log.Printf("Rate limit exceeded for IP: %s", ip)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusTooManyRequests)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Too many failed attempts. Please try again in 15 minutes.",
-		})
+		logging.Warnf("Rate limit exceeded for IP: %s", ip)
+		audit.LogFailure("", ip, "login", "/api/login", "locked out")
+		writeLoginLockout(w, "Too many failed attempts.", rateLimiter.RetryAfter(ip))
 		return
 	}
 
@@ -78,6 +85,7 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Username   string `json:"username"`
 		Password   string `json:"password"`
 		RememberMe bool   `json:"remember_me"`
+		Remember   bool   `json:"remember"` // alias for remember_me
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -89,38 +97,48 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Per-account limit stops password guessing from rotating IPs
+	account := accountKey(req.Username)
+	if !accountLimiter.AllowLogin(account) {
+		logging.Warnf("Rate limit exceeded for account %q from %s", req.Username, ip)
+		audit.LogFailure(req.Username, ip, "login", "/api/login", "account locked out")
+		writeLoginLockout(w, "Too many failed attempts for this account.", accountLimiter.RetryAfter(account))
+		return
+	}
+
 	// Get config
 	cfg := config.Get()
 
 	// Verify credentials
 	if req.Username != cfg.Auth.Username {
-		rateLimiter.RecordAttempt(ip)
-		audit.LogFailure(req.Username, ip, "login", "/api/login", "invalid username")
-		log.Printf("Login failed: invalid username from %s", ip)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid username or password",
-		})
+		recordFailedLogin(r, req.Username, "invalid username")
+		logging.Warnf("Login failed: invalid username from %s", ip)
+		writeLoginFailure(w, ip, account)
 		return
 	}
 
-	if err := auth.VerifyPassword(req.Password, cfg.Auth.PasswordHash); err != nil {
-		rateLimiter.RecordAttempt(ip)
-		audit.LogFailure(req.Username, ip, "login", "/api/login", "invalid password")
-		log.Printf("Login failed: invalid password from %s", ip)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid username or password",
-		})
+	if err := auth.VerifyPassword(req.Password, adminPasswordHash()); err != nil {
+		recordFailedLogin(r, req.Username, "invalid password")
+		logging.Warnf("Login failed: invalid password from %s", ip)
+		writeLoginFailure(w, ip, account)
 		return
 	}
 
-	// Credentials valid - create session
-	sessionID, err := sessionStore.CreateSession(req.Username)
+	// Credentials valid - create session. "Remember me" gets a long-lived session and a
+	// persistent cookie; otherwise the cookie ends with the browser session.
+	opts := auth.SessionOptions{Fingerprint: auth.RequestFingerprint(r)}
+	var cookieTTL time.Duration
+	if req.RememberMe || req.Remember {
+		opts.TTL = cfg.Auth.RememberMeDuration()
+		if opts.TTL == 0 {
+			opts.TTL = auth.RememberMeTTL
+		}
+		cookieTTL = opts.TTL
+	}
+
+	sessionID, err := sessionStore.CreateSessionWithOptions(req.Username, opts)
 	if err != nil {
-		log.Printf("Failed to create session: %v", err)
+		logging.Errorf("Failed to create session: %v", err)
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
@@ -130,19 +148,15 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set session cookie
-	ttl := auth.SessionTTL
-	if req.RememberMe {
-		ttl = auth.RememberMeTTL
-	}
-
-	auth.SetSessionCookie(w, sessionID, ttl, cfg.IsProduction())
+	auth.SetSessionCookie(w, sessionID, cookieTTL)
 
-	// Reset rate limit on successful login
+	// Reset rate limits on successful login
 	rateLimiter.Reset(ip)
+	accountLimiter.Reset(account)
 
 	// Log successful login
 	audit.LogSuccess(req.Username, ip, "login", "/api/login")
-	log.Printf("Login successful: %s from %s", req.Username, ip)
+	logging.Infof("Login successful: %s from %s", req.Username, ip)
 
 	// Return success
 	w.Header().Set("Content-Type", "application/json")
@@ -152,6 +166,73 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// recordFailedLogin counts a failed login against the request's address and username and audits it.
+// When the attempt locks the IP or the account out, the lockout is audited and an alert sent once per window.
+// The address is the connection's, so rotating X-Forwarded-For can't repeat the alert.
+func recordFailedLogin(r *http.Request, username, reason string) {
+	ip := analytics.RemoteIP(r)
+	account := accountKey(username)
+	rateLimiter.RecordAttempt(ip)
+	accountLimiter.RecordAttempt(account)
+	audit.LogFailure(username, ip, "login", "/api/login", reason)
+
+	if rateLimiter.MarkLockoutAlerted(ip) {
+		msg := fmt.Sprintf("%d failed logins from IP %s (username %q), locked %dm",
+			rateLimiter.GetAttempts(ip), ip, username, int(rateLimiter.Window().Minutes()))
+		reportLockout(ip, username, msg)
+	}
+
+	if accountLimiter.MarkLockoutAlerted(account) {
+		msg := fmt.Sprintf("%d failed logins for username %q (latest from IP %s), account locked %dm",
+			accountLimiter.GetAttempts(account), username, ip, int(accountLimiter.Window().Minutes()))
+		reportLockout(ip, username, msg)
+	}
+}
+
+// writeLoginFailure responds 401 with how many attempts are left before lockout
+func writeLoginFailure(w http.ResponseWriter, ip, account string) {
+	remaining := rateLimiter.Remaining(ip)
+	if n := accountLimiter.Remaining(account); n < remaining {
+		remaining = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":              "Invalid username or password",
+		"remaining_attempts": remaining,
+	})
+}
+
+// writeLoginLockout responds 429 with a Retry-After header for a locked-out IP or account
+func writeLoginLockout(w http.ResponseWriter, message string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	minutes := (seconds + 59) / 60
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":       fmt.Sprintf("%s Please try again in %d minute(s).", message, minutes),
+		"retry_after": seconds,
+	})
+}
+
+// reportLockout logs, audits and sends an alert for a login lockout
+func reportLockout(ip, username, msg string) {
+	logging.Warnf("Login lockout: %s", msg)
+	audit.Log(username, ip, "login_lockout", "/api/login", "locked", msg)
+	go notifier.NotifyError(msg)
+}
+
+// accountKey normalizes a username for the per-account rate limiter
+func accountKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
 // LogoutHandler handles logout requests
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	// Get session info for audit logging
@@ -170,7 +251,7 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Log logout
 	if username != "" {
-		ip := getClientIP(r)
+		ip := analytics.RemoteIP(r)
 		audit.LogSuccess(username, ip, "logout", "/api/logout")
 	}
 
@@ -188,56 +269,169 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
 
-// AuthStatusHandler returns the current authentication status
+// AuthStatusHandler returns the current authentication status.
+// It is public and always answers 200 so the dashboard can branch on "authenticated".
+// It reads the in-memory session only and does not extend it.
 func AuthStatusHandler(w http.ResponseWriter, r *http.Request) {
-	sessionID, err := auth.GetSessionCookie(r)
-	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	session := currentSession(r)
+	if session == nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"authenticated": false,
 		})
 		return
 	}
 
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"authenticated":     true,
+		"username":          session.Username,
+		"createdAt":         session.CreatedAt,
+		"expiresAt":         session.ExpiresAt,
+		"absoluteExpiresAt": session.AbsoluteExpiresAt,
+		"totpEnabled":       false, // no second factor yet
+		"roles":             []string{"admin"},
+	})
+}
+
+// currentSession returns the request's valid session, or nil.
+// Sessions rejected by fingerprint enforcement count as missing.
+func currentSession(r *http.Request) *auth.Session {
+	if sessionStore == nil {
+		return nil
+	}
+	sessionID, err := auth.GetSessionCookie(r)
+	if err != nil {
+		return nil
+	}
 	session, err := sessionStore.GetSession(sessionID)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"authenticated": false,
-		})
+		return nil
+	}
+	if !sessionStore.MatchesFingerprint(session, auth.RequestFingerprint(r)) {
+		return nil
+	}
+	return session
+}
+
+// ResetPasswordHandler sets a new admin password using a one-time token from `fazt server reset-token`.
+// All existing sessions are invalidated.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Guessing tokens counts against the same limit as guessing passwords.
+	// The address can't come from a client-supplied header, or every guess
+	// could claim a fresh one.
+	ip := analytics.RemoteIP(r)
+	if !rateLimiter.AllowLogin(ip) {
+		audit.LogFailure("", ip, "password_reset", "/api/reset-password", "locked out")
+		writeLoginLockout(w, "Too many failed attempts.", rateLimiter.RetryAfter(ip))
 		return
 	}
 
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// Check the password first so a weak one doesn't burn the token, but only
+	// hash it once the token is good, so bad tokens can't make us spend CPU
+	if err := auth.ValidatePassword(req.Password); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The token is only spent once the new password is saved
+	var passwordHash string
+	err := auth.RedeemResetToken(database.GetDB(), req.Token, func() error {
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			return err
+		}
+		if err := savePasswordHash(hash); err != nil {
+			return err
+		}
+		passwordHash = hash
+		return nil
+	})
+	if err == auth.ErrInvalidResetToken {
+		rateLimiter.RecordAttempt(ip)
+		audit.LogFailure("", ip, "password_reset", "/api/reset-password", err.Error())
+		jsonError(w, "Invalid or expired reset token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		audit.LogFailure("", ip, "password_reset", "/api/reset-password", err.Error())
+		logging.Errorf("Password reset failed: %v", err)
+		jsonError(w, "Failed to save new password", http.StatusInternalServerError)
+		return
+	}
+	setAdminPasswordHash(passwordHash)
+
+	cfg := config.Get()
+
+	sessions := sessionStore.DeleteUserSessions(cfg.Auth.Username)
+	rateLimiter.Reset(ip)
+	accountLimiter.Reset(accountKey(cfg.Auth.Username))
+
+	audit.LogSuccess(cfg.Auth.Username, ip, "password_reset", "/api/reset-password")
+	logging.Infof("Password reset for %s from %s (%d sessions invalidated)", cfg.Auth.Username, ip, sessions)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"authenticated": true,
-		"username":      session.Username,
-		"expiresAt":     session.ExpiresAt,
+		"success": true,
+		"message": "Password updated. Please log in again.",
 	})
 }
 
-// getClientIP extracts the client IP from the request
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// Take the first IP if multiple are present
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
+// adminPasswordHash returns the running admin password hash
+func adminPasswordHash() string {
+	return config.Get().Auth.PasswordHash
+}
+
+// setAdminPasswordHash replaces the running admin password hash, swapping the
+// live config like a config update does
+func setAdminPasswordHash(hash string) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config.Update(func(cfg *config.Config) {
+		cfg.Auth.PasswordHash = hash
+	})
+}
+
+// savePasswordHash writes a new password hash to the config file.
+// The file is re-read so env and flag overrides applied at startup aren't persisted.
+func savePasswordHash(passwordHash string) error {
+	// Config updates rewrite the same file
+	configMu.Lock()
+	defer configMu.Unlock()
 
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
+	path := config.Path()
+	if path == "" {
+		return fmt.Errorf("config file path unknown")
 	}
 
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	// Remove port if present
-	if idx := strings.LastIndex(ip, ":"); idx != -1 {
-		ip = ip[:idx]
+	fileCfg, err := config.LoadFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
 	}
+	fileCfg.Auth.PasswordHash = passwordHash
 
-	return ip
+	return config.SaveToFile(fileCfg, path)
+}
+
+// sessionUsername returns the dashboard user behind a request, or "" if there is no session
+func sessionUsername(r *http.Request) string {
+	if session := currentSession(r); session != nil {
+		return session.Username
+	}
+	return ""
 }
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/database"
+)
+
+func TestWriteLoginLockout(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeLoginLockout(w, "Too many failed attempts.", 90*time.Second+300*time.Millisecond)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "91" {
+		t.Errorf("Retry-After = %q, want 91", got)
+	}
+
+	var body struct {
+		Error      string `json:"error"`
+		RetryAfter int    `json:"retry_after"`
+	}
+	json.NewDecoder(w.Body).Decode(&body)
+	if body.RetryAfter != 91 {
+		t.Errorf("retry_after = %d, want 91", body.RetryAfter)
+	}
+	if body.Error != "Too many failed attempts. Please try again in 2 minute(s)." {
+		t.Errorf("error = %q", body.Error)
+	}
+}
+
+func TestWriteLoginLockout_MinimumOneSecond(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeLoginLockout(w, "Locked.", 0)
+
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+}
+
+func TestWriteLoginFailure_RemainingAttempts(t *testing.T) {
+	prevIP, prevAccount := rateLimiter, accountLimiter
+	defer func() { rateLimiter, accountLimiter = prevIP, prevAccount }()
+	rateLimiter = auth.NewRateLimiter()
+	accountLimiter = auth.NewAccountRateLimiter()
+
+	remaining := func(ip, account string) int {
+		w := httptest.NewRecorder()
+		writeLoginFailure(w, ip, account)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("status = %d, want 401", w.Code)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&body)
+		n, _ := body["remaining_attempts"].(float64)
+		return int(n)
+	}
+
+	rateLimiter.RecordAttempt("10.0.0.1")
+	if got := remaining("10.0.0.1", "admin"); got != auth.MaxLoginAttempts-1 {
+		t.Errorf("remaining_attempts = %d, want %d", got, auth.MaxLoginAttempts-1)
+	}
+
+	// The account limit applies when it is closer to lockout than the IP limit
+	for i := 0; i < auth.MaxAccountLoginAttempts-2; i++ {
+		accountLimiter.RecordAttempt("admin")
+	}
+	if got := remaining("10.0.0.2", "admin"); got != 2 {
+		t.Errorf("remaining_attempts = %d, want 2 from the account limit", got)
+	}
+}
+
+func TestAuthStatusHandler(t *testing.T) {
+	prev := sessionStore
+	defer func() { sessionStore = prev }()
+	sessionStore = auth.NewSessionStore(time.Hour)
+	defer sessionStore.Stop()
+
+	status := func(r *http.Request) map[string]interface{} {
+		w := httptest.NewRecorder()
+		AuthStatusHandler(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200", w.Code)
+		}
+		var body map[string]interface{}
+		json.NewDecoder(w.Body).Decode(&body)
+		return body
+	}
+
+	// No cookie
+	body := status(httptest.NewRequest("GET", "/api/auth/status", nil))
+	if body["authenticated"] != false || len(body) != 1 {
+		t.Errorf("unauthenticated body = %v, want only authenticated:false", body)
+	}
+
+	// Unknown session
+	r := httptest.NewRequest("GET", "/api/auth/status", nil)
+	r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: "nope"})
+	if body := status(r); body["authenticated"] != false {
+		t.Errorf("unknown session body = %v", body)
+	}
+
+	// Valid session
+	sessionID, _ := sessionStore.CreateSession("admin")
+	r = httptest.NewRequest("GET", "/api/auth/status", nil)
+	r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionID})
+	body = status(r)
+	if body["authenticated"] != true || body["username"] != "admin" {
+		t.Errorf("authenticated body = %v", body)
+	}
+	for _, key := range []string{"expiresAt", "absoluteExpiresAt", "createdAt", "totpEnabled", "roles"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("response missing %q", key)
+		}
+	}
+}
+
+func TestAuthStatusHandler_FingerprintEnforced(t *testing.T) {
+	prev := sessionStore
+	defer func() { sessionStore = prev }()
+	sessionStore = auth.NewSessionStore(time.Hour)
+	defer sessionStore.Stop()
+	sessionStore.SetFingerprintMode(auth.FingerprintEnforce)
+
+	sessionID, _ := sessionStore.CreateSessionWithOptions("admin", auth.SessionOptions{
+		Fingerprint: auth.Fingerprint("203.0.113.10", "browser"),
+	})
+
+	r := httptest.NewRequest("GET", "/api/auth/status", nil)
+	r.RemoteAddr = "198.51.100.10:4000"
+	r.Header.Set("User-Agent", "browser")
+	r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionID})
+
+	w := httptest.NewRecorder()
+	AuthStatusHandler(w, r)
+	if !strings.Contains(w.Body.String(), `"authenticated":false`) {
+		t.Errorf("cookie from another network should not be authenticated, got %s", w.Body.String())
+	}
+}
+
+func TestResetPasswordHandler_Rejections(t *testing.T) {
+	setupTestDatabase(t)
+	prevIP, prevAccount := rateLimiter, accountLimiter
+	defer func() { rateLimiter, accountLimiter = prevIP, prevAccount }()
+	rateLimiter = auth.NewRateLimiter()
+	accountLimiter = auth.NewAccountRateLimiter()
+
+	token, _, err := auth.CreateResetToken(database.GetDB(), auth.ResetTokenTTL)
+	if err != nil {
+		t.Fatalf("CreateResetToken failed: %v", err)
+	}
+
+	reset := func(token, password, forwardedFor string) int {
+		body, _ := json.Marshal(map[string]string{"token": token, "password": password})
+		req := httptest.NewRequest(http.MethodPost, "/api/reset-password", strings.NewReader(string(body)))
+		req.RemoteAddr = "203.0.113.5:40000"
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		w := httptest.NewRecorder()
+		ResetPasswordHandler(w, req)
+		return w.Code
+	}
+
+	// A weak password is refused before the token is looked at
+	if code := reset(token, "short", ""); code != http.StatusBadRequest {
+		t.Errorf("weak password: status = %d, want 400", code)
+	}
+	if err := auth.ConsumeResetToken(database.GetDB(), token); err != nil {
+		t.Errorf("a weak password shouldn't spend the token: %v", err)
+	}
+
+	// Wrong tokens count against the connection's address, whatever the header claims
+	for i := 0; i < auth.MaxLoginAttempts; i++ {
+		if code := reset("wrong", "long-enough-password", fmt.Sprintf("198.51.100.%d", i)); code != http.StatusUnauthorized {
+			t.Fatalf("wrong token %d: status = %d, want 401", i, code)
+		}
+	}
+	if code := reset("wrong", "long-enough-password", "198.51.100.200"); code != http.StatusTooManyRequests {
+		t.Errorf("after %d wrong tokens: status = %d, want 429", auth.MaxLoginAttempts, code)
+	}
+}
+
+func TestLoginHandler_LockoutIgnoresForwardedFor(t *testing.T) {
+	prevIP, prevAccount := rateLimiter, accountLimiter
+	defer func() { rateLimiter, accountLimiter = prevIP, prevAccount }()
+	rateLimiter = auth.NewRateLimiter()
+	accountLimiter = auth.NewAccountRateLimiter()
+
+	login := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader("not json"))
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		LoginHandler(w, req)
+		return w.Code
+	}
+
+	// Forging a victim's address doesn't lock the attacker out with them
+	for i := 0; i < auth.MaxLoginAttempts; i++ {
+		rateLimiter.RecordAttempt("198.51.100.7")
+	}
+	if code := login("203.0.113.5:40000", "198.51.100.7"); code != http.StatusBadRequest {
+		t.Errorf("forged victim address: status = %d, want 400", code)
+	}
+
+	// Rotating the header doesn't escape a lockout of the real address
+	for i := 0; i < auth.MaxLoginAttempts; i++ {
+		rateLimiter.RecordAttempt("203.0.113.5")
+	}
+	for i := 0; i < 3; i++ {
+		if code := login("203.0.113.5:40000", fmt.Sprintf("192.0.2.%d", i)); code != http.StatusTooManyRequests {
+			t.Errorf("rotated header %d: status = %d, want 429", i, code)
+		}
+	}
+}
+
+func TestRecordFailedLogin_KeysOnRemoteAddr(t *testing.T) {
+	prevIP, prevAccount := rateLimiter, accountLimiter
+	defer func() { rateLimiter, accountLimiter = prevIP, prevAccount }()
+	rateLimiter = auth.NewRateLimiter()
+	accountLimiter = auth.NewAccountRateLimiter()
+
+	// Stay under the lockout so no alert is sent; the count and the alert share one key
+	for i := 0; i < auth.MaxLoginAttempts-1; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", nil)
+		req.RemoteAddr = "203.0.113.5:40000"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("192.0.2.%d", i))
+		recordFailedLogin(req, "admin", "invalid password")
+	}
+
+	if got := rateLimiter.GetAttempts("203.0.113.5"); got != auth.MaxLoginAttempts-1 {
+		t.Errorf("attempts for the connection's address = %d, want %d", got, auth.MaxLoginAttempts-1)
+	}
+	if got := rateLimiter.GetAttempts("192.0.2.0"); got != 0 {
+		t.Errorf("attempts for a forwarded address = %d, want 0", got)
+	}
+}
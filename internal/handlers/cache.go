@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// writeCachedJSON writes v as JSON with an ETag of the encoded body, answering
+// 304 Not Modified when the client already has it. A non-zero lastModified is
+// sent as Last-Modified and checked against If-Modified-Since.
+// Clients must revalidate every time, so polling stays cheap but never stale.
+func writeCachedJSON(w http.ResponseWriter, r *http.Request, v interface{}, lastModified time.Time) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		logging.Errorf("Failed to encode response for %s: %v", r.URL.Path, err)
+		jsonError(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body.Bytes())
+}
+
+// notModified reports whether a conditional GET matches the current representation.
+// If-None-Match takes precedence over If-Modified-Since, as in RFC 9110.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// checkIfMatch enforces optimistic concurrency on an update: the request must carry
+// an If-Match header naming the current ETag, or "*". It writes 428 Precondition
+// Required or 412 Precondition Failed and returns false otherwise.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, etag string) bool {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		jsonError(w, "If-Match header required; GET the resource for its current ETag", http.StatusPreconditionRequired)
+		return false
+	}
+	if !ifMatches(match, etag) {
+		jsonError(w, "Resource was modified; reload and try again", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// ifMatches reports whether an If-Match header names etag. Weak tags never match,
+// as If-Match uses strong comparison.
+func ifMatches(match, etag string) bool {
+	for _, candidate := range strings.Split(match, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteCachedJSON_ETag(t *testing.T) {
+	payload := map[string]int{"count": 3}
+
+	rec := httptest.NewRecorder()
+	writeCachedJSON(rec, httptest.NewRequest("GET", "/api/stats", nil), payload, time.Time{})
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("first response = %d with ETag %q, want 200 with an ETag", rec.Code, etag)
+	}
+	if rec.Header().Get("Last-Modified") != "" {
+		t.Error("Last-Modified should be omitted without a timestamp")
+	}
+
+	for _, match := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		req := httptest.NewRequest("GET", "/api/stats", nil)
+		req.Header.Set("If-None-Match", match)
+		rec = httptest.NewRecorder()
+		writeCachedJSON(rec, req, payload, time.Time{})
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: status = %d with %d body bytes, want empty 304", match, rec.Code, rec.Body.Len())
+		}
+		if rec.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: 304 should repeat the ETag", match)
+		}
+	}
+
+	// A changed body gets a new ETag
+	req := httptest.NewRequest("GET", "/api/stats", nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	writeCachedJSON(rec, req, map[string]int{"count": 4}, time.Time{})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("changed body: status = %d, ETag %q; want 200 with a new ETag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestWriteCachedJSON_LastModified(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	tests := []struct {
+		name  string
+		since string
+		want  int
+	}{
+		{"same time", modified.Format(http.TimeFormat), http.StatusNotModified},
+		{"later", modified.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"earlier", modified.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK},
+		{"unparseable", "yesterday", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/sites", nil)
+			req.Header.Set("If-Modified-Since", tt.since)
+			rec := httptest.NewRecorder()
+			writeCachedJSON(rec, req, []string{"blog"}, modified)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if got := rec.Header().Get("Last-Modified"); got != modified.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %q, want %q", got, modified.Format(http.TimeFormat))
+			}
+		})
+	}
+
+	// If-None-Match wins over If-Modified-Since
+	req := httptest.NewRequest("GET", "/api/sites", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	req.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	rec := httptest.NewRecorder()
+	writeCachedJSON(rec, req, []string{"blog"}, modified)
+	if rec.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: status = %d, want 200", rec.Code)
+	}
+}
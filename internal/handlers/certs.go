@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// certExpiryWindow marks certificates this close to expiry as expiring
+var certExpiryWindow = 14 * 24 * time.Hour
+
+// SetCertExpiryWindow sets how close to expiry a certificate is flagged
+// (https.expiry_alert_days). Call it before serving requests.
+func SetCertExpiryWindow(d time.Duration) {
+	certExpiryWindow = d
+}
+
+// certStatus is a stored certificate as listed by /api/certs
+type certStatus struct {
+	database.CertInfo
+	DaysLeft int  `json:"days_left"`
+	Expiring bool `json:"expiring"` // inside the alert window, so renewal is overdue
+}
+
+// CertsHandler lists the managed certificates with their expiry dates, soonest first
+// GET /api/certs
+func CertsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	certs, err := database.NewSQLCertStorage(database.GetDB()).Certificates(r.Context())
+	if err != nil {
+		logging.Errorf("Failed to list certificates: %v", err)
+		jsonError(w, "Failed to list certificates", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	statuses := make([]certStatus, 0, len(certs))
+	for _, c := range certs {
+		left := c.NotAfter.Sub(now)
+		statuses = append(statuses, certStatus{
+			CertInfo: c,
+			DaysLeft: int(left.Hours() / 24),
+			Expiring: left < certExpiryWindow,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"certs":   statuses,
+	})
+}
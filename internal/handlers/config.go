@@ -1,23 +1,66 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
+	"sync"
 
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/auth"
 	"github.com/jikku/command-center/internal/config"
+	"github.com/jikku/command-center/internal/logging"
 )
 
+// configMu serializes config updates, so the If-Match check and the save are atomic
+var configMu sync.Mutex
+
 // ConfigHandler returns the current configuration (sanitized)
+// GET /api/config - the ETag is a hash of the config file
+// PUT /api/config - update the runtime-safe settings (ntfy, rate_limit) without a restart;
+// requires If-Match with the ETag from GET, and answers 412 if the file changed since
 func ConfigHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		updateConfig(w, r)
+		return
+	default:
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	cfg := config.Get()
+	if etag, err := configETag(config.Path()); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sanitizedConfig(config.Get()))
+}
 
-	// Create sanitized config (no password hash)
-	sanitized := map[string]interface{}{
+// configETag hashes the config file at path, so any edit, through the API or
+// by hand, changes it
+func configETag(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("config file path unknown")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// sanitizedConfig returns the configuration without secrets
+func sanitizedConfig(cfg *config.Config) map[string]interface{} {
+	return map[string]interface{}{
 		"server": map[string]interface{}{
 			"port":   cfg.Server.Port,
 			"domain": cfg.Server.Domain,
@@ -35,8 +78,178 @@ func ConfigHandler(w http.ResponseWriter, r *http.Request) {
 			"topic": cfg.Ntfy.Topic,
 			"url":   cfg.Ntfy.URL,
 		},
+		"analytics": map[string]interface{}{
+			"require_token": cfg.Analytics.RequireToken,
+		},
+		"rate_limit": map[string]interface{}{
+			"login_attempts":   rateLimiterMax(rateLimiter, cfg.RateLimit.LoginAttempts),
+			"account_attempts": rateLimiterMax(accountLimiter, cfg.RateLimit.AccountAttempts),
+		},
+	}
+}
+
+// rateLimiterMax reports a limiter's effective limit, falling back to the configured value
+func rateLimiterMax(limiter *auth.RateLimiter, configured int) int {
+	if limiter != nil {
+		return limiter.MaxAttempts()
+	}
+	return configured
+}
+
+// configUpdate is the body of PUT /api/config. Only these sections may change at runtime.
+type configUpdate struct {
+	Ntfy *struct {
+		Topic *string `json:"topic"`
+		URL   *string `json:"url"`
+	} `json:"ntfy"`
+	RateLimit *struct {
+		LoginAttempts   *int `json:"login_attempts"`
+		AccountAttempts *int `json:"account_attempts"`
+	} `json:"rate_limit"`
+}
+
+// apply copies the update onto cfg
+func (u *configUpdate) apply(cfg *config.Config) {
+	if u.Ntfy != nil {
+		if u.Ntfy.Topic != nil {
+			cfg.Ntfy.Topic = *u.Ntfy.Topic
+		}
+		if u.Ntfy.URL != nil {
+			cfg.Ntfy.URL = *u.Ntfy.URL
+		}
 	}
+	if u.RateLimit != nil {
+		if u.RateLimit.LoginAttempts != nil {
+			cfg.RateLimit.LoginAttempts = *u.RateLimit.LoginAttempts
+		}
+		if u.RateLimit.AccountAttempts != nil {
+			cfg.RateLimit.AccountAttempts = *u.RateLimit.AccountAttempts
+		}
+	}
+}
 
+// parseConfigUpdate decodes a PUT /api/config body, rejecting sections that need a
+// restart (server, database, https) or must never be set over HTTP (auth, api_key)
+func parseConfigUpdate(body []byte) (*configUpdate, error) {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(body, &sections); err != nil {
+		return nil, errors.New("invalid JSON")
+	}
+	for name := range sections {
+		switch name {
+		case "ntfy", "rate_limit":
+		case "server", "database", "https":
+			return nil, fmt.Errorf("%s settings require a restart; edit the config file instead", name)
+		case "auth", "api_key":
+			return nil, fmt.Errorf("%s settings cannot be changed through the API", name)
+		default:
+			return nil, fmt.Errorf("unknown config section: %s", name)
+		}
+	}
+
+	var update configUpdate
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&update); err != nil {
+		return nil, fmt.Errorf("invalid config update: %v", err)
+	}
+
+	if update.Ntfy != nil && update.Ntfy.URL != nil && *update.Ntfy.URL != "" {
+		u, err := url.Parse(*update.Ntfy.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, errors.New("ntfy url must be an http(s) URL")
+		}
+	}
+	if update.RateLimit != nil {
+		limits := config.RateLimitConfig{}
+		if update.RateLimit.LoginAttempts != nil {
+			limits.LoginAttempts = *update.RateLimit.LoginAttempts
+		}
+		if update.RateLimit.AccountAttempts != nil {
+			limits.AccountAttempts = *update.RateLimit.AccountAttempts
+		}
+		if err := limits.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return &update, nil
+}
+
+// updateConfig handles PUT /api/config
+func updateConfig(w http.ResponseWriter, r *http.Request) {
+	var body bytes.Buffer
+	if _, err := body.ReadFrom(http.MaxBytesReader(w, r.Body, maxBodySize)); err != nil {
+		jsonError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	update, err := parseConfigUpdate(body.Bytes())
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	configMu.Lock()
+	defer configMu.Unlock()
+
+	// Persist first: re-read the file so settings not loaded at runtime are kept
+	path := config.Path()
+	if path == "" {
+		jsonError(w, "Config file path unknown", http.StatusInternalServerError)
+		return
+	}
+	etag, err := configETag(path)
+	if err != nil {
+		logging.Errorf("Config update failed: %v", err)
+		jsonError(w, "Failed to load config file", http.StatusInternalServerError)
+		return
+	}
+	if !checkIfMatch(w, r, etag) {
+		return
+	}
+	fileCfg, err := config.LoadFromFile(path)
+	if err != nil {
+		logging.Errorf("Config update failed: %v", err)
+		jsonError(w, "Failed to load config file", http.StatusInternalServerError)
+		return
+	}
+	update.apply(fileCfg)
+	if err := config.SaveToFile(fileCfg, path); err != nil {
+		logging.Errorf("Config update failed: %v", err)
+		jsonError(w, "Failed to save config file", http.StatusInternalServerError)
+		return
+	}
+
+	// Then apply to the running server. The live config is swapped, not
+	// edited, since notifications read it without configMu.
+	cfg := config.Update(update.apply)
+	ApplyRateLimits(cfg.RateLimit)
+
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "config_update", "/api/config")
+	logging.Infof("Configuration updated at runtime by %s", sessionUsername(r))
+
+	if etag, err := configETag(path); err == nil {
+		w.Header().Set("ETag", etag)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(sanitized)
+	json.NewEncoder(w).Encode(sanitizedConfig(cfg))
+}
+
+// ApplyRateLimits sets the login rate limiters' thresholds; zero values use the defaults
+func ApplyRateLimits(limits config.RateLimitConfig) {
+	if rateLimiter != nil {
+		rateLimiter.SetMaxAttempts(orDefault(limits.LoginAttempts, auth.MaxLoginAttempts))
+	}
+	if accountLimiter != nil {
+		accountLimiter.SetMaxAttempts(orDefault(limits.AccountAttempts, auth.MaxAccountLoginAttempts))
+	}
+}
+
+// orDefault returns n, or def if n is zero
+func orDefault(n, def int) int {
+	if n == 0 {
+		return def
+	}
+	return n
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/config"
+)
+
+func TestParseConfigUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"ntfy", `{"ntfy": {"topic": "alerts", "url": "https://ntfy.example.com"}}`, ""},
+		{"rate limits", `{"rate_limit": {"login_attempts": 3}}`, ""},
+		{"port needs restart", `{"server": {"port": "9000"}}`, "require a restart"},
+		{"db path needs restart", `{"database": {"path": "/tmp/x.db"}}`, "require a restart"},
+		{"password hash rejected", `{"auth": {"password_hash": "x"}}`, "cannot be changed"},
+		{"api token rejected", `{"api_key": {"token": "x"}}`, "cannot be changed"},
+		{"unknown field", `{"ntfy": {"token": "x"}}`, "unknown field"},
+		{"bad ntfy url", `{"ntfy": {"url": "ftp://ntfy.sh"}}`, "http(s)"},
+		{"rate limit out of range", `{"rate_limit": {"account_attempts": -1}}`, "account_attempts"},
+		{"not JSON", `nope`, "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseConfigUpdate([]byte(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseConfigUpdate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseConfigUpdate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigHandler_Put(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	initial := config.CreateDefaultConfig()
+	initial.Auth.Username = "admin"
+	initial.Auth.PasswordHash = "hash"
+	if err := config.SaveToFile(initial, path); err != nil {
+		t.Fatalf("SaveToFile failed: %v", err)
+	}
+	if _, err := config.Load(&config.CLIFlags{ConfigPath: path}); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.Path() != path {
+		t.Skip("configuration already loaded by another test")
+	}
+
+	limiter := auth.NewRateLimiter()
+	defer InitAuth(sessionStore, rateLimiter, accountLimiter)
+	InitAuth(sessionStore, limiter, auth.NewAccountRateLimiter())
+
+	rec := httptest.NewRecorder()
+	ConfigHandler(rec, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("GET /api/config sent no ETag")
+	}
+
+	put := func(ifMatch string) *httptest.ResponseRecorder {
+		body := `{"ntfy": {"topic": "deploys"}, "rate_limit": {"login_attempts": 3}}`
+		req := httptest.NewRequest(http.MethodPut, "/api/config", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rec := httptest.NewRecorder()
+		ConfigHandler(rec, req)
+		return rec
+	}
+
+	if rec := put(""); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("PUT without If-Match status = %d, want 428", rec.Code)
+	}
+	rec = put(etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+	}
+	if newTag := rec.Header().Get("ETag"); newTag == "" || newTag == etag {
+		t.Errorf("ETag after update = %q, want a new one", newTag)
+	}
+
+	// A second tab still holding the old ETag must not clobber the update
+	if rec := put(etag); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("PUT with a stale ETag status = %d, want 412", rec.Code)
+	}
+
+	if config.Get().Ntfy.Topic != "deploys" {
+		t.Errorf("runtime ntfy topic = %q, want deploys", config.Get().Ntfy.Topic)
+	}
+	if limiter.MaxAttempts() != 3 {
+		t.Errorf("login limiter max = %d, want 3", limiter.MaxAttempts())
+	}
+
+	saved, err := config.LoadFromFile(path)
+	if err != nil {
+		t.Fatalf("LoadFromFile failed: %v", err)
+	}
+	if saved.Ntfy.Topic != "deploys" || saved.RateLimit.LoginAttempts != 3 {
+		t.Errorf("saved config ntfy=%q login_attempts=%d, want deploys and 3", saved.Ntfy.Topic, saved.RateLimit.LoginAttempts)
+	}
+	if saved.Auth.PasswordHash != "hash" {
+		t.Error("config update must not touch the password hash")
+	}
+}
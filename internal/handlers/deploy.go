@@ -2,76 +2,207 @@ package handlers
 
 import (
 	"archive/zip"
-	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/audit"
 	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/config"
 	"github.com/jikku/command-center/internal/database"
 	"github.com/jikku/command-center/internal/hosting"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/middleware"
 )
 
+const (
+	// deployFormMemory is how much of the multipart form is kept in memory; the rest goes to disk
+	deployFormMemory = 1 << 20
+
+	// maxIdempotencyKeyLen bounds the Idempotency-Key header
+	maxIdempotencyKeyLen = 255
+)
+
+// maxDeployBytes is set from hosting.max_deploy_bytes; 0 means the default
+var maxDeployBytes atomic.Int64
+
+// SetMaxDeployBytes sets the largest deploy or site file upload accepted, or
+// the default for 0. Call it before serving requests and RouteBodyLimits.
+func SetMaxDeployBytes(n int64) {
+	maxDeployBytes.Store(n)
+}
+
+// maxDeploySize is the largest ZIP accepted by DeployHandler, or file by the site files API
+func maxDeploySize() int64 {
+	if n := maxDeployBytes.Load(); n > 0 {
+		return n
+	}
+	return config.DefaultMaxDeployBytes
+}
+
+// deployBodyLimit caps a deploy or file upload request: the file plus form overhead
+func deployBodyLimit() int64 {
+	return maxDeploySize() + deployFormMemory
+}
+
+// writeUploadTooLarge answers 413 naming the configured limit
+func writeUploadTooLarge(w http.ResponseWriter) {
+	jsonError(w, "Upload exceeds the "+formatByteLimit(maxDeploySize())+" limit", http.StatusRequestEntityTooLarge)
+}
+
+// formatByteLimit renders a size limit as whole MB or KB where it divides evenly
+func formatByteLimit(n int64) string {
+	switch {
+	case n%(1<<20) == 0:
+		return fmt.Sprintf("%dMB", n>>20)
+	case n%(1<<10) == 0:
+		return fmt.Sprintf("%dKB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
+
+// deploysInFlight holds "<api key id>:<Idempotency-Key>" (key id 0 for the dashboard)
+// for deploys still running,
+// so a retry sent while the first attempt is running doesn't deploy twice
+var deploysInFlight sync.Map
+
 // DeployHandler handles site deployments via ZIP upload
 // POST /api/deploy
 // - Multipart form with "file" (ZIP) and "site_name" field
-// - Authorization: Bearer <token> header required
+// - Or "preview=true" (optionally with "ttl", e.g. "72h") for a generated subdomain
+// - Authorization: Bearer <token> header, or a dashboard session cookie
 func DeployHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Rate limit: 5 deploys per minute per IP
-	clientIP := r.RemoteAddr
-	if fwdIP := r.Header.Get("X-Forwarded-For"); fwdIP != "" {
-		clientIP = strings.Split(fwdIP, ",")[0]
+	// Fail fast on a declared size over the limit, before any of the body is read
+	if rejectOversized(w, r, deployBodyLimit()) {
+		return
 	}
+
+	// Rate limit: 5 deploys per minute per IP
+	clientIP := analytics.RemoteIP(r)
 	limiter := auth.GetDeployLimiter()
 	if !limiter.AllowDeploy(clientIP) {
 		jsonError(w, "Rate limit exceeded: max 5 deploys per minute", http.StatusTooManyRequests)
 		return
 	}
 
-	// Validate API key
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		jsonError(w, "Missing Authorization header", http.StatusUnauthorized)
+	db := database.GetDB()
+	d, status, err := authorizeDeploy(r, db)
+	if err != nil {
+		jsonError(w, err.Error(), status)
 		return
 	}
+	keyID, keyName := d.keyID, d.name
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == authHeader {
-		jsonError(w, "Invalid Authorization format, use: Bearer <token>", http.StatusUnauthorized)
+	// Parse multipart form (spooled to disk rather than held in memory)
+	r.Body = http.MaxBytesReader(w, r.Body, deployBodyLimit())
+	if err := r.ParseMultipartForm(deployFormMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeUploadTooLarge(w)
+			return
+		}
+		jsonError(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
 
-	db := database.GetDB()
-	keyID, keyName, err := hosting.ValidateAPIKey(db, token)
+	// Preview deploys get a generated subdomain and may expire
+	preview, err := parseBoolField(r.FormValue("preview"))
 	if err != nil {
-		jsonError(w, "Invalid API key", http.StatusUnauthorized)
+		jsonError(w, "Invalid preview: must be true or false", http.StatusBadRequest)
 		return
 	}
-
-	// Parse multipart form (max 100MB)
-	if err := r.ParseMultipartForm(100 << 20); err != nil {
-		jsonError(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+	ttl, err := parsePreviewTTL(r.FormValue("ttl"))
+	if err != nil {
+		jsonError(w, "Invalid ttl: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ttl > 0 && !preview {
+		jsonError(w, "ttl is only allowed with preview=true", http.StatusBadRequest)
 		return
 	}
 
 	// Get site name
 	siteName := r.FormValue("site_name")
-	if siteName == "" {
-		jsonError(w, "Missing site_name field", http.StatusBadRequest)
-		return
+	if preview {
+		if siteName != "" {
+			jsonError(w, "site_name can't be combined with preview=true; the name is generated", http.StatusBadRequest)
+			return
+		}
+	} else {
+		if siteName == "" {
+			jsonError(w, "Missing site_name field", http.StatusBadRequest)
+			return
+		}
+
+		// Validate site name
+		if err := hosting.ValidateSubdomain(siteName); err != nil {
+			jsonError(w, "Invalid site_name: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// An alias serves another site's files; deploying to it would be shadowed
+		if target, ok := hosting.ResolveSiteAlias(strings.ToLower(siteName)); ok {
+			jsonError(w, "site_name '"+siteName+"' is an alias of '"+target+"'; remove the alias to deploy to it", http.StatusConflict)
+			return
+		}
 	}
 
-	// Validate site name
-	if err := hosting.ValidateSubdomain(siteName); err != nil {
-		jsonError(w, "Invalid site_name: "+err.Error(), http.StatusBadRequest)
-		return
+	// A retry with the same Idempotency-Key gets the first attempt's result
+	// instead of deploying again
+	idempotencyKey := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > maxIdempotencyKeyLen {
+			jsonError(w, fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen), http.StatusBadRequest)
+			return
+		}
+
+		inFlight := fmt.Sprintf("%d:%s", keyID, idempotencyKey)
+		if _, busy := deploysInFlight.LoadOrStore(inFlight, struct{}{}); busy {
+			jsonError(w, "A deploy with this Idempotency-Key is still in progress", http.StatusConflict)
+			return
+		}
+		defer deploysInFlight.Delete(inFlight)
+
+		prior, err := hosting.FindIdempotentDeployment(db, idempotencyKey, keyID)
+		if err != nil {
+			jsonError(w, "Failed to check Idempotency-Key: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if prior != nil {
+			if !preview && prior.SiteID != siteName {
+				jsonError(w, "Idempotency-Key was already used to deploy site '"+prior.SiteID+"'", http.StatusUnprocessableEntity)
+				return
+			}
+			logging.Infof("Deploy of %s replayed for Idempotency-Key %q", prior.SiteID, idempotencyKey)
+			w.Header().Set("Idempotent-Replayed", "true")
+			writeDeployResult(w, prior.SiteID, prior.FileCount, prior.SizeBytes)
+			return
+		}
+	}
+
+	if preview {
+		if siteName, err = hosting.NewPreviewSubdomain(); err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Get uploaded file
@@ -88,57 +219,251 @@ func DeployHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Read file into memory (we need to seek for zip.Reader)
-	var buf bytes.Buffer
-	size, err := io.Copy(&buf, file)
+	if header.Size > maxDeploySize() {
+		writeUploadTooLarge(w)
+		return
+	}
+
+	// Spool to a temp file so the zip is read from disk instead of memory
+	zipPath, err := spoolUpload(file)
 	if err != nil {
 		jsonError(w, "Failed to read file: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	defer os.Remove(zipPath)
 
-	// Create zip reader
-	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), size)
+	zipReader, err := zip.OpenReader(zipPath)
 	if err != nil {
 		jsonError(w, "Invalid ZIP file: "+err.Error(), http.StatusBadRequest)
 		return
 	}
+	defer zipReader.Close()
+
+	// Only the key that first deployed a site (or an admin key) may overwrite it.
+	// The dashboard user is the admin, so session deploys skip the check.
+	// The upload is checked first so a bad one never claims the name.
+	deployed := false
+	if keyID != 0 {
+		if err := hosting.ClaimDeploy(db, siteName, keyID, keyName); err != nil {
+			if err == hosting.ErrSiteOwned {
+				audit.LogFailure(keyName, clientIP, "deploy", siteName, "site owned by another API key")
+				jsonError(w, "Forbidden: site '"+siteName+"' is owned by another API key", http.StatusForbidden)
+				return
+			}
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// A deploy that fails from here on mustn't leave the name claimed
+		defer func() {
+			if !deployed {
+				if err := hosting.ReleaseClaim(db, siteName, keyID); err != nil {
+					logging.Errorf("Failed to release claim on %s: %v", siteName, err)
+				}
+			}
+		}()
+	}
 
 	// Deploy the site
-	result, err := hosting.DeploySite(zipReader, siteName)
+	result, err := hosting.DeploySite(&zipReader.Reader, siteName)
 	if err != nil {
+		if errors.Is(err, hosting.ErrInvalidDeploy) {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		jsonError(w, "Deployment failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	deployed = true
 
 	// Record deployment
 	deployedBy := keyName
-	if err := hosting.RecordDeployment(db, result.SiteID, result.SizeBytes, result.FileCount, deployedBy); err != nil {
-		log.Printf("Failed to record deployment: %v", err)
+	if err := hosting.RecordDeployment(db, result.SiteID, result.SizeBytes, result.FileCount, deployedBy, keyID, idempotencyKey); err != nil {
+		logging.Errorf("Failed to record deployment: %v", err)
+	}
+	if err := hosting.RecordSiteDeploy(result.SiteID, keyID, keyName); err != nil {
+		logging.Errorf("Failed to update site metadata: %v", err)
+	}
+	if preview {
+		var expiresAt time.Time
+		if ttl > 0 {
+			expiresAt = time.Now().Add(ttl)
+		}
+		if err := hosting.MarkPreview(result.SiteID, expiresAt); err != nil {
+			logging.Errorf("Failed to record preview: %v", err)
+		}
 	}
 
 	// Record rate limit
 	limiter.RecordDeploy(clientIP)
 
-	log.Printf("Site deployed: %s by %s (key_id=%d), %d files, %d bytes",
+	audit.LogSuccess(keyName, clientIP, "deploy", siteName)
+
+	logging.Infof("Site deployed: %s by %s (key_id=%d), %d files, %d bytes",
 		siteName, keyName, keyID, result.FileCount, result.SizeBytes)
 
-	// Return success response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	writeDeployResult(w, siteName, result.FileCount, result.SizeBytes)
+}
+
+// deployer is who a deploy is attributed to: an API key, or the dashboard
+// user (keyID 0) when the upload comes from a logged-in session
+type deployer struct {
+	keyID int64
+	name  string
+}
+
+// authorizeDeploy accepts a Bearer API key (CLI/CI) or, without an
+// Authorization header, a valid dashboard session. It returns the HTTP
+// status to send when neither is present.
+func authorizeDeploy(r *http.Request, db *sql.DB) (deployer, int, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		session := currentSession(r)
+		if session == nil {
+			return deployer{}, http.StatusUnauthorized, errors.New("Missing Authorization header")
+		}
+		// The cookie rides along on cross-site requests when cookie.same_site
+		// is relaxed, so a browser upload must come from the dashboard itself
+		if !sameOrigin(r) {
+			return deployer{}, http.StatusForbidden, errors.New("Cross-origin deploys require an API key")
+		}
+		return deployer{name: session.Username}, 0, nil
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == authHeader {
+		return deployer{}, http.StatusUnauthorized, errors.New("Invalid Authorization format, use: Bearer <token>")
+	}
+
+	keyID, keyName, err := hosting.ValidateAPIKey(db, token, analytics.RemoteIP(r), r.UserAgent())
+	if err != nil {
+		return deployer{}, http.StatusUnauthorized, errors.New("Invalid API key")
+	}
+	return deployer{keyID: keyID, name: keyName}, 0, nil
+}
+
+// sameOrigin reports whether a browser request provably comes from this host:
+// its Origin names this host or, without an Origin, Sec-Fetch-Site says
+// same-origin. A request with neither is refused, since a missing header
+// proves nothing.
+func sameOrigin(r *http.Request) bool {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	return r.Header.Get("Sec-Fetch-Site") == "same-origin"
+}
+
+// siteBaseURL is the scheme and host sites are subdomains of; empty leaves
+// the site URL out of deploy results
+var siteBaseURL string
+
+// SetSiteBaseURL sets the URL deployed sites are reported under, such as
+// "https://example.com" (config.SiteBaseURL). Call it before serving requests.
+func SetSiteBaseURL(base string) {
+	siteBaseURL = strings.TrimRight(base, "/")
+}
+
+// siteURL returns the canonical URL of a site and whether it's served over
+// TLS, or "" when the base URL isn't set
+func siteURL(siteName string) (string, bool) {
+	scheme, host, ok := strings.Cut(siteBaseURL, "://")
+	if !ok || host == "" {
+		return "", false
+	}
+	return scheme + "://" + siteName + "." + host, scheme == "https"
+}
+
+// writeDeployResult sends a successful deploy's response, with the site's URL
+// when known. Previews also report when they expire (null when they don't).
+func writeDeployResult(w http.ResponseWriter, siteName string, fileCount int, sizeBytes int64) {
+	resp := map[string]interface{}{
 		"success":    true,
 		"site":       siteName,
-		"file_count": result.FileCount,
-		"size_bytes": result.SizeBytes,
+		"file_count": fileCount,
+		"size_bytes": sizeBytes,
 		"message":    "Deployment successful",
-	})
+	}
+	if u, tls := siteURL(siteName); u != "" {
+		resp["url"] = u
+		resp["tls"] = tls
+	}
+	if expiresAt, ok := hosting.PreviewExpiry(siteName); ok {
+		resp["preview"] = true
+		resp["expires_at"] = expiresAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseBoolField parses an optional form flag; empty means false
+func parseBoolField(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// parsePreviewTTL parses a preview's lifetime such as "72h"; empty means it
+// doesn't expire
+func parsePreviewTTL(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 || ttl > hosting.MaxPreviewTTL {
+		return 0, fmt.Errorf("must be positive and at most %v", hosting.MaxPreviewTTL)
+	}
+	return ttl, nil
+}
+
+// spoolUpload copies an uploaded file to a temp file and returns its path.
+// The caller removes the file.
+func spoolUpload(src io.Reader) (string, error) {
+	tmp, err := os.CreateTemp("", "fazt-deploy-*.zip")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write upload: %w", err)
+	}
+
+	return tmp.Name(), nil
 }
 
-// jsonError sends a JSON error response
+// jsonError sends a JSON error response carrying the request ID (see middleware.JSONError)
 func jsonError(w http.ResponseWriter, message string, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": false,
-		"error":   message,
-	})
+	middleware.JSONError(w, message, status)
+}
+
+// rejectOversized answers 413 when the request's Content-Length exceeds limit.
+// A missing or understated length is caught later by http.MaxBytesReader.
+func rejectOversized(w http.ResponseWriter, r *http.Request, limit int64) bool {
+	if r.ContentLength <= limit {
+		return false
+	}
+	// The body is left unread, so don't let the client reuse the connection
+	w.Header().Set("Connection", "close")
+	writeUploadTooLarge(w)
+	return true
+}
+
+// RouteBodyLimits returns the request body caps of the upload endpoints, which
+// accept more than middleware.MaxBodySize, for middleware.BodySizeLimit
+func RouteBodyLimits() map[string]int64 {
+	return map[string]int64{
+		"/api/deploy":           deployBodyLimit(),
+		"/api/sites/*/files":    deployBodyLimit(),
+		"/api/redirects/import": maxRedirectImportSize,
+	}
 }
@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/hosting"
+)
+
+func TestSpoolUpload(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("index.html")
+	f.Write([]byte("<h1>hello</h1>"))
+	zw.Close()
+
+	path, err := spoolUpload(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("spoolUpload failed: %v", err)
+	}
+	defer os.Remove(path)
+
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("zip.OpenReader failed: %v", err)
+	}
+	defer zr.Close()
+
+	if len(zr.File) != 1 || zr.File[0].Name != "index.html" {
+		t.Fatalf("spooled zip has %d files, want index.html", len(zr.File))
+	}
+	rc, _ := zr.File[0].Open()
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(content) != "<h1>hello</h1>" {
+		t.Errorf("content = %q, want <h1>hello</h1>", content)
+	}
+}
+
+// deployRequest builds a /api/deploy upload of a one-page site.
+// An empty token sends no Authorization header.
+func deployRequest(t *testing.T, token, site, idempotencyKey string) *http.Request {
+	t.Helper()
+	return deployRequestFields(t, token, map[string]string{"site_name": site}, idempotencyKey)
+}
+
+// deployRequestFields is deployRequest with arbitrary form fields
+func deployRequestFields(t *testing.T, token string, fields map[string]string, idempotencyKey string) *http.Request {
+	t.Helper()
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	f, _ := zw.Create("index.html")
+	f.Write([]byte("<h1>hello</h1>"))
+	zw.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	part, _ := mw.CreateFormFile("file", "deploy.zip")
+	part.Write(zipBuf.Bytes())
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/deploy", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if idempotencyKey != "" {
+		r.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	return r
+}
+
+// unreadBody fails the test if the handler reads from it
+type unreadBody struct{ t *testing.T }
+
+func (b unreadBody) Read([]byte) (int, error) {
+	b.t.Error("body was read")
+	return 0, io.EOF
+}
+
+func TestDeployHandler_RejectsOversizedEarly(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/api/deploy", unreadBody{t})
+	r.ContentLength = deployBodyLimit() + 1
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	r.Header.Set("Authorization", "Bearer nope")
+
+	w := httptest.NewRecorder()
+	DeployHandler(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 (body %s)", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Connection") != "close" {
+		t.Error("an unread oversized body should close the connection")
+	}
+
+	// Unknown length (-1) and lengths within the limit go on to be read
+	for _, length := range []int64{-1, deployBodyLimit()} {
+		r.ContentLength = length
+		if rejectOversized(httptest.NewRecorder(), r, deployBodyLimit()) {
+			t.Errorf("Content-Length %d rejected", length)
+		}
+	}
+}
+
+func TestDeployHandler_ConfiguredLimit(t *testing.T) {
+	SetMaxDeployBytes(2 << 20)
+	defer SetMaxDeployBytes(0)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/deploy", unreadBody{t})
+	r.ContentLength = deployBodyLimit() + 1
+	r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	r.Header.Set("Authorization", "Bearer nope")
+
+	w := httptest.NewRecorder()
+	DeployHandler(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 (body %s)", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "2MB") {
+		t.Errorf("body = %s, want the configured limit", w.Body.String())
+	}
+	if got := RouteBodyLimits()["/api/deploy"]; got != deployBodyLimit() {
+		t.Errorf("route body limit = %d, want %d", got, deployBodyLimit())
+	}
+}
+
+func TestDeployHandler_IdempotencyKey(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+	hosting.Init(db)
+
+	token, err := hosting.CreateAPIKey(db, "ci", "deploy")
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+
+	deploy := func(site, key string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		DeployHandler(rec, deployRequest(t, token, site, key))
+		return rec
+	}
+	countDeployments := func() int {
+		var n int
+		db.QueryRow("SELECT COUNT(*) FROM deployments").Scan(&n)
+		return n
+	}
+
+	if rec := deploy("blog", "retry-1"); rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("first deploy: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// The retry gets the recorded result without deploying again
+	rec := deploy("blog", "retry-1")
+	if rec.Code != http.StatusOK || rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry: %d, Idempotent-Replayed %q", rec.Code, rec.Header().Get("Idempotent-Replayed"))
+	}
+	if n := countDeployments(); n != 1 {
+		t.Errorf("%d deployments recorded, want 1", n)
+	}
+
+	// Reusing the key for another site is refused
+	if rec := deploy("shop", "retry-1"); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another site: status %d, want 422", rec.Code)
+	}
+
+	// Without a key, every request deploys
+	deploy("blog", "")
+	if n := countDeployments(); n != 2 {
+		t.Errorf("%d deployments recorded, want 2", n)
+	}
+}
+
+func TestAuthorizeDeploy_Session(t *testing.T) {
+	prev := sessionStore
+	defer func() { sessionStore = prev }()
+	sessionStore = auth.NewSessionStore(time.Hour)
+	defer sessionStore.Stop()
+	sessionID, _ := sessionStore.CreateSession("admin")
+
+	tests := []struct {
+		name      string
+		cookie    string
+		origin    string
+		fetchSite string
+		header    string
+		want      int
+	}{
+		{"session from the dashboard", sessionID, "http://example.com", "", "", 0},
+		{"session marked same-origin", sessionID, "", "same-origin", "", 0},
+		{"session without Origin", sessionID, "", "", "", http.StatusForbidden},
+		{"session marked cross-site", sessionID, "", "cross-site", "", http.StatusForbidden},
+		{"session from another site", sessionID, "https://evil.test", "", "", http.StatusForbidden},
+		{"Origin wins over Sec-Fetch-Site", sessionID, "https://evil.test", "same-origin", "", http.StatusForbidden},
+		{"opaque Origin", sessionID, "null", "", "", http.StatusForbidden},
+		{"unknown session", "nope", "", "", "", http.StatusUnauthorized},
+		{"no credentials", "", "", "", "", http.StatusUnauthorized},
+		{"malformed Authorization", sessionID, "", "", "Token abc", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/deploy", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: tt.cookie})
+			}
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.fetchSite != "" {
+				r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+			}
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			d, status, err := authorizeDeploy(r, nil)
+			if status != tt.want {
+				t.Fatalf("status = %d, want %d (err %v)", status, tt.want, err)
+			}
+			if tt.want == 0 && (d.keyID != 0 || d.name != "admin") {
+				t.Errorf("deployer = %+v, want the session user", d)
+			}
+		})
+	}
+}
+
+func TestDeployHandler_AuthPaths(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+	hosting.Init(db)
+
+	prev := sessionStore
+	defer func() { sessionStore = prev }()
+	sessionStore = auth.NewSessionStore(time.Hour)
+	defer sessionStore.Stop()
+	sessionID, _ := sessionStore.CreateSession("admin")
+
+	token, err := hosting.CreateAPIKey(db, "ci", "deploy")
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+
+	// Dashboard session: attributed to the user, the site stays unclaimed
+	r := deployRequest(t, "", "blog", "")
+	r.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: sessionID})
+	r.Header.Set("Origin", "http://example.com")
+	rec := httptest.NewRecorder()
+	DeployHandler(rec, r)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("session deploy: %d %q", rec.Code, rec.Body.String())
+	}
+	var deployedBy string
+	db.QueryRow("SELECT deployed_by FROM deployments WHERE site_id = 'blog'").Scan(&deployedBy)
+	if deployedBy != "admin" {
+		t.Errorf("deployed_by = %q, want admin", deployedBy)
+	}
+
+	// Bearer: still works, including over a dashboard-deployed site
+	rec = httptest.NewRecorder()
+	DeployHandler(rec, deployRequest(t, token, "blog", ""))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("bearer deploy: %d %q", rec.Code, rec.Body.String())
+	}
+	var owner string
+	db.QueryRow("SELECT owner FROM sites WHERE site_id = 'blog'").Scan(&owner)
+	if owner != "admin" {
+		t.Errorf("owner = %q, want admin", owner)
+	}
+
+	// Neither
+	rec = httptest.NewRecorder()
+	DeployHandler(rec, deployRequest(t, "", "blog", ""))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated deploy: status %d, want 401", rec.Code)
+	}
+}
+
+func TestParsePreviewTTL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"72h", 72 * time.Hour, false},
+		{"30m", 30 * time.Minute, false},
+		{"0s", 0, true},
+		{"-1h", 0, true},
+		{"100000h", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePreviewTTL(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parsePreviewTTL(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestWriteDeployResult_SiteURL(t *testing.T) {
+	SetSiteBaseURL("https://example.com/")
+	defer SetSiteBaseURL("")
+
+	rec := httptest.NewRecorder()
+	writeDeployResult(rec, "blog", 2, 100)
+	var resp struct {
+		URL string `json:"url"`
+		TLS bool   `json:"tls"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if resp.URL != "https://blog.example.com" || !resp.TLS {
+		t.Errorf("response = %+v, want https://blog.example.com over TLS", resp)
+	}
+
+	SetSiteBaseURL("http://localhost:8080")
+	if u, tls := siteURL("blog"); u != "http://blog.localhost:8080" || tls {
+		t.Errorf("siteURL() = %q, %v, want http://blog.localhost:8080 without TLS", u, tls)
+	}
+}
+
+func TestDeployHandler_Preview(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+	hosting.Init(db)
+
+	token, err := hosting.CreateAPIKey(db, "ci", "deploy")
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+	deploy := func(fields map[string]string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		DeployHandler(rec, deployRequestFields(t, token, fields, ""))
+		return rec
+	}
+
+	rec := deploy(map[string]string{"preview": "true", "ttl": "2h"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("preview deploy: %d %q", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Site      string     `json:"site"`
+		URL       string     `json:"url"`
+		Preview   bool       `json:"preview"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if !strings.HasPrefix(resp.Site, "pr-") || !resp.Preview || resp.ExpiresAt == nil {
+		t.Errorf("preview response = %+v, want a pr- site with an expiry", resp)
+	}
+	if resp.URL != "" {
+		t.Errorf("url = %q without a site base URL, want none", resp.URL)
+	}
+	if !hosting.SiteExists(resp.Site) {
+		t.Errorf("preview site %q wasn't deployed", resp.Site)
+	}
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+	}{
+		{"preview with site_name", map[string]string{"preview": "true", "site_name": "blog"}},
+		{"ttl without preview", map[string]string{"site_name": "blog", "ttl": "2h"}},
+		{"bad ttl", map[string]string{"preview": "true", "ttl": "soon"}},
+		{"bad preview", map[string]string{"preview": "maybe"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := deploy(tt.fields); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestDeployHandler_RejectedUploadLeavesNoClaim(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+	hosting.Init(db)
+
+	alice, _ := hosting.CreateAPIKey(db, "alice", "deploy")
+	bob, _ := hosting.CreateAPIKey(db, "bob", "deploy")
+
+	upload := func(token, filename string, files map[string]string) int {
+		var zipBuf bytes.Buffer
+		zw := zip.NewWriter(&zipBuf)
+		for name, content := range files {
+			f, _ := zw.Create(name)
+			f.Write([]byte(content))
+		}
+		zw.Close()
+
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("site_name", "blog")
+		part, _ := mw.CreateFormFile("file", filename)
+		part.Write(zipBuf.Bytes())
+		mw.Close()
+
+		r := httptest.NewRequest(http.MethodPost, "/api/deploy", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		DeployHandler(rec, r)
+		return rec.Code
+	}
+	page := map[string]string{"index.html": "<h1>hello</h1>"}
+
+	if code := upload(alice, "site.tar", page); code != http.StatusBadRequest {
+		t.Errorf("non-zip upload: status %d, want 400", code)
+	}
+	if code := upload(alice, "site.zip", map[string]string{"readme.txt": "no page"}); code != http.StatusBadRequest {
+		t.Errorf("archive without index.html: status %d, want 400", code)
+	}
+
+	var n int
+	db.QueryRow("SELECT COUNT(*) FROM sites WHERE site_id = 'blog'").Scan(&n)
+	if n != 0 {
+		t.Errorf("%d sites rows after rejected uploads, want 0", n)
+	}
+
+	// The name is still free for another key
+	if code := upload(bob, "site.zip", page); code != http.StatusOK {
+		t.Errorf("deploy by another key: status %d, want 200", code)
+	}
+}
+
+func TestDeployHandler_ClientAddress(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+	hosting.Init(db)
+	if err := audit.Init(db); err != nil {
+		t.Fatalf("audit.Init failed: %v", err)
+	}
+
+	token, err := hosting.CreateAPIKey(db, "ci", "deploy")
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+	deploy := func(forwardedFor string) int {
+		r := deployRequest(t, token, "blog", "")
+		r.RemoteAddr = "203.0.113.5:40000"
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		DeployHandler(rec, r)
+		return rec.Code
+	}
+
+	// The audit record names the connection, not the header
+	if code := deploy("198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("deploy: status %d", code)
+	}
+	entries, _, err := audit.Query(audit.QueryOptions{Action: "deploy"})
+	if err != nil || len(entries) != 1 || entries[0].IPAddress != "203.0.113.5" {
+		t.Errorf("audit entries = %+v, %v; want one from 203.0.113.5", entries, err)
+	}
+
+	// Rotating the header doesn't get around the rate limit
+	for i := 2; i <= 4; i++ {
+		deploy(fmt.Sprintf("198.51.100.%d", i))
+	}
+	if code := deploy("198.51.100.5"); code != http.StatusOK {
+		t.Errorf("fifth deploy: status %d, want 200", code)
+	}
+	if code := deploy("198.51.100.6"); code != http.StatusTooManyRequests {
+		t.Errorf("sixth deploy with a new header: status %d, want 429", code)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/jikku/command-center/internal/database"
+)
+
+// ready is set once the database, migrations and hosting are initialized
+var ready atomic.Bool
+
+// SetReady marks the server ready (or not) to receive traffic
+func SetReady(r bool) {
+	ready.Store(r)
+}
+
+// LivezHandler reports that the process is up. It does no I/O.
+// GET /livez
+func LivezHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// ReadyzHandler reports whether the server can serve traffic: startup has finished
+// and the database is reachable. Also served as /health.
+// GET /readyz
+func ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		http.Error(w, "Not ready", http.StatusServiceUnavailable)
+		return
+	}
+	if err := database.HealthCheck(); err != nil {
+		http.Error(w, "Database unhealthy", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLivezHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	LivezHandler(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
+
+func TestReadyzHandler_NotReady(t *testing.T) {
+	SetReady(false)
+	t.Cleanup(func() { SetReady(false) })
+
+	rec := httptest.NewRecorder()
+	ReadyzHandler(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d before startup finished, want 503", rec.Code)
+	}
+}
@@ -2,14 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/assets"
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/config"
 	"github.com/jikku/command-center/internal/database"
 	"github.com/jikku/command-center/internal/hosting"
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // validEnvVarName validates environment variable names
@@ -64,22 +71,476 @@ func HostingPageHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // SitesHandler returns the list of hosted sites
+// GET /api/sites?search=&sort=modtime|name|size&limit=&offset= (no limit returns every site)
 func SitesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	sites, err := hosting.ListSites()
+	query := r.URL.Query()
+	q := hosting.SiteQuery{
+		Search: strings.TrimSpace(query.Get("search")),
+		Sort:   query.Get("sort"),
+		Limit:  parseInt(query.Get("limit"), 0),
+		Offset: parseInt(query.Get("offset"), 0),
+	}
+	if q.Limit < 0 || q.Offset < 0 {
+		jsonError(w, "limit and offset must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	sites, total, err := hosting.QuerySites(q)
+	if errors.Is(err, hosting.ErrInvalidSort) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Last-Modified is the newest file change among the listed sites
+	var lastModified time.Time
+	for _, site := range sites {
+		if modTime, ok := site.ModTime.(time.Time); ok && modTime.After(lastModified) {
+			lastModified = modTime
+		}
+	}
+
+	writeCachedJSON(w, r, map[string]interface{}{
+		"success": true,
+		"sites":   sites,
+		"total":   total,
+		"limit":   q.Limit,
+		"offset":  q.Offset,
+	}, lastModified)
+}
+
+// HostingUsageHandler reports VFS storage per site against the configured limits
+// GET /api/hosting/usage
+func HostingUsageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limits := config.Get().Hosting
+	usage, err := hosting.Usage(limits.SiteQuotaBytes)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	writeCachedJSON(w, r, usageResponse(usage, limits), time.Time{})
+}
+
+// usageResponse adds the configured quota and remaining capacity to usage.
+// Limits that aren't configured are reported as null.
+func usageResponse(usage *hosting.StorageUsage, limits config.HostingConfig) map[string]interface{} {
+	resp := map[string]interface{}{
+		"success":           true,
+		"total_bytes":       usage.TotalBytes,
+		"site_count":        len(usage.Sites),
+		"sites":             usage.Sites,
+		"site_quota_bytes":  nil,
+		"max_storage_bytes": nil,
+		"remaining_bytes":   nil,
+	}
+	if limits.SiteQuotaBytes > 0 {
+		resp["site_quota_bytes"] = limits.SiteQuotaBytes
+	}
+	if limits.MaxStorageBytes > 0 {
+		remaining := limits.MaxStorageBytes - usage.TotalBytes
+		if remaining < 0 {
+			remaining = 0
+		}
+		resp["max_storage_bytes"] = limits.MaxStorageBytes
+		resp["remaining_bytes"] = remaining
+	}
+	return resp
+}
+
+// SiteActionsHandler routes per-site endpoints under /api/sites/{site}/...
+func SiteActionsHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/sites/"), "/", 2)
+	siteID := strings.ToLower(parts[0])
+	action := ""
+	if len(parts) > 1 {
+		action = strings.Trim(parts[1], "/")
+	}
+
+	if !hosting.ValidateSiteID(siteID) {
+		jsonError(w, "Invalid site", http.StatusBadRequest)
+		return
+	}
+
+	switch action {
+	case "":
+		SiteDeleteHandler(w, r, siteID)
+	case "download":
+		SiteDownloadHandler(w, r, siteID)
+	case "files":
+		SiteFilesHandler(w, r, siteID)
+	case "enable", "disable":
+		SiteToggleHandler(w, r, siteID, action == "enable")
+	case "logs/stream":
+		SiteLogStreamHandler(w, r, siteID)
+	case "invoke":
+		SiteInvokeHandler(w, r, siteID)
+	default:
+		jsonError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// SiteDownloadHandler streams a site's files as a ZIP archive
+// GET /api/sites/{site}/download
+func SiteDownloadHandler(w http.ResponseWriter, r *http.Request, siteID string) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hosting.SiteExists(siteID) {
+		jsonError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+siteID+`.zip"`)
+
+	// Headers are already sent once streaming starts, so failures can only be logged
+	if _, err := hosting.ExportSite(w, siteID); err != nil {
+		logging.Errorf("Failed to export site %s: %v", siteID, err)
+	}
+}
+
+// SiteFilesHandler lists, replaces or deletes a site's files
+// GET /api/sites/{site}/files - list files with sizes, MIME types and hashes; ?prefix=assets/ for one directory
+// PUT /api/sites/{site}/files - add or replace one file (multipart "path" and "file")
+// DELETE /api/sites/{site}/files?path= - delete one file
+func SiteFilesHandler(w http.ResponseWriter, r *http.Request, siteID string) {
+	switch r.Method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hosting.SiteExists(siteID) {
+		jsonError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		putSiteFile(w, r, siteID)
+		return
+	case http.MethodDelete:
+		deleteSiteFile(w, r, siteID)
+		return
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	files, err := hosting.ListSiteFiles(siteID, prefix)
+	if err != nil {
+		logging.Errorf("Failed to list files for %s: %v", siteID, err)
+		jsonError(w, "Failed to list files", http.StatusInternalServerError)
+		return
+	}
+
+	var totalBytes int64
+	var lastModified time.Time
+	for _, f := range files {
+		totalBytes += f.Size
+		if f.ModTime.After(lastModified) {
+			lastModified = f.ModTime
+		}
+	}
+
+	writeCachedJSON(w, r, map[string]interface{}{
+		"success":     true,
+		"site":        siteID,
+		"prefix":      strings.TrimPrefix(prefix, "/"),
+		"files":       files,
+		"count":       len(files),
+		"total_bytes": totalBytes,
+	}, lastModified)
+}
+
+// putSiteFile handles PUT /api/sites/{site}/files
+func putSiteFile(w http.ResponseWriter, r *http.Request, siteID string) {
+	if rejectOversized(w, r, deployBodyLimit()) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, deployBodyLimit())
+	if err := r.ParseMultipartForm(deployFormMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeUploadTooLarge(w)
+			return
+		}
+		jsonError(w, "Failed to parse form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		jsonError(w, "Missing or invalid file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	path, err := hosting.PutSiteFile(siteID, r.FormValue("path"), file, header.Size)
+	if errors.Is(err, hosting.ErrInvalidPath) {
+		jsonError(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logging.Errorf("Failed to write %s/%s: %v", siteID, r.FormValue("path"), err)
+		jsonError(w, "Failed to write file", http.StatusInternalServerError)
+		return
+	}
+
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "site_file_put", siteID+"/"+path)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"site":       siteID,
+		"path":       path,
+		"size_bytes": header.Size,
+	})
+}
+
+// deleteSiteFile handles DELETE /api/sites/{site}/files?path=
+func deleteSiteFile(w http.ResponseWriter, r *http.Request, siteID string) {
+	path, err := hosting.DeleteSiteFile(siteID, r.URL.Query().Get("path"))
+	switch {
+	case errors.Is(err, hosting.ErrInvalidPath):
+		jsonError(w, "Invalid path", http.StatusBadRequest)
+		return
+	case errors.Is(err, hosting.ErrFileNotFound):
+		jsonError(w, "File not found", http.StatusNotFound)
+		return
+	case errors.Is(err, hosting.ErrLastEntryPoint):
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		logging.Errorf("Failed to delete %s/%s: %v", siteID, r.URL.Query().Get("path"), err)
+		jsonError(w, "Failed to delete file", http.StatusInternalServerError)
+		return
+	}
+
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "site_file_delete", siteID+"/"+path)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"success": true,
-		"sites":   sites,
+		"site":    siteID,
+		"path":    path,
+	})
+}
+
+// logStreamKeepalive is how often an idle log stream gets a comment line, so
+// proxies don't time it out
+const logStreamKeepalive = 15 * time.Second
+
+// SiteLogStreamHandler streams a site's serverless log lines as server-sent events
+// until the client disconnects. ?level=warn only sends warn and error lines.
+// GET /api/sites/{site}/logs/stream
+func SiteLogStreamHandler(w http.ResponseWriter, r *http.Request, siteID string) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	minLevel := r.URL.Query().Get("level")
+	if minLevel == "" {
+		minLevel = "info"
+	}
+	if !hosting.ValidLogLevel(minLevel) {
+		jsonError(w, "level must be info, warn or error", http.StatusBadRequest)
+		return
+	}
+
+	// The stream outlives the server's read and write timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	entries, unsubscribe := hosting.SubscribeLogs(siteID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	if err := rc.Flush(); err != nil {
+		logging.Warnf("Log stream for %s can't flush: %v", siteID, err)
+		return
+	}
+
+	keepalive := time.NewTicker(logStreamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case entry, ok := <-entries:
+			if !ok {
+				return // server shutting down
+			}
+			if !hosting.LogAtLeast(entry.Level, minLevel) {
+				continue
+			}
+			data, _ := json.Marshal(entry)
+			fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// invokeRequest describes the request a test invocation passes to main.js
+type invokeRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// newInvokeRequest builds the *http.Request a test invocation runs main.js with
+func newInvokeRequest(r *http.Request, siteID string, in invokeRequest) (*http.Request, error) {
+	method := strings.ToUpper(in.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	path := in.Path
+	if path == "" {
+		path = "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, errors.New("path must start with /")
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), method, "http://"+siteID+path, strings.NewReader(in.Body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
+	}
+	for name, value := range in.Headers {
+		req.Header.Set(name, value)
+	}
+	req.RemoteAddr = r.RemoteAddr
+	return req, nil
+}
+
+// SiteInvokeHandler runs a site's main.js with a request described in the body and
+// returns the response and console output. Nothing is logged or counted as a visit.
+// POST /api/sites/{site}/invoke
+func SiteInvokeHandler(w http.ResponseWriter, r *http.Request, siteID string) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var in invokeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&in); err != nil {
+		jsonError(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	req, err := newInvokeRequest(r, siteID, in)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !hosting.SiteExists(siteID) {
+		jsonError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := hosting.InvokeServerless(req, siteID, database.GetDB())
+	if errors.Is(err, hosting.ErrNoServerless) {
+		jsonError(w, "Site has no main.js", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "site_invoke", siteID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// SiteDeleteHandler removes a site and all of its files
+// DELETE /api/sites/{site}
+func SiteDeleteHandler(w http.ResponseWriter, r *http.Request, siteID string) {
+	if r.Method != http.MethodDelete {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hosting.SiteExists(siteID) {
+		jsonError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	if err := hosting.DeleteSite(siteID); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "site_delete", siteID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Site deleted",
+	})
+}
+
+// SiteToggleHandler takes a site on or offline without deleting its files
+// POST /api/sites/{site}/enable, POST /api/sites/{site}/disable
+func SiteToggleHandler(w http.ResponseWriter, r *http.Request, siteID string, enabled bool) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !hosting.SiteExists(siteID) {
+		jsonError(w, "Site not found", http.StatusNotFound)
+		return
+	}
+
+	if err := hosting.SetSiteEnabled(siteID, enabled); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	action := "site_disable"
+	if enabled {
+		action = "site_enable"
+	}
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), action, siteID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"site":    siteID,
+		"enabled": enabled,
 	})
 }
 
@@ -124,6 +585,8 @@ func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "api_key_create", req.Name)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -150,6 +613,8 @@ func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "api_key_revoke", idStr)
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": true,
@@ -157,7 +622,217 @@ func APIKeysHandler(w http.ResponseWriter, r *http.Request) {
 		})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// APIKeyActionsHandler routes per-key endpoints under /api/keys/{id}/...
+func APIKeyActionsHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/keys/"), "/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	if len(parts) == 2 && parts[1] == "rotate" {
+		APIKeyRotateHandler(w, r, id)
+		return
+	}
+	jsonError(w, "Not found", http.StatusNotFound)
+}
+
+// APIKeyRotateHandler replaces a key without breaking clients still using the old token
+// POST /api/keys/{id}/rotate with optional {"grace_period": "24h"}
+func APIKeyRotateHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		GracePeriod string `json:"grace_period"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	grace := hosting.DefaultKeyRotationGrace
+	if req.GracePeriod != "" {
+		d, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || d < 0 {
+			jsonError(w, "Invalid grace_period (e.g. \"24h\", \"30m\")", http.StatusBadRequest)
+			return
+		}
+		grace = d
+	}
+
+	db := database.GetDB()
+	token, newID, expiresAt, err := hosting.RotateAPIKey(db, id, grace)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case hosting.ErrAPIKeyNotFound:
+			status = http.StatusNotFound
+		case hosting.ErrAPIKeyRotated:
+			status = http.StatusConflict
+		}
+		jsonError(w, err.Error(), status)
+		return
+	}
+
+	audit.Log(sessionUsername(r), analytics.RemoteIP(r), "api_key_rotate", strconv.FormatInt(id, 10), "success",
+		fmt.Sprintf("replaced by key %d, old key expires %s", newID, expiresAt.Format(time.RFC3339)))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"id":             newID,
+		"token":          token,
+		"old_key_id":     id,
+		"old_expires_at": expiresAt,
+		"message":        "API key rotated. Save this token - it won't be shown again!",
+	})
+}
+
+// CustomDomainsHandler manages hostname -> site mappings
+// GET lists mappings, POST {hostname, site_id} adds one, DELETE ?hostname= removes one
+func CustomDomainsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		domains, err := hosting.ListCustomDomains()
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"domains": domains,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Hostname string `json:"hostname"`
+			SiteID   string `json:"site_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.Hostname == "" || req.SiteID == "" {
+			jsonError(w, "hostname and site_id are required", http.StatusBadRequest)
+			return
+		}
+
+		if err := hosting.AddCustomDomain(req.Hostname, req.SiteID); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"hostname": hosting.NormalizeHostname(req.Hostname),
+			"site_id":  strings.ToLower(req.SiteID),
+		})
+
+	case http.MethodDelete:
+		hostname := r.URL.Query().Get("hostname")
+		if hostname == "" {
+			jsonError(w, "hostname parameter required", http.StatusBadRequest)
+			return
+		}
+
+		if err := hosting.RemoveCustomDomain(hostname); err != nil {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Custom domain removed",
+		})
+
+	default:
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// SiteAliasesHandler manages site aliases
+// GET /api/site-aliases, POST {"alias", "site_id"}, DELETE ?alias=
+func SiteAliasesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		aliases, err := hosting.ListSiteAliases()
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"aliases": aliases,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Alias  string `json:"alias"`
+			SiteID string `json:"site_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.Alias == "" || req.SiteID == "" {
+			jsonError(w, "alias and site_id are required", http.StatusBadRequest)
+			return
+		}
+
+		if err := hosting.AddSiteAlias(req.Alias, req.SiteID); err != nil {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "site_alias_add", strings.ToLower(req.Alias))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"alias":   strings.ToLower(req.Alias),
+			"site_id": strings.ToLower(req.SiteID),
+		})
+
+	case http.MethodDelete:
+		alias := r.URL.Query().Get("alias")
+		if alias == "" {
+			jsonError(w, "alias parameter required", http.StatusBadRequest)
+			return
+		}
+
+		if err := hosting.RemoveSiteAlias(alias); err != nil {
+			jsonError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "site_alias_remove", strings.ToLower(alias))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Alias removed",
+		})
+
+	default:
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -232,14 +907,14 @@ func EnvVarsHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // DeploymentsHandler returns recent deployments
 func DeploymentsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -1,7 +1,15 @@
 package handlers
 
 import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/jikku/command-center/internal/config"
+	"github.com/jikku/command-center/internal/hosting"
 )
 
 func TestValidateEnvVarName(t *testing.T) {
@@ -134,3 +142,150 @@ func TestValidateEnvVarNameEdgeCases(t *testing.T) {
 		t.Error("129-char name should be invalid")
 	}
 }
+
+func TestUsageResponse(t *testing.T) {
+	usage := &hosting.StorageUsage{
+		TotalBytes: 900,
+		Sites: []hosting.SiteUsage{
+			{Name: "big", SizeBytes: 600, OverQuota: true},
+			{Name: "small", SizeBytes: 300},
+		},
+	}
+
+	// No limits configured
+	resp := usageResponse(usage, config.HostingConfig{})
+	if resp["total_bytes"] != int64(900) || resp["site_count"] != 2 {
+		t.Errorf("totals = %v / %v, want 900 bytes over 2 sites", resp["total_bytes"], resp["site_count"])
+	}
+	if resp["site_quota_bytes"] != nil || resp["remaining_bytes"] != nil {
+		t.Errorf("unconfigured limits should be null, got quota %v remaining %v", resp["site_quota_bytes"], resp["remaining_bytes"])
+	}
+
+	resp = usageResponse(usage, config.HostingConfig{SiteQuotaBytes: 500, MaxStorageBytes: 1000})
+	if resp["site_quota_bytes"] != int64(500) || resp["remaining_bytes"] != int64(100) {
+		t.Errorf("quota %v remaining %v, want 500 and 100", resp["site_quota_bytes"], resp["remaining_bytes"])
+	}
+
+	// Over the max: nothing remaining, never negative
+	resp = usageResponse(usage, config.HostingConfig{MaxStorageBytes: 800})
+	if resp["remaining_bytes"] != int64(0) {
+		t.Errorf("remaining = %v, want 0 when over the max", resp["remaining_bytes"])
+	}
+}
+
+func TestSiteLogStreamHandler(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(SiteActionsHandler))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/sites/streamsite/logs/stream?level=warn")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	reader.ReadString('\n') // ": connected"
+	reader.ReadString('\n')
+
+	// The info line is filtered out; the error line comes through
+	hosting.WriteSiteLog(nil, "streamsite", "info", "quiet")
+	hosting.WriteSiteLog(nil, "streamsite", "error", "boom")
+
+	lines := make(chan string)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- line
+		}
+	}()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case line := <-lines:
+			if strings.Contains(line, "quiet") {
+				t.Fatal("info line should be filtered at level=warn")
+			}
+			if strings.HasPrefix(line, "data: ") {
+				if !strings.Contains(line, `"message":"boom"`) || !strings.Contains(line, `"level":"error"`) {
+					t.Errorf("event = %q, want the error line", line)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("no log event received")
+		}
+	}
+}
+
+func TestSiteLogStreamHandler_InvalidLevel(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/sites/blog/logs/stream?level=trace", nil)
+	rec := httptest.NewRecorder()
+	SiteActionsHandler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestNewInvokeRequest(t *testing.T) {
+	parent := httptest.NewRequest("POST", "/api/sites/blog/invoke", nil)
+
+	req, err := newInvokeRequest(parent, "blog", invokeRequest{
+		Method:  "post",
+		Path:    "/api/items?page=2",
+		Headers: map[string]string{"Content-Type": "application/json"},
+		Body:    `{"name":"x"}`,
+	})
+	if err != nil {
+		t.Fatalf("newInvokeRequest failed: %v", err)
+	}
+	if req.Method != "POST" || req.Host != "blog" || req.URL.Path != "/api/items" || req.URL.Query().Get("page") != "2" {
+		t.Errorf("request = %s %s%s, want POST blog/api/items?page=2", req.Method, req.Host, req.URL.RequestURI())
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	req, err = newInvokeRequest(parent, "blog", invokeRequest{})
+	if err != nil {
+		t.Fatalf("newInvokeRequest with defaults failed: %v", err)
+	}
+	if req.Method != "GET" || req.URL.Path != "/" {
+		t.Errorf("defaults = %s %s, want GET /", req.Method, req.URL.Path)
+	}
+
+	if _, err := newInvokeRequest(parent, "blog", invokeRequest{Path: "api"}); err == nil {
+		t.Error("expected error for path without leading slash")
+	}
+}
+
+func TestSiteInvokeHandler_BadInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"wrong method", "GET", "", http.StatusMethodNotAllowed},
+		{"invalid JSON", "POST", "{", http.StatusBadRequest},
+		{"relative path", "POST", `{"path":"x"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/sites/blog/invoke", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			SiteActionsHandler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"encoding/base64"
-	"log"
 	"net/http"
 	"strings"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/models"
 )
 
 // 1x1 transparent GIF pixel (base64 encoded)
@@ -32,13 +34,10 @@ func PixelHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse tags
-	tagsStr := ""
-	if tagsParam != "" {
-		tagsStr = tagsParam
-	}
+	tagsStr := models.JoinTags([]string{tagsParam})
 
 	// Extract client info
-	ipAddress := extractIPAddress(r)
+	ipAddress := analytics.ClientIP(r)
 	userAgent := r.UserAgent()
 	referrer := r.Referer()
 
@@ -47,22 +46,32 @@ func PixelHandler(w http.ResponseWriter, r *http.Request) {
 		source = "pixel"
 	}
 
-	// Log event to database
-	db := database.GetDB()
-	_, err := db.Exec(`
-		INSERT INTO events (domain, tags, source_type, event_type, path, referrer, user_agent, ip_address)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, domain, tagsStr, "pixel", source, "", referrer, userAgent, ipAddress)
-
-	if err != nil {
-		log.Printf("Error logging pixel event: %v", err)
-		// Don't fail - still return pixel
+	// Queue the event; the pixel is returned either way. In token mode, events
+	// without a token for this domain are dropped.
+	allowed := true
+	if analytics.RequireToken() {
+		ok, err := analytics.TokenAllows(database.GetDB(), query.Get("k"), domain)
+		if err != nil {
+			logging.Errorf("Failed to check tracking token: %v", err)
+		}
+		allowed = ok
+	}
+	if allowed {
+		analytics.Record(analytics.Event{
+			Domain:     domain,
+			Tags:       tagsStr,
+			SourceType: "pixel",
+			EventType:  analytics.NormalizeEventType(source),
+			Referrer:   referrer,
+			UserAgent:  userAgent,
+			IPAddress:  ipAddress,
+		})
 	}
 
 	// Decode base64 GIF
 	gifBytes, err := base64.StdEncoding.DecodeString(transparentGIF)
 	if err != nil {
-		log.Printf("Error decoding GIF: %v", err)
+		logging.Errorf("Error decoding GIF: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
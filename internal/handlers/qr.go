@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jikku/command-center/internal/config"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/qrcode"
+)
+
+const (
+	defaultQRSize = 256
+	minQRSize     = 64
+	maxQRSize     = 2048
+
+	// maxQRCacheEntries bounds the in-memory QR cache; it is cleared when full
+	maxQRCacheEntries = 512
+)
+
+var (
+	qrCache   = make(map[string][]byte)
+	qrCacheMu sync.Mutex
+)
+
+// RedirectQRHandler serves a PNG QR code encoding a redirect's short URL
+// GET /r/{slug}.png?size=256
+func RedirectQRHandler(w http.ResponseWriter, r *http.Request, slug string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	db := database.GetDB()
+	var exists int
+	if err := db.QueryRow("SELECT COUNT(*) FROM redirects WHERE slug = ?", slug).Scan(&exists); err != nil {
+		logging.Errorf("Error looking up redirect: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if exists == 0 {
+		http.Error(w, "Redirect not found", http.StatusNotFound)
+		return
+	}
+
+	size := parseInt(r.URL.Query().Get("size"), defaultQRSize)
+	if size < minQRSize {
+		size = minQRSize
+	}
+	if size > maxQRSize {
+		size = maxQRSize
+	}
+
+	shortURL := redirectURL(config.Get().Server.Domain, slug)
+	img, err := redirectQR(shortURL, size)
+	if err != nil {
+		logging.Errorf("Error generating QR code for %s: %v", slug, err)
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(img)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(img)
+}
+
+// redirectURL builds the public short URL for a slug
+func redirectURL(domain, slug string) string {
+	return strings.TrimRight(domain, "/") + "/r/" + url.PathEscape(slug)
+}
+
+// redirectQR returns the cached PNG for content at size, generating it on first use
+func redirectQR(content string, size int) ([]byte, error) {
+	key := strconv.Itoa(size) + "|" + content
+
+	qrCacheMu.Lock()
+	img, ok := qrCache[key]
+	qrCacheMu.Unlock()
+	if ok {
+		return img, nil
+	}
+
+	code, err := qrcode.Encode(content)
+	if err != nil {
+		return nil, err
+	}
+	img, err = code.PNG(size)
+	if err != nil {
+		return nil, err
+	}
+
+	qrCacheMu.Lock()
+	if len(qrCache) >= maxQRCacheEntries {
+		qrCache = make(map[string][]byte)
+	}
+	qrCache[key] = img
+	qrCacheMu.Unlock()
+
+	return img, nil
+}
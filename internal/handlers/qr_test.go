@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRedirectURL(t *testing.T) {
+	tests := []struct {
+		domain string
+		slug   string
+		want   string
+	}{
+		{"https://fazt.sh", "promo", "https://fazt.sh/r/promo"},
+		{"https://fazt.sh/", "promo", "https://fazt.sh/r/promo"},
+		{"https://fazt.sh", "spring sale", "https://fazt.sh/r/spring%20sale"},
+	}
+
+	for _, tt := range tests {
+		if got := redirectURL(tt.domain, tt.slug); got != tt.want {
+			t.Errorf("redirectURL(%q, %q) = %q, want %q", tt.domain, tt.slug, got, tt.want)
+		}
+	}
+}
+
+func TestRedirectQR_Cached(t *testing.T) {
+	first, err := redirectQR("https://fazt.sh/r/promo", 256)
+	if err != nil {
+		t.Fatalf("redirectQR failed: %v", err)
+	}
+	if !bytes.HasPrefix(first, []byte("\x89PNG")) {
+		t.Fatal("redirectQR should return a PNG")
+	}
+
+	second, _ := redirectQR("https://fazt.sh/r/promo", 256)
+	if &first[0] != &second[0] {
+		t.Error("second call should return the cached image")
+	}
+
+	larger, _ := redirectQR("https://fazt.sh/r/promo", 512)
+	if len(larger) <= len(first) {
+		t.Error("larger size should produce a larger image")
+	}
+}
@@ -2,11 +2,14 @@ package handlers
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/models"
 )
 
 // RedirectHandler handles redirect tracking
@@ -20,62 +23,95 @@ func RedirectHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// /r/{slug}.png serves a QR code for the short link
+	if strings.HasSuffix(slug, ".png") {
+		RedirectQRHandler(w, r, strings.TrimSuffix(slug, ".png"))
+		return
+	}
+
 	// Lookup redirect in database
 	db := database.GetDB()
-	var destination string
+	var redirect models.Redirect
 	var tags string
-	var id int64
+	var startsAt, expiresAt sql.NullTime
 
 	err := db.QueryRow(`
-		SELECT id, destination, tags FROM redirects WHERE slug = ?
-	`, slug).Scan(&id, &destination, &tags)
+		SELECT id, destination, tags, status_code, starts_at, expires_at, COALESCE(fallback_url, '')
+		FROM redirects WHERE slug = ?
+	`, slug).Scan(&redirect.ID, &redirect.Destination, &tags, &redirect.StatusCode, &startsAt, &expiresAt, &redirect.FallbackURL)
 
 	if err == sql.ErrNoRows {
 		http.Error(w, "Redirect not found", http.StatusNotFound)
 		return
 	} else if err != nil {
-		log.Printf("Error looking up redirect: %v", err)
+		logging.Errorf("Error looking up redirect: %v", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Parse additional tags from query string
-	query := r.URL.Query()
-	extraTags := query.Get("tags")
-	if extraTags != "" {
-		if tags != "" {
-			tags = tags + "," + extraTags
-		} else {
-			tags = extraTags
+	// Outside the schedule window: send to the fallback if set, otherwise 404
+	if startsAt.Valid {
+		redirect.StartsAt = &startsAt.Time
+	}
+	if expiresAt.Valid {
+		redirect.ExpiresAt = &expiresAt.Time
+	}
+	if !redirect.IsActive(time.Now()) {
+		if redirect.FallbackURL != "" {
+			http.Redirect(w, r, redirect.FallbackURL, http.StatusFound)
+			return
 		}
+		http.Error(w, "Redirect not found", http.StatusNotFound)
+		return
 	}
 
+	// Parse additional tags from query string
+	query := r.URL.Query()
+	tags = models.JoinTags([]string{tags, query.Get("tags")})
+
 	// Extract client info
-	ipAddress := extractIPAddress(r)
+	ipAddress := analytics.ClientIP(r)
 	userAgent := r.UserAgent()
 	referrer := r.Referer()
 
 	// Log the click event
-	_, err = db.Exec(`
-		INSERT INTO events (domain, tags, source_type, event_type, path, referrer, user_agent, ip_address)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, slug, tags, "redirect", "click", "/r/"+slug, referrer, userAgent, ipAddress)
-
-	if err != nil {
-		log.Printf("Error logging redirect event: %v", err)
-		// Don't fail the redirect - continue
-	}
+	analytics.Record(analytics.Event{
+		Domain:     slug,
+		Tags:       tags,
+		SourceType: "redirect",
+		EventType:  "click",
+		Path:       "/r/" + slug,
+		Referrer:   referrer,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		Country:    requestCountry(r),
+	})
 
 	// Increment click count
 	_, err = db.Exec(`
 		UPDATE redirects SET click_count = click_count + 1 WHERE id = ?
-	`, id)
+	`, redirect.ID)
 
 	if err != nil {
-		log.Printf("Error incrementing click count: %v", err)
+		logging.Errorf("Error incrementing click count: %v", err)
 		// Don't fail the redirect - continue
 	}
 
 	// Perform redirect
-	http.Redirect(w, r, destination, http.StatusFound)
+	status := redirect.StatusCode
+	if !models.ValidRedirectStatus(status) {
+		status = http.StatusFound
+	}
+	http.Redirect(w, r, redirect.Destination, status)
+}
+
+// requestCountry returns the visitor's country code as reported by an upstream proxy, or ""
+func requestCountry(r *http.Request) string {
+	for _, header := range []string{"CF-IPCountry", "X-Country-Code"} {
+		code := strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+		if len(code) == 2 {
+			return code
+		}
+	}
+	return ""
 }
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/models"
+)
+
+// maxRedirectImportSize caps the size of an uploaded CSV (10MB)
+const maxRedirectImportSize = 10 << 20
+
+// redirectRow is one parsed line of a redirect CSV
+type redirectRow struct {
+	Line        int
+	Slug        string
+	Destination string
+	Tags        string
+}
+
+// rowError reports why a CSV line was rejected
+type rowError struct {
+	Line  int    `json:"line"`
+	Slug  string `json:"slug,omitempty"`
+	Error string `json:"error"`
+}
+
+// parseRedirectCSV reads slug,destination,tags rows, skipping an optional header.
+// Invalid rows are returned as errors rather than aborting the whole file.
+func parseRedirectCSV(r io.Reader) ([]redirectRow, []rowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []redirectRow
+	var errs []rowError
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && strings.EqualFold(strings.TrimSpace(record[0]), "slug") {
+			continue
+		}
+		if len(record) < 2 || len(record) > 3 {
+			errs = append(errs, rowError{Line: line, Error: "expected slug,destination[,tags]"})
+			continue
+		}
+
+		row := redirectRow{
+			Line:        line,
+			Slug:        strings.TrimSpace(record[0]),
+			Destination: strings.TrimSpace(record[1]),
+		}
+		if len(record) == 3 {
+			row.Tags = models.JoinTags([]string{record[2]})
+		}
+
+		if err := validateRedirect(row.Slug, row.Destination); err != nil {
+			errs = append(errs, rowError{Line: line, Slug: row.Slug, Error: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, errs, nil
+}
+
+// validateRedirect checks a slug and destination URL
+func validateRedirect(slug, destination string) error {
+	if slug == "" || destination == "" {
+		return fmt.Errorf("slug and destination are required")
+	}
+	if strings.ContainsAny(slug, "/?# \t") {
+		return fmt.Errorf("slug must not contain slashes, spaces, '?' or '#'")
+	}
+	return validateRedirectURL("destination", destination)
+}
+
+// validateRedirectURL checks that a redirect target is an absolute http(s) URL
+func validateRedirectURL(field, value string) error {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute http(s) URL", field)
+	}
+	return nil
+}
+
+// RedirectsImportHandler bulk-creates redirects from CSV
+// POST /api/redirects/import with a text/csv body or a multipart "file" field
+func RedirectsImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRedirectImportSize)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			jsonError(w, "Missing or invalid file", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	rows, rowErrors, err := parseRedirectCSV(body)
+	if err != nil {
+		jsonError(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db := database.GetDB()
+	tx, err := db.Begin()
+	if err != nil {
+		jsonError(w, "Failed to start import", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	imported := 0
+	skipped := []string{}
+	for _, row := range rows {
+		// Duplicates (existing slugs or repeats within the file) are skipped
+		result, err := tx.Exec(`
+			INSERT INTO redirects (slug, destination, tags) VALUES (?, ?, ?)
+			ON CONFLICT(slug) DO NOTHING
+		`, row.Slug, row.Destination, row.Tags)
+		if err != nil {
+			rowErrors = append(rowErrors, rowError{Line: row.Line, Slug: row.Slug, Error: "insert failed"})
+			continue
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			skipped = append(skipped, row.Slug)
+			continue
+		}
+		imported++
+	}
+
+	if err := tx.Commit(); err != nil {
+		logging.Errorf("Error committing redirect import: %v", err)
+		jsonError(w, "Failed to import redirects", http.StatusInternalServerError)
+		return
+	}
+
+	if rowErrors == nil {
+		rowErrors = []rowError{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"imported": imported,
+		"skipped":  skipped,
+		"errors":   rowErrors,
+	})
+}
+
+// RedirectsExportHandler downloads all redirects as CSV (slug,destination,tags)
+// GET /api/redirects/export
+func RedirectsExportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	db := database.GetDB()
+	rows, err := db.Query(`SELECT slug, destination, COALESCE(tags, '') FROM redirects ORDER BY slug`)
+	if err != nil {
+		logging.Errorf("Error querying redirects: %v", err)
+		jsonError(w, "Failed to query redirects", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="redirects.csv"`)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"slug", "destination", "tags"})
+	for rows.Next() {
+		var slug, destination, tags string
+		if err := rows.Scan(&slug, &destination, &tags); err != nil {
+			continue
+		}
+		writer.Write([]string{slug, destination, tags})
+	}
+	writer.Flush()
+}
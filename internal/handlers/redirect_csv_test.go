@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRedirectCSV(t *testing.T) {
+	input := `slug,destination,tags
+promo,https://example.com/promo,"spring,email"
+docs,https://example.com/docs
+
+bad slug,https://example.com/x
+nourl,ftp://example.com/file
+onlyslug
+`
+	rows, errs, err := parseRedirectCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parseRedirectCSV failed: %v", err)
+	}
+
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2: %+v", len(rows), rows)
+	}
+	if rows[0].Slug != "promo" || rows[0].Tags != "spring,email" {
+		t.Errorf("row 0 = %+v", rows[0])
+	}
+	if rows[1].Slug != "docs" || rows[1].Tags != "" {
+		t.Errorf("row 1 = %+v", rows[1])
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("got %d errors, want 3: %+v", len(errs), errs)
+	}
+	wantLines := []int{5, 6, 7}
+	for i, e := range errs {
+		if e.Line != wantLines[i] {
+			t.Errorf("error %d line = %d, want %d", i, e.Line, wantLines[i])
+		}
+	}
+}
+
+func TestParseRedirectCSV_NoHeader(t *testing.T) {
+	rows, errs, err := parseRedirectCSV(strings.NewReader("a,https://example.com/a\n"))
+	if err != nil || len(errs) != 0 || len(rows) != 1 {
+		t.Fatalf("rows=%v errs=%v err=%v", rows, errs, err)
+	}
+	if rows[0].Line != 1 {
+		t.Errorf("line = %d, want 1", rows[0].Line)
+	}
+}
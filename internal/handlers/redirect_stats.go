@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/models"
+)
+
+// RedirectStatsHandler returns click analytics for one redirect
+// GET /api/redirects/{id}/stats?days=30
+func RedirectStatsHandler(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days := parseInt(r.URL.Query().Get("days"), 30)
+	if days < 1 {
+		days = 1
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	ctx := r.Context()
+	db := database.GetDB()
+	stats := models.RedirectStats{
+		ID:           id,
+		Daily:        []models.TimelineStat{},
+		TopReferrers: []models.ReferrerStat{},
+		Countries:    []models.CountryStat{},
+	}
+
+	err := db.QueryRowContext(ctx, "SELECT slug, click_count FROM redirects WHERE id = ?", id).Scan(&stats.Slug, &stats.TotalClicks)
+	if err == sql.ErrNoRows {
+		jsonError(w, "Redirect not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		logging.Errorf("Error looking up redirect: %v", err)
+		jsonError(w, "Failed to query redirect", http.StatusInternalServerError)
+		return
+	}
+
+	// Clicks are logged by RedirectHandler as source_type=redirect on /r/{slug}
+	path := "/r/" + stats.Slug
+	since := "-" + strconv.Itoa(days) + " days"
+
+	// Daily clicks
+	rows, err := db.QueryContext(ctx, `
+		SELECT DATE(created_at) as day, COUNT(*) as count
+		FROM events
+		WHERE source_type = 'redirect' AND path = ? AND created_at >= DATE('now', ?)
+		GROUP BY day
+		ORDER BY day
+	`, path, since)
+	if err == nil {
+		for rows.Next() {
+			var ts models.TimelineStat
+			rows.Scan(&ts.Timestamp, &ts.Count)
+			stats.Daily = append(stats.Daily, ts)
+		}
+		rows.Close()
+	}
+
+	// Top 10 referrers
+	rows, err = db.QueryContext(ctx, `
+		SELECT referrer, COUNT(*) as count
+		FROM events
+		WHERE source_type = 'redirect' AND path = ? AND created_at >= DATE('now', ?) AND referrer != ''
+		GROUP BY referrer
+		ORDER BY count DESC
+		LIMIT 10
+	`, path, since)
+	if err == nil {
+		for rows.Next() {
+			var rs models.ReferrerStat
+			rows.Scan(&rs.Referrer, &rs.Count)
+			stats.TopReferrers = append(stats.TopReferrers, rs)
+		}
+		rows.Close()
+	}
+
+	// Country breakdown
+	rows, err = db.QueryContext(ctx, `
+		SELECT COALESCE(country, '') as country, COUNT(*) as count
+		FROM events
+		WHERE source_type = 'redirect' AND path = ? AND created_at >= DATE('now', ?)
+		GROUP BY 1
+		ORDER BY count DESC
+	`, path, since)
+	if err == nil {
+		for rows.Next() {
+			var cs models.CountryStat
+			rows.Scan(&cs.Country, &cs.Count)
+			stats.Countries = append(stats.Countries, cs)
+		}
+		rows.Close()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/models"
+)
+
+// setupTestDatabase initializes a migrated database in a temp dir
+func setupTestDatabase(t *testing.T) {
+	t.Helper()
+	if err := database.Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatalf("Failed to init database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	auth.GetDeployLimiter().Reset()
+}
+
+func TestRedirectHandler_Schedule(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	past := time.Now().Add(-time.Hour).UTC()
+	future := time.Now().Add(time.Hour).UTC()
+
+	db.Exec(`INSERT INTO redirects (slug, destination, tags, expires_at) VALUES (?, ?, '', ?)`,
+		"expired", "https://example.com/sale", past)
+	db.Exec(`INSERT INTO redirects (slug, destination, tags, starts_at) VALUES (?, ?, '', ?)`,
+		"upcoming", "https://example.com/launch", future)
+	db.Exec(`INSERT INTO redirects (slug, destination, tags, expires_at, fallback_url) VALUES (?, ?, '', ?, ?)`,
+		"ended", "https://example.com/promo", past, "https://example.com/")
+	db.Exec(`INSERT INTO redirects (slug, destination, tags, starts_at, expires_at) VALUES (?, ?, '', ?, ?)`,
+		"live", "https://example.com/live", past, future)
+
+	tests := []struct {
+		slug     string
+		status   int
+		location string
+	}{
+		{"expired", http.StatusNotFound, ""},
+		{"upcoming", http.StatusNotFound, ""},
+		{"ended", http.StatusFound, "https://example.com/"},
+		{"live", http.StatusFound, "https://example.com/live"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.slug, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			RedirectHandler(w, httptest.NewRequest("GET", "/r/"+tt.slug, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if loc := w.Header().Get("Location"); loc != tt.location {
+				t.Errorf("Location = %q, want %q", loc, tt.location)
+			}
+		})
+	}
+
+	// Clicks outside the window are not counted
+	var clicks int
+	db.QueryRow("SELECT click_count FROM redirects WHERE slug = 'expired'").Scan(&clicks)
+	if clicks != 0 {
+		t.Errorf("expired redirect click_count = %d, want 0", clicks)
+	}
+}
+
+func TestValidateRedirectSchedule(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	if err := validateRedirectSchedule(nil, nil); err != nil {
+		t.Errorf("no schedule: unexpected error %v", err)
+	}
+	if err := validateRedirectSchedule(&now, &later); err != nil {
+		t.Errorf("valid window: unexpected error %v", err)
+	}
+	if err := validateRedirectSchedule(&later, &now); err == nil {
+		t.Error("expires before start should fail")
+	}
+}
+
+func TestRedirectStatsHandler(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	result, _ := db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('promo', 'https://example.com', '')`)
+	id, _ := result.LastInsertId()
+
+	// Two clicks from Germany via a newsletter, one from an unknown country
+	for _, country := range []string{"DE", "DE", ""} {
+		req := httptest.NewRequest("GET", "/r/promo", nil)
+		req.Header.Set("CF-IPCountry", country)
+		if country != "" {
+			req.Header.Set("Referer", "https://news.example.com")
+		}
+		RedirectHandler(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	RedirectActionsHandler(w, httptest.NewRequest("GET", "/api/redirects/"+strconv.FormatInt(id, 10)+"/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var stats models.RedirectStats
+	if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if stats.Slug != "promo" || stats.TotalClicks != 3 {
+		t.Errorf("slug = %q, total = %d; want promo, 3", stats.Slug, stats.TotalClicks)
+	}
+	if len(stats.Daily) != 1 || stats.Daily[0].Count != 3 {
+		t.Errorf("daily = %+v, want one bucket of 3", stats.Daily)
+	}
+	if len(stats.TopReferrers) != 1 || stats.TopReferrers[0].Count != 2 {
+		t.Errorf("top referrers = %+v", stats.TopReferrers)
+	}
+	if len(stats.Countries) != 2 || stats.Countries[0].Country != "DE" || stats.Countries[0].Count != 2 {
+		t.Errorf("countries = %+v", stats.Countries)
+	}
+
+	// Unknown redirect
+	w = httptest.NewRecorder()
+	RedirectActionsHandler(w, httptest.NewRequest("GET", "/api/redirects/9999/stats", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown id status = %d, want 404", w.Code)
+	}
+}
+
+func TestRequestCountry(t *testing.T) {
+	req := httptest.NewRequest("GET", "/r/x", nil)
+	if got := requestCountry(req); got != "" {
+		t.Errorf("no header: got %q", got)
+	}
+
+	req.Header.Set("X-Country-Code", "in")
+	if got := requestCountry(req); got != "IN" {
+		t.Errorf("X-Country-Code: got %q, want IN", got)
+	}
+
+	req.Header.Set("CF-IPCountry", "us")
+	if got := requestCountry(req); got != "US" {
+		t.Errorf("CF-IPCountry should take precedence: got %q", got)
+	}
+}
+
+func TestRedirectHandler_StatusCode(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('temp', 'https://example.com/a', '')`)
+	db.Exec(`INSERT INTO redirects (slug, destination, tags, status_code) VALUES ('moved', 'https://example.com/b', '', 301)`)
+
+	for slug, want := range map[string]int{"temp": http.StatusFound, "moved": http.StatusMovedPermanently} {
+		w := httptest.NewRecorder()
+		RedirectHandler(w, httptest.NewRequest("GET", "/r/"+slug, nil))
+		if w.Code != want {
+			t.Errorf("%s: status = %d, want %d", slug, w.Code, want)
+		}
+	}
+}
+
+func TestRedirectsHandler_IfMatch(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	result, _ := db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('docs', 'https://example.com/a', '')`)
+	id, _ := result.LastInsertId()
+
+	put := func(destination, ifMatch string) *httptest.ResponseRecorder {
+		body := `{"id": ` + strconv.FormatInt(id, 10) + `, "destination": "` + destination + `"}`
+		req := httptest.NewRequest(http.MethodPut, "/api/redirects", strings.NewReader(body))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		RedirectsHandler(w, req)
+		return w
+	}
+
+	if w := put("https://example.com/b", ""); w.Code != http.StatusPreconditionRequired {
+		t.Errorf("no If-Match: status = %d, want 428", w.Code)
+	}
+
+	// Two tabs load version 1; the first save wins
+	w := put("https://example.com/b", `"1"`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first update: status = %d, body %s", w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"2"` {
+		t.Errorf("ETag after update = %q, want \"2\"", etag)
+	}
+	if w := put("https://example.com/c", `"1"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: status = %d, want 412", w.Code)
+	}
+
+	var destination string
+	db.QueryRow("SELECT destination FROM redirects WHERE id = ?", id).Scan(&destination)
+	if destination != "https://example.com/b" {
+		t.Errorf("destination = %q, the stale update must not apply", destination)
+	}
+
+	// The list carries each redirect's version for the next update
+	w = httptest.NewRecorder()
+	RedirectsHandler(w, httptest.NewRequest(http.MethodGet, "/api/redirects", nil))
+	var redirects []struct {
+		Version int64 `json:"version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &redirects); err != nil || len(redirects) != 1 || redirects[0].Version != 2 {
+		t.Errorf("GET /api/redirects = %s, want version 2", w.Body.String())
+	}
+}
+
+func TestRedirectDetailHandler_ETag(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	result, _ := db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('docs', 'https://example.com/a', 'blog')`)
+	id, _ := result.LastInsertId()
+	path := "/api/redirects/" + strconv.FormatInt(id, 10)
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		RedirectActionsHandler(w, req)
+		return w
+	}
+
+	w := get(path, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: status = %d, body %s", w.Code, w.Body.String())
+	}
+	var redirect struct {
+		Slug    string   `json:"slug"`
+		Tags    []string `json:"tags"`
+		Version int64    `json:"version"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &redirect); err != nil || redirect.Slug != "docs" || len(redirect.Tags) != 1 || redirect.Version != 1 {
+		t.Errorf("GET body = %s", w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag != `"1"` {
+		t.Fatalf("ETag = %q, want \"1\"", etag)
+	}
+	if w := get(path, etag); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: status = %d, want 304", w.Code)
+	}
+
+	// The ETag it sends is the one a PUT accepts
+	body := `{"id": ` + strconv.FormatInt(id, 10) + `, "destination": "https://example.com/b"}`
+	req := httptest.NewRequest(http.MethodPut, "/api/redirects", strings.NewReader(body))
+	req.Header.Set("If-Match", etag)
+	put := httptest.NewRecorder()
+	RedirectsHandler(put, req)
+	if put.Code != http.StatusOK {
+		t.Fatalf("PUT with the GET's ETag: status = %d, body %s", put.Code, put.Body.String())
+	}
+	if w := get(path, ""); w.Header().Get("ETag") != put.Header().Get("ETag") {
+		t.Errorf("ETag after update = %q, want %q", w.Header().Get("ETag"), put.Header().Get("ETag"))
+	}
+
+	for _, p := range []string{"/api/redirects/999", "/api/redirects/abc", path + "/clicks"} {
+		if w := get(p, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status = %d, want 404", p, w.Code)
+		}
+	}
+}
+
+func TestRedirectsHandler_PutPatch(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	starts := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+	result, _ := db.Exec(`INSERT INTO redirects (slug, destination, tags, starts_at, expires_at, fallback_url) VALUES ('sale', 'https://example.com/a', '', ?, ?, 'https://example.com/')`,
+		starts, expires)
+	id, _ := result.LastInsertId()
+	version := 1
+
+	put := func(fields string) *httptest.ResponseRecorder {
+		body := `{"id": ` + strconv.FormatInt(id, 10) + fields + `}`
+		req := httptest.NewRequest(http.MethodPut, "/api/redirects", strings.NewReader(body))
+		req.Header.Set("If-Match", `"`+strconv.Itoa(version)+`"`)
+		w := httptest.NewRecorder()
+		RedirectsHandler(w, req)
+		if w.Code == http.StatusOK {
+			version++
+		}
+		return w
+	}
+	stored := func() (startsAt, expiresAt sql.NullTime, fallback string) {
+		db.QueryRow("SELECT starts_at, expires_at, COALESCE(fallback_url, '') FROM redirects WHERE id = ?", id).
+			Scan(&startsAt, &expiresAt, &fallback)
+		return
+	}
+
+	// Fields left out keep their values
+	if w := put(`, "destination": "https://example.com/b"`); w.Code != http.StatusOK {
+		t.Fatalf("destination update: status = %d, body %s", w.Code, w.Body.String())
+	}
+	if s, e, f := stored(); !s.Time.Equal(starts) || !e.Time.Equal(expires) || f != "https://example.com/" {
+		t.Errorf("after destination update: starts %v, expires %v, fallback %q; want them kept", s, e, f)
+	}
+
+	// A new end is checked against the kept start
+	if w := put(`, "expires_at": "2029-12-01T00:00:00Z"`); w.Code != http.StatusBadRequest {
+		t.Errorf("expiry before the stored start: status = %d, want 400", w.Code)
+	}
+
+	// null clears
+	if w := put(`, "starts_at": null, "fallback_url": null`); w.Code != http.StatusOK {
+		t.Fatalf("clearing update: status = %d, body %s", w.Code, w.Body.String())
+	}
+	if s, e, f := stored(); s.Valid || !e.Time.Equal(expires) || f != "" {
+		t.Errorf("after clearing: starts %v, expires %v, fallback %q; want only expires_at left", s, e, f)
+	}
+
+	for _, fields := range []string{
+		`, "destination": "javascript:alert(1)"`,
+		`, "destination": "/relative"`,
+		`, "fallback_url": "ftp://example.com/"`,
+	} {
+		if w := put(fields); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: status = %d, want 400", fields, w.Code)
+		}
+	}
+
+	body := `{"slug": "new", "destination": "https://example.com/", "fallback_url": "example.com"}`
+	w := httptest.NewRecorder()
+	RedirectsHandler(w, httptest.NewRequest(http.MethodPost, "/api/redirects", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST with a relative fallback_url: status = %d, want 400", w.Code)
+	}
+}
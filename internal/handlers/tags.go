@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+	"github.com/jikku/command-center/internal/models"
+)
+
+// TagActionsHandler renames or deletes a tag across events and redirects
+// PUT /api/tags/{tag} {"name": "new-name"} - rename (merges into an existing tag)
+// DELETE /api/tags/{tag} - remove the tag everywhere
+func TagActionsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimSpace(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/tags/"), "/")))
+	if tag == "" {
+		TagsHandler(w, r)
+		return
+	}
+
+	var rename string
+	switch r.Method {
+	case http.MethodPut:
+		var req struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		names := models.NormalizeTags([]string{req.Name})
+		if len(names) != 1 {
+			jsonError(w, "name must be a single non-empty tag", http.StatusBadRequest)
+			return
+		}
+		rename = names[0]
+	case http.MethodDelete:
+	default:
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	counts, err := rewriteTag(database.GetDB(), tag, rename)
+	if err != nil {
+		logging.Errorf("Error rewriting tag %q: %v", tag, err)
+		jsonError(w, "Failed to update tag", http.StatusInternalServerError)
+		return
+	}
+
+	action := "tag_delete"
+	if rename != "" {
+		action = "tag_rename"
+	}
+	audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), action, tag)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":           true,
+		"tag":               tag,
+		"name":              rename,
+		"events_updated":    counts["events"],
+		"redirects_updated": counts["redirects"],
+	})
+}
+
+// rewriteTag replaces tag with rename (or removes it if rename is "") in the
+// tags column of events and redirects, returning the rows changed per table
+func rewriteTag(db *sql.DB, tag, rename string) (map[string]int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	counts := make(map[string]int64)
+	for _, table := range []string{"events", "redirects"} {
+		// LIKE is only a case-insensitive prefilter; matching happens on normalized tags
+		rows, err := tx.Query("SELECT id, tags FROM "+table+" WHERE tags LIKE ?", "%"+tag+"%")
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", table, err)
+		}
+
+		updates := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var tagsStr string
+			if err := rows.Scan(&id, &tagsStr); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			tags := models.SplitTags(tagsStr)
+			found := false
+			for i, t := range tags {
+				if t == tag {
+					tags[i] = rename
+					found = true
+				}
+			}
+			if found {
+				updates[id] = models.JoinTags(tags)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+
+		for id, tagsStr := range updates {
+			if _, err := tx.Exec("UPDATE "+table+" SET tags = ? WHERE id = ?", tagsStr, id); err != nil {
+				return nil, fmt.Errorf("failed to update %s: %w", table, err)
+			}
+		}
+		counts[table] = int64(len(updates))
+	}
+
+	return counts, tx.Commit()
+}
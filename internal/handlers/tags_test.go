@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jikku/command-center/internal/database"
+)
+
+func TestTagActionsHandler(t *testing.T) {
+	setupTestDatabase(t)
+	db := database.GetDB()
+
+	// Legacy rows with unnormalized tags
+	db.Exec(`INSERT INTO events (domain, tags, source_type, event_type) VALUES ('a.com', 'Blog ,news', 'web', 'pageview')`)
+	db.Exec(`INSERT INTO events (domain, tags, source_type, event_type) VALUES ('a.com', 'weblog,blog', 'web', 'pageview')`)
+	db.Exec(`INSERT INTO events (domain, tags, source_type, event_type) VALUES ('a.com', 'blogroll', 'web', 'pageview')`)
+	db.Exec(`INSERT INTO redirects (slug, destination, tags) VALUES ('x', 'https://example.com', 'blog')`)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/tags/blog", strings.NewReader(`{"name": "Weblog"}`))
+	rec := httptest.NewRecorder()
+	TagActionsHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rename status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var got []string
+	rows, _ := db.Query("SELECT tags FROM events ORDER BY id")
+	for rows.Next() {
+		var s string
+		rows.Scan(&s)
+		got = append(got, s)
+	}
+	rows.Close()
+	want := []string{"weblog,news", "weblog", "blogroll"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("events tags after rename = %q, want %q", got, want)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/tags/weblog", nil)
+	rec = httptest.NewRecorder()
+	TagActionsHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, body %s", rec.Code, rec.Body.String())
+	}
+
+	var redirectTags string
+	db.QueryRow("SELECT tags FROM redirects WHERE slug = 'x'").Scan(&redirectTags)
+	if redirectTags != "" {
+		t.Errorf("redirect tags after delete = %q, want empty", redirectTags)
+	}
+}
+
+func TestTagActionsHandler_InvalidRename(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/api/tags/blog", strings.NewReader(`{"name": " , "}`))
+	rec := httptest.NewRecorder()
+	TagActionsHandler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
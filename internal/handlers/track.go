@@ -1,34 +1,40 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"log"
-	"net"
+	"errors"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/assets"
 	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
 	"github.com/jikku/command-center/internal/models"
 )
 
 const maxBodySize = 10 * 1024 // 10KB
 
-// TrackHandler handles tracking requests
+// TrackHandler handles tracking requests. POST bodies may be JSON (also sent as
+// text/plain, which is what navigator.sendBeacon uses for strings), urlencoded or
+// multipart form data; GET takes the same fields as query parameters.
 func TrackHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if r.Method != http.MethodPost && r.Method != http.MethodGet {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Limit body size
 	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
 
-	// Parse JSON body
-	var req models.TrackRequest
-	decoder := json.NewDecoder(r.Body)
-	if err := decoder.Decode(&req); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+	req, status, err := parseTrackRequest(r)
+	if err != nil {
+		jsonError(w, err.Error(), status)
 		return
 	}
 
@@ -42,9 +48,14 @@ func TrackHandler(w http.ResponseWriter, r *http.Request) {
 	if req.EventType == "" {
 		req.EventType = "pageview"
 	}
+	req.EventType, err = analytics.ParseEventType(req.EventType)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Extract client information
-	ipAddress := extractIPAddress(r)
+	ipAddress := analytics.ClientIP(r)
 	userAgent := r.UserAgent()
 	referrer := req.Referrer
 	if referrer == "" {
@@ -52,34 +63,162 @@ func TrackHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Prepare tags as comma-separated string
-	tagsStr := ""
-	if len(req.Tags) > 0 {
-		tagsStr = strings.Join(req.Tags, ",")
-	}
+	tagsStr := models.JoinTags(req.Tags)
 
 	// Sanitize inputs
 	domain = sanitizeInput(domain)
 	req.Path = sanitizeInput(req.Path)
 	referrer = sanitizeInput(referrer)
 
+	// In token mode, only a token issued for this domain may record events
+	if analytics.RequireToken() {
+		ok, err := analytics.TokenAllows(database.GetDB(), req.Token, domain)
+		if err != nil {
+			logging.Errorf("Failed to check tracking token: %v", err)
+			jsonError(w, "Failed to check tracking token", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			jsonError(w, "Invalid or missing tracking token for this domain", http.StatusForbidden)
+			return
+		}
+	}
+
 	// Convert query params to JSON string
 	queryParamsJSON := req.ToQueryParamsJSON()
 
-	// Insert into database
-	db := database.GetDB()
-	_, err := db.Exec(`
-		INSERT INTO events (domain, tags, source_type, event_type, path, referrer, user_agent, ip_address, query_params)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, domain, tagsStr, "web", req.EventType, req.Path, referrer, userAgent, ipAddress, queryParamsJSON)
+	// Queue for the background writer
+	analytics.Record(analytics.Event{
+		Domain:      domain,
+		Tags:        tagsStr,
+		SourceType:  "web",
+		EventType:   req.EventType,
+		Path:        req.Path,
+		Referrer:    referrer,
+		UserAgent:   userAgent,
+		IPAddress:   ipAddress,
+		QueryParams: queryParamsJSON,
+	})
+
+	// Return 204 No Content on success
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseTrackRequest reads the tracking fields in whichever format r carries them.
+// On failure it returns the status to answer with.
+func parseTrackRequest(r *http.Request) (models.TrackRequest, int, error) {
+	var req models.TrackRequest
 
+	if r.Method == http.MethodGet {
+		if err := trackRequestFromValues(&req, r.URL.Query()); err != nil {
+			return req, http.StatusBadRequest, err
+		}
+		return req, 0, nil
+	}
+
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		parsed, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return req, http.StatusUnsupportedMediaType, errors.New("Invalid Content-Type")
+		}
+		mediaType = parsed
+	}
+
+	switch mediaType {
+	case "application/json", "text/plain":
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, http.StatusBadRequest, errors.New("Invalid JSON")
+		}
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		var err error
+		if mediaType == "multipart/form-data" {
+			err = r.ParseMultipartForm(maxBodySize)
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			return req, http.StatusBadRequest, errors.New("Invalid form data")
+		}
+		if err := trackRequestFromValues(&req, r.PostForm); err != nil {
+			return req, http.StatusBadRequest, err
+		}
+	default:
+		return req, http.StatusUnsupportedMediaType, errors.New("Unsupported Content-Type: use JSON, text/plain or form data")
+	}
+	return req, 0, nil
+}
+
+// trackRequestFromValues fills req from form or query fields named like the JSON
+// ones. Tags may repeat or be comma-separated; q is a JSON object.
+func trackRequestFromValues(req *models.TrackRequest, values url.Values) error {
+	req.Hostname = values.Get("h")
+	req.Domain = values.Get("d")
+	req.Path = values.Get("p")
+	req.EventType = values.Get("e")
+	req.Tags = values["t"]
+	req.Referrer = values.Get("ref")
+	req.Token = values.Get("k")
+	if q := values.Get("q"); q != "" {
+		if err := json.Unmarshal([]byte(q), &req.QueryParams); err != nil {
+			return errors.New("Invalid q: must be a JSON object of strings")
+		}
+	}
+	return nil
+}
+
+// trackScriptPath is the analytics snippet served at /track.js
+const trackScriptPath = "web/static/js/fazt.js"
+
+var (
+	trackScriptOnce    sync.Once
+	trackScript        []byte
+	trackScriptVersion string
+)
+
+// loadTrackScript reads the embedded snippet and versions it by content hash
+func loadTrackScript() {
+	content, err := assets.WebFS.ReadFile(trackScriptPath)
 	if err != nil {
-		log.Printf("Error inserting event: %v", err)
-		http.Error(w, "Failed to track event", http.StatusInternalServerError)
+		logging.Errorf("Failed to load tracking script: %v", err)
 		return
 	}
+	sum := sha256.Sum256(content)
+	trackScript, trackScriptVersion = content, hex.EncodeToString(sum[:6])
+}
 
-	// Return 204 No Content on success
-	w.WriteHeader(http.StatusNoContent)
+// TrackScriptHandler serves the embeddable tracking snippet. Requested as
+// /track.js?v=<version> it's cached for a year; otherwise for an hour, so
+// unversioned embeds pick up new releases. The version is in X-Fazt-Version.
+func TrackScriptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	trackScriptOnce.Do(loadTrackScript)
+	if trackScript == nil {
+		jsonError(w, "Tracking script unavailable", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("X-Fazt-Version", trackScriptVersion)
+	w.Header().Set("ETag", `"`+trackScriptVersion+`"`)
+	if r.URL.Query().Get("v") == trackScriptVersion {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+
+	if r.Header.Get("If-None-Match") == `"`+trackScriptVersion+`"` {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(trackScript)
 }
 
 // determineDomain extracts domain from request in order of priority
@@ -108,33 +247,6 @@ func determineDomain(req *models.TrackRequest, r *http.Request) string {
 	return "unknown"
 }
 
-// extractIPAddress gets the client's IP address from the request
-func extractIPAddress(r *http.Request) string {
-	// Check X-Forwarded-For header first (for proxies/load balancers)
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		// Take the first IP in the list
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-
-	return ip
-}
-
 // sanitizeInput removes potentially dangerous characters and limits length
 func sanitizeInput(input string) string {
 	// Trim whitespace
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/models"
+)
+
+func TestParseTrackRequest_ContentTypes(t *testing.T) {
+	want := models.TrackRequest{
+		Hostname:    "blog.example.com",
+		Path:        "/post/1",
+		EventType:   "click",
+		Tags:        []string{"app", "beta"},
+		QueryParams: map[string]string{"utm_source": "hn"},
+		Referrer:    "https://news.ycombinator.com",
+	}
+	jsonBody := `{"h":"blog.example.com","p":"/post/1","e":"click","t":["app","beta"],"q":{"utm_source":"hn"},"ref":"https://news.ycombinator.com"}`
+	values := url.Values{
+		"h":   {"blog.example.com"},
+		"p":   {"/post/1"},
+		"e":   {"click"},
+		"t":   {"app", "beta"},
+		"q":   {`{"utm_source":"hn"}`},
+		"ref": {"https://news.ycombinator.com"},
+	}
+
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	for key, vals := range values {
+		for _, v := range vals {
+			mw.WriteField(key, v)
+		}
+	}
+	mw.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+	}{
+		{"json", http.MethodPost, "/track", "application/json", jsonBody},
+		{"no content type", http.MethodPost, "/track", "", jsonBody},
+		{"sendBeacon string", http.MethodPost, "/track", "text/plain;charset=UTF-8", jsonBody},
+		{"urlencoded", http.MethodPost, "/track", "application/x-www-form-urlencoded", values.Encode()},
+		{"multipart", http.MethodPost, "/track", mw.FormDataContentType(), multipartBody.String()},
+		{"get", http.MethodGet, "/track?" + values.Encode(), "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			got, status, err := parseTrackRequest(req)
+			if err != nil {
+				t.Fatalf("parseTrackRequest() error = %v (status %d)", err, status)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("parseTrackRequest() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseTrackRequest_CommaSeparatedTags(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/track?h=a.com&t=app,beta", nil)
+	got, _, err := parseTrackRequest(req)
+	if err != nil {
+		t.Fatalf("parseTrackRequest() error = %v", err)
+	}
+	if tags := models.JoinTags(got.Tags); tags != "app,beta" {
+		t.Errorf("tags = %q, want \"app,beta\"", tags)
+	}
+}
+
+func TestTrackHandler_Responses(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"json", http.MethodPost, "application/json", `{"h":"a.com"}`, http.StatusNoContent},
+		{"sendBeacon string", http.MethodPost, "text/plain", `{"h":"a.com"}`, http.StatusNoContent},
+		{"urlencoded", http.MethodPost, "application/x-www-form-urlencoded", "h=a.com&p=%2F", http.StatusNoContent},
+		{"get", http.MethodGet, "", "", http.StatusNoContent},
+		{"invalid json", http.MethodPost, "text/plain", "h=a.com", http.StatusBadRequest},
+		{"invalid q", http.MethodPost, "application/x-www-form-urlencoded", "h=a.com&q=nope", http.StatusBadRequest},
+		{"invalid event type", http.MethodPost, "application/json", `{"h":"a.com","e":"<b>hi</b>"}`, http.StatusBadRequest},
+		{"unsupported type", http.MethodPost, "application/xml", "<h>a.com</h>", http.StatusUnsupportedMediaType},
+		{"method", http.MethodPut, "application/json", `{"h":"a.com"}`, http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/track?h=a.com", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			TrackHandler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestTrackHandler_EventTypes(t *testing.T) {
+	setupTestDatabase(t)
+
+	for _, e := range []string{"Click", "clck", "pageview"} {
+		rec := httptest.NewRecorder()
+		TrackHandler(rec, httptest.NewRequest(http.MethodPost, "/track", strings.NewReader(`{"h":"a.com","e":"`+e+`"}`)))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("event %q: status = %d, want 204", e, rec.Code)
+		}
+	}
+
+	// Unknown types share one bucket rather than each becoming a dimension
+	rows, err := database.GetDB().Query("SELECT event_type, COUNT(*) FROM events GROUP BY event_type ORDER BY event_type")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var eventType string
+		var n int
+		rows.Scan(&eventType, &n)
+		got = append(got, fmt.Sprintf("%s=%d", eventType, n))
+	}
+	if want := []string{"click=1", "other=1", "pageview=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("event types = %v, want %v", got, want)
+	}
+}
+
+func TestTrackScriptHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	TrackScriptHandler(rec, httptest.NewRequest(http.MethodGet, "/track.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/javascript") {
+		t.Errorf("Content-Type = %q, want application/javascript", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "window.fazt = fazt") {
+		t.Error("script should expose window.fazt")
+	}
+	version := rec.Header().Get("X-Fazt-Version")
+	if version == "" {
+		t.Fatal("X-Fazt-Version not set")
+	}
+	if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+		t.Errorf("unversioned Cache-Control = %q, want an hour", cc)
+	}
+
+	rec = httptest.NewRecorder()
+	TrackScriptHandler(rec, httptest.NewRequest(http.MethodGet, "/track.js?v="+version, nil))
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("versioned Cache-Control = %q, want immutable", cc)
+	}
+
+	// A stale version gets the current script, briefly cached
+	rec = httptest.NewRecorder()
+	TrackScriptHandler(rec, httptest.NewRequest(http.MethodGet, "/track.js?v=old", nil))
+	if cc := rec.Header().Get("Cache-Control"); strings.Contains(cc, "immutable") || rec.Body.Len() == 0 {
+		t.Errorf("stale version: Cache-Control = %q, body %d bytes", cc, rec.Body.Len())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/track.js", nil)
+	req.Header.Set("If-None-Match", `"`+version+`"`)
+	rec = httptest.NewRecorder()
+	TrackScriptHandler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match status = %d, want 304", rec.Code)
+	}
+}
+
+func TestTrackHandler_TokenMode(t *testing.T) {
+	setupTestDatabase(t)
+	analytics.SetRequireToken(true)
+	defer analytics.SetRequireToken(false)
+
+	token, err := analytics.CreateToken(database.GetDB(), "blog.example.com", "")
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"token for the domain", `{"h":"blog.example.com","k":"` + token.Token + `"}`, http.StatusNoContent},
+		{"token for another domain", `{"h":"shop.example.com","k":"` + token.Token + `"}`, http.StatusForbidden},
+		{"no token", `{"h":"blog.example.com"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			TrackHandler(rec, httptest.NewRequest(http.MethodPost, "/track", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %q)", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+
+	// The pixel still answers, but only records with a valid token
+	rec := httptest.NewRecorder()
+	PixelHandler(rec, httptest.NewRequest(http.MethodGet, "/pixel.gif?domain=blog.example.com", nil))
+	rec = httptest.NewRecorder()
+	PixelHandler(rec, httptest.NewRequest(http.MethodGet, "/pixel.gif?domain=blog.example.com&k="+token.Token, nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("pixel status = %d, want 200", rec.Code)
+	}
+	var count int
+	database.GetDB().QueryRow("SELECT COUNT(*) FROM events WHERE source_type = 'pixel'").Scan(&count)
+	if count != 1 {
+		t.Errorf("recorded %d pixel events, want 1", count)
+	}
+}
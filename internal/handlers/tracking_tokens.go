@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/jikku/command-center/internal/analytics"
+	"github.com/jikku/command-center/internal/audit"
+	"github.com/jikku/command-center/internal/database"
+)
+
+// TrackingTokensHandler manages public per-domain tracking tokens
+// GET /api/tracking-tokens[?domain=], POST {"domain", "name"}, DELETE ?id=
+func TrackingTokensHandler(w http.ResponseWriter, r *http.Request) {
+	db := database.GetDB()
+
+	switch r.Method {
+	case http.MethodGet:
+		tokens, err := analytics.ListTokens(db, r.URL.Query().Get("domain"))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":         true,
+			"require_token":   analytics.RequireToken(),
+			"tracking_tokens": tokens,
+		})
+
+	case http.MethodPost:
+		var req struct {
+			Domain string `json:"domain"`
+			Name   string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			jsonError(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if analytics.NormalizeDomain(req.Domain) == "" {
+			jsonError(w, "Domain is required", http.StatusBadRequest)
+			return
+		}
+
+		token, err := analytics.CreateToken(db, req.Domain, sanitizeInput(req.Name))
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "tracking_token_create", token.Domain)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":        true,
+			"tracking_token": token,
+		})
+
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			jsonError(w, "Invalid ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := analytics.DeleteToken(db, id); err != nil {
+			status := http.StatusInternalServerError
+			if err == analytics.ErrTokenNotFound {
+				status = http.StatusNotFound
+			}
+			jsonError(w, err.Error(), status)
+			return
+		}
+
+		audit.LogSuccess(sessionUsername(r), analytics.RemoteIP(r), "tracking_token_delete", strconv.FormatInt(id, 10))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": true,
+			"message": "Tracking token deleted",
+		})
+
+	default:
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+var (
+	versionBody = buildInfoJSON("dev", "", "")
+	versionMu   sync.RWMutex
+)
+
+// SetBuildInfo sets what /api/version reports. Call it before serving requests.
+func SetBuildInfo(version, commit, buildTime string) {
+	body := buildInfoJSON(version, commit, buildTime)
+	versionMu.Lock()
+	versionBody = body
+	versionMu.Unlock()
+}
+
+// buildInfoJSON encodes the build info once, so /api/version stays cheap.
+// An empty commit or build time falls back to the VCS stamp Go embeds in the
+// binary, if any.
+func buildInfoJSON(version, commit, buildTime string) []byte {
+	info := BuildInfo{
+		Version:   version,
+		Commit:    commit,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+
+	body, _ := json.Marshal(info)
+	return body
+}
+
+// VersionHandler reports the version and build of the running server
+// GET /api/version
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	versionMu.RLock()
+	body := versionBody
+	versionMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(body)
+}
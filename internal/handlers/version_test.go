@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	defer SetBuildInfo("dev", "", "")
+	SetBuildInfo("v1.2.3", "abc1234", "2026-01-02T03:04:05Z")
+
+	rec := httptest.NewRecorder()
+	VersionHandler(rec, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var info BuildInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatalf("invalid JSON %q: %v", rec.Body.String(), err)
+	}
+	want := BuildInfo{
+		Version:   "v1.2.3",
+		Commit:    "abc1234",
+		BuildTime: "2026-01-02T03:04:05Z",
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+	if info != want {
+		t.Errorf("info = %+v, want %+v", info, want)
+	}
+
+	rec = httptest.NewRecorder()
+	VersionHandler(rec, httptest.NewRequest(http.MethodPost, "/api/version", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
@@ -7,17 +7,21 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"io"
-	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/jikku/command-center/internal/analytics"
 	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // WebhookHandler handles incoming webhooks
 func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -26,7 +30,7 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	endpoint := strings.TrimSpace(path)
 
 	if endpoint == "" {
-		http.Error(w, "Invalid webhook endpoint", http.StatusBadRequest)
+		jsonError(w, "Invalid webhook endpoint", http.StatusBadRequest)
 		return
 	}
 
@@ -42,37 +46,31 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	`, endpoint).Scan(&webhookID, &name, &secret, &isActive)
 
 	if err == sql.ErrNoRows {
-		http.Error(w, "Webhook endpoint not found", http.StatusNotFound)
+		jsonError(w, "Webhook endpoint not found", http.StatusNotFound)
 		return
 	} else if err != nil {
-		log.Printf("Error looking up webhook: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		logging.Errorf("Error looking up webhook: %v", err)
+		jsonError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	// Check if webhook is active
 	if !isActive {
-		http.Error(w, "Webhook is disabled", http.StatusForbidden)
+		jsonError(w, "Webhook is disabled", http.StatusForbidden)
 		return
 	}
 
 	// Read body
 	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
 	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		jsonError(w, "Failed to read body", http.StatusBadRequest)
 		return
 	}
 
 	// Verify signature if secret is configured
 	if secret != "" {
-		signature := r.Header.Get("X-Webhook-Signature")
-		if signature == "" {
-			http.Error(w, "Missing signature", http.StatusUnauthorized)
-			return
-		}
-
-		if !verifySignature(body, secret, signature) {
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		if status, msg := checkWebhookSignature(r, body, secret, time.Now()); status != 0 {
+			jsonError(w, msg, status)
 			return
 		}
 	}
@@ -89,16 +87,16 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract useful fields if present
 	eventType := "webhook"
 	if et, ok := payload["event"].(string); ok {
-		eventType = et
+		eventType = analytics.NormalizeEventType(et)
 	} else if et, ok := payload["type"].(string); ok {
-		eventType = et
+		eventType = analytics.NormalizeEventType(et)
 	}
 
 	// Convert payload back to JSON string for storage
 	payloadJSON, _ := json.Marshal(payload)
 
 	// Extract client info
-	ipAddress := extractIPAddress(r)
+	ipAddress := analytics.ClientIP(r)
 	userAgent := r.UserAgent()
 
 	// Log event to database
@@ -108,8 +106,8 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	`, endpoint, "", "webhook", eventType, "/webhook/"+endpoint, "", userAgent, ipAddress, string(payloadJSON))
 
 	if err != nil {
-		log.Printf("Error logging webhook event: %v", err)
-		http.Error(w, "Failed to log event", http.StatusInternalServerError)
+		logging.Errorf("Error logging webhook event: %v", err)
+		jsonError(w, "Failed to log event", http.StatusInternalServerError)
 		return
 	}
 
@@ -123,6 +121,82 @@ func WebhookHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// webhookReplayTolerance, when set, makes signed webhooks carry an
+// X-Webhook-Timestamp that is part of the signature and within this long of now
+var webhookReplayTolerance time.Duration
+
+// SetWebhookReplayTolerance turns on replay protection for signed webhooks
+// (webhooks.replay_tolerance); 0 turns it off. Call it before serving requests.
+func SetWebhookReplayTolerance(d time.Duration) {
+	webhookReplayTolerance = d
+}
+
+// seenSignatures remembers accepted signatures until their timestamp falls
+// outside the tolerance, after which the timestamp check rejects them anyway
+var seenSignatures = &signatureCache{seen: make(map[string]time.Time)}
+
+type signatureCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // signature -> when it can be forgotten
+}
+
+// add records signature until expires. It reports false if it was already seen.
+func (c *signatureCache) add(signature string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for sig, exp := range c.seen {
+		if now.After(exp) {
+			delete(c.seen, sig)
+		}
+	}
+	if _, ok := c.seen[signature]; ok {
+		return false
+	}
+	c.seen[signature] = expires
+	return true
+}
+
+// checkWebhookSignature verifies X-Webhook-Signature over the body. With replay
+// protection on, the signed content is "<X-Webhook-Timestamp>.<body>" (Unix
+// seconds), the timestamp must be within the tolerance of now, and each
+// signature is accepted once. It returns the status and message to reject with,
+// or 0 if the request is good.
+func checkWebhookSignature(r *http.Request, body []byte, secret string, now time.Time) (int, string) {
+	signature := r.Header.Get("X-Webhook-Signature")
+	if signature == "" {
+		return http.StatusUnauthorized, "Missing signature"
+	}
+
+	if webhookReplayTolerance <= 0 {
+		if !verifySignature(body, secret, signature) {
+			return http.StatusUnauthorized, "Invalid signature"
+		}
+		return 0, ""
+	}
+
+	tsHeader := r.Header.Get("X-Webhook-Timestamp")
+	if tsHeader == "" {
+		return http.StatusUnauthorized, "Missing timestamp"
+	}
+	ts, err := strconv.ParseInt(tsHeader, 10, 64)
+	if err != nil {
+		return http.StatusUnauthorized, "Invalid timestamp"
+	}
+	if !verifySignature([]byte(tsHeader+"."+string(body)), secret, signature) {
+		return http.StatusUnauthorized, "Invalid signature"
+	}
+
+	sent := time.Unix(ts, 0)
+	if age := now.Sub(sent); age > webhookReplayTolerance || age < -webhookReplayTolerance {
+		return http.StatusUnauthorized, "Timestamp outside tolerance"
+	}
+	if !seenSignatures.add(signature, sent.Add(webhookReplayTolerance), now) {
+		return http.StatusConflict, "Webhook already received"
+	}
+	return 0, ""
+}
+
 // verifySignature verifies HMAC SHA256 signature
 func verifySignature(body []byte, secret, signature string) bool {
 	// Compute HMAC SHA256
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sign(secret, content string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRequest builds a delivery signed over "<ts>.<body>"; a zero ts sends no timestamp
+func webhookRequest(secret, body string, ts int64) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/webhook/deploys", strings.NewReader(body))
+	if ts == 0 {
+		r.Header.Set("X-Webhook-Signature", sign(secret, body))
+		return r
+	}
+	stamp := strconv.FormatInt(ts, 10)
+	r.Header.Set("X-Webhook-Timestamp", stamp)
+	r.Header.Set("X-Webhook-Signature", sign(secret, stamp+"."+body))
+	return r
+}
+
+func TestCheckWebhookSignature_NoReplayProtection(t *testing.T) {
+	SetWebhookReplayTolerance(0)
+	now := time.Now()
+
+	// A plain body signature works, and can be delivered again
+	for i := 0; i < 2; i++ {
+		if status, msg := checkWebhookSignature(webhookRequest("s3cret", `{"event":"push"}`, 0), []byte(`{"event":"push"}`), "s3cret", now); status != 0 {
+			t.Fatalf("delivery %d rejected: %d %s", i+1, status, msg)
+		}
+	}
+	if status, _ := checkWebhookSignature(webhookRequest("wrong", `{}`, 0), []byte(`{}`), "s3cret", now); status != http.StatusUnauthorized {
+		t.Errorf("bad signature: status %d, want 401", status)
+	}
+}
+
+func TestCheckWebhookSignature_ReplayProtection(t *testing.T) {
+	SetWebhookReplayTolerance(5 * time.Minute)
+	defer SetWebhookReplayTolerance(0)
+
+	now := time.Now()
+	body := `{"event":"push"}`
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		status  int
+		message string
+	}{
+		{"fresh", webhookRequest("s3cret", body, now.Unix()), 0, ""},
+		{"replayed", webhookRequest("s3cret", body, now.Unix()), http.StatusConflict, "already received"},
+		{"slightly ahead", webhookRequest("s3cret", body, now.Add(time.Minute).Unix()), 0, ""},
+		{"expired", webhookRequest("s3cret", body, now.Add(-6*time.Minute).Unix()), http.StatusUnauthorized, "outside tolerance"},
+		{"too far ahead", webhookRequest("s3cret", body, now.Add(time.Hour).Unix()), http.StatusUnauthorized, "outside tolerance"},
+		{"no timestamp", webhookRequest("s3cret", body, 0), http.StatusUnauthorized, "Missing timestamp"},
+		{"bad signature", webhookRequest("wrong", body, now.Unix()), http.StatusUnauthorized, "Invalid signature"},
+	}
+
+	// A timestamp moved into the window breaks the signature
+	tampered := webhookRequest("s3cret", body, now.Add(-6*time.Minute).Unix())
+	tampered.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	tests = append(tests, struct {
+		name    string
+		req     *http.Request
+		status  int
+		message string
+	}{"tampered timestamp", tampered, http.StatusUnauthorized, "Invalid signature"})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, msg := checkWebhookSignature(tt.req, []byte(body), "s3cret", now)
+			if status != tt.status || !strings.Contains(msg, tt.message) {
+				t.Errorf("got %d %q, want %d containing %q", status, msg, tt.status, tt.message)
+			}
+		})
+	}
+}
+
+func TestSignatureCache_ForgetsExpired(t *testing.T) {
+	c := &signatureCache{seen: make(map[string]time.Time)}
+	now := time.Now()
+
+	if !c.add("abc", now.Add(time.Minute), now) {
+		t.Fatal("first add should succeed")
+	}
+	if c.add("abc", now.Add(time.Minute), now) {
+		t.Error("second add within the window should fail")
+	}
+	if !c.add("abc", now.Add(3*time.Minute), now.Add(2*time.Minute)) {
+		t.Error("add after expiry should succeed")
+	}
+}
@@ -0,0 +1,132 @@
+package hosting
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SiteAlias points a subdomain at another site's files
+type SiteAlias struct {
+	Alias     string    `json:"alias"`
+	SiteID    string    `json:"site_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddSiteAlias makes alias serve siteID's files, replacing any existing target.
+// Aliases point straight at deployed sites: an alias can't point at another
+// alias, and a name that other aliases point at can't become one, so there
+// are no chains or cycles to follow.
+func AddSiteAlias(alias, siteID string) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	alias = strings.ToLower(alias)
+	siteID = strings.ToLower(siteID)
+	if err := ValidateSubdomain(alias); err != nil {
+		return err
+	}
+	if !ValidateSiteID(siteID) {
+		return fmt.Errorf("invalid site '%s'", siteID)
+	}
+	if alias == siteID {
+		return fmt.Errorf("'%s' can't be an alias of itself", alias)
+	}
+
+	if target, ok := ResolveSiteAlias(siteID); ok {
+		return fmt.Errorf("'%s' is itself an alias of '%s'; alias '%s' instead", siteID, target, target)
+	}
+	if !siteDeployed(siteID) {
+		return fmt.Errorf("site '%s' not found", siteID)
+	}
+	if siteDeployed(alias) {
+		return fmt.Errorf("'%s' is a deployed site; delete it before using the name as an alias", alias)
+	}
+
+	var pointedAt int
+	if err := database.QueryRow("SELECT COUNT(*) FROM site_aliases WHERE site_id = ?", alias).Scan(&pointedAt); err != nil {
+		return fmt.Errorf("failed to check aliases: %w", err)
+	}
+	if pointedAt > 0 {
+		return fmt.Errorf("other aliases point at '%s'; remove them first", alias)
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO site_aliases (alias, site_id) VALUES (?, ?)
+		ON CONFLICT(alias) DO UPDATE SET site_id = excluded.site_id
+	`, alias, siteID)
+	if err != nil {
+		return fmt.Errorf("failed to store alias: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveSiteAlias deletes an alias; the site it pointed at is untouched
+func RemoveSiteAlias(alias string) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	result, err := database.Exec("DELETE FROM site_aliases WHERE alias = ?", strings.ToLower(alias))
+	if err != nil {
+		return fmt.Errorf("failed to delete alias: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("alias '%s' not found", alias)
+	}
+
+	return nil
+}
+
+// ListSiteAliases returns all aliases
+func ListSiteAliases() ([]SiteAlias, error) {
+	if database == nil {
+		return nil, fmt.Errorf("hosting not initialized")
+	}
+
+	rows, err := database.Query("SELECT alias, site_id, created_at FROM site_aliases ORDER BY alias")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := []SiteAlias{}
+	for rows.Next() {
+		var a SiteAlias
+		if err := rows.Scan(&a.Alias, &a.SiteID, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan alias: %w", err)
+		}
+		aliases = append(aliases, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// ResolveSiteAlias returns the site an alias points at, if subdomain is an alias
+func ResolveSiteAlias(subdomain string) (string, bool) {
+	if database == nil {
+		return "", false
+	}
+
+	var siteID string
+	err := database.QueryRow("SELECT site_id FROM site_aliases WHERE alias = ?", subdomain).Scan(&siteID)
+	if err != nil {
+		return "", false
+	}
+
+	return siteID, true
+}
+
+// ResolveSite returns the site whose files subdomain serves: the alias
+// target, or subdomain itself
+func ResolveSite(subdomain string) string {
+	if siteID, ok := ResolveSiteAlias(subdomain); ok {
+		return siteID
+	}
+	return subdomain
+}
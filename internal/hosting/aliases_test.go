@@ -0,0 +1,95 @@
+package hosting
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSiteAliases(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+	fs.WriteFile("blog", "index.html", strings.NewReader("hi"), 2, "text/html")
+	fs.WriteFile("shop", "index.html", strings.NewReader("hi"), 2, "text/html")
+
+	if err := AddSiteAlias("Staging", "blog"); err != nil {
+		t.Fatalf("AddSiteAlias failed: %v", err)
+	}
+	if got := ResolveSite("staging"); got != "blog" {
+		t.Errorf("ResolveSite(staging) = %q, want blog", got)
+	}
+	if got := ResolveSite("blog"); got != "blog" {
+		t.Errorf("ResolveSite(blog) = %q, want blog", got)
+	}
+	if !SiteExists("staging") {
+		t.Error("SiteExists should follow the alias")
+	}
+
+	tests := []struct {
+		name, alias, site string
+	}{
+		{"alias of itself", "blog", "blog"},
+		{"alias of an alias", "preview", "staging"},
+		{"deployed site", "shop", "blog"},
+		{"missing site", "preview", "ghost"},
+		{"invalid alias", "-bad", "blog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AddSiteAlias(tt.alias, tt.site); err == nil {
+				t.Errorf("AddSiteAlias(%q, %q) should fail", tt.alias, tt.site)
+			}
+		})
+	}
+
+	// Re-adding retargets the alias
+	if err := AddSiteAlias("staging", "shop"); err != nil {
+		t.Fatalf("retargeting failed: %v", err)
+	}
+	if got := ResolveSite("staging"); got != "shop" {
+		t.Errorf("ResolveSite(staging) = %q after retargeting, want shop", got)
+	}
+
+	// ListSites marks aliases
+	sites, err := ListSites()
+	if err != nil {
+		t.Fatalf("ListSites failed: %v", err)
+	}
+	if len(sites) != 3 || sites[2].Name != "staging" || sites[2].AliasOf != "shop" || sites[0].AliasOf != "" {
+		t.Errorf("ListSites = %+v, want blog and shop then the staging alias", sites)
+	}
+
+	// Deleting the target removes its aliases
+	if err := DeleteSite("shop"); err != nil {
+		t.Fatalf("DeleteSite failed: %v", err)
+	}
+	if _, ok := ResolveSiteAlias("staging"); ok {
+		t.Error("DeleteSite left an alias pointing at the deleted site")
+	}
+
+	AddSiteAlias("preview", "blog")
+	if err := RemoveSiteAlias("preview"); err != nil {
+		t.Errorf("RemoveSiteAlias failed: %v", err)
+	}
+	if err := RemoveSiteAlias("preview"); err == nil {
+		t.Error("removing a missing alias should fail")
+	}
+	if !SiteExists("blog") {
+		t.Error("removing an alias should leave its site alone")
+	}
+}
+
+func TestListSiteAliases_ScanError(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	// A row that can't be read fails the listing instead of vanishing from it
+	if _, err := db.Exec(`INSERT INTO site_aliases (alias, site_id, created_at) VALUES ('staging', 'blog', 'not a time')`); err != nil {
+		t.Fatalf("insert failed: %v", err)
+	}
+	if aliases, err := ListSiteAliases(); err == nil {
+		t.Errorf("ListSiteAliases() = %+v, want a scan error", aliases)
+	}
+}
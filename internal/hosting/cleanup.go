@@ -0,0 +1,116 @@
+package hosting
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jikku/command-center/internal/logging"
+)
+
+const (
+	// OrphanCleanupInterval is how often data left by deleted sites is removed
+	OrphanCleanupInterval = 6 * time.Hour
+
+	// OrphanGracePeriod spares per-site rows this young, so env vars set up
+	// before a site's first deploy aren't removed
+	OrphanGracePeriod = 24 * time.Hour
+)
+
+// siteDataTables hold the per-site rows, keyed by site_id, that go with a
+// site. Each is paired with the column that dates its rows.
+var siteDataTables = []struct{ name, timeColumn string }{
+	{"env_vars", "created_at"},
+	{"kv_store", "updated_at"},
+	{"site_logs", "created_at"},
+	{"custom_domains", "created_at"},
+}
+
+// deleteSiteData removes a site's files, metadata, aliases, custom domains and
+// per-site data in one transaction, so a reused name starts clean
+func deleteSiteData(subdomain string) error {
+	tx, err := database.Begin()
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		"DELETE FROM files WHERE site_id = ?",
+		"DELETE FROM sites WHERE site_id = ?",
+	}
+	for _, table := range siteDataTables {
+		statements = append(statements, "DELETE FROM "+table.name+" WHERE site_id = ?")
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt, subdomain); err != nil {
+			return fmt.Errorf("failed to delete site %s: %w", subdomain, err)
+		}
+	}
+
+	// The aliases pointing at the site and any alias by this name
+	if _, err := tx.Exec("DELETE FROM site_aliases WHERE site_id = ? OR alias = ?", subdomain, subdomain); err != nil {
+		return fmt.Errorf("failed to delete site %s: %w", subdomain, err)
+	}
+
+	return tx.Commit()
+}
+
+// DeleteOrphanedSiteData removes per-site rows for sites that no longer have
+// files or metadata, leaving rows younger than grace alone. It returns the
+// number of rows deleted from each table that had any.
+func DeleteOrphanedSiteData(now time.Time, grace time.Duration) (map[string]int64, error) {
+	if database == nil {
+		return nil, fmt.Errorf("hosting not initialized")
+	}
+
+	cutoff := now.Add(-grace).UTC().Format(sqliteTimeFormat)
+	deleted := map[string]int64{}
+	for _, table := range siteDataTables {
+		result, err := database.Exec(`
+			DELETE FROM `+table.name+`
+			WHERE site_id NOT IN (SELECT site_id FROM files)
+			  AND site_id NOT IN (SELECT site_id FROM sites)
+			  AND `+table.timeColumn+` < ?
+		`, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to clean up %s: %w", table.name, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			deleted[table.name] = n
+		}
+	}
+	return deleted, nil
+}
+
+// StartOrphanCleanup deletes data left by deleted sites, and rotated API keys
+// whose grace period has ended, every interval until the returned stop
+// function is called
+func StartOrphanCleanup(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if err := PurgeExpiredAPIKeys(database); err != nil {
+					logging.Errorf("Expired API key cleanup failed: %v", err)
+				}
+				deleted, err := DeleteOrphanedSiteData(now, OrphanGracePeriod)
+				if err != nil {
+					logging.Errorf("Orphaned site data cleanup failed: %v", err)
+					continue
+				}
+				for table, n := range deleted {
+					logging.Infof("Deleted %d orphaned %s rows", n, table)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,102 @@
+package hosting
+
+import (
+	"testing"
+	"time"
+)
+
+// countRows returns how many rows table has for siteID
+func countRows(t *testing.T, table, siteID string) int {
+	t.Helper()
+	var n int
+	if err := database.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE site_id = ?", siteID).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+// seedSiteData gives siteID an env var, a KV entry, a log line and a custom domain
+func seedSiteData(t *testing.T, siteID string) {
+	t.Helper()
+	for _, stmt := range []string{
+		"INSERT INTO env_vars (site_id, name, value) VALUES (?, 'API_KEY', 'secret')",
+		"INSERT INTO kv_store (site_id, key, value) VALUES (?, 'count', '1')",
+		"INSERT INTO site_logs (site_id, level, message) VALUES (?, 'info', 'hello')",
+		"INSERT INTO custom_domains (hostname, site_id) VALUES (?1 || '.example.com', ?1)",
+	} {
+		if _, err := database.Exec(stmt, siteID); err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+}
+
+func TestDeleteSite_RemovesSiteData(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	for _, site := range []string{"blog", "shop"} {
+		if _, err := DeploySite(zipOf(t, map[string]string{"index.html": site}), site); err != nil {
+			t.Fatalf("DeploySite(%s) failed: %v", site, err)
+		}
+		seedSiteData(t, site)
+	}
+
+	if err := DeleteSite("blog"); err != nil {
+		t.Fatalf("DeleteSite failed: %v", err)
+	}
+	if site, ok := ResolveCustomDomain("blog.example.com"); ok {
+		t.Errorf("deleted site's domain still resolves to %s", site)
+	}
+	for _, table := range []string{"files", "sites", "env_vars", "kv_store", "site_logs", "custom_domains"} {
+		if n := countRows(t, table, "blog"); n != 0 {
+			t.Errorf("%s still has %d rows for the deleted site", table, n)
+		}
+	}
+	for _, table := range []string{"files", "env_vars", "kv_store", "site_logs", "custom_domains"} {
+		if n := countRows(t, table, "shop"); n == 0 {
+			t.Errorf("%s lost the other site's rows", table)
+		}
+	}
+}
+
+func TestDeleteOrphanedSiteData(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	if _, err := DeploySite(zipOf(t, map[string]string{"index.html": "live"}), "live"); err != nil {
+		t.Fatalf("DeploySite failed: %v", err)
+	}
+	seedSiteData(t, "live")
+	seedSiteData(t, "gone") // left behind by a site deleted before cleanup existed
+
+	// Within the grace period nothing is touched
+	deleted, err := DeleteOrphanedSiteData(time.Now(), OrphanGracePeriod)
+	if err != nil {
+		t.Fatalf("DeleteOrphanedSiteData failed: %v", err)
+	}
+	if len(deleted) != 0 || countRows(t, "env_vars", "gone") != 1 {
+		t.Errorf("young orphans were deleted: %v", deleted)
+	}
+
+	deleted, err = DeleteOrphanedSiteData(time.Now().Add(2*OrphanGracePeriod), OrphanGracePeriod)
+	if err != nil {
+		t.Fatalf("DeleteOrphanedSiteData failed: %v", err)
+	}
+	want := map[string]int64{"env_vars": 1, "kv_store": 1, "site_logs": 1, "custom_domains": 1}
+	if len(deleted) != len(want) {
+		t.Errorf("deleted = %v, want %v", deleted, want)
+	}
+	for table, n := range want {
+		if deleted[table] != n {
+			t.Errorf("deleted[%s] = %d, want %d", table, deleted[table], n)
+		}
+		if countRows(t, table, "gone") != 0 {
+			t.Errorf("%s still has orphaned rows", table)
+		}
+		if countRows(t, table, "live") != 1 {
+			t.Errorf("%s lost the live site's rows", table)
+		}
+	}
+}
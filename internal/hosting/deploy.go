@@ -2,15 +2,20 @@ package hosting
 
 import (
 	"archive/zip"
+	"bytes"
 	"crypto/rand"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"mime"
+	"net/http"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // DeployResult contains information about a deployment
@@ -20,18 +25,29 @@ type DeployResult struct {
 	FileCount int
 }
 
-// DeploySite extracts a ZIP file to the VFS
+// ErrInvalidDeploy is returned (wrapped) when a deploy archive fails validation
+var ErrInvalidDeploy = errors.New("invalid deploy archive")
+
+// deployEntry is a zip file that passed validation, with its cleaned VFS path
+type deployEntry struct {
+	file *zip.File
+	path string
+}
+
+// DeploySite extracts a ZIP file to the VFS.
+// The archive is validated first; the existing site is only replaced if it passes.
 func DeploySite(zipReader *zip.Reader, subdomain string) (*DeployResult, error) {
 	// Validate subdomain
 	if err := ValidateSubdomain(subdomain); err != nil {
 		return nil, err
 	}
 
-	// Clear existing site files?
-	// The VFS WriteFile does INSERT OR UPDATE, so files are overwritten.
-	// But stale files (files removed in the new deploy) would remain.
-	// Ideally we should delete the site first or track current files.
-	// For now, let's delete the site first to ensure a clean state (Cartridge style).
+	entries, err := validateZip(zipReader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delete the site first so files removed in this deploy don't linger (Cartridge style)
 	if err := fs.DeleteSite(subdomain); err != nil {
 		return nil, fmt.Errorf("failed to clear existing site: %w", err)
 	}
@@ -40,13 +56,75 @@ func DeploySite(zipReader *zip.Reader, subdomain string) (*DeployResult, error)
 	var fileCount int
 
 	// Extract files
+	for _, entry := range entries {
+		// Open file from zip
+		src, err := entry.file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file %s: %w", entry.file.Name, err)
+		}
+
+		// Determine MIME type
+		mimeType, content, err := detectMimeType(entry.path, src)
+		if err != nil {
+			src.Close()
+			return nil, fmt.Errorf("failed to read file %s: %w", entry.path, err)
+		}
+
+		// Write to VFS
+		fileSize := entry.file.FileInfo().Size()
+		if err := fs.WriteFile(subdomain, entry.path, content, fileSize, mimeType); err != nil {
+			src.Close()
+			return nil, fmt.Errorf("failed to write file %s: %w", entry.path, err)
+		}
+		src.Close()
+
+		totalSize += fileSize
+		fileCount++
+	}
+
+	return &DeployResult{
+		SiteID:    subdomain,
+		SizeBytes: totalSize,
+		FileCount: fileCount,
+	}, nil
+}
+
+// sniffLen is how much content http.DetectContentType looks at
+const sniffLen = 512
+
+// detectMimeType returns the MIME type for a file from its extension, falling back to
+// sniffing the content. The returned reader yields the full content, including the sniffed bytes.
+func detectMimeType(path string, src io.Reader) (string, io.Reader, error) {
+	if mimeType := mime.TypeByExtension(filepath.Ext(path)); mimeType != "" {
+		return mimeType, src, nil
+	}
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", nil, err
+	}
+	head = head[:n]
+
+	// DetectContentType keeps the charset for text types, e.g. "text/plain; charset=utf-8"
+	mimeType := http.DetectContentType(head)
+	return mimeType, io.MultiReader(bytes.NewReader(head), src), nil
+}
+
+// validateZip checks every entry of a deploy archive without touching the VFS.
+// Entries that try to escape the site are skipped; every other file must read
+// back intact, and index.html or main.js must be present at the root.
+func validateZip(zipReader *zip.Reader) ([]deployEntry, error) {
+	var entries []deployEntry
+	servable := false
+
 	for _, file := range zipReader.File {
 		// Security: Prevent path traversal
 		cleanPath := filepath.Clean(file.Name)
 		if strings.HasPrefix(cleanPath, "..") || strings.HasPrefix(cleanPath, "/") || strings.Contains(cleanPath, "\\") {
 			continue // Skip files that try to escape
 		}
-		
+
 		// Normalize path to forward slashes for DB consistency
 		cleanPath = filepath.ToSlash(cleanPath)
 
@@ -55,47 +133,130 @@ func DeploySite(zipReader *zip.Reader, subdomain string) (*DeployResult, error)
 			continue
 		}
 
-		// Open file from zip
+		// Read the whole entry so corrupt data (bad CRC, truncation) fails here
 		src, err := file.Open()
 		if err != nil {
-			return nil, fmt.Errorf("failed to open file %s: %w", file.Name, err)
+			return nil, fmt.Errorf("%w: cannot open %s: %v", ErrInvalidDeploy, file.Name, err)
 		}
-
-		// Determine MIME type
-		ext := filepath.Ext(cleanPath)
-		mimeType := mime.TypeByExtension(ext)
-		if mimeType == "" {
-			mimeType = "application/octet-stream"
+		_, err = io.Copy(io.Discard, src)
+		src.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: cannot read %s: %v", ErrInvalidDeploy, file.Name, err)
 		}
 
-		// Write to VFS
-		fileSize := file.FileInfo().Size()
-		if err := fs.WriteFile(subdomain, cleanPath, src, fileSize, mimeType); err != nil {
-			src.Close()
-			return nil, fmt.Errorf("failed to write file %s: %w", cleanPath, err)
+		if cleanPath == "index.html" || cleanPath == "main.js" {
+			servable = true
 		}
-		src.Close()
+		entries = append(entries, deployEntry{file: file, path: cleanPath})
+	}
 
-		totalSize += fileSize
-		fileCount++
+	if !servable {
+		return nil, fmt.Errorf("%w: no index.html or main.js at the root", ErrInvalidDeploy)
 	}
 
-	return &DeployResult{
-		SiteID:    subdomain,
-		SizeBytes: totalSize,
-		FileCount: fileCount,
-	}, nil
+	return entries, nil
 }
 
-// ValidateAPIKey validates an API key against the database
-func ValidateAPIKey(db *sql.DB, token string) (int64, string, error) {
-	// Get all API keys from database
-	rows, err := db.Query("SELECT id, name, key_hash FROM api_keys")
+// AdminScope lets an API key deploy over sites owned by other keys
+const AdminScope = "admin"
+
+// ErrAPIKeyNotFound is returned when an API key ID does not exist
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrAPIKeyRotated is returned when rotating a key that is already being retired
+var ErrAPIKeyRotated = errors.New("API key has already been rotated")
+
+// ErrSiteOwned is returned when a key deploys to a site claimed by another key
+var ErrSiteOwned = errors.New("site is owned by another API key")
+
+// ClaimDeploy verifies keyID may deploy to subdomain, claiming the site for it
+// if no key owns it yet. Call it before writing any files: the claim and the
+// check are one statement, so two keys racing for a new site can't both win.
+func ClaimDeploy(db *sql.DB, subdomain string, keyID int64, keyName string) error {
+	var ownerKeyID sql.NullInt64
+	err := db.QueryRow(`
+		INSERT INTO sites (site_id, owner_key_id, owner) VALUES (?, ?, ?)
+		ON CONFLICT(site_id) DO UPDATE SET
+			owner_key_id = COALESCE(sites.owner_key_id, excluded.owner_key_id),
+			owner = COALESCE(sites.owner, excluded.owner)
+		RETURNING owner_key_id
+	`, subdomain, keyID, keyName).Scan(&ownerKeyID)
+	if err != nil {
+		return fmt.Errorf("failed to claim site: %w", err)
+	}
+
+	if ownerKeyID.Int64 == keyID {
+		return nil
+	}
+
+	hasAdmin, err := APIKeyHasScope(db, keyID, AdminScope)
+	if err != nil {
+		return err
+	}
+	if hasAdmin {
+		return nil
+	}
+
+	return ErrSiteOwned
+}
+
+// ReleaseClaim undoes ClaimDeploy after the deploy it guarded failed. Only a
+// site keyID claimed and never deployed is removed, so a rejected upload
+// doesn't reserve the name for that key.
+func ReleaseClaim(db *sql.DB, subdomain string, keyID int64) error {
+	_, err := db.Exec(`
+		DELETE FROM sites WHERE site_id = ? AND owner_key_id = ? AND deploy_count = 0
+	`, subdomain, keyID)
+	if err != nil {
+		return fmt.Errorf("failed to release site claim: %w", err)
+	}
+	return nil
+}
+
+// APIKeyHasScope reports whether an API key was granted scope.
+// Scopes are stored as a comma or space separated list.
+func APIKeyHasScope(db *sql.DB, keyID int64, scope string) (bool, error) {
+	var scopes sql.NullString
+	if err := db.QueryRow("SELECT scopes FROM api_keys WHERE id = ?", keyID).Scan(&scopes); err != nil {
+		return false, fmt.Errorf("failed to query API key scopes: %w", err)
+	}
+
+	for _, s := range strings.FieldsFunc(scopes.String, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if s == scope {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// apiKeyTokenPrefix marks tokens that carry a lookup prefix: fazt_<prefix>_<secret>
+const apiKeyTokenPrefix = "fazt_"
+
+// ValidateAPIKey validates an API key against the database and records the
+// client IP and user agent that used it.
+// Prefixed tokens are checked with a single hash compare; legacy tokens
+// are compared against keys created before prefixes existed.
+func ValidateAPIKey(db *sql.DB, token, ip, userAgent string) (int64, string, error) {
+	// Keys past their rotation grace period are refused until the cleanup deletes them
+	now := time.Now().UTC().Format(sqliteTimeFormat)
+
+	var rows *sql.Rows
+	var err error
+	if prefix, ok := parseKeyPrefix(token); ok {
+		rows, err = db.Query(`SELECT id, name, key_hash FROM api_keys
+			WHERE key_prefix = ? AND (expires_at IS NULL OR expires_at > ?)`, prefix, now)
+	} else {
+		rows, err = db.Query(`SELECT id, name, key_hash FROM api_keys
+			WHERE key_prefix IS NULL AND (expires_at IS NULL OR expires_at > ?)`, now)
+	}
 	if err != nil {
 		return 0, "", fmt.Errorf("failed to query API keys: %w", err)
 	}
 	defer rows.Close()
 
+	var matchID int64
+	var matchName string
 	for rows.Next() {
 		var id int64
 		var name, keyHash string
@@ -103,41 +264,157 @@ func ValidateAPIKey(db *sql.DB, token string) (int64, string, error) {
 			continue
 		}
 
-		// Compare token with hash
-		if err := bcrypt.CompareHashAndPassword([]byte(keyHash), []byte(token)); err == nil {
-			// Update last_used_at
-			db.Exec("UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = ?", id)
-			return id, name, nil
+		// Compare token with hash (SHA-256, or bcrypt/argon2id for older keys)
+		if err := auth.VerifyToken(token, keyHash); err == nil {
+			matchID, matchName = id, name
+			break
 		}
 	}
+	rows.Close()
 
-	return 0, "", fmt.Errorf("invalid API key")
+	if matchID == 0 {
+		return 0, "", fmt.Errorf("invalid API key")
+	}
+	if err := RecordAPIKeyUse(db, matchID, ip, userAgent); err != nil {
+		logging.Errorf("Failed to record API key use: %v", err)
+	}
+	return matchID, matchName, nil
+}
+
+// parseKeyPrefix extracts the lookup prefix from a fazt_<prefix>_<secret> token
+func parseKeyPrefix(token string) (string, bool) {
+	if !strings.HasPrefix(token, apiKeyTokenPrefix) {
+		return "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, apiKeyTokenPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// RecordAPIKeyUse stores when a key was last used, and the client IP and user agent that used it
+func RecordAPIKeyUse(db *sql.DB, id int64, ip, userAgent string) error {
+	_, err := db.Exec(
+		"UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = ?, last_used_user_agent = ? WHERE id = ?",
+		ip, userAgent, id,
+	)
+	return err
 }
 
 // CreateAPIKey creates a new API key and returns the raw token
 func CreateAPIKey(db *sql.DB, name string, scopes string) (string, error) {
-	// Generate random token (32 bytes = 64 hex chars)
-	token, err := generateRandomToken(32)
+	token, _, err := createAPIKey(db, name, scopes)
+	return token, err
+}
+
+// createAPIKey stores a new key and returns its raw token and ID
+func createAPIKey(db *sql.DB, name string, scopes string) (string, int64, error) {
+	token, prefix, hash, err := newAPIKeyToken()
 	if err != nil {
-		return "", fmt.Errorf("failed to generate token: %w", err)
+		return "", 0, err
 	}
 
-	// Hash the token
-	hash, err := bcrypt.GenerateFromPassword([]byte(token), bcrypt.DefaultCost)
+	// Store in database
+	result, err := db.Exec(
+		"INSERT INTO api_keys (name, key_hash, scopes, key_prefix) VALUES (?, ?, ?, ?)",
+		name, hash, scopes, prefix,
+	)
 	if err != nil {
-		return "", fmt.Errorf("failed to hash token: %w", err)
+		return "", 0, fmt.Errorf("failed to store API key: %w", err)
 	}
 
-	// Store in database
-	_, err = db.Exec(
-		"INSERT INTO api_keys (name, key_hash, scopes) VALUES (?, ?, ?)",
-		name, string(hash), scopes,
+	id, _ := result.LastInsertId()
+	return token, id, nil
+}
+
+// newAPIKeyToken generates a raw token and returns it with its lookup prefix and hash
+func newAPIKeyToken() (token, prefix, hash string, err error) {
+	// Generate lookup prefix (6 bytes = 12 hex chars) and secret (24 bytes = 48 hex chars)
+	prefix, err = generateRandomToken(6)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret, err := generateRandomToken(24)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token = apiKeyTokenPrefix + prefix + "_" + secret
+	return token, prefix, auth.HashToken(token), nil
+}
+
+// DefaultKeyRotationGrace is how long a rotated key stays valid by default
+const DefaultKeyRotationGrace = 24 * time.Hour
+
+// RotateAPIKey issues a replacement for key id with the same name and scopes.
+// The old key keeps working until the grace period ends; sites it owns move to the new key.
+// A key that is already expiring can't be rotated again (ErrAPIKeyRotated).
+func RotateAPIKey(db *sql.DB, id int64, grace time.Duration) (string, int64, time.Time, error) {
+	token, prefix, hash, err := newAPIKeyToken()
+	if err != nil {
+		return "", 0, time.Time{}, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	var name string
+	var scopes sql.NullString
+	var oldExpiry sql.NullTime
+	err = tx.QueryRow("SELECT name, scopes, expires_at FROM api_keys WHERE id = ?", id).Scan(&name, &scopes, &oldExpiry)
+	if err == sql.ErrNoRows {
+		return "", 0, time.Time{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("failed to query API key: %w", err)
+	}
+	if oldExpiry.Valid {
+		return "", 0, time.Time{}, ErrAPIKeyRotated
+	}
+
+	expiresAt := time.Now().UTC().Add(grace)
+	result, err := tx.Exec("UPDATE api_keys SET expires_at = ? WHERE id = ? AND expires_at IS NULL",
+		expiresAt.Format(sqliteTimeFormat), id)
+	if err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("failed to schedule revocation: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return "", 0, time.Time{}, ErrAPIKeyRotated
+	}
+
+	result, err = tx.Exec(
+		"INSERT INTO api_keys (name, key_hash, scopes, key_prefix) VALUES (?, ?, ?, ?)",
+		name, hash, scopes.String, prefix,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to store API key: %w", err)
+		return "", 0, time.Time{}, fmt.Errorf("failed to store API key: %w", err)
 	}
+	newID, _ := result.LastInsertId()
 
-	return token, nil
+	// Site ownership follows the key
+	if _, err := tx.Exec("UPDATE sites SET owner_key_id = ? WHERE owner_key_id = ?", newID, id); err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("failed to transfer site ownership: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", 0, time.Time{}, fmt.Errorf("failed to commit rotation: %w", err)
+	}
+	return token, newID, expiresAt, nil
+}
+
+// sqliteTimeFormat matches SQLite's CURRENT_TIMESTAMP so stored times compare as text
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// PurgeExpiredAPIKeys deletes rotated keys whose grace period has ended
+func PurgeExpiredAPIKeys(db *sql.DB) error {
+	_, err := db.Exec(
+		"DELETE FROM api_keys WHERE expires_at IS NOT NULL AND expires_at <= ?",
+		time.Now().UTC().Format(sqliteTimeFormat),
+	)
+	return err
 }
 
 // generateRandomToken generates a random hex token
@@ -149,18 +426,63 @@ func generateRandomToken(length int) (string, error) {
 	return fmt.Sprintf("%x", bytes), nil
 }
 
-// RecordDeployment records a deployment in the database
-func RecordDeployment(db *sql.DB, siteID string, sizeBytes int64, fileCount int, deployedBy string) error {
+// RecordDeployment records a deployment in the database. idempotencyKey is the
+// request's Idempotency-Key, if any, remembered for apiKeyID.
+func RecordDeployment(db *sql.DB, siteID string, sizeBytes int64, fileCount int, deployedBy string, apiKeyID int64, idempotencyKey string) error {
+	var key, keyID interface{}
+	if idempotencyKey != "" {
+		key = idempotencyKey
+	}
+	if apiKeyID != 0 {
+		keyID = apiKeyID
+	}
 	_, err := db.Exec(
-		"INSERT INTO deployments (site_id, size_bytes, file_count, deployed_by) VALUES (?, ?, ?, ?)",
-		siteID, sizeBytes, fileCount, deployedBy,
+		"INSERT INTO deployments (site_id, size_bytes, file_count, deployed_by, api_key_id, idempotency_key) VALUES (?, ?, ?, ?, ?, ?)",
+		siteID, sizeBytes, fileCount, deployedBy, keyID, key,
 	)
 	return err
 }
 
+// DeployIdempotencyWindow is how long a deploy's Idempotency-Key is honored
+const DeployIdempotencyWindow = 24 * time.Hour
+
+// IdempotentDeployment is the result recorded for an Idempotency-Key
+type IdempotentDeployment struct {
+	SiteID    string
+	SizeBytes int64
+	FileCount int
+}
+
+// FindIdempotentDeployment returns the deployment apiKeyID recorded under
+// idempotencyKey within DeployIdempotencyWindow, or nil if there is none.
+// apiKeyID 0 matches dashboard deploys, which have no key.
+func FindIdempotentDeployment(db *sql.DB, idempotencyKey string, apiKeyID int64) (*IdempotentDeployment, error) {
+	var keyID interface{}
+	if apiKeyID != 0 {
+		keyID = apiKeyID
+	}
+	var d IdempotentDeployment
+	err := db.QueryRow(`
+		SELECT site_id, size_bytes, file_count FROM deployments
+		WHERE idempotency_key = ? AND api_key_id IS ? AND created_at >= ?
+		ORDER BY id DESC LIMIT 1
+	`, idempotencyKey, keyID, time.Now().UTC().Add(-DeployIdempotencyWindow).Format(sqliteTimeFormat)).Scan(&d.SiteID, &d.SizeBytes, &d.FileCount)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
 // ListAPIKeys lists all API keys (without the actual keys)
 func ListAPIKeys(db *sql.DB) ([]APIKeyInfo, error) {
-	rows, err := db.Query("SELECT id, name, scopes, created_at, last_used_at FROM api_keys ORDER BY created_at DESC")
+	rows, err := db.Query(`
+		SELECT id, name, scopes, created_at, last_used_at, expires_at,
+			COALESCE(last_used_ip, ''), COALESCE(last_used_user_agent, '')
+		FROM api_keys ORDER BY created_at DESC
+	`)
 	if err != nil {
 		return nil, err
 	}
@@ -169,13 +491,17 @@ func ListAPIKeys(db *sql.DB) ([]APIKeyInfo, error) {
 	var keys []APIKeyInfo
 	for rows.Next() {
 		var k APIKeyInfo
-		var lastUsed sql.NullTime
-		if err := rows.Scan(&k.ID, &k.Name, &k.Scopes, &k.CreatedAt, &lastUsed); err != nil {
+		var lastUsed, expiresAt sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Name, &k.Scopes, &k.CreatedAt, &lastUsed, &expiresAt,
+			&k.LastUsedIP, &k.LastUsedUserAgent); err != nil {
 			continue
 		}
 		if lastUsed.Valid {
 			k.LastUsedAt = &lastUsed.Time
 		}
+		if expiresAt.Valid {
+			k.ExpiresAt = &expiresAt.Time
+		}
 		keys = append(keys, k)
 	}
 
@@ -184,11 +510,14 @@ func ListAPIKeys(db *sql.DB) ([]APIKeyInfo, error) {
 
 // APIKeyInfo contains information about an API key
 type APIKeyInfo struct {
-	ID         int64      `json:"id"`
-	Name       string     `json:"name"`
-	Scopes     string     `json:"scopes"`
-	CreatedAt  time.Time  `json:"created_at"`
-	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ID                int64      `json:"id"`
+	Name              string     `json:"name"`
+	Scopes            string     `json:"scopes"`
+	CreatedAt         time.Time  `json:"created_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP        string     `json:"last_used_ip,omitempty"`
+	LastUsedUserAgent string     `json:"last_used_user_agent,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
 }
 
 // DeleteAPIKey deletes an API key by ID
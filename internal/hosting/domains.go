@@ -0,0 +1,156 @@
+package hosting
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// validHostnameRegex matches a fully-qualified hostname (at least one dot)
+var validHostnameRegex = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// ErrCertNotAllowed is returned (wrapped) by AllowCertificate for hostnames we don't serve
+var ErrCertNotAllowed = errors.New("certificate not allowed")
+
+// CustomDomain maps an external hostname to a hosted site
+type CustomDomain struct {
+	Hostname  string    `json:"hostname"`
+	SiteID    string    `json:"site_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NormalizeHostname lowercases a hostname and strips any port or trailing dot.
+// IPv6 addresses lose their brackets: "[::1]:8080" becomes "::1".
+func NormalizeHostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	} else {
+		// No port: a name, a bare IPv6 address or a bracketed one
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// ValidateHostname checks if a hostname can be used as a custom domain
+func ValidateHostname(hostname string) error {
+	if len(hostname) < 1 || len(hostname) > 253 {
+		return fmt.Errorf("hostname must be 1-253 characters")
+	}
+	if !validHostnameRegex.MatchString(hostname) {
+		return fmt.Errorf("invalid hostname '%s'", hostname)
+	}
+	return nil
+}
+
+// AddCustomDomain maps a hostname to a site, replacing any existing mapping
+func AddCustomDomain(hostname, siteID string) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	hostname = NormalizeHostname(hostname)
+	if err := ValidateHostname(hostname); err != nil {
+		return err
+	}
+	if !ValidateSiteID(strings.ToLower(siteID)) {
+		return fmt.Errorf("invalid site '%s'", siteID)
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO custom_domains (hostname, site_id) VALUES (?, ?)
+		ON CONFLICT(hostname) DO UPDATE SET site_id = excluded.site_id
+	`, hostname, strings.ToLower(siteID))
+	if err != nil {
+		return fmt.Errorf("failed to store custom domain: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveCustomDomain deletes a hostname mapping
+func RemoveCustomDomain(hostname string) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	result, err := database.Exec("DELETE FROM custom_domains WHERE hostname = ?", NormalizeHostname(hostname))
+	if err != nil {
+		return fmt.Errorf("failed to delete custom domain: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("custom domain '%s' not found", hostname)
+	}
+
+	return nil
+}
+
+// ListCustomDomains returns all hostname mappings
+func ListCustomDomains() ([]CustomDomain, error) {
+	if database == nil {
+		return nil, fmt.Errorf("hosting not initialized")
+	}
+
+	rows, err := database.Query("SELECT hostname, site_id, created_at FROM custom_domains ORDER BY hostname")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query custom domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []CustomDomain{}
+	for rows.Next() {
+		var d CustomDomain
+		if err := rows.Scan(&d.Hostname, &d.SiteID, &d.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan custom domain: %w", err)
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read custom domains: %w", err)
+	}
+
+	return domains, nil
+}
+
+// ResolveCustomDomain returns the site mapped to a hostname, if any
+func ResolveCustomDomain(host string) (string, bool) {
+	if database == nil {
+		return "", false
+	}
+
+	var siteID string
+	err := database.QueryRow("SELECT site_id FROM custom_domains WHERE hostname = ?", NormalizeHostname(host)).Scan(&siteID)
+	if err != nil {
+		return "", false
+	}
+
+	return siteID, true
+}
+
+// AllowCertificate decides whether an on-demand TLS certificate may be issued for
+// name: the main domain, a subdomain of it with a deployed site, or a configured
+// custom domain. Anything else is refused so clients can't trigger unlimited ACME orders.
+func AllowCertificate(name, mainDomain string) error {
+	host := NormalizeHostname(name)
+	mainDomain = NormalizeHostname(mainDomain)
+
+	if host == mainDomain {
+		return nil
+	}
+
+	if sub := strings.TrimSuffix(host, "."+mainDomain); sub != host {
+		if sub != "" && !strings.Contains(sub, ".") && SiteExists(sub) {
+			return nil
+		}
+		return fmt.Errorf("%w: no site deployed at %s", ErrCertNotAllowed, host)
+	}
+
+	if _, ok := ResolveCustomDomain(host); ok {
+		return nil
+	}
+
+	return fmt.Errorf("%w: unknown host %s", ErrCertNotAllowed, host)
+}
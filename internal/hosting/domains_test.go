@@ -0,0 +1,67 @@
+package hosting
+
+import "testing"
+
+func TestNormalizeHostname(t *testing.T) {
+	tests := map[string]string{
+		"example.com":        "example.com",
+		"WWW.Example.COM":    "www.example.com",
+		" example.com. ":     "example.com",
+		"example.com:8080":   "example.com",
+		"example.com.:443":   "example.com",
+		"203.0.113.7:80":     "203.0.113.7",
+		"[2001:db8::1]:8080": "2001:db8::1",
+		"[2001:db8::1]":      "2001:db8::1",
+		"2001:db8::1":        "2001:db8::1",
+		"[::1]:443":          "::1",
+		"":                   "",
+	}
+	for in, want := range tests {
+		if got := NormalizeHostname(in); got != want {
+			t.Errorf("NormalizeHostname(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestResolveCustomDomain(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	if err := AddCustomDomain("WWW.MyBrand.com.", "Blog"); err != nil {
+		t.Fatalf("AddCustomDomain failed: %v", err)
+	}
+	if err := AddCustomDomain("shop.example.org", "shop"); err != nil {
+		t.Fatalf("AddCustomDomain failed: %v", err)
+	}
+
+	tests := []struct {
+		host string
+		site string
+		ok   bool
+	}{
+		{"www.mybrand.com", "blog", true},
+		{"WWW.MYBRAND.COM:443", "blog", true},
+		{"www.mybrand.com.", "blog", true},
+		{"shop.example.org:8080", "shop", true},
+		{"mybrand.com", "", false},
+		{"unknown.example", "", false},
+	}
+	for _, tt := range tests {
+		site, ok := ResolveCustomDomain(tt.host)
+		if site != tt.site || ok != tt.ok {
+			t.Errorf("ResolveCustomDomain(%q) = %q, %v; want %q, %v", tt.host, site, ok, tt.site, tt.ok)
+		}
+	}
+
+	domains, err := ListCustomDomains()
+	if err != nil || len(domains) != 2 || domains[0].Hostname != "shop.example.org" || domains[1].SiteID != "blog" {
+		t.Errorf("ListCustomDomains() = %+v, %v", domains, err)
+	}
+
+	// Remapping a hostname replaces its site
+	AddCustomDomain("www.mybrand.com", "shop")
+	if site, _ := ResolveCustomDomain("www.mybrand.com"); site != "shop" {
+		t.Errorf("after remapping, ResolveCustomDomain = %q, want shop", site)
+	}
+}
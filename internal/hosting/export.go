@@ -0,0 +1,52 @@
+package hosting
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+)
+
+// ExportSite streams a site's VFS files to w as a ZIP archive.
+// Entries keep their VFS paths; the stored MIME type is kept in each entry's comment.
+func ExportSite(w io.Writer, siteID string) (int, error) {
+	files, err := fs.ListFiles(siteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list files: %w", err)
+	}
+	if len(files) == 0 {
+		return 0, fmt.Errorf("site '%s' not found", siteID)
+	}
+
+	zipWriter := zip.NewWriter(w)
+	for _, info := range files {
+		file, err := fs.ReadFile(siteID, info.Path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read file %s: %w", info.Path, err)
+		}
+
+		header := &zip.FileHeader{
+			Name:     info.Path,
+			Method:   zip.Deflate,
+			Modified: info.ModTime,
+			Comment:  info.MimeType,
+		}
+
+		entry, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			file.Content.Close()
+			return 0, fmt.Errorf("failed to add %s to archive: %w", info.Path, err)
+		}
+
+		_, err = io.Copy(entry, file.Content)
+		file.Content.Close()
+		if err != nil {
+			return 0, fmt.Errorf("failed to write %s to archive: %w", info.Path, err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finalize archive: %w", err)
+	}
+
+	return len(files), nil
+}
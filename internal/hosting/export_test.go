@@ -0,0 +1,52 @@
+package hosting
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExportSite(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	fs.WriteFile("blog", "index.html", strings.NewReader("<h1>Hi</h1>"), 11, "text/html; charset=utf-8")
+	fs.WriteFile("blog", "css/style.css", strings.NewReader("body{}"), 6, "text/css; charset=utf-8")
+
+	var buf bytes.Buffer
+	count, err := ExportSite(&buf, "blog")
+	if err != nil {
+		t.Fatalf("ExportSite failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	zipReader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Export is not a valid zip: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, f := range zipReader.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(data)
+		if f.Name == "css/style.css" && f.Comment != "text/css; charset=utf-8" {
+			t.Errorf("mime type comment = %q, want text/css", f.Comment)
+		}
+	}
+	if got["index.html"] != "<h1>Hi</h1>" || got["css/style.css"] != "body{}" {
+		t.Errorf("unexpected archive contents: %v", got)
+	}
+
+	// Unknown site
+	if _, err := ExportSite(io.Discard, "ghost"); err == nil {
+		t.Error("ExportSite should fail for a site with no files")
+	}
+}
@@ -34,6 +34,11 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 		path = "index.html"
 	}
 
+	if isPrivatePath(path) {
+		serveNotFound(w, r, siteID)
+		return
+	}
+
 	// 1. Try exact match
 	file, err := fs.ReadFile(siteID, path)
 	if err != nil {
@@ -46,7 +51,7 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 		
 		// 3. If still not found, 404
 		if err != nil {
-			http.NotFound(w, r)
+			serveNotFound(w, r, siteID)
 			return
 		}
 	}
@@ -79,3 +84,39 @@ func ServeVFS(w http.ResponseWriter, r *http.Request, siteID string) {
 		// Log error?
 	}
 }
+
+// isPrivatePath reports whether a site file must never be served as static content:
+// the serverless source (main.js) and hidden files, except under .well-known
+func isPrivatePath(path string) bool {
+	if path == "main.js" {
+		return true
+	}
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ".") && part != ".well-known" {
+			return true
+		}
+	}
+	return false
+}
+
+// serveNotFound serves the site's own 404.html if present, otherwise a plain 404
+func serveNotFound(w http.ResponseWriter, r *http.Request, siteID string) {
+	file, err := fs.ReadFile(siteID, "404.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Content.Close()
+
+	contentType := file.MimeType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", file.Size))
+	w.WriteHeader(http.StatusNotFound)
+
+	if r.Method != http.MethodHead {
+		io.Copy(w, file.Content)
+	}
+}
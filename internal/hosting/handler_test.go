@@ -0,0 +1,82 @@
+package hosting
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeVFS_Custom404(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	fs.WriteFile("branded", "index.html", strings.NewReader("home"), 4, "text/html")
+	fs.WriteFile("branded", "404.html", strings.NewReader("lost?"), 5, "text/html")
+	fs.WriteFile("plain", "index.html", strings.NewReader("home"), 4, "text/html")
+
+	// Site with 404.html serves it with a 404 status
+	req := httptest.NewRequest("GET", "/missing", nil)
+	rr := httptest.NewRecorder()
+	ServeVFS(rr, req, "branded")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+	if rr.Body.String() != "lost?" {
+		t.Errorf("body = %q, want custom 404 page", rr.Body.String())
+	}
+
+	// Site without 404.html falls back to the default
+	req = httptest.NewRequest("GET", "/missing", nil)
+	rr = httptest.NewRecorder()
+	ServeVFS(rr, req, "plain")
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusNotFound)
+	}
+	if strings.Contains(rr.Body.String(), "lost?") {
+		t.Error("default 404 should not use another site's page")
+	}
+}
+
+func TestIsPrivatePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"index.html", false},
+		{"js/main.js", false},
+		{".well-known/security.txt", false},
+		{"main.js", true},
+		{".env", true},
+		{"config/.secret", true},
+		{".git/config", true},
+	}
+
+	for _, tt := range tests {
+		if got := isPrivatePath(tt.path); got != tt.want {
+			t.Errorf("isPrivatePath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestServeVFS_PrivateFiles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	fs.WriteFile("app", "index.html", strings.NewReader("home"), 4, "text/html")
+	fs.WriteFile("app", "main.js", strings.NewReader("res.send('hi')"), 14, "application/javascript")
+	fs.WriteFile("app", ".env", strings.NewReader("KEY=1"), 5, "text/plain")
+
+	for _, path := range []string{"/main.js", "/.env"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		ServeVFS(rr, req, "app")
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("GET %s: status = %d, want %d", path, rr.Code, http.StatusNotFound)
+		}
+	}
+}
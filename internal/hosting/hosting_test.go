@@ -3,19 +3,23 @@ package hosting
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"golang.org/x/crypto/bcrypt"
 	_ "modernc.org/sqlite"
 )
 
 // setupTestDB creates a temporary in-memory database for testing
-func setupTestDB(t *testing.T) *sql.DB {
+func setupTestDB(t testing.TB) *sql.DB {
 	db, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		t.Fatalf("Failed to open DB: %v", err)
@@ -33,17 +37,44 @@ func setupTestDB(t *testing.T) *sql.DB {
 		size_bytes INTEGER NOT NULL,
 		mime_type TEXT,
 		hash TEXT NOT NULL,
+		encoding TEXT NOT NULL DEFAULT '',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		PRIMARY KEY (site_id, path)
 	);
+	CREATE TABLE sites (
+		site_id TEXT PRIMARY KEY,
+		enabled BOOLEAN NOT NULL DEFAULT 1,
+		owner_key_id INTEGER,
+		owner TEXT,
+		last_deployed_at DATETIME,
+		deploy_count INTEGER NOT NULL DEFAULT 0,
+		preview BOOLEAN NOT NULL DEFAULT 0,
+		expires_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE custom_domains (
+		hostname TEXT PRIMARY KEY,
+		site_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE site_aliases (
+		alias TEXT PRIMARY KEY,
+		site_id TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	CREATE TABLE api_keys (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		name TEXT NOT NULL,
 		key_hash TEXT NOT NULL,
 		scopes TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		last_used_at DATETIME
+		last_used_at DATETIME,
+		expires_at DATETIME,
+		last_used_ip TEXT,
+		last_used_user_agent TEXT,
+		key_prefix TEXT UNIQUE
 	);
 	CREATE TABLE deployments (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -51,6 +82,30 @@ func setupTestDB(t *testing.T) *sql.DB {
 		size_bytes INTEGER,
 		file_count INTEGER,
 		deployed_by TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		idempotency_key TEXT,
+		api_key_id INTEGER
+	);
+	CREATE TABLE env_vars (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		site_id TEXT NOT NULL,
+		name TEXT NOT NULL,
+		value TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(site_id, name)
+	);
+	CREATE TABLE kv_store (
+		site_id TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (site_id, key)
+	);
+	CREATE TABLE site_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		site_id TEXT NOT NULL,
+		level TEXT NOT NULL,
+		message TEXT NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	`
@@ -90,6 +145,118 @@ func TestVFS_WriteAndRead(t *testing.T) {
 	}
 }
 
+func TestVFS_Compression(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	fs := NewSQLFileSystem(db)
+	fs.SetCompression(9, 64)
+
+	small := []byte("tiny file")
+	large := bytes.Repeat([]byte("<p>compress me</p>\n"), 100)
+	fs.WriteFile("site1", "small.txt", bytes.NewReader(small), int64(len(small)), "text/plain")
+	fs.WriteFile("site1", "large.html", bytes.NewReader(large), int64(len(large)), "text/html")
+
+	tests := []struct {
+		path     string
+		content  []byte
+		encoding string
+	}{
+		{"small.txt", small, ""},
+		{"large.html", large, "gzip"},
+	}
+	for _, tt := range tests {
+		var encoding string
+		var stored []byte
+		db.QueryRow("SELECT encoding, content FROM files WHERE path = ?", tt.path).Scan(&encoding, &stored)
+		if encoding != tt.encoding {
+			t.Errorf("%s: encoding = %q, want %q", tt.path, encoding, tt.encoding)
+		}
+		if tt.encoding == "" && !bytes.Equal(stored, tt.content) {
+			t.Errorf("%s: sub-threshold file should be stored raw", tt.path)
+		}
+
+		file, err := fs.ReadFile("site1", tt.path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) failed: %v", tt.path, err)
+		}
+		got, _ := io.ReadAll(file.Content)
+		file.Content.Close()
+		if !bytes.Equal(got, tt.content) || file.Size != int64(len(tt.content)) {
+			t.Errorf("%s: read %d bytes (size %d), want the original %d", tt.path, len(got), file.Size, len(tt.content))
+		}
+	}
+}
+
+func TestVFS_Rehash(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	fs := NewSQLFileSystem(db)
+	page := bytes.Repeat([]byte("<p>rehash me</p>\n"), 100)
+	fs.WriteFile("site1", "index.html", bytes.NewReader(page), int64(len(page)), "text/html; charset=utf-8")
+	fs.WriteFile("site1", "app.js", bytes.NewReader([]byte("x")), 1, "text/javascript; charset=utf-8")
+
+	// Simulate files written by an older storage format
+	db.Exec("UPDATE files SET content = ?, encoding = '', hash = 'stale' WHERE path = 'index.html'", page)
+
+	result, err := fs.Rehash(false)
+	if err != nil {
+		t.Fatalf("Rehash failed: %v", err)
+	}
+	if result.Scanned != 2 || result.Updated != 1 {
+		t.Errorf("result = %+v, want 2 scanned, 1 updated", result)
+	}
+
+	var hash, encoding string
+	db.QueryRow("SELECT hash, encoding FROM files WHERE path = 'index.html'").Scan(&hash, &encoding)
+	if hash == "stale" || encoding != "" {
+		t.Errorf("after rehash: hash = %q, encoding = %q, want a fresh hash and raw content", hash, encoding)
+	}
+
+	// Recompressing picks up the current gzip settings
+	result, err = fs.Rehash(true)
+	if err != nil {
+		t.Fatalf("Rehash(recompress) failed: %v", err)
+	}
+	if result.Updated != 1 {
+		t.Errorf("recompress updated %d files, want 1", result.Updated)
+	}
+	db.QueryRow("SELECT encoding FROM files WHERE path = 'index.html'").Scan(&encoding)
+	if encoding != "gzip" {
+		t.Errorf("encoding = %q, want gzip after recompress", encoding)
+	}
+
+	file, _ := fs.ReadFile("site1", "index.html")
+	got, _ := io.ReadAll(file.Content)
+	if !bytes.Equal(got, page) {
+		t.Error("content changed by rehash")
+	}
+}
+
+func TestCompress(t *testing.T) {
+	text := bytes.Repeat([]byte("hello "), 500)
+
+	// Below the threshold: stored raw
+	out, encoding, err := compress(text[:100], DefaultGzipLevel, 1024)
+	if err != nil || encoding != "" || !bytes.Equal(out, text[:100]) {
+		t.Errorf("sub-threshold: encoding = %q, err = %v, want raw", encoding, err)
+	}
+
+	// Above it: gzipped and smaller
+	out, encoding, err = compress(text, DefaultGzipLevel, 1024)
+	if err != nil || encoding != "gzip" || len(out) >= len(text) {
+		t.Errorf("compressible: encoding = %q, %d -> %d bytes, err = %v", encoding, len(text), len(out), err)
+	}
+
+	// Incompressible content is stored raw
+	noise := make([]byte, 4096)
+	rand.Read(noise)
+	if _, encoding, _ := compress(noise, DefaultGzipLevel, 1024); encoding != "" {
+		t.Errorf("random data: encoding = %q, want raw", encoding)
+	}
+}
+
 func TestDeploySite(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -145,6 +312,69 @@ func TestDeploySite(t *testing.T) {
 	}
 }
 
+// zipOf builds a zip reader holding files
+func zipOf(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Failed to create zip reader: %v", err)
+	}
+	return zr
+}
+
+func TestValidateZip(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr bool
+	}{
+		{"static site", map[string]string{"index.html": "hi", "css/a.css": "x"}, false},
+		{"serverless app", map[string]string{"main.js": "code"}, false},
+		{"empty", map[string]string{}, true},
+		{"nested index only", map[string]string{"docs/index.html": "hi"}, true},
+		{"only traversal", map[string]string{"../index.html": "hi"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validateZip(zipOf(t, tt.files))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateZip() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidDeploy) {
+				t.Errorf("validateZip() error = %v, want ErrInvalidDeploy", err)
+			}
+		})
+	}
+}
+
+func TestDeploySite_InvalidZipKeepsSite(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	if _, err := DeploySite(zipOf(t, map[string]string{"index.html": "v1"}), "keep"); err != nil {
+		t.Fatalf("DeploySite failed: %v", err)
+	}
+
+	_, err := DeploySite(zipOf(t, map[string]string{"readme.txt": "no entry point"}), "keep")
+	if !errors.Is(err, ErrInvalidDeploy) {
+		t.Fatalf("DeploySite error = %v, want ErrInvalidDeploy", err)
+	}
+
+	if exists, _ := GetFileSystem().Exists("keep", "index.html"); !exists {
+		t.Error("failed deploy removed the live site")
+	}
+}
+
 func TestSiteExists(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.Close()
@@ -168,3 +398,558 @@ func TestSiteExists(t *testing.T) {
 		t.Error("SiteExists returned false for serverless app")
 	}
 }
+
+func TestSiteEnabled(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	fs.WriteFile("blog", "index.html", strings.NewReader("hi"), 2, "text/html")
+
+	// Sites are enabled by default
+	if !IsSiteEnabled("blog") {
+		t.Error("IsSiteEnabled should default to true")
+	}
+
+	if err := SetSiteEnabled("blog", false); err != nil {
+		t.Fatalf("SetSiteEnabled failed: %v", err)
+	}
+	if IsSiteEnabled("blog") {
+		t.Error("IsSiteEnabled returned true for disabled site")
+	}
+
+	// Files are kept and the flag is reported by ListSites
+	sites, err := ListSites()
+	if err != nil || len(sites) != 1 {
+		t.Fatalf("ListSites = %v, %v", sites, err)
+	}
+	if sites[0].Enabled {
+		t.Error("ListSites reported disabled site as enabled")
+	}
+
+	SetSiteEnabled("blog", true)
+	if !IsSiteEnabled("blog") {
+		t.Error("IsSiteEnabled returned false after re-enabling")
+	}
+}
+
+func TestRecordSiteDeploy(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	fs.WriteFile("blog", "index.html", strings.NewReader("hi"), 2, "text/html")
+	if err := RecordSiteDeploy("blog", 1, "alice"); err != nil {
+		t.Fatalf("RecordSiteDeploy failed: %v", err)
+	}
+	if err := RecordSiteDeploy("blog", 2, "bob"); err != nil {
+		t.Fatalf("RecordSiteDeploy failed: %v", err)
+	}
+
+	sites, err := ListSites()
+	if err != nil || len(sites) != 1 {
+		t.Fatalf("ListSites = %v, %v", sites, err)
+	}
+	site := sites[0]
+	if site.DeployCount != 2 {
+		t.Errorf("DeployCount = %d, want 2", site.DeployCount)
+	}
+	if site.Owner != "alice" {
+		t.Errorf("Owner = %q, want first deployer alice", site.Owner)
+	}
+	if site.LastDeployedAt == nil || site.CreatedAt == nil {
+		t.Error("expected CreatedAt and LastDeployedAt to be set")
+	}
+}
+
+func TestQuerySites(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	for name, size := range map[string]int{"alpha": 10, "beta": 300, "blog": 50, "my_site": 5} {
+		fs.WriteFile(name, "index.html", strings.NewReader(strings.Repeat("x", size)), int64(size), "text/html")
+	}
+
+	names := func(sites []SiteInfo) string {
+		var out []string
+		for _, s := range sites {
+			out = append(out, s.Name)
+		}
+		return strings.Join(out, ",")
+	}
+
+	tests := []struct {
+		name      string
+		query     SiteQuery
+		want      string
+		wantTotal int
+	}{
+		{"by name", SiteQuery{Sort: "name"}, "alpha,beta,blog,my_site", 4},
+		{"by size", SiteQuery{Sort: "size"}, "beta,blog,alpha,my_site", 4},
+		{"page", SiteQuery{Sort: "name", Limit: 2, Offset: 1}, "beta,blog", 4},
+		{"search", SiteQuery{Sort: "name", Search: "B"}, "beta,blog", 2},
+		{"search is literal", SiteQuery{Search: "_"}, "my_site", 1},
+	}
+	for _, tt := range tests {
+		sites, total, err := QuerySites(tt.query)
+		if err != nil {
+			t.Fatalf("%s: QuerySites failed: %v", tt.name, err)
+		}
+		if got := names(sites); got != tt.want || total != tt.wantTotal {
+			t.Errorf("%s: got %s (total %d), want %s (total %d)", tt.name, got, total, tt.want, tt.wantTotal)
+		}
+	}
+
+	sites, _, _ := QuerySites(SiteQuery{Sort: "name", Limit: 1})
+	if modTime, ok := sites[0].ModTime.(time.Time); !ok || time.Since(modTime) > time.Hour {
+		t.Errorf("first site = %+v, want a recent modification time", sites)
+	}
+
+	if _, _, err := QuerySites(SiteQuery{Sort: "owner"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("unknown sort error = %v, want ErrInvalidSort", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	fs.WriteFile("big", "index.html", strings.NewReader(strings.Repeat("x", 600)), 600, "text/html")
+	fs.WriteFile("small", "index.html", strings.NewReader(strings.Repeat("x", 200)), 200, "text/html")
+	fs.WriteFile("small", "app.js", strings.NewReader(strings.Repeat("x", 100)), 100, "text/javascript")
+
+	usage, err := Usage(500)
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.TotalBytes != 900 || len(usage.Sites) != 2 {
+		t.Fatalf("usage = %+v, want 900 bytes over 2 sites", usage)
+	}
+	if usage.Sites[0].Name != "big" || !usage.Sites[0].OverQuota {
+		t.Errorf("first site = %+v, want big flagged over quota", usage.Sites[0])
+	}
+	if usage.Sites[1].OverQuota || usage.Sites[1].FileCount != 2 {
+		t.Errorf("second site = %+v, want small with 2 files under quota", usage.Sites[1])
+	}
+}
+
+func TestClaimDeploy(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	CreateAPIKey(db, "alice", "deploy")
+	CreateAPIKey(db, "bob", "deploy")
+	CreateAPIKey(db, "root", "deploy,admin")
+	ids := map[string]int64{}
+	keys, _ := ListAPIKeys(db)
+	for _, k := range keys {
+		ids[k.Name] = k.ID
+	}
+
+	// Unclaimed sites can be deployed by anyone, and the first key claims them
+	if err := ClaimDeploy(db, "blog", ids["alice"], "alice"); err != nil {
+		t.Errorf("unclaimed site: got %v, want nil", err)
+	}
+	if err := ClaimDeploy(db, "blog", ids["alice"], "alice"); err != nil {
+		t.Errorf("owner redeploy: got %v, want nil", err)
+	}
+	if err := ClaimDeploy(db, "blog", ids["bob"], "bob"); err != ErrSiteOwned {
+		t.Errorf("second key overwrite: got %v, want ErrSiteOwned", err)
+	}
+	if err := ClaimDeploy(db, "blog", ids["root"], "root"); err != nil {
+		t.Errorf("admin key overwrite: got %v, want nil", err)
+	}
+
+	// An admin deploy doesn't take the site over, and the deploy record keeps the owner
+	RecordSiteDeploy("blog", ids["root"], "root")
+	if err := ClaimDeploy(db, "blog", ids["alice"], "alice"); err != nil {
+		t.Errorf("owner after admin deploy: got %v, want nil", err)
+	}
+
+	// Sites first deployed from the dashboard are claimed by the first key
+	RecordSiteDeploy("docs", 0, "admin")
+	if err := ClaimDeploy(db, "docs", ids["bob"], "bob"); err != nil {
+		t.Errorf("dashboard site: got %v, want nil", err)
+	}
+	if err := ClaimDeploy(db, "docs", ids["alice"], "alice"); err != ErrSiteOwned {
+		t.Errorf("dashboard site claimed by bob: got %v, want ErrSiteOwned", err)
+	}
+}
+
+func TestReleaseClaim(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	_, alice, _ := createAPIKey(db, "alice", "deploy")
+	_, bob, _ := createAPIKey(db, "bob", "deploy")
+
+	// A claim that never saw a deploy is dropped, freeing the name
+	ClaimDeploy(db, "blog", alice, "alice")
+	if err := ReleaseClaim(db, "blog", alice); err != nil {
+		t.Fatalf("ReleaseClaim failed: %v", err)
+	}
+	if err := ClaimDeploy(db, "blog", bob, "bob"); err != nil {
+		t.Errorf("released name: got %v, want nil", err)
+	}
+
+	// Another key can't release it, and a deployed site is kept
+	ReleaseClaim(db, "blog", alice)
+	if err := ClaimDeploy(db, "blog", alice, "alice"); err != ErrSiteOwned {
+		t.Errorf("release by another key: got %v, want ErrSiteOwned", err)
+	}
+	RecordSiteDeploy("blog", bob, "bob")
+	ReleaseClaim(db, "blog", bob)
+	if err := ClaimDeploy(db, "blog", alice, "alice"); err != ErrSiteOwned {
+		t.Errorf("release of a deployed site: got %v, want ErrSiteOwned", err)
+	}
+}
+
+func TestClaimDeploy_Concurrent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	// Every connection to :memory: is a separate database
+	db.SetMaxOpenConns(1)
+
+	const racers = 8
+	ids := make([]int64, racers)
+	for i := range ids {
+		_, ids[i], _ = createAPIKey(db, fmt.Sprintf("key%d", i), "deploy")
+	}
+
+	var wg sync.WaitGroup
+	var winners atomic.Int32
+	for _, id := range ids {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			err := ClaimDeploy(db, "blog", id, "racer")
+			if err == nil {
+				winners.Add(1)
+			} else if err != ErrSiteOwned {
+				t.Errorf("ClaimDeploy failed: %v", err)
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	if n := winners.Load(); n != 1 {
+		t.Errorf("%d keys claimed the same new site, want 1", n)
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	oldToken, oldID, err := createAPIKey(db, "ci", "deploy")
+	if err != nil {
+		t.Fatalf("createAPIKey failed: %v", err)
+	}
+	RecordSiteDeploy("blog", oldID, "ci")
+
+	newToken, newID, _, err := RotateAPIKey(db, oldID, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateAPIKey failed: %v", err)
+	}
+	if newToken == oldToken || newID == oldID {
+		t.Fatal("rotation should issue a new key")
+	}
+
+	// Both keys work during the grace period
+	if id, _, err := ValidateAPIKey(db, oldToken, "", ""); err != nil || id != oldID {
+		t.Errorf("old key should still be valid: id=%d err=%v", id, err)
+	}
+	if id, name, err := ValidateAPIKey(db, newToken, "", ""); err != nil || id != newID || name != "ci" {
+		t.Errorf("new key should be valid with same name: id=%d name=%s err=%v", id, name, err)
+	}
+
+	// Ownership moves to the new key
+	if err := ClaimDeploy(db, "blog", newID, "ci"); err != nil {
+		t.Errorf("new key should own the site: %v", err)
+	}
+
+	// A key on its way out can't be rotated again
+	if _, _, _, err := RotateAPIKey(db, oldID, time.Hour); err != ErrAPIKeyRotated {
+		t.Errorf("second rotation error = %v, want ErrAPIKeyRotated", err)
+	}
+	var keys int
+	db.QueryRow("SELECT COUNT(*) FROM api_keys").Scan(&keys)
+	if keys != 2 {
+		t.Errorf("got %d keys after a refused rotation, want 2", keys)
+	}
+
+	// Once the grace period ends the old key is refused, then purged
+	db.Exec("UPDATE api_keys SET expires_at = '2000-01-01 00:00:00' WHERE id = ?", oldID)
+	if _, _, err := ValidateAPIKey(db, oldToken, "", ""); err == nil {
+		t.Error("old key should be revoked after the grace period")
+	}
+	if err := PurgeExpiredAPIKeys(db); err != nil {
+		t.Fatalf("PurgeExpiredAPIKeys failed: %v", err)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE id = ?", oldID).Scan(&keys)
+	if keys != 0 {
+		t.Error("expired key should be purged")
+	}
+	if _, _, err := ValidateAPIKey(db, newToken, "", ""); err != nil {
+		t.Errorf("new key should survive the purge: %v", err)
+	}
+
+	if _, _, _, err := RotateAPIKey(db, 9999, time.Hour); err == nil {
+		t.Error("rotating an unknown key should fail")
+	}
+}
+
+func TestRecordAPIKeyUse(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	token, id, _ := createAPIKey(db, "ci", "deploy")
+	if err := RecordAPIKeyUse(db, id, "203.0.113.7", "fazt-cli/0.5"); err != nil {
+		t.Fatalf("RecordAPIKeyUse failed: %v", err)
+	}
+
+	keys, err := ListAPIKeys(db)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("ListAPIKeys = %v, %v", keys, err)
+	}
+	if keys[0].LastUsedIP != "203.0.113.7" || keys[0].LastUsedUserAgent != "fazt-cli/0.5" {
+		t.Errorf("got ip=%q ua=%q", keys[0].LastUsedIP, keys[0].LastUsedUserAgent)
+	}
+
+	// Every successful validation records its caller
+	if _, _, err := ValidateAPIKey(db, token, "198.51.100.4", "curl/8.0"); err != nil {
+		t.Fatalf("ValidateAPIKey failed: %v", err)
+	}
+	keys, _ = ListAPIKeys(db)
+	if keys[0].LastUsedIP != "198.51.100.4" || keys[0].LastUsedUserAgent != "curl/8.0" || keys[0].LastUsedAt == nil {
+		t.Errorf("after validation got ip=%q ua=%q last used %v", keys[0].LastUsedIP, keys[0].LastUsedUserAgent, keys[0].LastUsedAt)
+	}
+}
+
+func TestFindIdempotentDeployment(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	if err := RecordDeployment(db, "blog", 2048, 3, "ci", 7, "deploy-abc"); err != nil {
+		t.Fatalf("RecordDeployment failed: %v", err)
+	}
+	RecordDeployment(db, "blog", 4096, 5, "ci", 7, "")
+
+	got, err := FindIdempotentDeployment(db, "deploy-abc", 7)
+	if err != nil || got == nil {
+		t.Fatalf("FindIdempotentDeployment = %v, %v", got, err)
+	}
+	if *got != (IdempotentDeployment{SiteID: "blog", SizeBytes: 2048, FileCount: 3}) {
+		t.Errorf("got %+v", *got)
+	}
+
+	// Keys belong to the API key that sent them
+	if got, _ := FindIdempotentDeployment(db, "deploy-abc", 8); got != nil {
+		t.Errorf("another API key found %+v", *got)
+	}
+	if got, _ := FindIdempotentDeployment(db, "deploy-xyz", 7); got != nil {
+		t.Errorf("unknown key found %+v", *got)
+	}
+
+	// Keys older than the window are forgotten
+	db.Exec("UPDATE deployments SET created_at = ? WHERE idempotency_key = 'deploy-abc'",
+		time.Now().UTC().Add(-DeployIdempotencyWindow-time.Hour).Format(sqliteTimeFormat))
+	if got, _ := FindIdempotentDeployment(db, "deploy-abc", 7); got != nil {
+		t.Errorf("expired key found %+v", *got)
+	}
+}
+
+func TestValidateAPIKey_PrefixedAndLegacy(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	token, id, err := createAPIKey(db, "ci", "deploy")
+	if err != nil {
+		t.Fatalf("createAPIKey failed: %v", err)
+	}
+	if _, ok := parseKeyPrefix(token); !ok {
+		t.Fatalf("new token %q should carry a lookup prefix", token)
+	}
+	if got, _, err := ValidateAPIKey(db, token, "", ""); err != nil || got != id {
+		t.Errorf("prefixed token: id=%d err=%v", got, err)
+	}
+	var keyHash string
+	db.QueryRow("SELECT key_hash FROM api_keys WHERE id = ?", id).Scan(&keyHash)
+	if !strings.HasPrefix(keyHash, "$sha256$") {
+		t.Errorf("key hash = %q, want SHA-256", keyHash)
+	}
+
+	// A forged secret with a valid prefix is rejected
+	prefix, _ := parseKeyPrefix(token)
+	if _, _, err := ValidateAPIKey(db, apiKeyTokenPrefix+prefix+"_forged", "", ""); err == nil {
+		t.Error("forged secret should be rejected")
+	}
+
+	// Keys created before prefixes still validate
+	legacy := "0123456789abcdef"
+	hash, _ := bcrypt.GenerateFromPassword([]byte(legacy), bcrypt.MinCost)
+	db.Exec("INSERT INTO api_keys (name, key_hash, scopes) VALUES ('old', ?, 'deploy')", string(hash))
+	if _, name, err := ValidateAPIKey(db, legacy, "", ""); err != nil || name != "old" {
+		t.Errorf("legacy token: name=%q err=%v", name, err)
+	}
+
+	// Prefixed keys hashed with bcrypt before the switch to SHA-256 still validate
+	older := apiKeyTokenPrefix + "0a1b2c3d4e5f_" + strings.Repeat("ab", 24)
+	hash, _ = bcrypt.GenerateFromPassword([]byte(older), bcrypt.MinCost)
+	db.Exec("INSERT INTO api_keys (name, key_hash, scopes, key_prefix) VALUES ('bcrypt', ?, 'deploy', '0a1b2c3d4e5f')", string(hash))
+	if _, name, err := ValidateAPIKey(db, older, "", ""); err != nil || name != "bcrypt" {
+		t.Errorf("bcrypt prefixed token: name=%q err=%v", name, err)
+	}
+}
+
+func TestParseKeyPrefix(t *testing.T) {
+	tests := []struct {
+		token  string
+		prefix string
+		ok     bool
+	}{
+		{"fazt_abc123_secret", "abc123", true},
+		{"fazt_abc123_sec_ret", "abc123", true},
+		{"fazt__secret", "", false},
+		{"fazt_abc123_", "", false},
+		{"fazt_abc123", "", false},
+		{"0123456789abcdef", "", false},
+	}
+
+	for _, tt := range tests {
+		prefix, ok := parseKeyPrefix(tt.token)
+		if prefix != tt.prefix || ok != tt.ok {
+			t.Errorf("parseKeyPrefix(%q) = %q, %v; want %q, %v", tt.token, prefix, ok, tt.prefix, tt.ok)
+		}
+	}
+}
+
+// seedAPIKeys inserts n keys hashed at bcrypt.MinCost, prefixed or legacy,
+// and returns the token of the last one
+func seedAPIKeys(b *testing.B, db *sql.DB, n int, prefixed bool) string {
+	b.Helper()
+	var token string
+	for i := 0; i < n; i++ {
+		secret, _ := generateRandomToken(16)
+		prefix := fmt.Sprintf("%012x", i)
+		token = secret
+		if prefixed {
+			token = apiKeyTokenPrefix + prefix + "_" + secret
+		}
+		hash, _ := bcrypt.GenerateFromPassword([]byte(token), bcrypt.MinCost)
+
+		var err error
+		if prefixed {
+			_, err = db.Exec("INSERT INTO api_keys (name, key_hash, key_prefix) VALUES (?, ?, ?)", "k", string(hash), prefix)
+		} else {
+			_, err = db.Exec("INSERT INTO api_keys (name, key_hash) VALUES (?, ?)", "k", string(hash))
+		}
+		if err != nil {
+			b.Fatalf("seed failed: %v", err)
+		}
+	}
+	return token
+}
+
+// Run with: go test ./internal/hosting -run '^$' -bench ValidateAPIKey
+func BenchmarkValidateAPIKey_Legacy1000(b *testing.B) {
+	db := setupTestDB(b)
+	defer db.Close()
+	token := seedAPIKeys(b, db, 1000, false)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ValidateAPIKey(db, token, "", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkValidateAPIKey_Prefixed1000(b *testing.B) {
+	db := setupTestDB(b)
+	defer db.Close()
+	token := seedAPIKeys(b, db, 1000, true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := ValidateAPIKey(db, token, "", ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	tests := []struct {
+		path    string
+		content string
+		want    string
+	}{
+		{"index.html", "plain words", "text/html; charset=utf-8"},
+		{"LICENSE", "MIT License", "text/plain; charset=utf-8"},
+		{"page", "<!DOCTYPE html><html></html>", "text/html; charset=utf-8"},
+		{"logo", "\x89PNG\r\n\x1a\n0000", "image/png"},
+		{"empty", "", "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		mimeType, r, err := detectMimeType(tt.path, strings.NewReader(tt.content))
+		if err != nil {
+			t.Fatalf("detectMimeType(%q) failed: %v", tt.path, err)
+		}
+		if mimeType != tt.want {
+			t.Errorf("detectMimeType(%q) = %q, want %q", tt.path, mimeType, tt.want)
+		}
+
+		// Sniffed bytes are not lost
+		got, _ := io.ReadAll(r)
+		if string(got) != tt.content {
+			t.Errorf("detectMimeType(%q) content = %q, want %q", tt.path, got, tt.content)
+		}
+	}
+}
+
+func TestAllowCertificate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+
+	fs := GetFileSystem()
+	fs.WriteFile("blog", "index.html", strings.NewReader("hi"), 2, "text/html")
+	if err := AddCustomDomain("www.mybrand.com", "blog"); err != nil {
+		t.Fatalf("AddCustomDomain failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		allow bool
+	}{
+		{"fazt.sh", true},
+		{"blog.fazt.sh", true},
+		{"BLOG.fazt.sh.", true},
+		{"www.mybrand.com", true},
+		{"ghost.fazt.sh", false},
+		{"a.blog.fazt.sh", false},
+		{"random.example.com", false},
+		{"fazt.sh.evil.com", false},
+	}
+
+	for _, tt := range tests {
+		err := AllowCertificate(tt.name, "fazt.sh")
+		if (err == nil) != tt.allow {
+			t.Errorf("AllowCertificate(%q) error = %v, want allowed=%v", tt.name, err, tt.allow)
+		}
+		if err != nil && !errors.Is(err, ErrCertNotAllowed) {
+			t.Errorf("AllowCertificate(%q) error = %v, want ErrCertNotAllowed", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package hosting
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Serverless log levels, lowest first
+var logLevels = map[string]int{"info": 0, "warn": 1, "error": 2}
+
+// ValidLogLevel reports whether level is a site log level
+func ValidLogLevel(level string) bool {
+	_, ok := logLevels[level]
+	return ok
+}
+
+// LogAtLeast reports whether level is at or above min
+func LogAtLeast(level, min string) bool {
+	return logLevels[level] >= logLevels[min]
+}
+
+// LogEntry is a line logged by a site's serverless function
+type LogEntry struct {
+	ID        int64     `json:"id"`
+	SiteID    string    `json:"site_id"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// logSubscriberBuffer is how many entries a slow subscriber may fall behind before
+// entries are dropped for it
+const logSubscriberBuffer = 64
+
+// logBroker fans site log entries out to live subscribers, like SiteHub does for
+// WebSocket broadcasts
+type logBroker struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan LogEntry]struct{}
+	closed bool
+}
+
+var logs = &logBroker{subs: make(map[string]map[chan LogEntry]struct{})}
+
+// subscribe returns a channel of new entries for siteID and a function to
+// unsubscribe. The channel is closed on unsubscribe or when the broker stops.
+func (b *logBroker) subscribe(siteID string) (<-chan LogEntry, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan LogEntry, logSubscriberBuffer)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subs[siteID] == nil {
+		b.subs[siteID] = make(map[chan LogEntry]struct{})
+	}
+	b.subs[siteID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[siteID][ch]; ok {
+			delete(b.subs[siteID], ch)
+			if len(b.subs[siteID]) == 0 {
+				delete(b.subs, siteID)
+			}
+			close(ch)
+		}
+	}
+}
+
+// publish delivers entry to the site's subscribers without blocking
+func (b *logBroker) publish(entry LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[entry.SiteID] {
+		select {
+		case ch <- entry:
+		default:
+			// Subscriber is behind; drop rather than stall the function
+		}
+	}
+}
+
+// stop closes every subscription
+func (b *logBroker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for siteID, chans := range b.subs {
+		for ch := range chans {
+			close(ch)
+		}
+		delete(b.subs, siteID)
+	}
+	b.closed = true
+}
+
+// SubscribeLogs streams new log entries for a site until the returned function is called
+func SubscribeLogs(siteID string) (<-chan LogEntry, func()) {
+	return logs.subscribe(siteID)
+}
+
+// StopLogStreams ends all live log subscriptions (call on server shutdown)
+func StopLogStreams() {
+	logs.stop()
+}
+
+// WriteSiteLog stores a serverless log line in site_logs and publishes it to live
+// subscribers. A nil db only publishes.
+func WriteSiteLog(db *sql.DB, siteID, level, message string) error {
+	entry := LogEntry{SiteID: siteID, Level: level, Message: message, CreatedAt: time.Now().UTC()}
+
+	if db != nil {
+		result, err := db.Exec(
+			"INSERT INTO site_logs (site_id, level, message, created_at) VALUES (?, ?, ?, ?)",
+			siteID, level, message, entry.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store site log: %w", err)
+		}
+		entry.ID, _ = result.LastInsertId()
+	}
+
+	logs.publish(entry)
+	return nil
+}
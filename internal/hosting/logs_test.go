@@ -0,0 +1,72 @@
+package hosting
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogBroker(t *testing.T) {
+	b := &logBroker{subs: make(map[string]map[chan LogEntry]struct{})}
+
+	blog, unsubscribeBlog := b.subscribe("blog")
+	other, unsubscribeOther := b.subscribe("other")
+	defer unsubscribeOther()
+
+	b.publish(LogEntry{SiteID: "blog", Level: "info", Message: "hello"})
+
+	select {
+	case entry := <-blog:
+		if entry.Message != "hello" {
+			t.Errorf("entry = %+v, want hello", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber didn't receive the entry")
+	}
+	select {
+	case entry := <-other:
+		t.Errorf("other site received %+v", entry)
+	default:
+	}
+
+	// A slow subscriber doesn't block publishing
+	for i := 0; i < logSubscriberBuffer*2; i++ {
+		b.publish(LogEntry{SiteID: "blog", Level: "info"})
+	}
+
+	unsubscribeBlog()
+	unsubscribeBlog() // safe to call twice
+	if _, ok := b.subs["blog"]; ok {
+		t.Error("unsubscribing the last subscriber should remove the site")
+	}
+
+	b.stop()
+	for range other {
+		// drain until closed
+	}
+	if ch, _ := b.subscribe("late"); ch != nil {
+		if _, ok := <-ch; ok {
+			t.Error("subscribing after stop should return a closed channel")
+		}
+	}
+}
+
+func TestLogAtLeast(t *testing.T) {
+	tests := []struct {
+		level, min string
+		want       bool
+	}{
+		{"info", "info", true},
+		{"warn", "info", true},
+		{"info", "warn", false},
+		{"error", "warn", true},
+		{"warn", "error", false},
+	}
+	for _, tt := range tests {
+		if got := LogAtLeast(tt.level, tt.min); got != tt.want {
+			t.Errorf("LogAtLeast(%s, %s) = %v, want %v", tt.level, tt.min, got, tt.want)
+		}
+	}
+	if ValidLogLevel("debug") {
+		t.Error("debug is not a site log level")
+	}
+}
@@ -2,10 +2,15 @@ package hosting
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 var (
@@ -34,20 +39,21 @@ func GetFileSystem() FileSystem {
 	return fs
 }
 
-// SiteExists checks if a site directory exists
+// SiteExists checks if a site directory exists. An alias exists while the
+// site it points at does.
 func SiteExists(subdomain string) bool {
+	return siteDeployed(ResolveSite(subdomain))
+}
+
+// siteDeployed checks the VFS for a site's own files, ignoring aliases
+func siteDeployed(subdomain string) bool {
 	// Check VFS first
 	exists, err := fs.Exists(subdomain, "index.html")
 	if err == nil && exists {
 		return true
 	}
-	// Check for main.js (serverless)
-	exists, err = fs.Exists(subdomain, "main.js")
-	if err == nil && exists {
-		return true
-	}
-	
-	return false
+	// Serverless sites may have no index.html
+	return HasServerless(subdomain)
 }
 
 // ValidateSubdomain checks if a subdomain name is valid
@@ -62,9 +68,14 @@ func ValidateSubdomain(subdomain string) error {
 		return fmt.Errorf("subdomain must contain only lowercase letters, numbers, and hyphens, and cannot start or end with a hyphen")
 	}
 
-	// Reserved subdomains
-	reserved := []string{"www", "api", "admin", "mail", "ftp", "smtp", "pop", "imap", "ns1", "ns2", "localhost"}
-	for _, r := range reserved {
+	// The server routes these hosts itself, so a site there could never be reached
+	for _, r := range routingSubdomains {
+		if subdomain == r {
+			return fmt.Errorf("'%s' is a reserved subdomain", subdomain)
+		}
+	}
+
+	for _, r := range reservedSubdomains {
 		if subdomain == r {
 			return fmt.Errorf("'%s' is a reserved subdomain", subdomain)
 		}
@@ -73,47 +84,240 @@ func ValidateSubdomain(subdomain string) error {
 	return nil
 }
 
-// ListSites returns all hosted sites
+// DefaultReservedSubdomains are refused as site names unless the config replaces the
+// list. It's empty: the dashboard and API are served on the main domain, never on a
+// subdomain, so names like "api" or "admin" don't collide with anything.
+var DefaultReservedSubdomains = []string{}
+
+// LegacyReservedSubdomains is the list reserved by default before host-based routing
+// made it unnecessary, for operators who want to keep refusing these names
+var LegacyReservedSubdomains = []string{"www", "api", "admin", "mail", "ftp", "smtp", "pop", "imap", "ns1", "ns2"}
+
+// routingSubdomains are refused whatever the config says: "localhost" is the dashboard host
+var routingSubdomains = []string{"localhost"}
+
+// reservedSubdomains is the operator's list, checked by ValidateSubdomain
+var reservedSubdomains = DefaultReservedSubdomains
+
+// SetReservedSubdomains replaces the reserved site names (hosting.reserved_subdomains);
+// nil restores the defaults and an empty list reserves only routing names.
+// Call it before serving requests.
+func SetReservedSubdomains(names []string) {
+	if names == nil {
+		reservedSubdomains = DefaultReservedSubdomains
+		return
+	}
+	reservedSubdomains = make([]string, len(names))
+	for i, name := range names {
+		reservedSubdomains[i] = strings.ToLower(strings.TrimSpace(name))
+	}
+}
+
+// ErrInvalidSort is returned by QuerySites for an unknown sort order
+var ErrInvalidSort = errors.New("invalid sort (must be name, size or modtime)")
+
+// siteSortOrders maps SiteQuery.Sort values to ORDER BY clauses
+var siteSortOrders = map[string]string{
+	"":        "last_mod DESC",
+	"modtime": "last_mod DESC",
+	"name":    "f.site_id ASC",
+	"size":    "total_size DESC, f.site_id ASC",
+}
+
+// SiteQuery filters and pages QuerySites
+type SiteQuery struct {
+	Search string // substring of the site name
+	Sort   string // "modtime" (default, newest first), "name" or "size" (largest first)
+	Limit  int    // 0 means no limit
+	Offset int
+}
+
+// ListSites returns all hosted sites, followed by aliases (AliasOf set)
 func ListSites() ([]SiteInfo, error) {
+	sites, _, err := QuerySites(SiteQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := ListSiteAliases()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range aliases {
+		createdAt := a.CreatedAt
+		sites = append(sites, SiteInfo{
+			Name:      a.Alias,
+			Path:      "vfs://" + a.SiteID,
+			Enabled:   IsSiteEnabled(a.SiteID),
+			CreatedAt: &createdAt,
+			AliasOf:   a.SiteID,
+		})
+	}
+
+	return sites, nil
+}
+
+// QuerySites returns one page of hosted sites and the total number matching q
+func QuerySites(q SiteQuery) ([]SiteInfo, int, error) {
 	if database == nil {
-		return nil, fmt.Errorf("hosting not initialized")
+		return nil, 0, fmt.Errorf("hosting not initialized")
+	}
+
+	orderBy, ok := siteSortOrders[q.Sort]
+	if !ok {
+		return nil, 0, ErrInvalidSort
+	}
+
+	where := "1=1"
+	var args []interface{}
+	if q.Search != "" {
+		where = `f.site_id LIKE ? ESCAPE '\'`
+		args = append(args, "%"+strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(q.Search))+"%")
+	}
+
+	var total int
+	if err := database.QueryRow("SELECT COUNT(DISTINCT f.site_id) FROM files f WHERE "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count sites: %w", err)
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
 	}
 
 	query := `
-		SELECT site_id, COUNT(*) as file_count, SUM(size_bytes) as total_size, MAX(updated_at) as last_mod
-		FROM files
-		GROUP BY site_id
-		ORDER BY last_mod DESC
+		SELECT f.site_id, COUNT(*) as file_count, SUM(f.size_bytes) as total_size, MAX(f.updated_at) as last_mod,
+			COALESCE(s.enabled, 1) as enabled, s.created_at, s.last_deployed_at,
+			COALESCE(s.deploy_count, 0) as deploy_count, COALESCE(s.owner, '') as owner,
+			COALESCE(s.preview, 0) as preview, s.expires_at
+		FROM files f
+		LEFT JOIN sites s ON s.site_id = f.site_id
+		WHERE ` + where + `
+		GROUP BY f.site_id
+		ORDER BY ` + orderBy + `
+		LIMIT ? OFFSET ?
 	`
 
-	rows, err := database.Query(query)
+	rows, err := database.Query(query, append(args, limit, q.Offset)...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query sites: %w", err)
+		return nil, 0, fmt.Errorf("failed to query sites: %w", err)
 	}
 	defer rows.Close()
 
 	var sites []SiteInfo
 	for rows.Next() {
 		var site SiteInfo
-		var lastMod time.Time
-		if err := rows.Scan(&site.Name, &site.FileCount, &site.SizeBytes, &lastMod); err != nil {
-			continue
+		// MAX() drops the column's DATETIME type, so the driver returns text
+		var lastMod sql.NullString
+		var createdAt, lastDeployed, expiresAt sql.NullTime
+		if err := rows.Scan(&site.Name, &site.FileCount, &site.SizeBytes, &lastMod, &site.Enabled,
+			&createdAt, &lastDeployed, &site.DeployCount, &site.Owner, &site.Preview, &expiresAt); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan site: %w", err)
+		}
+		if lastMod.Valid {
+			if site.ModTime, err = time.Parse(sqliteTimeFormat, lastMod.String); err != nil {
+				return nil, 0, fmt.Errorf("failed to parse modification time of site %s: %w", site.Name, err)
+			}
 		}
-		site.ModTime = lastMod
 		site.Path = "vfs://" + site.Name
+		if createdAt.Valid {
+			site.CreatedAt = &createdAt.Time
+		}
+		if lastDeployed.Valid {
+			site.LastDeployedAt = &lastDeployed.Time
+		}
+		if expiresAt.Valid {
+			site.ExpiresAt = &expiresAt.Time
+		}
 		sites = append(sites, site)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read sites: %w", err)
+	}
 
-	return sites, nil
+	return sites, total, nil
+}
+
+// SiteUsage is one site's share of VFS storage
+type SiteUsage struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+	FileCount int    `json:"file_count"`
+	OverQuota bool   `json:"over_quota"`
+}
+
+// StorageUsage summarizes VFS storage, largest sites first
+type StorageUsage struct {
+	TotalBytes int64       `json:"total_bytes"`
+	Sites      []SiteUsage `json:"sites"`
+}
+
+// Usage totals VFS storage per site. Sites larger than siteQuota are flagged;
+// a zero siteQuota means no quota.
+func Usage(siteQuota int64) (*StorageUsage, error) {
+	sites, _, err := QuerySites(SiteQuery{Sort: "size"})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &StorageUsage{Sites: []SiteUsage{}}
+	for _, site := range sites {
+		usage.TotalBytes += site.SizeBytes
+		usage.Sites = append(usage.Sites, SiteUsage{
+			Name:      site.Name,
+			SizeBytes: site.SizeBytes,
+			FileCount: site.FileCount,
+			OverQuota: siteQuota > 0 && site.SizeBytes > siteQuota,
+		})
+	}
+	return usage, nil
 }
 
 // SiteInfo contains information about a hosted site
 type SiteInfo struct {
-	Name      string
-	Path      string
-	FileCount int
-	SizeBytes int64
-	ModTime   interface{} // time.Time
+	Name           string
+	Path           string
+	FileCount      int
+	SizeBytes      int64
+	ModTime        interface{} // time.Time
+	Enabled        bool
+	CreatedAt      *time.Time
+	LastDeployedAt *time.Time
+	DeployCount    int
+	Owner          string
+	AliasOf        string // set for aliases: the site whose files they serve
+	Preview        bool
+	ExpiresAt      *time.Time // when a preview is deleted; nil keeps it
+}
+
+// RecordSiteDeploy updates a site's metadata after a deploy.
+// The first deploy creates the row and records the deploying key as owner.
+// keyID 0 (a dashboard deploy) leaves the site unclaimed by any key.
+func RecordSiteDeploy(subdomain string, keyID int64, keyName string) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	var ownerKeyID interface{}
+	if keyID != 0 {
+		ownerKeyID = keyID
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO sites (site_id, owner_key_id, owner, last_deployed_at, deploy_count)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, 1)
+		ON CONFLICT(site_id) DO UPDATE SET
+			owner_key_id = COALESCE(sites.owner_key_id, excluded.owner_key_id),
+			owner = COALESCE(sites.owner, excluded.owner),
+			last_deployed_at = CURRENT_TIMESTAMP,
+			deploy_count = sites.deploy_count + 1,
+			updated_at = CURRENT_TIMESTAMP
+	`, subdomain, ownerKeyID, keyName)
+	if err != nil {
+		return fmt.Errorf("failed to record site deploy: %w", err)
+	}
+
+	return nil
 }
 
 // CreateSite creates a new site (placeholder for VFS)
@@ -126,6 +330,145 @@ func DeleteSite(subdomain string) error {
 	// Clean up WebSocket hub
 	RemoveHub(subdomain)
 
-	// Delete from VFS
-	return fs.DeleteSite(subdomain)
+	if database == nil {
+		return fs.DeleteSite(subdomain)
+	}
+
+	// Files, metadata, aliases, env vars, KV data and logs go together
+	return deleteSiteData(subdomain)
+}
+
+// ListSiteFiles lists a site's files in path order, only those under prefix if
+// it's set. A leading slash on prefix is ignored.
+func ListSiteFiles(subdomain, prefix string) ([]FileInfo, error) {
+	files, err := fs.ListFiles(subdomain)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix = strings.TrimPrefix(prefix, "/")
+	matched := make([]FileInfo, 0, len(files))
+	for _, f := range files {
+		if strings.HasPrefix(f.Path, prefix) {
+			matched = append(matched, f)
+		}
+	}
+	return matched, nil
+}
+
+// ErrInvalidPath is returned for file paths that are empty or escape the site
+var ErrInvalidPath = errors.New("invalid file path")
+
+// ErrLastEntryPoint is returned when deleting a file would leave a site with
+// neither index.html nor main.js
+var ErrLastEntryPoint = errors.New("a site needs index.html or main.js; delete the site instead")
+
+// cleanSitePath normalizes a file path within a site, applying the same
+// traversal rules as deploys. A leading slash is allowed.
+func cleanSitePath(path string) (string, error) {
+	path = strings.TrimPrefix(path, "/")
+	if path == "" || strings.HasSuffix(path, "/") || strings.Contains(path, "\\") {
+		return "", ErrInvalidPath
+	}
+	cleanPath := filepath.ToSlash(filepath.Clean(path))
+	if cleanPath == "." || cleanPath == ".." || strings.HasPrefix(cleanPath, "../") || strings.HasPrefix(cleanPath, "/") {
+		return "", ErrInvalidPath
+	}
+	return cleanPath, nil
+}
+
+// PutSiteFile adds or replaces one file in an existing site and returns its cleaned path
+func PutSiteFile(subdomain, path string, content io.Reader, size int64) (string, error) {
+	cleanPath, err := cleanSitePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	mimeType, content, err := detectMimeType(cleanPath, content)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	if err := fs.WriteFile(subdomain, cleanPath, content, size, mimeType); err != nil {
+		return "", err
+	}
+
+	touchSite(subdomain)
+	return cleanPath, nil
+}
+
+// DeleteSiteFile removes one file from a site and returns its cleaned path.
+// The site's last index.html or main.js can't be removed this way.
+func DeleteSiteFile(subdomain, path string) (string, error) {
+	cleanPath, err := cleanSitePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	var other string
+	switch cleanPath {
+	case "index.html":
+		other = "main.js"
+	case "main.js":
+		other = "index.html"
+	}
+	if other != "" {
+		if exists, err := fs.Exists(subdomain, other); err != nil {
+			return "", err
+		} else if !exists {
+			return "", ErrLastEntryPoint
+		}
+	}
+
+	if err := fs.DeleteFile(subdomain, cleanPath); err != nil {
+		return "", err
+	}
+
+	touchSite(subdomain)
+	return cleanPath, nil
+}
+
+// touchSite records that a site's files changed outside a deploy
+func touchSite(subdomain string) {
+	if database == nil {
+		return
+	}
+	_, err := database.Exec(`
+		INSERT INTO sites (site_id) VALUES (?)
+		ON CONFLICT(site_id) DO UPDATE SET updated_at = CURRENT_TIMESTAMP
+	`, subdomain)
+	if err != nil {
+		logging.Warnf("Failed to update site %s: %v", subdomain, err)
+	}
+}
+
+// SetSiteEnabled turns a site on or off without touching its files
+func SetSiteEnabled(subdomain string, enabled bool) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO sites (site_id, enabled) VALUES (?, ?)
+		ON CONFLICT(site_id) DO UPDATE SET enabled = excluded.enabled, updated_at = CURRENT_TIMESTAMP
+	`, subdomain, enabled)
+	if err != nil {
+		return fmt.Errorf("failed to update site: %w", err)
+	}
+
+	return nil
+}
+
+// IsSiteEnabled reports whether a site is serving traffic (sites are enabled unless switched off)
+func IsSiteEnabled(subdomain string) bool {
+	if database == nil {
+		return true
+	}
+
+	var enabled bool
+	err := database.QueryRow("SELECT enabled FROM sites WHERE site_id = ?", subdomain).Scan(&enabled)
+	if err != nil {
+		return true
+	}
+
+	return enabled
 }
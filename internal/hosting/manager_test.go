@@ -1,8 +1,8 @@
 package hosting
 
 import (
-	"os"
-	"path/filepath"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -37,46 +37,65 @@ func TestValidateSubdomain(t *testing.T) {
 	}
 }
 
-func TestInitAndGetSitesDir(t *testing.T) {
-	// Create temp directory for test
-	tmpDir, err := os.MkdirTemp("", "cc-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
+func TestValidateSubdomain_ReservedList(t *testing.T) {
+	defer SetReservedSubdomains(nil)
 
-	// Initialize hosting
-	if err := Init(tmpDir); err != nil {
-		t.Fatalf("Init() failed: %v", err)
+	// By default only names the server routes itself are refused; the dashboard
+	// lives on the main domain, so "api" and "admin" are ordinary site names
+	defaults := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"api", false},
+		{"admin", false},
+		{"www", false},
+		{"mail", false},
+		{"localhost", true},
+		{"LocalHost", true},
+	}
+	for _, tt := range defaults {
+		if err := ValidateSubdomain(tt.input); (err != nil) != tt.wantErr {
+			t.Errorf("by default, ValidateSubdomain(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
 	}
 
-	// Check sites directory was created
-	sitesDir := GetSitesDir()
-	if sitesDir == "" {
-		t.Error("GetSitesDir() returned empty string")
+	// Operators can restore the old blanket list
+	SetReservedSubdomains(LegacyReservedSubdomains)
+	for _, name := range []string{"www", "api", "admin", "ns1"} {
+		if err := ValidateSubdomain(name); err == nil {
+			t.Errorf("with the legacy list, ValidateSubdomain(%q) should be rejected", name)
+		}
 	}
 
-	expectedPath := filepath.Join(tmpDir, "sites")
-	if sitesDir != expectedPath {
-		t.Errorf("GetSitesDir() = %q, want %q", sitesDir, expectedPath)
+	// A custom list replaces the defaults
+	SetReservedSubdomains([]string{"Status", " blog "})
+	tests := []struct {
+		input   string
+		wantErr bool
+	}{
+		{"status", true},
+		{"blog", true},
+		{"api", false},
+		{"admin", false},
+		{"localhost", true}, // routes to the dashboard, so always refused
+	}
+	for _, tt := range tests {
+		if err := ValidateSubdomain(tt.input); (err != nil) != tt.wantErr {
+			t.Errorf("with custom list, ValidateSubdomain(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
 	}
 
-	// Check directory exists
-	if _, err := os.Stat(sitesDir); os.IsNotExist(err) {
-		t.Error("Sites directory was not created")
+	// nil restores the defaults
+	SetReservedSubdomains(nil)
+	if err := ValidateSubdomain("status"); err != nil {
+		t.Errorf("after reset, ValidateSubdomain(status) error = %v", err)
 	}
 }
 
 func TestSiteOperations(t *testing.T) {
-	// Create temp directory for test
-	tmpDir, err := os.MkdirTemp("", "cc-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	// Initialize hosting
-	if err := Init(tmpDir); err != nil {
+	db := setupTestDB(t)
+	defer db.Close()
+	if err := Init(db); err != nil {
 		t.Fatalf("Init() failed: %v", err)
 	}
 
@@ -85,19 +104,15 @@ func TestSiteOperations(t *testing.T) {
 	if err := CreateSite(siteName); err != nil {
 		t.Fatalf("CreateSite() failed: %v", err)
 	}
+	if err := GetFileSystem().WriteFile(siteName, "index.html", strings.NewReader("home"), 4, "text/html"); err != nil {
+		t.Fatalf("WriteFile() failed: %v", err)
+	}
 
 	// Check site exists
 	if !SiteExists(siteName) {
 		t.Error("SiteExists() returned false for created site")
 	}
 
-	// Check site directory
-	siteDir := GetSiteDir(siteName)
-	expectedDir := filepath.Join(tmpDir, "sites", siteName)
-	if siteDir != expectedDir {
-		t.Errorf("GetSiteDir() = %q, want %q", siteDir, expectedDir)
-	}
-
 	// Test listing sites
 	sites, err := ListSites()
 	if err != nil {
@@ -119,15 +134,9 @@ func TestSiteOperations(t *testing.T) {
 }
 
 func TestSiteExistsForNonexistent(t *testing.T) {
-	// Create temp directory for test
-	tmpDir, err := os.MkdirTemp("", "cc-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	// Initialize hosting
-	if err := Init(tmpDir); err != nil {
+	db := setupTestDB(t)
+	defer db.Close()
+	if err := Init(db); err != nil {
 		t.Fatalf("Init() failed: %v", err)
 	}
 
@@ -137,40 +146,127 @@ func TestSiteExistsForNonexistent(t *testing.T) {
 }
 
 func TestHasServerless(t *testing.T) {
-	// Create temp directory for test
-	tmpDir, err := os.MkdirTemp("", "cc-test-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
+	db := setupTestDB(t)
+	defer db.Close()
+	if err := Init(db); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	fs := GetFileSystem()
+
+	// A site without main.js
+	fs.WriteFile("static", "index.html", strings.NewReader("home"), 4, "text/html")
+	if HasServerless("static") {
+		t.Error("HasServerless() returned true for site without main.js")
+	}
+
+	// A site with main.js
+	fs.WriteFile("serverless", "main.js", strings.NewReader("res.send('hello');"), 18, "application/javascript")
+	if !HasServerless("serverless") {
+		t.Error("HasServerless() returned false for site with main.js")
 	}
-	defer os.RemoveAll(tmpDir)
 
-	// Initialize hosting
-	if err := Init(tmpDir); err != nil {
+	if HasServerless("nonexistent") {
+		t.Error("HasServerless() returned true for nonexistent site")
+	}
+}
+
+func TestListSiteFiles(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	if err := Init(db); err != nil {
 		t.Fatalf("Init() failed: %v", err)
 	}
+	fs := GetFileSystem()
 
-	// Create a site without main.js
-	if err := CreateSite("static"); err != nil {
-		t.Fatalf("CreateSite() failed: %v", err)
+	fs.WriteFile("docs", "index.html", strings.NewReader("home"), 4, "text/html")
+	fs.WriteFile("docs", "assets/app.css", strings.NewReader("body{}"), 6, "text/css")
+	fs.WriteFile("docs", "assets/img/logo.svg", strings.NewReader("<svg/>"), 6, "image/svg+xml")
+	fs.WriteFile("other", "assets/x.css", strings.NewReader("x"), 1, "text/css")
+
+	files, err := ListSiteFiles("docs", "")
+	if err != nil {
+		t.Fatalf("ListSiteFiles() failed: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("ListSiteFiles() returned %d files, want 3", len(files))
+	}
+	if files[0].Path != "assets/app.css" || files[0].MimeType != "text/css" || files[0].Size != 6 {
+		t.Errorf("first file = %+v, want assets/app.css text/css 6 bytes", files[0])
 	}
 
-	staticDir := GetSiteDir("static")
-	if HasServerless(staticDir) {
-		t.Error("HasServerless() returned true for site without main.js")
+	files, err = ListSiteFiles("docs", "/assets/img/")
+	if err != nil {
+		t.Fatalf("ListSiteFiles() with prefix failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path != "assets/img/logo.svg" {
+		t.Errorf("ListSiteFiles(prefix) = %+v, want only assets/img/logo.svg", files)
 	}
 
-	// Create a site with main.js
-	if err := CreateSite("serverless"); err != nil {
-		t.Fatalf("CreateSite() failed: %v", err)
+	files, err = ListSiteFiles("docs", "missing/")
+	if err != nil || files == nil || len(files) != 0 {
+		t.Errorf("ListSiteFiles(no match) = %v, %v; want empty list", files, err)
 	}
+}
 
-	serverlessDir := GetSiteDir("serverless")
-	mainJS := filepath.Join(serverlessDir, "main.js")
-	if err := os.WriteFile(mainJS, []byte("res.send('hello');"), 0644); err != nil {
-		t.Fatalf("Failed to write main.js: %v", err)
+func TestCleanSitePath(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"index.html", "index.html", false},
+		{"/assets/app.css", "assets/app.css", false},
+		{"assets/./img//logo.svg", "assets/img/logo.svg", false},
+		{"assets/../index.html", "index.html", false},
+		{"", "", true},
+		{"/", "", true},
+		{"assets/", "", true},
+		{"../etc/passwd", "", true},
+		{"assets/../../secret", "", true},
+		{"//etc/passwd", "", true},
+		{"..\\secret", "", true},
 	}
 
-	if !HasServerless(serverlessDir) {
-		t.Error("HasServerless() returned false for site with main.js")
+	for _, tt := range tests {
+		got, err := cleanSitePath(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("cleanSitePath(%q) = %q, %v; want %q, wantErr %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPutAndDeleteSiteFile(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	if err := Init(db); err != nil {
+		t.Fatalf("Init() failed: %v", err)
+	}
+	GetFileSystem().WriteFile("blog", "index.html", strings.NewReader("home"), 4, "text/html")
+
+	path, err := PutSiteFile("blog", "/css/site.css", strings.NewReader("body{}"), 6)
+	if err != nil || path != "css/site.css" {
+		t.Fatalf("PutSiteFile() = %q, %v; want css/site.css", path, err)
+	}
+	file, err := GetFileSystem().ReadFile("blog", "css/site.css")
+	if err != nil {
+		t.Fatalf("ReadFile() failed: %v", err)
+	}
+	file.Content.Close()
+	if !strings.HasPrefix(file.MimeType, "text/css") {
+		t.Errorf("MimeType = %q, want text/css", file.MimeType)
+	}
+
+	if _, err := PutSiteFile("blog", "../other/index.html", strings.NewReader("x"), 1); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("PutSiteFile(traversal) error = %v, want ErrInvalidPath", err)
+	}
+
+	if _, err := DeleteSiteFile("blog", "css/site.css"); err != nil {
+		t.Fatalf("DeleteSiteFile() failed: %v", err)
+	}
+	if _, err := DeleteSiteFile("blog", "css/site.css"); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("second DeleteSiteFile() error = %v, want ErrFileNotFound", err)
+	}
+	if _, err := DeleteSiteFile("blog", "index.html"); !errors.Is(err, ErrLastEntryPoint) {
+		t.Errorf("DeleteSiteFile(index.html) error = %v, want ErrLastEntryPoint", err)
 	}
 }
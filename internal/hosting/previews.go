@@ -0,0 +1,157 @@
+package hosting
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jikku/command-center/internal/logging"
+)
+
+const (
+	// previewPrefix starts every generated preview subdomain
+	previewPrefix = "pr-"
+
+	// MaxPreviewTTL is the longest lifetime a preview deploy may ask for
+	MaxPreviewTTL = 90 * 24 * time.Hour
+
+	// PreviewCleanupInterval is how often expired previews are deleted
+	PreviewCleanupInterval = time.Minute
+)
+
+// NewPreviewSubdomain picks an unused subdomain for a preview deploy, e.g. "pr-3fa9c1"
+func NewPreviewSubdomain() (string, error) {
+	if database == nil {
+		return "", fmt.Errorf("hosting not initialized")
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		b := make([]byte, 3)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to generate preview name: %w", err)
+		}
+		name := previewPrefix + hex.EncodeToString(b)
+
+		var taken int
+		err := database.QueryRow(`
+			SELECT (SELECT COUNT(*) FROM sites WHERE site_id = ?) + (SELECT COUNT(*) FROM site_aliases WHERE alias = ?)
+		`, name, name).Scan(&taken)
+		if err != nil {
+			return "", fmt.Errorf("failed to check preview name: %w", err)
+		}
+		if taken == 0 && !siteDeployed(name) {
+			return name, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to find a free preview name")
+}
+
+// MarkPreview records a deployed site as a preview. A zero expiresAt keeps it
+// until it's deleted by hand.
+func MarkPreview(subdomain string, expiresAt time.Time) error {
+	if database == nil {
+		return fmt.Errorf("hosting not initialized")
+	}
+
+	var expires interface{}
+	if !expiresAt.IsZero() {
+		expires = expiresAt.UTC().Format(sqliteTimeFormat)
+	}
+
+	_, err := database.Exec(`
+		INSERT INTO sites (site_id, preview, expires_at) VALUES (?, 1, ?)
+		ON CONFLICT(site_id) DO UPDATE SET preview = 1, expires_at = excluded.expires_at, updated_at = CURRENT_TIMESTAMP
+	`, subdomain, expires)
+	if err != nil {
+		return fmt.Errorf("failed to record preview: %w", err)
+	}
+
+	return nil
+}
+
+// PreviewExpiry reports whether subdomain is a preview and when it expires
+// (nil when it doesn't)
+func PreviewExpiry(subdomain string) (*time.Time, bool) {
+	if database == nil {
+		return nil, false
+	}
+
+	var preview bool
+	var expiresAt sql.NullTime
+	err := database.QueryRow("SELECT preview, expires_at FROM sites WHERE site_id = ?", subdomain).Scan(&preview, &expiresAt)
+	if err != nil || !preview {
+		return nil, false
+	}
+
+	if !expiresAt.Valid {
+		return nil, true
+	}
+	return &expiresAt.Time, true
+}
+
+// DeleteExpiredPreviews deletes previews whose expiry is at or before now and
+// returns their names
+func DeleteExpiredPreviews(now time.Time) ([]string, error) {
+	if database == nil {
+		return nil, fmt.Errorf("hosting not initialized")
+	}
+
+	rows, err := database.Query(
+		"SELECT site_id FROM sites WHERE preview = 1 AND expires_at IS NOT NULL AND expires_at <= ?",
+		now.UTC().Format(sqliteTimeFormat),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired previews: %w", err)
+	}
+	var expired []string
+	for rows.Next() {
+		var siteID string
+		if err := rows.Scan(&siteID); err == nil {
+			expired = append(expired, siteID)
+		}
+	}
+	rows.Close()
+
+	deleted := []string{}
+	for _, siteID := range expired {
+		if err := DeleteSite(siteID); err != nil {
+			logging.Errorf("Failed to delete expired preview %s: %v", siteID, err)
+			continue
+		}
+		deleted = append(deleted, siteID)
+	}
+
+	return deleted, nil
+}
+
+// StartPreviewCleanup deletes expired previews every interval until the
+// returned stop function is called
+func StartPreviewCleanup(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				deleted, err := DeleteExpiredPreviews(now)
+				if err != nil {
+					logging.Errorf("Preview cleanup failed: %v", err)
+					continue
+				}
+				for _, siteID := range deleted {
+					logging.Infof("Deleted expired preview %s", siteID)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,60 @@
+package hosting
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPreviews(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	Init(db)
+	fs := GetFileSystem()
+
+	name, err := NewPreviewSubdomain()
+	if err != nil {
+		t.Fatalf("NewPreviewSubdomain failed: %v", err)
+	}
+	if !strings.HasPrefix(name, previewPrefix) || ValidateSubdomain(name) != nil {
+		t.Errorf("NewPreviewSubdomain() = %q, want a valid pr- subdomain", name)
+	}
+
+	now := time.Now()
+	for _, site := range []string{"pr-old", "pr-new", "pr-keep", "blog"} {
+		fs.WriteFile(site, "index.html", strings.NewReader("hi"), 2, "text/html")
+	}
+	MarkPreview("pr-old", now.Add(-time.Minute))
+	MarkPreview("pr-new", now.Add(time.Hour))
+	MarkPreview("pr-keep", time.Time{})
+
+	if expiresAt, ok := PreviewExpiry("pr-new"); !ok || expiresAt == nil {
+		t.Errorf("PreviewExpiry(pr-new) = %v, %v; want an expiry", expiresAt, ok)
+	}
+	if expiresAt, ok := PreviewExpiry("pr-keep"); !ok || expiresAt != nil {
+		t.Errorf("PreviewExpiry(pr-keep) = %v, %v; want a preview without expiry", expiresAt, ok)
+	}
+	if _, ok := PreviewExpiry("blog"); ok {
+		t.Error("PreviewExpiry(blog) reported a regular site as a preview")
+	}
+
+	deleted, err := DeleteExpiredPreviews(now)
+	if err != nil {
+		t.Fatalf("DeleteExpiredPreviews failed: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0] != "pr-old" {
+		t.Errorf("DeleteExpiredPreviews deleted %v, want [pr-old]", deleted)
+	}
+	for site, want := range map[string]bool{"pr-old": false, "pr-new": true, "pr-keep": true, "blog": true} {
+		if SiteExists(site) != want {
+			t.Errorf("SiteExists(%s) = %v, want %v", site, !want, want)
+		}
+	}
+
+	sites, _ := ListSites()
+	for _, s := range sites {
+		if s.Name == "pr-new" && (!s.Preview || s.ExpiresAt == nil) {
+			t.Errorf("ListSites reported pr-new as %+v", s)
+		}
+	}
+}
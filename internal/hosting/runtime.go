@@ -1,36 +1,411 @@
 package hosting
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"runtime/metrics"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/jikku/command-center/internal/logging"
 )
 
-// RunServerless executes JavaScript if main.js exists in the site
-// Returns true if serverless was executed, false if should fall back to static
-func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sql.DB, subdomain string) bool {
+// DefaultMaxConcurrentVMs caps simultaneous serverless executions when unconfigured
+const DefaultMaxConcurrentVMs = 32
+
+// vmQueueWait is how long a request waits for a free VM slot before getting a 503
+const vmQueueWait = 250 * time.Millisecond
+
+// vmPool is a semaphore bounding how many JavaScript VMs run at once
+type vmPool struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func newVMPool(size int, wait time.Duration) *vmPool {
+	return &vmPool{slots: make(chan struct{}, size), wait: wait}
+}
+
+// acquire takes a slot, queueing for up to p.wait. It reports false if the pool
+// stayed full or ctx was cancelled.
+func (p *vmPool) acquire(ctx context.Context) bool {
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(p.wait)
+	defer timer.Stop()
+	select {
+	case p.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees a slot taken by acquire
+func (p *vmPool) release() {
+	<-p.slots
+}
+
+// memSampleInterval is how often a running script's memory use is checked
+const memSampleInterval = 10 * time.Millisecond
+
+// errMemoryLimit is the interrupt value for scripts over the memory limit
+var errMemoryLimit = errors.New("memory limit exceeded")
+
+// vmMemoryLimit is the heap growth a single script may cause before it's interrupted
+var vmMemoryLimit = DefaultLimits().MaxMemoryBytes
+
+// SetVMMemoryLimit sets the per-script memory limit in bytes; 0 uses the default.
+// Call it before serving requests.
+func SetVMMemoryLimit(n int64) {
+	if n == 0 {
+		n = DefaultLimits().MaxMemoryBytes
+	}
+	vmMemoryLimit = n
+}
+
+// heapBytes returns the bytes held by live and not-yet-swept heap objects. Unlike
+// runtime.ReadMemStats it doesn't stop the world, so it's cheap to sample often.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// watchMemory interrupts vm with errMemoryLimit once the heap has grown more than
+// limit bytes since the call, sampling until stop is closed. Goja can't account
+// memory per VM, so this is best effort: the heap is shared with the rest of the
+// process, and a burst can overshoot between samples. It reports whether it fired.
+func watchMemory(vm *goja.Runtime, limit int64, stop <-chan struct{}) *atomic.Bool {
+	exceeded := &atomic.Bool{}
+	baseline := heapBytes()
+
+	go func() {
+		ticker := time.NewTicker(memSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if now := heapBytes(); now > baseline && int64(now-baseline) > limit {
+					exceeded.Store(true)
+					vm.Interrupt(errMemoryLimit)
+					return
+				}
+			}
+		}
+	}()
+
+	return exceeded
+}
+
+// vms is the global serverless execution pool
+var vms = newVMPool(DefaultMaxConcurrentVMs, vmQueueWait)
+
+// SetMaxConcurrentVMs resizes the serverless execution pool; 0 uses the default.
+// Call it before serving requests.
+func SetMaxConcurrentVMs(n int) {
+	if n == 0 {
+		n = DefaultMaxConcurrentVMs
+	}
+	vms = newVMPool(n, vmQueueWait)
+}
+
+// Host capabilities a serverless script can be denied
+const (
+	CapabilityDB      = "db"      // the KV store
+	CapabilityFetch   = "fetch"   // outbound HTTP
+	CapabilitySocket  = "socket"  // WebSocket broadcast
+	CapabilityProcess = "process" // the site's environment variables
+)
+
+var capabilities = map[string]bool{CapabilityDB: true, CapabilityFetch: true, CapabilitySocket: true, CapabilityProcess: true}
+
+// disabledCapabilities holds what's turned off for every site and, by site ID, for one site
+var (
+	disabledCapabilities     = map[string]bool{}
+	siteDisabledCapabilities = map[string]map[string]bool{}
+)
+
+// SetDisabledCapabilities turns off host capabilities for every site and per
+// site (hosting.disabled_capabilities, hosting.site_disabled_capabilities).
+// A disabled global throws when called. Call it before serving requests.
+func SetDisabledCapabilities(server []string, sites map[string][]string) error {
+	toSet := func(names []string) (map[string]bool, error) {
+		set := make(map[string]bool, len(names))
+		for _, name := range names {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !capabilities[name] {
+				return nil, fmt.Errorf("unknown capability %q (must be db, fetch, socket or process)", name)
+			}
+			set[name] = true
+		}
+		return set, nil
+	}
+
+	serverSet, err := toSet(server)
+	if err != nil {
+		return err
+	}
+	siteSets := make(map[string]map[string]bool, len(sites))
+	for siteID, names := range sites {
+		set, err := toSet(names)
+		if err != nil {
+			return fmt.Errorf("site %s: %w", siteID, err)
+		}
+		siteSets[strings.ToLower(siteID)] = set
+	}
+
+	disabledCapabilities, siteDisabledCapabilities = serverSet, siteSets
+	return nil
+}
+
+// capabilityEnabled reports whether siteID's scripts may use capability
+func capabilityEnabled(siteID, capability string) bool {
+	return !disabledCapabilities[capability] && !siteDisabledCapabilities[siteID][capability]
+}
+
+// disabledCall stands in for a host function whose capability is turned off
+func disabledCall(vm *goja.Runtime, name, capability string) func(goja.FunctionCall) goja.Value {
+	return func(goja.FunctionCall) goja.Value {
+		panic(vm.NewGoError(fmt.Errorf("%s is disabled for this site (capability %q)", name, capability)))
+	}
+}
+
+// Defaults for outbound fetch() limits
+const (
+	DefaultFetchMaxRequests   = 20      // fetch() calls per invocation
+	DefaultFetchMaxBytes      = 5 << 20 // response bytes downloaded per invocation
+	DefaultFetchRatePerMinute = 300     // fetch() calls per site per minute
+)
+
+// fetchMaxBytesPerResponse caps a single response body, within the invocation budget
+const fetchMaxBytesPerResponse = 1 << 20
+
+var (
+	fetchMaxRequests   = DefaultFetchMaxRequests
+	fetchMaxBytes      = int64(DefaultFetchMaxBytes)
+	fetchRatePerMinute = DefaultFetchRatePerMinute
+)
+
+// SetFetchLimits sets how many fetch() calls and downloaded bytes one script
+// invocation may use, and how many calls a site may make per minute across
+// invocations; 0 uses the default. Call it before serving requests.
+func SetFetchLimits(maxRequests int, maxBytes int64, ratePerMinute int) {
+	if maxRequests == 0 {
+		maxRequests = DefaultFetchMaxRequests
+	}
+	if maxBytes == 0 {
+		maxBytes = DefaultFetchMaxBytes
+	}
+	if ratePerMinute == 0 {
+		ratePerMinute = DefaultFetchRatePerMinute
+	}
+	fetchMaxRequests, fetchMaxBytes, fetchRatePerMinute = maxRequests, maxBytes, ratePerMinute
+	fetchRate.reset()
+}
+
+// fetchLimiter counts each site's fetch() calls over the last minute
+type fetchLimiter struct {
+	mu    sync.Mutex
+	calls map[string][]time.Time
+}
+
+var fetchRate = &fetchLimiter{calls: make(map[string][]time.Time)}
+
+// allow records a call for siteID and reports whether it's within limit per minute
+func (fl *fetchLimiter) allow(siteID string, limit int) bool {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	cutoff := time.Now().Add(-time.Minute)
+	recent := fl.calls[siteID][:0]
+	for _, t := range fl.calls[siteID] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= limit {
+		fl.calls[siteID] = recent
+		return false
+	}
+	fl.calls[siteID] = append(recent, time.Now())
+	return true
+}
+
+func (fl *fetchLimiter) reset() {
+	fl.mu.Lock()
+	fl.calls = make(map[string][]time.Time)
+	fl.mu.Unlock()
+}
+
+// kvSetSQL upserts one key for db.set(); scripts call it often, so it's prepared once
+const kvSetSQL = `
+	INSERT INTO kv_store (site_id, key, value, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT(site_id, key) DO UPDATE SET value = ?, updated_at = CURRENT_TIMESTAMP
+`
+
+// prepareStmt returns a cached prepared statement, when one is available
+var prepareStmt func(query string) (*sql.Stmt, error)
+
+// SetStatementCache makes hot queries use prepared statements from prepare
+// (database.Stmt), which must be on the same database as Init's.
+// Call it before serving requests.
+func SetStatementCache(prepare func(query string) (*sql.Stmt, error)) {
+	prepareStmt = prepare
+}
+
+// execPrepared runs query through the statement cache, or directly on db without one
+func execPrepared(ctx context.Context, db *sql.DB, query string, args ...interface{}) error {
+	if prepareStmt != nil {
+		stmt, err := prepareStmt(query)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.ExecContext(ctx, args...)
+		return err
+	}
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// RunServerless executes JavaScript if main.js exists in the site.
+// Returns false only when the site has no main.js and static files should be
+// served instead; errors loading main.js are answered here, never by static fallback.
+func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sql.DB) bool {
+	return runServerless(w, r, siteID, db, nil)
+}
+
+// ErrNoServerless is returned by InvokeServerless for sites without main.js
+var ErrNoServerless = errors.New("site has no main.js")
+
+// InvokeResult is the captured outcome of a test invocation
+type InvokeResult struct {
+	Status     int               `json:"status"`
+	Headers    map[string]string `json:"headers"`
+	Body       string            `json:"body"`
+	Console    []LogEntry        `json:"console"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// InvokeServerless runs a site's main.js against r like a real request, but
+// captures the response and console output instead of sending or storing them
+func InvokeServerless(r *http.Request, siteID string, db *sql.DB) (*InvokeResult, error) {
+	var mu sync.Mutex
+	console := []LogEntry{}
+	capture := func(entry LogEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		console = append(console, entry)
+	}
+
+	rec := &captureWriter{header: make(http.Header), status: http.StatusOK}
+	start := time.Now()
+	if !runServerless(rec, r, siteID, db, capture) {
+		return nil, ErrNoServerless
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	result := &InvokeResult{
+		Status:     rec.status,
+		Headers:    make(map[string]string),
+		Body:       rec.body.String(),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	for k, v := range rec.header {
+		result.Headers[k] = strings.Join(v, ", ")
+	}
+	mu.Lock()
+	result.Console = append(result.Console, console...)
+	mu.Unlock()
+
+	return result, nil
+}
+
+// captureWriter is an http.ResponseWriter that keeps the response in memory
+type captureWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (c *captureWriter) Header() http.Header { return c.header }
+
+func (c *captureWriter) WriteHeader(status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.wroteHeader {
+		c.status = status
+		c.wroteHeader = true
+	}
+}
+
+func (c *captureWriter) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wroteHeader = true
+	return c.body.Write(p)
+}
+
+// runServerless implements RunServerless. If capture is set, console output goes
+// to it instead of site_logs and live log streams.
+func runServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sql.DB, capture func(LogEntry)) bool {
 	// Check if main.js exists in VFS
 	file, err := fs.ReadFile(siteID, "main.js")
-	if err != nil {
+	if errors.Is(err, ErrFileNotFound) {
 		return false // No main.js, serve static files
 	}
+	if err != nil {
+		logging.Errorf("Failed to load main.js for %s: %v", siteID, err)
+		http.Error(w, "Serverless function unavailable", http.StatusInternalServerError)
+		return true
+	}
 	defer file.Content.Close()
-	
+
 	codeBytes, err := io.ReadAll(file.Content)
 	if err != nil {
-		// Log error?
-		return false
+		logging.Errorf("Failed to read main.js for %s: %v", siteID, err)
+		http.Error(w, "Serverless function unavailable", http.StatusInternalServerError)
+		return true
 	}
 	code := string(codeBytes)
 
+	// Bound concurrent VMs; the slot is held until the script goroutine exits,
+	// which can be after the timeout response if it's blocked in a host call
+	pool := vms
+	if !pool.acquire(r.Context()) {
+		logging.Debugf("Serverless pool full, rejecting %s %s for %s", r.Method, r.URL.Path, siteID)
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Server busy, try again shortly", http.StatusServiceUnavailable)
+		return true
+	}
+
+	// DB calls made on behalf of the script stop when the client goes away or
+	// the script is abandoned after its timeout
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
 	// Create JavaScript runtime
 	vm := goja.New()
 
@@ -77,35 +452,67 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 		},
 		"status": func(call goja.FunctionCall) goja.Value {
 			if len(call.Arguments) > 0 {
-				response.statusCode = int(call.Arguments[0].ToInteger())
+				response.Status(int(call.Arguments[0].ToInteger()))
 			}
 			return goja.Undefined()
 		},
 		"header": func(call goja.FunctionCall) goja.Value {
 			if len(call.Arguments) >= 2 {
-				response.headers[call.Arguments[0].String()] = call.Arguments[1].String()
+				response.Header(call.Arguments[0].String(), call.Arguments[1].String())
+			}
+			return goja.Undefined()
+		},
+		// Streaming: write sends headers on first use, flush pushes buffered output
+		// to the client, end finishes the response
+		"write": func(call goja.FunctionCall) goja.Value {
+			if len(call.Arguments) > 0 {
+				response.Write(call.Arguments[0].String())
+			}
+			return goja.Undefined()
+		},
+		"flush": func(call goja.FunctionCall) goja.Value {
+			response.Flush()
+			return goja.Undefined()
+		},
+		"end": func(call goja.FunctionCall) goja.Value {
+			if len(call.Arguments) > 0 {
+				response.Write(call.Arguments[0].String())
 			}
+			response.End()
 			return goja.Undefined()
 		},
 	})
 
-	// Inject console for debugging
-	vm.Set("console", map[string]interface{}{
-		"log": func(call goja.FunctionCall) goja.Value {
+	// Inject console for debugging; lines go to site_logs and live log streams
+	consoleFunc := func(level string) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
 			args := make([]string, len(call.Arguments))
 			for i, arg := range call.Arguments {
 				args[i] = arg.String()
 			}
-			fmt.Printf("[JS:%s] %s\n", siteID, strings.Join(args, " "))
-			// TODO: Log to site_logs table
+			message := strings.Join(args, " ")
+			if capture != nil {
+				capture(LogEntry{SiteID: siteID, Level: level, Message: message, CreatedAt: time.Now().UTC()})
+				return goja.Undefined()
+			}
+			logging.Debugf("[JS:%s] %s: %s", siteID, level, message)
+			if err := WriteSiteLog(db, siteID, level, message); err != nil {
+				logging.Warnf("[JS:%s] %v", siteID, err)
+			}
 			return goja.Undefined()
-		},
+		}
+	}
+	vm.Set("console", map[string]interface{}{
+		"log":   consoleFunc("info"),
+		"info":  consoleFunc("info"),
+		"warn":  consoleFunc("warn"),
+		"error": consoleFunc("error"),
 	})
 
 	// Load environment variables for this site
 	envVars := make(map[string]interface{})
-	if db != nil {
-		rows, err := db.Query("SELECT name, value FROM env_vars WHERE site_id = ?", siteID)
+	if db != nil && capabilityEnabled(siteID, CapabilityProcess) {
+		rows, err := db.QueryContext(ctx, "SELECT name, value FROM env_vars WHERE site_id = ?", siteID)
 		if err == nil {
 			defer rows.Close()
 			for rows.Next() {
@@ -144,7 +551,7 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 			}
 			key := call.Arguments[0].String()
 			var value string
-			err := db.QueryRow("SELECT value FROM kv_store WHERE site_id = ? AND key = ?", siteID, key).Scan(&value)
+			err := db.QueryRowContext(ctx, "SELECT value FROM kv_store WHERE site_id = ? AND key = ?", siteID, key).Scan(&value)
 			if err != nil {
 				return goja.Null()
 			}
@@ -169,10 +576,7 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 				jsonBytes, _ := json.Marshal(v)
 				valueStr = string(jsonBytes)
 			}
-			db.Exec(`
-				INSERT INTO kv_store (site_id, key, value, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)
-				ON CONFLICT(site_id, key) DO UPDATE SET value = ?, updated_at = CURRENT_TIMESTAMP
-			`, siteID, key, valueStr, valueStr)
+			execPrepared(ctx, db, kvSetSQL, siteID, key, valueStr, valueStr)
 			return goja.Undefined()
 		},
 		"delete": func(call goja.FunctionCall) goja.Value {
@@ -180,12 +584,14 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 				return goja.Undefined()
 			}
 			key := call.Arguments[0].String()
-			db.Exec("DELETE FROM kv_store WHERE site_id = ? AND key = ?", siteID, key)
+			db.ExecContext(ctx, "DELETE FROM kv_store WHERE site_id = ? AND key = ?", siteID, key)
 			return goja.Undefined()
 		},
 	})
 
-	// Inject fetch function for HTTP requests
+	// Inject fetch function for HTTP requests. Calls count against the
+	// invocation's and the site's limits even when they're refused later on.
+	fetchCalls, fetchBytes := 0, int64(0)
 	vm.Set("fetch", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) == 0 {
 			return vm.ToValue(map[string]interface{}{
@@ -193,6 +599,23 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 			})
 		}
 
+		fetchCalls++
+		if fetchCalls > fetchMaxRequests {
+			return vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("Fetch limit exceeded: %d requests per invocation", fetchMaxRequests),
+			})
+		}
+		if fetchBytes >= fetchMaxBytes {
+			return vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("Fetch limit exceeded: %d bytes per invocation", fetchMaxBytes),
+			})
+		}
+		if !fetchRate.allow(siteID, fetchRatePerMinute) {
+			return vm.ToValue(map[string]interface{}{
+				"error": fmt.Sprintf("Fetch rate limit exceeded: %d requests per minute for this site", fetchRatePerMinute),
+			})
+		}
+
 		fetchURL := call.Arguments[0].String()
 
 		// Parse and validate URL
@@ -233,12 +656,7 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 			}
 		}
 
-		// Create HTTP client with timeout
-		client := &http.Client{
-			Timeout: 5 * time.Second,
-		}
-
-		req, err := http.NewRequest(method, fetchURL, reqBody)
+		req, err := http.NewRequestWithContext(ctx, method, fetchURL, reqBody)
 		if err != nil {
 			return vm.ToValue(map[string]interface{}{
 				"error": "Request error: " + err.Error(),
@@ -249,7 +667,12 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 			req.Header.Set(k, v)
 		}
 
-		resp, err := client.Do(req)
+		resp, err := fetchClient.Do(req)
+		if errors.Is(err, errInternalHost) {
+			return vm.ToValue(map[string]interface{}{
+				"error": "Blocked: internal/localhost URLs not allowed",
+			})
+		}
 		if err != nil {
 			return vm.ToValue(map[string]interface{}{
 				"error": "Fetch error: " + err.Error(),
@@ -257,9 +680,19 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 		}
 		defer resp.Body.Close()
 
-		// Limit response body to 1MB
-		limitedResp := io.LimitReader(resp.Body, 1<<20)
-		bodyBytes, err := io.ReadAll(limitedResp)
+		// Limit response body to 1MB and to what's left of the invocation's budget
+		limit := min(int64(fetchMaxBytesPerResponse), fetchMaxBytes-fetchBytes)
+		bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+		if int64(len(bodyBytes)) > limit {
+			if limit < fetchMaxBytesPerResponse {
+				fetchBytes = fetchMaxBytes
+				return vm.ToValue(map[string]interface{}{
+					"error": fmt.Sprintf("Fetch limit exceeded: %d bytes per invocation", fetchMaxBytes),
+				})
+			}
+			bodyBytes = bodyBytes[:limit]
+		}
+		fetchBytes += int64(len(bodyBytes))
 		if err != nil {
 			return vm.ToValue(map[string]interface{}{
 				"error": "Read error: " + err.Error(),
@@ -281,16 +714,41 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 		})
 	})
 
+	// Globals the operator turned off throw instead; process.env is just left empty
+	if !capabilityEnabled(siteID, CapabilityDB) {
+		vm.Set("db", map[string]interface{}{
+			"get":    disabledCall(vm, "db.get", CapabilityDB),
+			"set":    disabledCall(vm, "db.set", CapabilityDB),
+			"delete": disabledCall(vm, "db.delete", CapabilityDB),
+		})
+	}
+	if !capabilityEnabled(siteID, CapabilitySocket) {
+		vm.Set("socket", map[string]interface{}{
+			"broadcast": disabledCall(vm, "socket.broadcast", CapabilitySocket),
+			"clients":   disabledCall(vm, "socket.clients", CapabilitySocket),
+		})
+	}
+	if !capabilityEnabled(siteID, CapabilityFetch) {
+		vm.Set("fetch", disabledCall(vm, "fetch", CapabilityFetch))
+	}
+
 	// Run with timeout
 	done := make(chan error, 1)
+	stopWatch := make(chan struct{})
+	overMemory := watchMemory(vm, vmMemoryLimit, stopWatch)
 	go func() {
+		defer pool.release()
+		defer close(stopWatch)
 		_, err := vm.RunString(string(code))
 		done <- err
 	}()
 
 	select {
 	case err := <-done:
-		if err != nil {
+		if err != nil && overMemory.Load() {
+			logging.Warnf("Serverless script for %s interrupted: %v", siteID, errMemoryLimit)
+			response.Error("Script memory limit exceeded")
+		} else if err != nil {
 			response.Error(fmt.Sprintf("JavaScript error: %v", err))
 		}
 	case <-time.After(100 * time.Millisecond):
@@ -298,26 +756,31 @@ func RunServerless(w http.ResponseWriter, r *http.Request, siteID string, db *sq
 		response.Error("Script execution timed out (100ms limit)")
 	}
 
-	// Write response if not already written
-	if !response.bodyWritten {
-		response.Send("")
-	}
+	// Write response if not already written; a script still running after a
+	// timeout can't write to it anymore
+	response.End()
 
 	return true
 }
 
-// jsResponse handles the HTTP response from JavaScript
+// jsResponse handles the HTTP response from JavaScript. The script runs on its
+// own goroutine, so methods are serialized, and writes after End are dropped.
 type jsResponse struct {
+	mu          sync.Mutex
 	w           http.ResponseWriter
 	headers     map[string]string
 	statusCode  int
 	bodyWritten bool
+	ended       bool
 }
 
 func (r *jsResponse) writeHeaders() {
 	if r.bodyWritten {
 		return
 	}
+	if r.w.Header().Get("Content-Type") == "" {
+		r.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
 	for k, v := range r.headers {
 		r.w.Header().Set(k, v)
 	}
@@ -325,21 +788,82 @@ func (r *jsResponse) writeHeaders() {
 	r.bodyWritten = true
 }
 
+// Status sets the status code; it has no effect once the body has started
+func (r *jsResponse) Status(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statusCode = code
+}
+
+// Header sets a response header; it has no effect once the body has started
+func (r *jsResponse) Header(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers[key] = value
+}
+
 func (r *jsResponse) Send(body string) {
-	if r.w.Header().Get("Content-Type") == "" {
-		r.w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
 	}
 	r.writeHeaders()
 	r.w.Write([]byte(body))
 }
 
 func (r *jsResponse) JSON(data interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
 	r.w.Header().Set("Content-Type", "application/json")
 	r.writeHeaders()
 	json.NewEncoder(r.w).Encode(data)
 }
 
+// Write streams a chunk of the body, sending the headers first if needed
+func (r *jsResponse) Write(chunk string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
+	r.writeHeaders()
+	r.w.Write([]byte(chunk))
+}
+
+// Flush sends everything written so far to the client
+func (r *jsResponse) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
+	r.writeHeaders()
+	http.NewResponseController(r.w).Flush()
+}
+
+// End finishes the response: headers are sent if nothing was written, and
+// later writes are ignored
+func (r *jsResponse) End() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
+	r.writeHeaders()
+	http.NewResponseController(r.w).Flush()
+	r.ended = true
+}
+
 func (r *jsResponse) Error(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ended {
+		return
+	}
 	r.statusCode = 500
 	r.w.Header().Set("Content-Type", "text/plain")
 	r.writeHeaders()
@@ -347,53 +871,75 @@ func (r *jsResponse) Error(msg string) {
 }
 
 // HasServerless checks if a site has a main.js file
-func HasServerless(siteDir string) bool {
-	// siteDir in this new context is effectively siteID, as we pass subdomain mostly
-	// But main.go passes GetSiteDir(subdomain).
-	// We need to fix the caller in main.go to pass subdomain.
-	// For now, let's assume the caller will be fixed or this function is deprecated in favor of SiteExists check
-	
-	// Actually, main.go:
-	// siteDir := hosting.GetSiteDir(subdomain)
-	// if hosting.HasServerless(siteDir) { ... }
-	
-	// We should probably rely on fs.Exists(siteID, "main.js")
-	// But siteDir is a path. We need to extract siteID from it or change the signature.
-	// Since we are refactoring, let's change the signature to match the new world.
-	
-	// WARNING: Changing signature requires changing caller in main.go.
-	// We are doing that in the next step anyway.
-	
-	// But wait, `RunServerless` also takes siteDir.
-	// I updated `RunServerless` to take `siteID`.
-	// I will update this too.
-	return false
-}
-
-// isInternalHost checks if a host is localhost or an internal IP (SSRF protection)
+func HasServerless(siteID string) bool {
+	exists, err := fs.Exists(siteID, "main.js")
+	return err == nil && exists
+}
+
+// isInternalHost checks if a host is localhost or an internal IP literal (SSRF
+// protection). Hostnames are checked when fetchClient connects, by dialPublic.
 func isInternalHost(host string) bool {
-	// Check for localhost variations
 	host = strings.ToLower(host)
-	if host == "localhost" || host == "127.0.0.1" || host == "::1" || host == "0.0.0.0" {
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
 		return true
 	}
-
-	// Parse IP and check for internal ranges
 	ip := net.ParseIP(host)
-	if ip == nil {
-		// Not an IP, try to resolve it
-		ips, err := net.LookupIP(host)
-		if err == nil && len(ips) > 0 {
-			ip = ips[0]
-		}
+	return ip != nil && isInternalIP(ip)
+}
+
+// isInternalIP reports whether ip is loopback, private, link-local or unspecified
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// errInternalHost is returned by dialPublic for internal addresses
+var errInternalHost = errors.New("internal/localhost URLs not allowed")
+
+// fetchLookupIP resolves hostnames for fetch(); tests replace it
+var fetchLookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
+
+// fetchDial opens fetch() connections; tests replace it
+var fetchDial = (&net.Dialer{Timeout: 5 * time.Second}).DialContext
+
+// dialPublic resolves addr's host once, refuses it if any address is internal,
+// and dials the exact IP it checked. Letting the dialer resolve again would let
+// a rebinding DNS server pass the check with a public IP and then connect us to
+// an internal one. The URL is unchanged, so Host and TLS SNI stay the original name.
+func dialPublic(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
 	}
 
-	if ip != nil {
-		// Check for private/internal IP ranges
-		if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-			return true
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else if ips, err = fetchLookupIP(ctx, host); err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	for _, ip := range ips {
+		if isInternalIP(ip) {
+			return nil, errInternalHost
 		}
 	}
 
-	return false
+	return fetchDial(ctx, network, net.JoinHostPort(ips[0].String(), port))
+}
+
+// fetchClient makes fetch() requests. It never uses a proxy, so dialPublic
+// sees (and redirects are checked against) the real destination.
+var fetchClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext:         dialPublic,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
 }
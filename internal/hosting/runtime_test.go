@@ -0,0 +1,364 @@
+package hosting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+func TestVMPool_BoundsConcurrency(t *testing.T) {
+	pool := newVMPool(3, time.Second)
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !pool.acquire(context.Background()) {
+				t.Error("acquire failed while slots free up within the wait")
+				return
+			}
+			defer pool.release()
+
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+		}()
+	}
+	wg.Wait()
+
+	if got := peak.Load(); got > 3 {
+		t.Errorf("peak concurrency = %d, want at most 3", got)
+	}
+}
+
+func TestVMPool_RejectsWhenFull(t *testing.T) {
+	pool := newVMPool(1, 20*time.Millisecond)
+	if !pool.acquire(context.Background()) {
+		t.Fatal("first acquire should succeed")
+	}
+
+	start := time.Now()
+	if pool.acquire(context.Background()) {
+		t.Fatal("acquire should fail while the pool is full")
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("gave up after %v, want to queue for the wait period", waited)
+	}
+
+	// A cancelled request stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if pool.acquire(ctx) {
+		t.Error("acquire should fail for a cancelled context")
+	}
+
+	pool.release()
+	if !pool.acquire(context.Background()) {
+		t.Error("acquire should succeed once a slot is released")
+	}
+}
+
+func TestWatchMemory_InterruptsAllocatingScript(t *testing.T) {
+	vm := goja.New()
+	stop := make(chan struct{})
+	defer close(stop)
+
+	exceeded := watchMemory(vm, 4<<20, stop)
+	safety := time.AfterFunc(5*time.Second, func() { vm.Interrupt("safety timeout") })
+	defer safety.Stop()
+
+	_, err := vm.RunString(`var hog = []; while (true) { hog.push(new Array(10000).fill("x")); }`)
+
+	var interrupted *goja.InterruptedError
+	if !errors.As(err, &interrupted) || interrupted.Value() != errMemoryLimit {
+		t.Fatalf("RunString error = %v, want interruption with errMemoryLimit", err)
+	}
+	if !exceeded.Load() {
+		t.Error("watchMemory should report the limit was exceeded")
+	}
+}
+
+func TestWatchMemory_QuietScript(t *testing.T) {
+	vm := goja.New()
+	stop := make(chan struct{})
+
+	exceeded := watchMemory(vm, 64<<20, stop)
+	if _, err := vm.RunString(`var total = 0; for (var i = 0; i < 1000; i++) { total += i; }`); err != nil {
+		t.Fatalf("RunString failed: %v", err)
+	}
+	close(stop)
+
+	if exceeded.Load() {
+		t.Error("a small script should stay under the limit")
+	}
+}
+
+func TestJSResponse_Streaming(t *testing.T) {
+	rec := httptest.NewRecorder()
+	res := &jsResponse{w: rec, headers: make(map[string]string), statusCode: 200}
+
+	res.Header("Content-Type", "text/event-stream")
+	res.Write("data: one\n\n")
+	res.Flush()
+	if !rec.Flushed {
+		t.Error("Flush should flush the underlying writer")
+	}
+	if rec.Body.String() != "data: one\n\n" {
+		t.Errorf("body after first chunk = %q", rec.Body.String())
+	}
+
+	// Headers are fixed once streaming starts
+	res.Status(http.StatusTeapot)
+	res.Write("data: two\n\n")
+	res.End()
+	res.Send("ignored")
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	if got := rec.Body.String(); got != "data: one\n\ndata: two\n\n" {
+		t.Errorf("body = %q, want both chunks and nothing after End", got)
+	}
+}
+
+func TestJSResponse_SendStillBuffers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	res := &jsResponse{w: rec, headers: make(map[string]string), statusCode: 200}
+
+	res.Status(http.StatusCreated)
+	res.Send("<h1>hi</h1>")
+	res.End()
+
+	if rec.Code != http.StatusCreated || rec.Body.String() != "<h1>hi</h1>" {
+		t.Errorf("got %d %q, want 201 <h1>hi</h1>", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want the text/html default", ct)
+	}
+}
+
+func TestCaptureWriter_FirstStatusWins(t *testing.T) {
+	w := &captureWriter{header: make(http.Header), status: http.StatusOK}
+	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(http.StatusTeapot)
+	w.Write([]byte("hello"))
+	if w.status != http.StatusCreated || w.body.String() != "hello" {
+		t.Errorf("captured %d %q, want 201 \"hello\"", w.status, w.body.String())
+	}
+}
+
+// scriptFS serves one main.js for every site
+type scriptFS struct {
+	FileSystem
+	code string
+}
+
+func (s scriptFS) ReadFile(siteID, path string) (*File, error) {
+	if path != "main.js" {
+		return nil, ErrFileNotFound
+	}
+	return &File{Content: io.NopCloser(strings.NewReader(s.code)), Size: int64(len(s.code))}, nil
+}
+
+func TestRunServerless_DisabledCapabilities(t *testing.T) {
+	oldFS := fs
+	defer func() { fs = oldFS }()
+	defer SetDisabledCapabilities(nil, nil)
+
+	fs = scriptFS{code: `
+		try {
+			fetch("https://example.com");
+			res.send("fetched");
+		} catch (e) {
+			res.status(403);
+			res.send(e.message);
+		}
+	`}
+
+	run := func(siteID string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		runServerless(rec, httptest.NewRequest(http.MethodGet, "/", nil), siteID, nil, nil)
+		return rec
+	}
+
+	if err := SetDisabledCapabilities(nil, map[string][]string{"locked": {"fetch"}}); err != nil {
+		t.Fatalf("SetDisabledCapabilities() error = %v", err)
+	}
+	rec := run("locked")
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "fetch is disabled for this site") {
+		t.Errorf("fetch-disabled site got %d %q, want 403 with the disabled error", rec.Code, rec.Body.String())
+	}
+
+	// Server-wide
+	if err := SetDisabledCapabilities([]string{"FETCH"}, nil); err != nil {
+		t.Fatalf("SetDisabledCapabilities() error = %v", err)
+	}
+	if rec := run("other"); !strings.Contains(rec.Body.String(), "fetch is disabled") {
+		t.Errorf("server-wide disable: got %q", rec.Body.String())
+	}
+
+	if err := SetDisabledCapabilities([]string{"filesystem"}, nil); err == nil {
+		t.Error("SetDisabledCapabilities() should reject unknown capabilities")
+	}
+}
+
+func TestCapabilityEnabled(t *testing.T) {
+	defer SetDisabledCapabilities(nil, nil)
+	SetDisabledCapabilities([]string{"db"}, map[string][]string{"Blog": {"socket"}})
+
+	tests := []struct {
+		site, capability string
+		want             bool
+	}{
+		{"blog", CapabilityDB, false},
+		{"blog", CapabilitySocket, false},
+		{"blog", CapabilityFetch, true},
+		{"shop", CapabilityDB, false},
+		{"shop", CapabilitySocket, true},
+	}
+	for _, tt := range tests {
+		if got := capabilityEnabled(tt.site, tt.capability); got != tt.want {
+			t.Errorf("capabilityEnabled(%s, %s) = %v, want %v", tt.site, tt.capability, got, tt.want)
+		}
+	}
+}
+
+func TestRunServerless_FetchCountLimit(t *testing.T) {
+	oldFS := fs
+	defer func() { fs = oldFS }()
+	defer SetFetchLimits(0, 0, 0)
+
+	// Internal URLs are refused before any network access, but still count
+	fs = scriptFS{code: `
+		var errors = [];
+		for (var i = 0; i < 5; i++) {
+			errors.push(fetch("http://127.0.0.1/").error);
+		}
+		res.json(errors);
+	`}
+	SetFetchLimits(3, 0, 0)
+
+	rec := httptest.NewRecorder()
+	runServerless(rec, httptest.NewRequest(http.MethodGet, "/", nil), "looper", nil, nil)
+
+	var errs []string
+	if err := json.Unmarshal(rec.Body.Bytes(), &errs); err != nil || len(errs) != 5 {
+		t.Fatalf("response %q: want 5 fetch errors", rec.Body.String())
+	}
+	for i, msg := range errs {
+		limited := strings.Contains(msg, "Fetch limit exceeded: 3 requests per invocation")
+		if limited != (i >= 3) {
+			t.Errorf("fetch %d error = %q, want the limit error only after 3 calls", i+1, msg)
+		}
+	}
+
+	// The count is per invocation
+	rec = httptest.NewRecorder()
+	runServerless(rec, httptest.NewRequest(http.MethodGet, "/", nil), "looper", nil, nil)
+	if err := json.Unmarshal(rec.Body.Bytes(), &errs); err != nil || strings.Contains(errs[0], "limit") {
+		t.Errorf("second invocation's first fetch = %q, want it allowed", errs)
+	}
+}
+
+func TestFetchLimiter_PerSite(t *testing.T) {
+	fl := &fetchLimiter{calls: make(map[string][]time.Time)}
+	for i := 0; i < 3; i++ {
+		if !fl.allow("blog", 3) {
+			t.Fatalf("call %d refused, want 3 allowed", i+1)
+		}
+	}
+	if fl.allow("blog", 3) {
+		t.Error("fourth call within a minute should be refused")
+	}
+	if !fl.allow("shop", 3) {
+		t.Error("other sites have their own limit")
+	}
+
+	// Calls older than a minute no longer count
+	fl.calls["blog"][0] = time.Now().Add(-2 * time.Minute)
+	if !fl.allow("blog", 3) {
+		t.Error("call should be allowed once an old one ages out")
+	}
+}
+
+func TestFetch_DNSRebindingBlocked(t *testing.T) {
+	oldFS, oldLookup, oldDial := fs, fetchLookupIP, fetchDial
+	defer func() { fs, fetchLookupIP, fetchDial = oldFS, oldLookup, oldDial }()
+
+	// A rebinding DNS server: public for the first answer, loopback after that
+	var lookups atomic.Int32
+	fetchLookupIP = func(ctx context.Context, host string) ([]net.IP, error) {
+		if lookups.Add(1) == 1 {
+			return []net.IP{net.ParseIP("203.0.113.10")}, nil
+		}
+		return []net.IP{net.ParseIP("127.0.0.1")}, nil
+	}
+	var dialed []string
+	fetchDial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, errors.New("dial refused by test")
+	}
+
+	fs = scriptFS{code: `res.send(fetch("http://rebind.example:8080/").error || "ok");`}
+	rec := httptest.NewRecorder()
+	runServerless(rec, httptest.NewRequest(http.MethodGet, "/", nil), "rebind", nil, nil)
+
+	if len(dialed) != 1 || dialed[0] != "203.0.113.10:8080" {
+		t.Errorf("dialed %v, want only the validated 203.0.113.10:8080", dialed)
+	}
+	if n := lookups.Load(); n != 1 {
+		t.Errorf("host resolved %d times, want once", n)
+	}
+	if !strings.Contains(rec.Body.String(), "Fetch error") {
+		t.Errorf("got %q, want the stub dial error", rec.Body.String())
+	}
+}
+
+func TestFetch_BlocksHostsResolvingInternal(t *testing.T) {
+	oldFS, oldLookup, oldDial := fs, fetchLookupIP, fetchDial
+	defer func() { fs, fetchLookupIP, fetchDial = oldFS, oldLookup, oldDial }()
+
+	fetchDial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		t.Errorf("dialed %s, want no connection", addr)
+		return nil, errors.New("unexpected dial")
+	}
+	fs = scriptFS{code: `res.send(fetch("http://internal.example/").error || "ok");`}
+
+	tests := map[string][]net.IP{
+		"private":             {net.ParseIP("10.0.0.5")},
+		"link-local":          {net.ParseIP("169.254.169.254")},
+		"public and loopback": {net.ParseIP("203.0.113.10"), net.ParseIP("127.0.0.1")},
+	}
+	for name, ips := range tests {
+		t.Run(name, func(t *testing.T) {
+			fetchLookupIP = func(context.Context, string) ([]net.IP, error) { return ips, nil }
+			rec := httptest.NewRecorder()
+			runServerless(rec, httptest.NewRequest(http.MethodGet, "/", nil), "ssrf", nil, nil)
+			if !strings.Contains(rec.Body.String(), "Blocked") {
+				t.Errorf("got %q, want the fetch blocked", rec.Body.String())
+			}
+		})
+	}
+}
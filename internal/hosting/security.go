@@ -1,62 +1,13 @@
 package hosting
 
 import (
-	"net/http"
-	"path/filepath"
 	"strings"
 )
 
-// SecureFileServer returns a file server that prevents path traversal
-// and restricts access to the specified root directory
-type SecureFileServer struct {
-	root    string
-	handler http.Handler
-}
-
-// NewSecureFileServer creates a secure file server for the given directory
-func NewSecureFileServer(root string) *SecureFileServer {
-	absRoot, _ := filepath.Abs(root)
-	return &SecureFileServer{
-		root:    absRoot,
-		handler: http.FileServer(http.Dir(root)),
-	}
-}
-
-// ServeHTTP handles requests with path traversal protection
-func (s *SecureFileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Clean the path
-	cleanPath := filepath.Clean(r.URL.Path)
-
-	// Block obvious traversal attempts
-	if strings.Contains(r.URL.Path, "..") {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
-
-	// Verify the requested path stays within root
-	fullPath := filepath.Join(s.root, cleanPath)
-	absPath, err := filepath.Abs(fullPath)
-	if err != nil || !strings.HasPrefix(absPath, s.root) {
-		http.Error(w, "Forbidden", http.StatusForbidden)
-		return
-	}
-
-	// Block access to hidden files (starting with .)
-	parts := strings.Split(cleanPath, "/")
-	for _, part := range parts {
-		if len(part) > 0 && part[0] == '.' {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-	}
-
-	s.handler.ServeHTTP(w, r)
-}
-
 // SecurityLimits defines resource limits for serverless execution
 type SecurityLimits struct {
 	MaxExecutionTime int64 // milliseconds
-	MaxMemoryBytes   int64 // bytes (not strictly enforced by Goja)
+	MaxMemoryBytes   int64 // bytes (best effort: heap growth is sampled while the script runs)
 	MaxFileSize      int64 // bytes for uploaded files
 	MaxSiteSize      int64 // total bytes for a site
 }
@@ -1,10 +1,6 @@
 package hosting
 
 import (
-	"net/http"
-	"net/http/httptest"
-	"os"
-	"path/filepath"
 	"testing"
 )
 
@@ -64,61 +60,6 @@ func TestSanitizeInput(t *testing.T) {
 	}
 }
 
-func TestSecureFileServer(t *testing.T) {
-	// Create temp directory with test files
-	tmpDir, err := os.MkdirTemp("", "cc-secure-*")
-	if err != nil {
-		t.Fatalf("Failed to create temp dir: %v", err)
-	}
-	defer os.RemoveAll(tmpDir)
-
-	// Create test file
-	testContent := []byte("test content")
-	if err := os.WriteFile(filepath.Join(tmpDir, "index.html"), testContent, 0644); err != nil {
-		t.Fatalf("Failed to write test file: %v", err)
-	}
-
-	// Create hidden file
-	if err := os.WriteFile(filepath.Join(tmpDir, ".secret"), []byte("secret"), 0644); err != nil {
-		t.Fatalf("Failed to write hidden file: %v", err)
-	}
-
-	// Create subdirectory
-	subDir := filepath.Join(tmpDir, "sub")
-	if err := os.MkdirAll(subDir, 0755); err != nil {
-		t.Fatalf("Failed to create subdir: %v", err)
-	}
-	if err := os.WriteFile(filepath.Join(subDir, "file.txt"), []byte("sub content"), 0644); err != nil {
-		t.Fatalf("Failed to write sub file: %v", err)
-	}
-
-	server := NewSecureFileServer(tmpDir)
-
-	tests := []struct {
-		name       string
-		path       string
-		wantStatus int
-	}{
-		{"valid file via root", "/", http.StatusOK}, // serves index.html
-		{"valid subdir file", "/sub/file.txt", http.StatusOK},
-		{"hidden file blocked", "/.secret", http.StatusForbidden},
-		{"path traversal blocked", "/../etc/passwd", http.StatusForbidden},
-		{"double dot blocked", "/sub/../../../etc/passwd", http.StatusForbidden},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			req := httptest.NewRequest("GET", tt.path, nil)
-			w := httptest.NewRecorder()
-			server.ServeHTTP(w, req)
-
-			if w.Code != tt.wantStatus {
-				t.Errorf("GET %s: got status %d, want %d", tt.path, w.Code, tt.wantStatus)
-			}
-		})
-	}
-}
-
 func TestDefaultLimits(t *testing.T) {
 	limits := DefaultLimits()
 
@@ -1,9 +1,12 @@
 package hosting
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -13,8 +16,10 @@ import (
 type FileSystem interface {
 	WriteFile(siteID, path string, content io.Reader, size int64, mimeType string) error
 	ReadFile(siteID, path string) (*File, error)
+	DeleteFile(siteID, path string) error
 	DeleteSite(siteID string) error
 	Exists(siteID, path string) (bool, error)
+	ListFiles(siteID string) ([]FileInfo, error)
 }
 
 // File represents a file in the VFS
@@ -26,14 +31,87 @@ type File struct {
 	ModTime  time.Time
 }
 
+// ErrFileNotFound is returned by ReadFile for paths not in the site
+var ErrFileNotFound = errors.New("file not found")
+
+// FileInfo describes a file in the VFS without its content
+type FileInfo struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size_bytes"`
+	MimeType string    `json:"mime_type"`
+	Hash     string    `json:"hash"`
+	ModTime  time.Time `json:"updated_at"`
+}
+
+// Gzip storage defaults, chosen to keep deploys cheap on a small VPS
+const (
+	// DefaultGzipLevel trades a little size for much less CPU than gzip.BestCompression
+	DefaultGzipLevel = 5
+
+	// DefaultGzipMinBytes is the size below which gzip overhead outweighs the savings
+	DefaultGzipMinBytes = 1024
+)
+
+// encodingGzip marks files stored gzip-compressed
+const encodingGzip = "gzip"
+
 // SQLFileSystem implements FileSystem using SQLite
 type SQLFileSystem struct {
 	db *sql.DB
+
+	gzipLevel    int
+	gzipMinBytes int64
 }
 
 // NewSQLFileSystem creates a new SQL-backed file system
 func NewSQLFileSystem(db *sql.DB) *SQLFileSystem {
-	return &SQLFileSystem{db: db}
+	return &SQLFileSystem{db: db, gzipLevel: DefaultGzipLevel, gzipMinBytes: DefaultGzipMinBytes}
+}
+
+// SetCompression sets the gzip level (1-9) and the minimum size of files stored
+// compressed; zero values use the defaults
+func (fs *SQLFileSystem) SetCompression(level int, minBytes int64) {
+	if level == 0 {
+		level = DefaultGzipLevel
+	}
+	if minBytes == 0 {
+		minBytes = DefaultGzipMinBytes
+	}
+	fs.gzipLevel = level
+	fs.gzipMinBytes = minBytes
+}
+
+// SetCompression configures gzip storage for the active file system
+func SetCompression(level int, minBytes int64) {
+	if sqlFS, ok := fs.(*SQLFileSystem); ok {
+		sqlFS.SetCompression(level, minBytes)
+	}
+}
+
+// compress gzips data when it's at least minBytes long and compression pays off.
+// It returns the bytes to store and their encoding ("" for raw).
+func compress(data []byte, level int, minBytes int64) ([]byte, string, error) {
+	if int64(len(data)) < minBytes {
+		return data, "", nil
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, "", err
+	}
+
+	// Already-compressed formats (images, archives) don't shrink
+	if buf.Len() >= len(data) {
+		return data, "", nil
+	}
+	return buf.Bytes(), encodingGzip, nil
 }
 
 // WriteFile writes a file to the database
@@ -49,23 +127,29 @@ func (fs *SQLFileSystem) WriteFile(siteID, path string, content io.Reader, size
 		return fmt.Errorf("failed to read content: %w", err)
 	}
 
-	// Calculate SHA256 hash
+	// Calculate SHA256 hash of the uncompressed content
 	hash := sha256.Sum256(data)
 	hashStr := hex.EncodeToString(hash[:])
 
+	stored, encoding, err := compress(data, fs.gzipLevel, fs.gzipMinBytes)
+	if err != nil {
+		return fmt.Errorf("failed to compress content: %w", err)
+	}
+
 	// Insert or Replace
 	query := `
-		INSERT INTO files (site_id, path, content, size_bytes, mime_type, hash, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO files (site_id, path, content, size_bytes, mime_type, hash, encoding, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(site_id, path) DO UPDATE SET
 			content = excluded.content,
 			size_bytes = excluded.size_bytes,
 			mime_type = excluded.mime_type,
 			hash = excluded.hash,
+			encoding = excluded.encoding,
 			updated_at = CURRENT_TIMESTAMP
 	`
 	
-	_, err = fs.db.Exec(query, siteID, path, data, size, mimeType, hashStr)
+	_, err = fs.db.Exec(query, siteID, path, stored, size, mimeType, hashStr, encoding)
 	if err != nil {
 		return fmt.Errorf("failed to write file to DB: %w", err)
 	}
@@ -73,28 +157,172 @@ func (fs *SQLFileSystem) WriteFile(siteID, path string, content io.Reader, size
 	return nil
 }
 
+// RehashResult reports what a rehash changed
+type RehashResult struct {
+	Scanned int `json:"scanned"`
+	Updated int `json:"updated"`
+}
+
+// rehashBatchSize bounds how many files are loaded and rewritten per transaction
+const rehashBatchSize = 100
+
+// storedFile is a files row as stored, before decompression
+type storedFile struct {
+	siteID, path   string
+	content        []byte
+	mimeType, hash string
+	encoding       string
+}
+
+// Rehash recomputes every file's SHA256 and MIME type from its content, and with
+// recompress also re-encodes it with the current gzip settings. Files are
+// processed in batches, each in its own transaction, so the database is never
+// locked for the whole run.
+func (fs *SQLFileSystem) Rehash(recompress bool) (RehashResult, error) {
+	var result RehashResult
+	var lastSite, lastPath string
+
+	for {
+		batch, err := fs.loadBatch(lastSite, lastPath)
+		if err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		updated, err := fs.rehashBatch(batch, recompress)
+		if err != nil {
+			return result, err
+		}
+		result.Scanned += len(batch)
+		result.Updated += updated
+
+		last := batch[len(batch)-1]
+		lastSite, lastPath = last.siteID, last.path
+	}
+}
+
+// loadBatch reads the next batch of files after (siteID, path)
+func (fs *SQLFileSystem) loadBatch(siteID, path string) ([]storedFile, error) {
+	rows, err := fs.db.Query(`
+		SELECT site_id, path, content, mime_type, hash, encoding
+		FROM files WHERE (site_id, path) > (?, ?)
+		ORDER BY site_id, path LIMIT ?
+	`, siteID, path, rehashBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []storedFile
+	for rows.Next() {
+		var f storedFile
+		var mimeType sql.NullString
+		if err := rows.Scan(&f.siteID, &f.path, &f.content, &mimeType, &f.hash, &f.encoding); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		f.mimeType = mimeType.String
+		batch = append(batch, f)
+	}
+	return batch, rows.Err()
+}
+
+// rehashBatch rewrites the files in batch whose derived fields changed
+func (fs *SQLFileSystem) rehashBatch(batch []storedFile, recompress bool) (int, error) {
+	tx, err := fs.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	defer tx.Rollback()
+
+	updated := 0
+	for _, f := range batch {
+		data := f.content
+		if f.encoding == encodingGzip {
+			zr, err := gzip.NewReader(bytes.NewReader(f.content))
+			if err != nil {
+				return 0, fmt.Errorf("corrupt compressed file %s/%s: %w", f.siteID, f.path, err)
+			}
+			if data, err = io.ReadAll(zr); err != nil {
+				return 0, fmt.Errorf("corrupt compressed file %s/%s: %w", f.siteID, f.path, err)
+			}
+		}
+
+		sum := sha256.Sum256(data)
+		hash := hex.EncodeToString(sum[:])
+		mimeType, _, err := detectMimeType(f.path, bytes.NewReader(data))
+		if err != nil {
+			return 0, err
+		}
+
+		stored, encoding := f.content, f.encoding
+		if recompress {
+			if stored, encoding, err = compress(data, fs.gzipLevel, fs.gzipMinBytes); err != nil {
+				return 0, fmt.Errorf("failed to compress content: %w", err)
+			}
+		}
+
+		if hash == f.hash && mimeType == f.mimeType && encoding == f.encoding && bytes.Equal(stored, f.content) {
+			continue
+		}
+
+		_, err = tx.Exec(`
+			UPDATE files SET content = ?, size_bytes = ?, mime_type = ?, hash = ?, encoding = ?
+			WHERE site_id = ? AND path = ?
+		`, stored, len(data), mimeType, hash, encoding, f.siteID, f.path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update %s/%s: %w", f.siteID, f.path, err)
+		}
+		updated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("database error: %w", err)
+	}
+	return updated, nil
+}
+
+// RehashFiles runs Rehash on the active file system
+func RehashFiles(recompress bool) (RehashResult, error) {
+	sqlFS, ok := fs.(*SQLFileSystem)
+	if !ok {
+		return RehashResult{}, fmt.Errorf("file system does not support rehashing")
+	}
+	return sqlFS.Rehash(recompress)
+}
+
 // ReadFile reads a file from the database
 func (fs *SQLFileSystem) ReadFile(siteID, path string) (*File, error) {
 	query := `
-		SELECT content, size_bytes, mime_type, hash, updated_at
+		SELECT content, size_bytes, mime_type, hash, encoding, updated_at
 		FROM files WHERE site_id = ? AND path = ?
 	`
 	
 	var data []byte
 	var size int64
-	var mimeType, hash string
+	var mimeType, hash, encoding string
 	var modTime time.Time
 
-	err := fs.db.QueryRow(query, siteID, path).Scan(&data, &size, &mimeType, &hash, &modTime)
+	err := fs.db.QueryRow(query, siteID, path).Scan(&data, &size, &mimeType, &hash, &encoding, &modTime)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("file not found") // OS-agnostic error?
+		return nil, ErrFileNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
 	}
 
+	var content io.ReadCloser = io.NopCloser(newByteReader(data))
+	if encoding == encodingGzip {
+		zr, err := gzip.NewReader(newByteReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("corrupt compressed file: %w", err)
+		}
+		content = zr
+	}
+
 	return &File{
-		Content:  io.NopCloser(newByteReader(data)),
+		Content:  content,
 		Size:     size,
 		MimeType: mimeType,
 		Hash:     hash,
@@ -102,6 +330,18 @@ func (fs *SQLFileSystem) ReadFile(siteID, path string) (*File, error) {
 	}, nil
 }
 
+// DeleteFile deletes one file, returning ErrFileNotFound if it doesn't exist
+func (fs *SQLFileSystem) DeleteFile(siteID, path string) error {
+	result, err := fs.db.Exec("DELETE FROM files WHERE site_id = ? AND path = ?", siteID, path)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrFileNotFound
+	}
+	return nil
+}
+
 // DeleteSite deletes all files for a site
 func (fs *SQLFileSystem) DeleteSite(siteID string) error {
 	_, err := fs.db.Exec("DELETE FROM files WHERE site_id = ?", siteID)
@@ -118,6 +358,31 @@ func (fs *SQLFileSystem) Exists(siteID, path string) (bool, error) {
 	return count > 0, nil
 }
 
+// ListFiles returns metadata for every file in a site, ordered by path
+func (fs *SQLFileSystem) ListFiles(siteID string) ([]FileInfo, error) {
+	rows, err := fs.db.Query(`
+		SELECT path, size_bytes, mime_type, hash, updated_at
+		FROM files WHERE site_id = ? ORDER BY path
+	`, siteID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	defer rows.Close()
+
+	var files []FileInfo
+	for rows.Next() {
+		var f FileInfo
+		var mimeType sql.NullString
+		if err := rows.Scan(&f.Path, &f.Size, &mimeType, &f.Hash, &f.ModTime); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		f.MimeType = mimeType.String
+		files = append(files, f)
+	}
+
+	return files, rows.Err()
+}
+
 // Helper for byte reader
 type byteReader struct {
 	data []byte
@@ -1,12 +1,15 @@
 package hosting
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // checkOrigin validates WebSocket origin against allowed patterns
@@ -30,7 +33,7 @@ func checkOrigin(r *http.Request) bool {
 		return true
 	}
 
-	log.Printf("[WS] Rejected origin: %s (host: %s)", origin, r.Host)
+	logging.Warnf("[WS] Rejected origin: %s (host: %s)", origin, r.Host)
 	return false
 }
 
@@ -48,6 +51,8 @@ type SiteHub struct {
 	register   chan *websocket.Conn
 	unregister chan *websocket.Conn
 	done       chan struct{}
+	stopped    chan struct{}
+	stopOnce   sync.Once
 	mu         sync.RWMutex
 }
 
@@ -77,6 +82,7 @@ func GetHub(siteID string) *SiteHub {
 		register:   make(chan *websocket.Conn),
 		unregister: make(chan *websocket.Conn),
 		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
 	}
 
 	hubManager.hubs[siteID] = hub
@@ -93,30 +99,76 @@ func RemoveHub(siteID string) {
 	if hub, exists := hubManager.hubs[siteID]; exists {
 		hub.Stop()
 		delete(hubManager.hubs, siteID)
-		log.Printf("[WS:%s] Hub removed", siteID)
+		logging.Debugf("[WS:%s] Hub removed", siteID)
+	}
+}
+
+// StopAll stops every hub, closing their client connections, and waits for them to exit
+func (m *HubManager) StopAll() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for siteID, hub := range m.hubs {
+		hub.Stop()
+		delete(m.hubs, siteID)
 	}
 }
 
+// DrainAll drains every hub in parallel (see SiteHub.Drain) and removes them
+func (m *HubManager) DrainAll(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for siteID, hub := range m.hubs {
+		wg.Add(1)
+		go func(hub *SiteHub) {
+			defer wg.Done()
+			hub.Drain(ctx)
+		}(hub)
+		delete(m.hubs, siteID)
+	}
+	wg.Wait()
+}
+
+// StopAllHubs stops all site hubs immediately
+func StopAllHubs() {
+	hubManager.StopAll()
+}
+
+// DrainAllHubs asks all WebSocket clients to reconnect and stops the hubs once they
+// have left or ctx is done (call on server shutdown)
+func DrainAllHubs(ctx context.Context) {
+	hubManager.DrainAll(ctx)
+}
+
+// closeGracePeriod bounds how long a close frame may take to write on shutdown
+const closeGracePeriod = time.Second
+
 // run handles the hub's event loop
 func (h *SiteHub) run() {
+	defer close(h.stopped)
+
 	for {
 		select {
 		case <-h.done:
-			// Shutdown: close all client connections
+			// Shutdown: tell clients we're going away, then close their connections
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
 			h.mu.Lock()
 			for conn := range h.clients {
+				conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeGracePeriod))
 				conn.Close()
 				delete(h.clients, conn)
 			}
 			h.mu.Unlock()
-			log.Printf("[WS:%s] Hub shutdown complete", h.siteID)
+			logging.Debugf("[WS:%s] Hub shutdown complete", h.siteID)
 			return
 
 		case conn := <-h.register:
 			h.mu.Lock()
 			h.clients[conn] = true
 			h.mu.Unlock()
-			log.Printf("[WS:%s] Client connected (%d total)", h.siteID, len(h.clients))
+			logging.Debugf("[WS:%s] Client connected (%d total)", h.siteID, len(h.clients))
 
 		case conn := <-h.unregister:
 			h.mu.Lock()
@@ -125,7 +177,7 @@ func (h *SiteHub) run() {
 				conn.Close()
 			}
 			h.mu.Unlock()
-			log.Printf("[WS:%s] Client disconnected (%d remaining)", h.siteID, len(h.clients))
+			logging.Debugf("[WS:%s] Client disconnected (%d remaining)", h.siteID, len(h.clients))
 
 		case message := <-h.broadcast:
 			h.mu.RLock()
@@ -141,9 +193,38 @@ func (h *SiteHub) run() {
 	}
 }
 
-// Stop signals the hub to shutdown
+// drainPollInterval is how often Drain checks whether clients have disconnected
+const drainPollInterval = 50 * time.Millisecond
+
+// Drain sends every client a "server restarting" close frame (1001 going away) so
+// browsers can reconnect to the next instance, waits until they disconnect or ctx
+// is done, then stops the hub
+func (h *SiteHub) Drain(ctx context.Context) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server restarting")
+	h.mu.RLock()
+	for conn := range h.clients {
+		conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(closeGracePeriod))
+	}
+	h.mu.RUnlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for h.ClientCount() > 0 {
+		select {
+		case <-ctx.Done():
+			logging.Debugf("[WS:%s] Drain timed out with %d clients", h.siteID, h.ClientCount())
+			h.Stop()
+			return
+		case <-ticker.C:
+		}
+	}
+	h.Stop()
+}
+
+// Stop shuts the hub down and waits for its event loop to exit. It is safe to call more than once.
 func (h *SiteHub) Stop() {
-	close(h.done)
+	h.stopOnce.Do(func() { close(h.done) })
+	<-h.stopped
 }
 
 // Broadcast sends a message to all connected clients
@@ -152,7 +233,7 @@ func (h *SiteHub) Broadcast(message string) {
 	case h.broadcast <- []byte(message):
 	default:
 		// Channel full, drop message
-		log.Printf("[WS:%s] Broadcast channel full, dropping message", h.siteID)
+		logging.Warnf("[WS:%s] Broadcast channel full, dropping message", h.siteID)
 	}
 }
 
@@ -163,21 +244,31 @@ func (h *SiteHub) ClientCount() int {
 	return len(h.clients)
 }
 
-// HandleWebSocket upgrades HTTP connections to WebSocket
+// HandleWebSocket upgrades HTTP connections to WebSocket. The server's
+// timeouts bound the handshake; hijacking clears them once it succeeds.
 func HandleWebSocket(w http.ResponseWriter, r *http.Request, siteID string) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("[WS:%s] Upgrade error: %v", siteID, err)
+		logging.Errorf("[WS:%s] Upgrade error: %v", siteID, err)
 		return
 	}
 
 	hub := GetHub(siteID)
-	hub.register <- conn
+	select {
+	case hub.register <- conn:
+	case <-hub.done:
+		conn.Close()
+		return
+	}
 
 	// Read loop - handle client messages and disconnection
 	go func() {
 		defer func() {
-			hub.unregister <- conn
+			// A stopped hub has already closed the connection
+			select {
+			case hub.unregister <- conn:
+			case <-hub.done:
+			}
 		}()
 
 		for {
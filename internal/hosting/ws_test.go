@@ -1,8 +1,15 @@
 package hosting
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 func TestHubManager(t *testing.T) {
@@ -73,3 +80,125 @@ func TestSiteIsolation(t *testing.T) {
 		}
 	}
 }
+
+func TestStopAllHubs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(w, r, "stop-all")
+	}))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	hubs := []*SiteHub{GetHub("stop-all"), GetHub("stop-all-idle")}
+
+	// Wait for the client to register
+	deadline := time.Now().Add(time.Second)
+	for hubs[0].ClientCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	StopAllHubs()
+
+	for _, hub := range hubs {
+		select {
+		case <-hub.stopped:
+		default:
+			t.Errorf("hub %s still running after StopAllHubs", hub.siteID)
+		}
+	}
+
+	hubManager.mu.RLock()
+	remaining := len(hubManager.hubs)
+	hubManager.mu.RUnlock()
+	if remaining != 0 {
+		t.Errorf("%d hubs remain after StopAllHubs, want 0", remaining)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("client read error = %v, want going-away close", err)
+	}
+
+	// Stopping again is a no-op
+	hubs[0].Stop()
+}
+
+func TestDrainAllHubs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(w, r, "drain")
+	}))
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	polite, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer polite.Close()
+
+	hub := GetHub("drain")
+	deadline := time.Now().Add(time.Second)
+	for hub.ClientCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The client answers the close frame, so the drain finishes well before the timeout
+	closeErr := make(chan error, 1)
+	go func() {
+		_, _, err := polite.ReadMessage()
+		closeErr <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	DrainAllHubs(ctx)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("DrainAllHubs took %v, want it to finish once clients leave", elapsed)
+	}
+
+	err = <-closeErr
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) || ce.Code != websocket.CloseGoingAway || ce.Text != "server restarting" {
+		t.Errorf("client read error = %v, want 1001 server restarting", err)
+	}
+
+	select {
+	case <-hub.stopped:
+	default:
+		t.Error("hub still running after DrainAllHubs")
+	}
+}
+
+func TestDrainAllHubs_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		HandleWebSocket(w, r, "drain-timeout")
+	}))
+	defer srv.Close()
+
+	// This client never reads, so it never answers the close frame
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	hub := GetHub("drain-timeout")
+	deadline := time.Now().Add(time.Second)
+	for hub.ClientCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	DrainAllHubs(ctx)
+
+	if hub.ClientCount() != 0 {
+		t.Errorf("ClientCount() = %d after drain timeout, want 0", hub.ClientCount())
+	}
+}
@@ -0,0 +1,176 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Output formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	jsonEnabled bool
+	level       = new(slog.LevelVar) // Info by default
+
+	// fileLogger writes JSON lines to the log file opened by OpenFile, if any
+	fileLogger *slog.Logger
+)
+
+// SetLevel sets the minimum level logged by Debugf, Infof, Warnf, Errorf and Request
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// LevelFromFlags maps the --verbose and --quiet flags to a level.
+// Quiet wins: errors only. Verbose enables debug output.
+func LevelFromFlags(verbose, quiet bool) slog.Level {
+	switch {
+	case quiet:
+		return slog.LevelError
+	case verbose:
+		return slog.LevelDebug
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Setup selects the log format. "json" sends all log output, including the
+// standard log package, through a JSON handler on stderr; "text" (or "")
+// keeps the plain log package output.
+func Setup(format string) error {
+	return setup(format, os.Stderr)
+}
+
+func setup(format string, w io.Writer) error {
+	switch format {
+	case "", FormatText:
+		jsonEnabled = false
+		return nil
+	case FormatJSON:
+		// Also redirects log.Printf to the JSON handler
+		slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+		jsonEnabled = true
+		return nil
+	default:
+		return fmt.Errorf("invalid log format: %s (must be '%s' or '%s')", format, FormatText, FormatJSON)
+	}
+}
+
+// OpenFile also writes every log message to path as JSON lines, whatever the
+// console format, so `fazt server logs` can filter and format them. Call it
+// before serving; close the returned file on shutdown.
+func OpenFile(path string) (io.Closer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+	fileLogger = slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level}))
+	return f, nil
+}
+
+// IsJSON reports whether JSON logging is enabled
+func IsJSON() bool {
+	return jsonEnabled
+}
+
+// Request logs a completed HTTP request at info level
+func Request(requestID, method, path string, status int, duration time.Duration) {
+	if !Enabled(slog.LevelInfo) {
+		return
+	}
+	if fileLogger != nil {
+		fileLogger.Info("request",
+			"request_id", requestID,
+			"method", method,
+			"path", path,
+			"status", status,
+			"duration_ms", float64(duration.Microseconds())/1000,
+		)
+	}
+	if jsonEnabled {
+		slog.Info("request",
+			"request_id", requestID,
+			"method", method,
+			"path", path,
+			"status", status,
+			"duration_ms", float64(duration.Microseconds())/1000,
+		)
+		return
+	}
+
+	if requestID != "" {
+		log.Printf("[%s] %s %s %d %v", requestID, method, path, status, duration)
+	} else {
+		log.Printf("%s %s %d %v", method, path, status, duration)
+	}
+}
+
+// Enabled reports whether messages at l are logged
+func Enabled(l slog.Level) bool {
+	return l >= level.Level()
+}
+
+// Debugf logs a debug message (shown with --verbose)
+func Debugf(format string, args ...any) {
+	logf(slog.LevelDebug, format, args...)
+}
+
+// Infof logs an informational message (hidden with --quiet)
+func Infof(format string, args ...any) {
+	logf(slog.LevelInfo, format, args...)
+}
+
+// Warnf logs a warning (hidden with --quiet)
+func Warnf(format string, args ...any) {
+	logf(slog.LevelWarn, format, args...)
+}
+
+// Errorf logs an error (always shown)
+func Errorf(format string, args ...any) {
+	logf(slog.LevelError, format, args...)
+}
+
+// logf writes a formatted message at l, as a JSON record or a plain log line
+func logf(l slog.Level, format string, args ...any) {
+	if !Enabled(l) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if fileLogger != nil {
+		fileLogger.Log(context.Background(), l, msg)
+	}
+	if jsonEnabled {
+		slog.Log(context.Background(), l, msg)
+		return
+	}
+	if l == slog.LevelDebug {
+		msg = "[debug] " + msg
+	}
+	log.Print(msg)
+}
+
+// Event logs a structured event with key/value attributes in JSON mode and to
+// the log file. Text mode skips it; callers already write their own plain log lines.
+func Event(msg string, args ...any) {
+	if !Enabled(slog.LevelInfo) {
+		return
+	}
+	if fileLogger != nil {
+		fileLogger.Info(msg, args...)
+	}
+	if jsonEnabled {
+		slog.Info(msg, args...)
+	}
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureJSON enables JSON logging into a buffer for the duration of the test
+func captureJSON(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	prev := slog.Default()
+	var buf bytes.Buffer
+	if err := setup(FormatJSON, &buf); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+		jsonEnabled = false
+	})
+	return &buf
+}
+
+func decodeLine(t *testing.T, line string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		t.Fatalf("log line is not JSON: %q", line)
+	}
+	return m
+}
+
+func TestRequest_JSON(t *testing.T) {
+	buf := captureJSON(t)
+
+	Request("abc123", "GET", "/api/stats", 200, 1500*time.Microsecond)
+
+	entry := decodeLine(t, strings.TrimSpace(buf.String()))
+	want := map[string]interface{}{
+		"level":       "INFO",
+		"msg":         "request",
+		"request_id":  "abc123",
+		"method":      "GET",
+		"path":        "/api/stats",
+		"status":      float64(200),
+		"duration_ms": 1.5,
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Error("entry missing time")
+	}
+}
+
+func TestSetup_RedirectsStandardLog(t *testing.T) {
+	buf := captureJSON(t)
+
+	log.Printf("Hosting initialized")
+	Event("api_key_create", "user", "admin")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+	if msg := decodeLine(t, lines[0])["msg"]; msg != "Hosting initialized" {
+		t.Errorf("msg = %v, want log.Printf message", msg)
+	}
+	if user := decodeLine(t, lines[1])["user"]; user != "admin" {
+		t.Errorf("user = %v, want admin", user)
+	}
+}
+
+func TestSetup_Text(t *testing.T) {
+	if err := Setup(""); err != nil || IsJSON() {
+		t.Errorf("Setup(\"\") = %v, IsJSON = %v; want text", err, IsJSON())
+	}
+	if err := Setup("xml"); err == nil {
+		t.Error("Setup should reject unknown formats")
+	}
+}
+
+func TestLevels_Text(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+		SetLevel(slog.LevelInfo)
+	}()
+
+	tests := []struct {
+		level slog.Level
+		want  string
+	}{
+		{LevelFromFlags(false, false), "info\nwarn\nerror\nGET /x 200 1ms\n"},
+		{LevelFromFlags(true, false), "[debug] debug\ninfo\nwarn\nerror\nGET /x 200 1ms\n"},
+		{LevelFromFlags(false, true), "error\n"},
+		{LevelFromFlags(true, true), "error\n"},
+	}
+
+	for _, tt := range tests {
+		buf.Reset()
+		SetLevel(tt.level)
+		Debugf("debug")
+		Infof("info")
+		Warnf("warn")
+		Errorf("error")
+		Request("", "GET", "/x", 200, time.Millisecond)
+		if buf.String() != tt.want {
+			t.Errorf("level %v logged %q, want %q", tt.level, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestLevels_JSON(t *testing.T) {
+	buf := captureJSON(t)
+	defer SetLevel(slog.LevelInfo)
+	SetLevel(slog.LevelDebug)
+
+	Debugf("session %s created", "abc")
+	Errorf("boom")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if e := decodeLine(t, lines[0]); e["level"] != "DEBUG" || e["msg"] != "session abc created" {
+		t.Errorf("debug entry = %v", e)
+	}
+	if e := decodeLine(t, lines[1]); e["level"] != "ERROR" {
+		t.Errorf("error entry = %v", e)
+	}
+}
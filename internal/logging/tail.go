@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// followInterval is how often Follow checks the log file for new lines
+const followInterval = 500 * time.Millisecond
+
+// ParseLevel parses a --level value: debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("invalid level: %s (must be debug, info, warn or error)", s)
+	}
+}
+
+// FormatLine turns one JSON line from the log file into readable text:
+// "2006-01-02 15:04:05 LEVEL message key=value ...". Lines that aren't
+// JSON records are passed through as info.
+func FormatLine(line []byte) (string, slog.Level) {
+	var record map[string]interface{}
+	if err := json.Unmarshal(line, &record); err != nil {
+		return string(line), slog.LevelInfo
+	}
+
+	var lvl slog.Level
+	if s, ok := record["level"].(string); ok {
+		lvl.UnmarshalText([]byte(s))
+	}
+
+	var out strings.Builder
+	if s, ok := record["time"].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			out.WriteString(t.Local().Format("2006-01-02 15:04:05") + " ")
+		}
+	}
+	fmt.Fprintf(&out, "%-5s %v", lvl.String(), record["msg"])
+
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		if k != "time" && k != "level" && k != "msg" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&out, " %s=%v", k, record[k])
+	}
+	return out.String(), lvl
+}
+
+// Tail returns the last n formatted lines of the log file at or above min,
+// and the offset Follow should continue from
+func Tail(path string, n int, min slog.Level) ([]string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	var lines []string
+	var offset int64
+	reader := bufio.NewReader(f)
+	for {
+		raw, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break // a trailing partial line is left for Follow
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += int64(len(raw))
+
+		text, lvl := FormatLine(bytes.TrimRight(raw, "\r\n"))
+		if lvl < min {
+			continue
+		}
+		lines = append(lines, text)
+		if len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+	return lines, offset, nil
+}
+
+// Follow calls fn with each new formatted line at or above min appended to the
+// log file after offset, until ctx is cancelled. A file that shrinks (truncated
+// or rotated) is read again from the start.
+func Follow(ctx context.Context, path string, offset int64, min slog.Level, fn func(string)) error {
+	var partial []byte
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // mid-rotation
+		}
+		if info.Size() < offset {
+			offset, partial = 0, nil
+		}
+		if info.Size() == offset {
+			continue
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		data := make([]byte, info.Size()-offset)
+		n, err := f.ReadAt(data, offset)
+		f.Close()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		offset += int64(n)
+
+		data = append(partial, data[:n]...)
+		for {
+			i := bytes.IndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			text, lvl := FormatLine(bytes.TrimRight(data[:i], "\r"))
+			if lvl >= min {
+				fn(text)
+			}
+			data = data[i+1:]
+		}
+		partial = append([]byte(nil), data...)
+	}
+}
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"":      slog.LevelInfo,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	}
+	for in, want := range tests {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("ParseLevel(trace) should fail")
+	}
+}
+
+func TestFormatLine(t *testing.T) {
+	text, lvl := FormatLine([]byte(`{"time":"2025-03-01T12:00:00Z","level":"WARN","msg":"request","status":503,"path":"/x"}`))
+	if lvl != slog.LevelWarn {
+		t.Errorf("level = %v, want WARN", lvl)
+	}
+	if !strings.Contains(text, "WARN  request path=/x status=503") {
+		t.Errorf("text = %q, want level, message and sorted attributes", text)
+	}
+
+	text, lvl = FormatLine([]byte("plain line"))
+	if text != "plain line" || lvl != slog.LevelInfo {
+		t.Errorf("non-JSON line = %q, %v; want it passed through as info", text, lvl)
+	}
+}
+
+// useLogFile sends log output to a file in a temp dir for the duration of the test
+func useLogFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	f, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	log.SetOutput(io.Discard)
+	SetLevel(slog.LevelDebug)
+	t.Cleanup(func() {
+		f.Close()
+		fileLogger = nil
+		log.SetOutput(os.Stderr)
+		SetLevel(slog.LevelInfo)
+	})
+	return path
+}
+
+func TestOpenFile_Tail(t *testing.T) {
+	path := useLogFile(t)
+
+	Debugf("debug %d", 1)
+	Infof("info %d", 2)
+	Warnf("warn %d", 3)
+	Errorf("error %d", 4)
+	Request("req-1", "GET", "/x", 200, time.Millisecond)
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("log file should exist with 0600 permissions: %v", err)
+	}
+
+	lines, offset, err := Tail(path, 10, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	if len(lines) != 4 || !strings.Contains(lines[0], "info 2") || !strings.Contains(lines[3], "request_id=req-1") {
+		t.Errorf("Tail(info) = %q, want info, warn, error and the request", lines)
+	}
+	if offset != info.Size() {
+		t.Errorf("offset = %d, want file size %d", offset, info.Size())
+	}
+
+	lines, _, _ = Tail(path, 1, slog.LevelWarn)
+	if len(lines) != 1 || !strings.Contains(lines[0], "error 4") {
+		t.Errorf("Tail(1, warn) = %q, want only the last error", lines)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	path := useLogFile(t)
+	Infof("before")
+	_, offset, err := Tail(path, 0, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var got []string
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- Follow(ctx, path, offset, slog.LevelWarn, func(line string) {
+			mu.Lock()
+			got = append(got, line)
+			mu.Unlock()
+		})
+	}()
+
+	Infof("skipped")
+	Warnf("after")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Follow failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 || !strings.Contains(got[0], "after") {
+		t.Errorf("Follow lines = %q, want only the new warning", got)
+	}
+}
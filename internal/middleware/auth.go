@@ -1,11 +1,11 @@
 package middleware
 
 import (
-	"log"
 	"net/http"
 	"strings"
 
 	"github.com/jikku/command-center/internal/auth"
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // AuthMiddleware checks if a user is authenticated before allowing access to protected routes
@@ -23,21 +23,21 @@ func AuthMiddleware(sessionStore *auth.SessionStore) func(http.Handler) http.Han
 			sessionID, err := auth.GetSessionCookie(r)
 			if err != nil {
 				// No session cookie, redirect to login
-				log.Printf("No session cookie for %s %s", r.Method, r.URL.Path)
+				logging.Debugf("No session cookie for %s %s", r.Method, r.URL.Path)
 				redirectToLogin(w, r)
 				return
 			}
 
-			// Validate session
-			valid, err := sessionStore.ValidateSession(sessionID)
+			// Validate session (and the client fingerprint, if configured)
+			valid, err := sessionStore.ValidateSessionFingerprint(sessionID, auth.RequestFingerprint(r))
 			if err != nil {
-				log.Printf("Session validation error: %v", err)
+				logging.Errorf("Session validation error: %v", err)
 				redirectToLogin(w, r)
 				return
 			}
 
 			if !valid {
-				log.Printf("Invalid or expired session for %s %s", r.Method, r.URL.Path)
+				logging.Debugf("Invalid or expired session for %s %s", r.Method, r.URL.Path)
 				redirectToLogin(w, r)
 				return
 			}
@@ -59,8 +59,14 @@ func requiresAuth(path string) bool {
 		"/static/",
 		"/login",
 		"/api/login",
+		"/api/auth/status",
+		"/api/reset-password",
 		"/api/deploy",
 		"/health",
+		"/livez",
+		"/readyz",
+		"/api/openapi.json",
+		"/api/version",
 	}
 
 	// Check if path matches any public path
@@ -78,9 +84,7 @@ func requiresAuth(path string) bool {
 func redirectToLogin(w http.ResponseWriter, r *http.Request) {
 	// For API requests, return 401 Unauthorized
 	if strings.HasPrefix(r.URL.Path, "/api/") {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(`{"error":"Authentication required"}`))
+		JSONError(w, "Authentication required", http.StatusUnauthorized)
 		return
 	}
 
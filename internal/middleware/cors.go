@@ -0,0 +1,34 @@
+package middleware
+
+import "net/http"
+
+// trackingPaths are called cross-origin by the sites being tracked
+var trackingPaths = map[string]bool{
+	"/track":     true,
+	"/track.js":  true,
+	"/pixel.gif": true,
+}
+
+// IsTrackingPath reports whether path is a public tracking endpoint
+func IsTrackingPath(path string) bool {
+	return trackingPaths[path]
+}
+
+// TrackingCORS lets any origin call a tracking endpoint in every environment,
+// unlike the development-only dashboard CORS. Credentials are never allowed.
+// Preflight requests are answered here.
+func TrackingCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Del("Access-Control-Allow-Credentials")
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Max-Age", "86400")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
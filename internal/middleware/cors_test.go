@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrackingCORS(t *testing.T) {
+	called := false
+	handler := TrackingCORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// Preflight is answered without reaching the handler
+	req := httptest.NewRequest(http.MethodOptions, "/track", nil)
+	req.Header.Set("Origin", "https://blog.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "content-type")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent || called {
+		t.Errorf("preflight: status %d, handler called %v; want 204 from the middleware", rec.Code, called)
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
+		"Access-Control-Allow-Headers": "Content-Type",
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want unset", got)
+	}
+
+	// Actual requests get the headers and reach the handler
+	req = httptest.NewRequest(http.MethodPost, "/track", nil)
+	req.Header.Set("Origin", "https://blog.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("POST: handler called %v, Allow-Origin %q", called, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestIsTrackingPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/track":      true,
+		"/track.js":   true,
+		"/pixel.gif":  true,
+		"/api/track":  false,
+		"/tracker":    false,
+		"/api/events": false,
+	} {
+		if got := IsTrackingPath(path); got != want {
+			t.Errorf("IsTrackingPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
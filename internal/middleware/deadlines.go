@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ConnDeadlines replaces the server's read and write timeouts on uploads,
+// which would otherwise be cut off mid-transfer, with upload from the start of
+// the request (0 for no limit). Streams are left alone here: their handlers
+// lift the deadlines once the stream is authorized and open, so a client can't
+// hold a connection idle just by asking for one.
+func ConnDeadlines(upload time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isUpload(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var deadline time.Time
+			if upload > 0 {
+				deadline = time.Now().Add(upload)
+			}
+
+			// Writers that can't change deadlines (tests, hijacked) keep the server's
+			rc := http.NewResponseController(w)
+			rc.SetReadDeadline(deadline)
+			rc.SetWriteDeadline(deadline)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isUpload reports whether a request carries a deploy or site file upload
+func isUpload(r *http.Request) bool {
+	return r.URL.Path == "/api/deploy" ||
+		(r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/api/sites/") && strings.HasSuffix(r.URL.Path, "/files"))
+}
+
+// isStream reports whether a request is for a streaming route: a site's log
+// stream or a site WebSocket. Matched by path, never by client headers.
+func isStream(r *http.Request) bool {
+	if r.Method != http.MethodGet {
+		return false
+	}
+	return r.URL.Path == "/ws" ||
+		(strings.HasPrefix(r.URL.Path, "/api/sites/") && strings.HasSuffix(r.URL.Path, "/logs/stream"))
+}
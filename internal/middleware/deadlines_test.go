@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConnDeadlines(t *testing.T) {
+	// Responds after the server's write timeout has passed
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("done"))
+	})
+	srv := httptest.NewUnstartedServer(ConnDeadlines(time.Minute)(slow))
+	srv.Config.ReadTimeout = 50 * time.Millisecond
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		accept string
+		wantOK bool
+	}{
+		{"ordinary request", http.MethodGet, "/api/stats", "", false},
+		{"deploy", http.MethodPost, "/api/deploy", "", true},
+		{"site file upload", http.MethodPut, "/api/sites/blog/files", "", true},
+		{"site file listing", http.MethodGet, "/api/sites/blog/files", "", false},
+		// Streams lift their own deadlines once open, so a header can't buy one
+		{"event stream", http.MethodGet, "/api/sites/blog/logs/stream", "text/event-stream", false},
+		{"event stream header elsewhere", http.MethodGet, "/api/stats", "text/event-stream", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, srv.URL+tt.path, strings.NewReader("body"))
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			// A fresh connection each time, so no deadline carries over
+			req.Close = true
+
+			resp, err := srv.Client().Do(req)
+			var body []byte
+			if err == nil {
+				body, err = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+			gotOK := err == nil && string(body) == "done"
+			if gotOK != tt.wantOK {
+				t.Errorf("completed = %v (err %v), want %v", gotOK, err, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JSONError writes an error response as {"success": false, "error", "request_id", "status"}.
+// The request ID is the one RequestTracing set on the response, so clients can quote
+// it when reporting a problem.
+func JSONError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    false,
+		"error":      message,
+		"request_id": w.Header().Get("X-Request-ID"),
+		"status":     status,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"net/http"
+	"strings"
 
 	"github.com/jikku/command-center/internal/config"
 )
@@ -11,23 +12,53 @@ import (
 // MaxBodySize is the default maximum request body size (1MB)
 const MaxBodySize = 1 << 20 // 1MB
 
-// BodySizeLimit limits the size of request bodies to prevent memory exhaustion
-func BodySizeLimit(maxBytes int64) func(http.Handler) http.Handler {
+// BodySizeLimit limits the size of request bodies to prevent memory exhaustion.
+// Requests are capped at maxBytes unless their path is in routes, which gives
+// endpoints that accept uploads their own cap. A route is an exact path in which
+// a "*" segment matches any one segment, e.g. /api/sites/*/files.
+func BodySizeLimit(maxBytes int64, routes map[string]int64) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Skip for paths that have their own limits (deploy has 100MB)
-			if r.URL.Path == "/api/deploy" {
-				next.ServeHTTP(w, r)
-				return
+			limit := maxBytes
+			if routeLimit, ok := routeBodyLimit(routes, r.URL.Path); ok {
+				limit = routeLimit
 			}
 
-			// Limit request body size
-			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// routeBodyLimit returns the limit of the route matching path. An exact match
+// wins over a wildcard one.
+func routeBodyLimit(routes map[string]int64, path string) (int64, bool) {
+	if limit, ok := routes[path]; ok {
+		return limit, true
+	}
+	segments := strings.Split(path, "/")
+	for route, limit := range routes {
+		if !strings.Contains(route, "*") {
+			continue
+		}
+		parts := strings.Split(route, "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		match := true
+		for i, part := range parts {
+			if part != "*" && part != segments[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return limit, true
+		}
+	}
+	return 0, false
+}
+
 // RequestTracing adds a unique request ID header for tracing
 func RequestTracing(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -54,7 +85,8 @@ func generateRequestID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// SecurityHeaders adds security-related HTTP headers
+// SecurityHeaders adds the strict security headers for the dashboard and API.
+// Hosted sites get SetSiteSecurityHeaders instead.
 func SecurityHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg := config.Get()
@@ -66,14 +98,7 @@ func SecurityHeaders(next http.Handler) http.Handler {
 		w.Header().Set("X-XSS-Protection", "1; mode=block")
 
 		// Content Security Policy
-		csp := "default-src 'self'; " +
-			"script-src 'self' 'unsafe-inline' 'unsafe-eval' https://cdn.jsdelivr.net; " +
-			"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; " +
-			"img-src 'self' data: https:; " +
-			"font-src 'self' data: https://cdn.jsdelivr.net; " +
-			"connect-src 'self'"
-
-		w.Header().Set("Content-Security-Policy", csp)
+		w.Header().Set("Content-Security-Policy", cfg.Security.DashboardPolicy())
 
 		// HSTS in production
 		if cfg.IsProduction() {
@@ -86,3 +111,33 @@ func SecurityHeaders(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// SetSiteSecurityHeaders adds the headers for hosted sites. They are lighter than
+// the dashboard's: a site may be framed by other pages, and what it can load is
+// governed by security.site_csp alone.
+func SetSiteSecurityHeaders(w http.ResponseWriter) {
+	siteSecurityHeaders(w.Header(), config.Get())
+}
+
+// siteSecurityHeaders sets the hosted-site headers from cfg. security.site_headers
+// is applied last: it adds or overrides headers, and an empty value removes one.
+func siteSecurityHeaders(h http.Header, cfg *config.Config) {
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+	if csp := cfg.Security.SitePolicy(); csp != "" {
+		h.Set("Content-Security-Policy", csp)
+	}
+
+	// Custom domains aren't ours, so no includeSubDomains
+	if cfg.IsProduction() {
+		h.Set("Strict-Transport-Security", "max-age=31536000")
+	}
+
+	for name, value := range cfg.Security.SiteHeaders {
+		if value == "" {
+			h.Del(name)
+		} else {
+			h.Set(name, value)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/jikku/command-center/internal/config"
 )
 
 func TestBodySizeLimit(t *testing.T) {
@@ -19,8 +22,11 @@ func TestBodySizeLimit(t *testing.T) {
 		w.Write(body)
 	})
 
-	// Wrap with body size limit (100 bytes)
-	limited := BodySizeLimit(100)(handler)
+	// Wrap with body size limit (100 bytes), with larger caps for upload routes
+	limited := BodySizeLimit(100, map[string]int64{
+		"/api/deploy":        300,
+		"/api/sites/*/files": 150,
+	})(handler)
 
 	tests := []struct {
 		name       string
@@ -31,7 +37,12 @@ func TestBodySizeLimit(t *testing.T) {
 		{"small body", "/api/test", 50, http.StatusOK},
 		{"exact limit", "/api/test", 100, http.StatusOK},
 		{"over limit", "/api/test", 200, http.StatusRequestEntityTooLarge},
-		{"deploy endpoint skipped", "/api/deploy", 200, http.StatusOK}, // Deploy has its own limit
+		{"deploy route limit", "/api/deploy", 200, http.StatusOK},
+		{"over deploy route limit", "/api/deploy", 400, http.StatusRequestEntityTooLarge},
+		{"wildcard route limit", "/api/sites/blog/files", 150, http.StatusOK},
+		{"over wildcard route limit", "/api/sites/blog/files", 151, http.StatusRequestEntityTooLarge},
+		{"wildcard matches one segment", "/api/sites/blog/extra/files", 150, http.StatusRequestEntityTooLarge},
+		{"route prefix not matched", "/api/deploy/x", 200, http.StatusRequestEntityTooLarge},
 	}
 
 	for _, tt := range tests {
@@ -111,6 +122,37 @@ func TestRequestTracing(t *testing.T) {
 	})
 }
 
+func TestJSONError(t *testing.T) {
+	handler := RequestTracing(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		JSONError(w, "Something broke", http.StatusBadRequest)
+	}))
+
+	req := httptest.NewRequest("GET", "/api/test", nil)
+	req.Header.Set("X-Request-ID", "abc123")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	var body struct {
+		Success   bool   `json:"success"`
+		Error     string `json:"error"`
+		RequestID string `json:"request_id"`
+		Status    int    `json:"status"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if body.Success || body.Error != "Something broke" || body.RequestID != "abc123" || body.Status != 400 {
+		t.Errorf("body = %+v, want the error with request_id abc123 and status 400", body)
+	}
+}
+
 func TestGenerateRequestID(t *testing.T) {
 	id1 := generateRequestID()
 	if id1 == "" {
@@ -137,6 +179,44 @@ func TestSecurityHeaders(t *testing.T) {
 	t.Skip("Requires config initialization - covered by integration tests")
 }
 
+func TestSiteSecurityHeaders(t *testing.T) {
+	cfg := &config.Config{Server: config.ServerConfig{Env: "production"}}
+	h := http.Header{}
+	siteSecurityHeaders(h, cfg)
+
+	if h.Get("X-Frame-Options") != "" {
+		t.Error("hosted sites must be frameable by default")
+	}
+	if h.Get("Content-Security-Policy") != config.DefaultSiteCSP {
+		t.Errorf("CSP = %q, want the site default", h.Get("Content-Security-Policy"))
+	}
+	if h.Get("X-Content-Type-Options") != "nosniff" || h.Get("Strict-Transport-Security") == "" {
+		t.Errorf("headers = %v, want nosniff and HSTS in production", h)
+	}
+
+	// site_headers adds, overrides and removes
+	none := ""
+	cfg.Security = config.SecurityConfig{
+		SiteCSP: &none,
+		SiteHeaders: map[string]string{
+			"X-Frame-Options":        "SAMEORIGIN",
+			"Referrer-Policy":        "no-referrer",
+			"X-Content-Type-Options": "",
+		},
+	}
+	h = http.Header{}
+	siteSecurityHeaders(h, cfg)
+	if h.Get("X-Frame-Options") != "SAMEORIGIN" || h.Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("headers = %v, want site_headers applied", h)
+	}
+	if _, ok := h["X-Content-Type-Options"]; ok {
+		t.Error("an empty site_headers value should remove the header")
+	}
+	if _, ok := h["Content-Security-Policy"]; ok {
+		t.Error("site_csp \"\" should send no CSP")
+	}
+}
+
 func TestMaxBodySizeConstant(t *testing.T) {
 	// Verify the constant is 1MB
 	expected := int64(1 << 20) // 1MB
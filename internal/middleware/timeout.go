@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// RequestTimeout cancels each request's context after d, so context-aware DB
+// calls abort, and answers 503 if the handler hadn't started its response by
+// then. Long-lived requests (uploads, WebSockets, event streams) are
+// exempt. A zero d disables the timeout.
+func RequestTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isLongLived(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+
+			r = r.WithContext(ctx)
+			tw := &timeoutWriter{ResponseWriter: w, req: r}
+			next.ServeHTTP(tw, r)
+
+			// A handler that gave up on the cancelled context without writing anything
+			if !tw.wroteHeader && tw.expired() {
+				tw.timeout()
+			}
+		})
+	}
+}
+
+// isLongLived reports whether a request is expected to outlast any sensible timeout
+func isLongLived(r *http.Request) bool {
+	return isUpload(r) || isStream(r)
+}
+
+// timeoutWriter replaces a response that starts after the deadline with a 503,
+// discarding whatever the handler writes once its queries have been cancelled
+type timeoutWriter struct {
+	http.ResponseWriter
+	req         *http.Request
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) expired() bool {
+	return errors.Is(tw.req.Context().Err(), context.DeadlineExceeded)
+}
+
+// timeout writes the 503 in place of the handler's response
+func (tw *timeoutWriter) timeout() {
+	tw.wroteHeader = true
+	tw.timedOut = true
+	logging.Warnf("Request timed out: %s %s", tw.req.Method, tw.req.URL.Path)
+
+	// Validators set for the abandoned response don't describe the error
+	h := tw.ResponseWriter.Header()
+	h.Del("ETag")
+	h.Del("Last-Modified")
+	h.Del("Content-Length")
+	JSONError(tw.ResponseWriter, "Request timed out", http.StatusServiceUnavailable)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	if tw.wroteHeader {
+		return
+	}
+	if tw.expired() {
+		tw.timeout()
+		return
+	}
+	tw.wroteHeader = true
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.WriteHeader(http.StatusOK)
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	// Waits for its context like a handler blocked in QueryContext, then reports the error
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			w.Header().Set("ETag", `"stale"`)
+			http.Error(w, "query failed", http.StatusInternalServerError)
+		case <-time.After(100 * time.Millisecond):
+			w.Write([]byte("done"))
+		}
+	})
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	silent := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	tests := []struct {
+		name       string
+		handler    http.Handler
+		path       string
+		accept     string
+		wantStatus int
+	}{
+		{"fast handler", fast, "/api/stats", "", http.StatusOK},
+		{"slow handler", slow, "/api/stats", "", http.StatusServiceUnavailable},
+		{"handler that writes nothing", silent, "/api/stats", "", http.StatusServiceUnavailable},
+		{"deploy exempt", slow, "/api/deploy", "", http.StatusOK},
+		{"event stream exempt", slow, "/api/sites/blog/logs/stream", "text/event-stream", http.StatusOK},
+		{"websocket exempt", slow, "/ws", "", http.StatusOK},
+		{"event stream header elsewhere", slow, "/api/stats", "text/event-stream", http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			RequestTimeout(20*time.Millisecond)(tt.handler).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusServiceUnavailable {
+				return
+			}
+			if rec.Header().Get("ETag") != "" {
+				t.Error("503 should not carry the abandoned response's ETag")
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("503 body is not JSON: %v", err)
+			}
+			if body["error"] != "Request timed out" {
+				t.Errorf("error = %v, want Request timed out", body["error"])
+			}
+		})
+	}
+}
+
+func TestRequestTimeout_Disabled(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("context should have no deadline when the timeout is disabled")
+		}
+	})
+	RequestTimeout(0)(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"database/sql"
 	"encoding/json"
+	"net/http"
 	"strings"
 	"time"
 )
@@ -24,46 +25,84 @@ type Event struct {
 
 // TagsToString converts tags slice to comma-separated string for storage
 func (e *Event) TagsToString() string {
-	if len(e.Tags) == 0 {
-		return ""
-	}
-	return strings.Join(e.Tags, ",")
+	return JoinTags(e.Tags)
 }
 
 // TagsFromString parses comma-separated string to tags slice
 func (e *Event) TagsFromString(tagsStr string) {
-	if tagsStr == "" {
-		e.Tags = []string{}
-		return
+	e.Tags = SplitTags(tagsStr)
+}
+
+// NormalizeTags trims and lowercases tags, splits comma-joined entries, and drops
+// empty and duplicate tags (the first occurrence keeps its position)
+func NormalizeTags(tags []string) []string {
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, entry := range tags {
+		for _, tag := range strings.Split(entry, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			out = append(out, tag)
+		}
 	}
-	e.Tags = strings.Split(tagsStr, ",")
+	return out
+}
+
+// JoinTags normalizes tags and joins them for storage
+func JoinTags(tags []string) string {
+	return strings.Join(NormalizeTags(tags), ",")
+}
+
+// SplitTags parses stored comma-separated tags into normalized tags
+func SplitTags(tagsStr string) []string {
+	return NormalizeTags([]string{tagsStr})
 }
 
 // Redirect represents a URL redirect with click tracking
 type Redirect struct {
-	ID          int64     `json:"id"`
-	Slug        string    `json:"slug"`
-	Destination string    `json:"destination"`
-	Tags        []string  `json:"tags"`
-	ClickCount  int64     `json:"click_count"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          int64      `json:"id"`
+	Slug        string     `json:"slug"`
+	Destination string     `json:"destination"`
+	Tags        []string   `json:"tags"`
+	ClickCount  int64      `json:"click_count"`
+	StatusCode  int        `json:"status_code"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ValidRedirectStatus reports whether code is an allowed redirect status (301, 302, 307 or 308)
+func ValidRedirectStatus(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// IsActive reports whether the redirect's schedule allows it to fire at now
+func (r *Redirect) IsActive(now time.Time) bool {
+	if r.StartsAt != nil && now.Before(*r.StartsAt) {
+		return false
+	}
+	if r.ExpiresAt != nil && !now.Before(*r.ExpiresAt) {
+		return false
+	}
+	return true
 }
 
 // TagsToString converts tags slice to comma-separated string for storage
 func (r *Redirect) TagsToString() string {
-	if len(r.Tags) == 0 {
-		return ""
-	}
-	return strings.Join(r.Tags, ",")
+	return JoinTags(r.Tags)
 }
 
 // TagsFromString parses comma-separated string to tags slice
 func (r *Redirect) TagsFromString(tagsStr string) {
-	if tagsStr == "" {
-		r.Tags = []string{}
-		return
-	}
-	r.Tags = strings.Split(tagsStr, ",")
+	r.Tags = SplitTags(tagsStr)
 }
 
 // Webhook represents a webhook endpoint configuration
@@ -117,6 +156,28 @@ type TimelineStat struct {
 	Count     int64  `json:"count"`
 }
 
+// ReferrerStat represents clicks from a referrer
+type ReferrerStat struct {
+	Referrer string `json:"referrer"`
+	Count    int64  `json:"count"`
+}
+
+// CountryStat represents clicks from a country ("" when unknown)
+type CountryStat struct {
+	Country string `json:"country"`
+	Count   int64  `json:"count"`
+}
+
+// RedirectStats represents click analytics for a single redirect
+type RedirectStats struct {
+	ID           int64          `json:"id"`
+	Slug         string         `json:"slug"`
+	TotalClicks  int64          `json:"total_clicks"`
+	Daily        []TimelineStat `json:"daily"`
+	TopReferrers []ReferrerStat `json:"top_referrers"`
+	Countries    []CountryStat  `json:"countries"`
+}
+
 // TrackRequest represents an incoming tracking request
 type TrackRequest struct {
 	Hostname    string            `json:"h"`     // hostname/domain
@@ -126,6 +187,7 @@ type TrackRequest struct {
 	Tags        []string          `json:"t"`     // tags array
 	QueryParams map[string]string `json:"q"`     // query parameters
 	Referrer    string            `json:"ref"`   // referrer
+	Token       string            `json:"k"`     // public tracking token, required in token mode
 }
 
 // ToQueryParamsJSON converts query params map to JSON string
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"nil", nil, []string{}},
+		{"trim and lowercase", []string{" Blog ", "NEWS"}, []string{"blog", "news"}},
+		{"dedupe keeps first position", []string{"blog", "news", "Blog", "blog "}, []string{"blog", "news"}},
+		{"drop empties", []string{"", "  ", "app"}, []string{"app"}},
+		{"split comma-joined entries", []string{"app, Production,,app"}, []string{"app", "production"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeTags(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NormalizeTags(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJoinAndSplitTags(t *testing.T) {
+	if got := JoinTags([]string{"Blog", "blog ", "News"}); got != "blog,news" {
+		t.Errorf("JoinTags() = %q, want %q", got, "blog,news")
+	}
+	if got := SplitTags(" Blog,,news,BLOG"); !reflect.DeepEqual(got, []string{"blog", "news"}) {
+		t.Errorf("SplitTags() = %q, want [blog news]", got)
+	}
+	if got := SplitTags(""); len(got) != 0 {
+		t.Errorf("SplitTags(\"\") = %q, want empty", got)
+	}
+}
@@ -0,0 +1,81 @@
+package notifier
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
+)
+
+// CertExpiryCheckInterval is how often stored certificates are checked for expiry
+const CertExpiryCheckInterval = 12 * time.Hour
+
+// ExpiringCerts returns the certificates that expire within window of now,
+// including ones that already have
+func ExpiringCerts(certs []database.CertInfo, now time.Time, window time.Duration) []database.CertInfo {
+	var expiring []database.CertInfo
+	for _, c := range certs {
+		if c.NotAfter.Sub(now) < window {
+			expiring = append(expiring, c)
+		}
+	}
+	return expiring
+}
+
+// certExpiryMessage describes a certificate that should have been renewed by now
+func certExpiryMessage(c database.CertInfo, now time.Time) string {
+	left := c.NotAfter.Sub(now)
+	if left <= 0 {
+		return fmt.Sprintf("Certificate for %s expired on %s and was not renewed", c.Domain, c.NotAfter.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("Certificate for %s expires on %s (%d days left) and has not renewed",
+		c.Domain, c.NotAfter.Format("2006-01-02"), int(left.Hours()/24))
+}
+
+// StartCertExpiryCheck checks the stored certificates now and every interval until
+// the returned stop function is called, sending an error notification for each one
+// that expires within window. A certificate is reported once; a renewal replaces it.
+func StartCertExpiryCheck(storage *database.SQLCertStorage, window, interval time.Duration) (stop func()) {
+	reported := make(map[string]time.Time) // domain -> NotAfter already alerted
+
+	check := func(now time.Time) {
+		certs, err := storage.Certificates(context.Background())
+		if err != nil {
+			logging.Errorf("Certificate expiry check failed: %v", err)
+			return
+		}
+		for _, c := range ExpiringCerts(certs, now, window) {
+			if reported[c.Domain].Equal(c.NotAfter) {
+				continue
+			}
+			reported[c.Domain] = c.NotAfter
+
+			msg := certExpiryMessage(c, now)
+			logging.Warnf("%s", msg)
+			if err := NotifyError(msg); err != nil {
+				logging.Errorf("Failed to send certificate expiry alert: %v", err)
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		check(time.Now())
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				check(now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,31 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jikku/command-center/internal/database"
+)
+
+func TestExpiringCerts(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	certs := []database.CertInfo{
+		{Domain: "expired.example.com", NotAfter: now.Add(-day)},
+		{Domain: "soon.example.com", NotAfter: now.Add(5 * day)},
+		{Domain: "fine.example.com", NotAfter: now.Add(60 * day)},
+	}
+
+	expiring := ExpiringCerts(certs, now, 14*day)
+	if len(expiring) != 2 || expiring[0].Domain != "expired.example.com" || expiring[1].Domain != "soon.example.com" {
+		t.Fatalf("ExpiringCerts() = %+v, want the expired and soon certs", expiring)
+	}
+
+	if msg := certExpiryMessage(expiring[0], now); !strings.Contains(msg, "expired on 2026-02-28") {
+		t.Errorf("expired message = %q", msg)
+	}
+	if msg := certExpiryMessage(expiring[1], now); !strings.Contains(msg, "5 days left") {
+		t.Errorf("expiring message = %q", msg)
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
 	"github.com/jikku/command-center/internal/config"
 	"github.com/jikku/command-center/internal/database"
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // Notification types
@@ -26,13 +26,13 @@ func Send(title, message, notificationType string) error {
 
 	// In development mode, just log instead of sending
 	if cfg.IsDevelopment() {
-		log.Printf("[NTFY MOCK] Type: %s, Title: %s, Message: %s", notificationType, title, message)
+		logging.Infof("[NTFY MOCK] Type: %s, Title: %s, Message: %s", notificationType, title, message)
 		return logNotification(0, notificationType, fmt.Sprintf("%s: %s", title, message))
 	}
 
 	// Check if ntfy topic is configured
 	if cfg.Ntfy.Topic == "" {
-		log.Println("ntfy.sh topic not configured, skipping notification")
+		logging.Debugf("ntfy.sh topic not configured, skipping notification")
 		return nil
 	}
 
@@ -82,7 +82,7 @@ func Send(title, message, notificationType string) error {
 		return fmt.Errorf("ntfy.sh returned status %d", resp.StatusCode)
 	}
 
-	log.Printf("Notification sent: %s - %s", title, message)
+	logging.Infof("Notification sent: %s - %s", title, message)
 	return logNotification(0, notificationType, fmt.Sprintf("%s: %s", title, message))
 }
 
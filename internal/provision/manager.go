@@ -7,8 +7,8 @@ import (
 	"path/filepath"
 	"strconv"
 
+	"github.com/jikku/command-center/internal/auth"
 	"github.com/jikku/command-center/internal/config"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type InstallOptions struct {
@@ -42,7 +42,7 @@ func RunInstall(opts InstallOptions) error {
 	gid, _ := strconv.Atoi(targetUser.Gid)
 
 	// 2. Install Binary
-	targetBin := "/usr/local/bin/fazt"
+	targetBin := filepath.Join("/usr/local/bin", config.AppName)
 	if err := InstallBinary(targetBin); err != nil {
 		return err
 	}
@@ -53,7 +53,7 @@ func RunInstall(opts InstallOptions) error {
 	}
 
 	// 4. Configure
-	configDir := filepath.Join(targetUser.HomeDir, ".config", "fazt")
+	configDir := filepath.Join(targetUser.HomeDir, ".config", config.AppName)
 	configPath := filepath.Join(configDir, "config.json")
 	
 	fmt.Printf("Creating configuration at %s...\n", configPath)
@@ -68,7 +68,7 @@ func RunInstall(opts InstallOptions) error {
 
 	// Generate Config
 	// Hash password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(opts.AdminPassword), 12)
+	passwordHash, err := auth.Hash(opts.AdminPassword)
 	if err != nil {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
@@ -84,7 +84,7 @@ func RunInstall(opts InstallOptions) error {
 		},
 		Auth: config.AuthConfig{
 			Username:     opts.AdminUser,
-			PasswordHash: string(passwordHash),
+			PasswordHash: passwordHash,
 		},
 		HTTPS: config.HTTPSConfig{
 			Enabled: opts.HTTPS,
@@ -123,12 +123,12 @@ func RunInstall(opts InstallOptions) error {
 		User:       opts.User,
 		BinaryPath: targetBin,
 	}
-	if err := InstallSystemdService("fazt", svcConfig); err != nil {
+	if err := InstallSystemdService(config.AppName, svcConfig); err != nil {
 		return err
 	}
 
 	// 6. Start Service
-	if err := EnableAndStartService("fazt"); err != nil {
+	if err := EnableAndStartService(config.AppName); err != nil {
 		return err
 	}
 
@@ -0,0 +1,148 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestRSRemainder(t *testing.T) {
+	// "HELLO WORLD" at 1-M, from the ISO 18004 worked example
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+
+	got := rsRemainder(data, rsDivisor(10))
+	if !bytes.Equal(got, want) {
+		t.Errorf("rsRemainder = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// Level M format strings from the specification
+	tests := map[int]uint32{
+		0: 0x5412, // 101010000010010
+		1: 0x5125, // 101000100100101
+		4: 0x45F9, // 100010111111001
+		7: 0x4AA0, // 100101010100000
+	}
+
+	for mask, want := range tests {
+		if got := formatBits(mask); got != want {
+			t.Errorf("formatBits(%d) = %015b, want %015b", mask, got, want)
+		}
+	}
+}
+
+func TestEncode_VersionSelection(t *testing.T) {
+	tests := []struct {
+		length  int
+		version int
+	}{
+		{1, 1},
+		{14, 1},
+		{15, 2},
+		{100, 6},
+		{213, 10},
+	}
+
+	for _, tt := range tests {
+		c, err := Encode(strings.Repeat("a", tt.length))
+		if err != nil {
+			t.Fatalf("Encode(%d bytes) failed: %v", tt.length, err)
+		}
+		if c.Version != tt.version {
+			t.Errorf("Encode(%d bytes) version = %d, want %d", tt.length, c.Version, tt.version)
+		}
+		if c.Size != tt.version*4+17 {
+			t.Errorf("size = %d, want %d", c.Size, tt.version*4+17)
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("a", 214)); err == nil {
+		t.Error("Encode should fail for content over capacity")
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	content := "https://fazt.example.com/r/spring-sale"
+	c, err := Encode(content)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Read the format bits back to find the mask
+	var bits uint32
+	for i := 0; i <= 5; i++ {
+		if c.Modules[i][8] {
+			bits |= 1 << uint(i)
+		}
+	}
+	mask := -1
+	for m := 0; m < 8; m++ {
+		if formatBits(m)&0x3F == bits {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("no mask matches format bits %06b", bits)
+	}
+
+	// Unmask and read codewords in placement order
+	c.applyMask(mask)
+	var read bitBuffer
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				if x := right - j; !c.isFunction[y][x] {
+					read = append(read, c.Modules[y][x])
+				}
+			}
+		}
+	}
+
+	want := addErrorCorrection(encodeData([]byte(content), c.Version), c.Version)
+	got := read.bytes()[:len(want)]
+	if !bytes.Equal(got, want) {
+		t.Error("codewords read back from the symbol do not match the encoded data")
+	}
+
+	// Finder pattern corners are dark, separators light
+	if !c.Modules[0][0] || !c.Modules[0][c.Size-1] || !c.Modules[c.Size-1][0] {
+		t.Error("finder pattern corners should be dark")
+	}
+	if c.Modules[7][7] {
+		t.Error("finder separator should be light")
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode("https://fazt.sh/r/x")
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	data, err := c.PNG(300)
+	if err != nil {
+		t.Fatalf("PNG failed: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("output is not a valid PNG: %v", err)
+	}
+
+	total := c.Size + 2*quietZone
+	width := img.Bounds().Dx()
+	if width%total != 0 || width > 300 || width < 300-total {
+		t.Errorf("width = %d, want a multiple of %d close to 300", width, total)
+	}
+}
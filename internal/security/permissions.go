@@ -2,8 +2,9 @@ package security
 
 import (
 	"fmt"
-	"log"
 	"os"
+
+	"github.com/jikku/command-center/internal/logging"
 )
 
 // CheckFilePermissions verifies that sensitive files have proper permissions
@@ -18,14 +19,14 @@ func CheckFilePermissions(path string, expectedPerms os.FileMode) error {
 
 	actualPerms := info.Mode().Perm()
 	if actualPerms != expectedPerms {
-		log.Printf("WARNING: %s has permissions %o, should be %o", path, actualPerms, expectedPerms)
-		log.Printf("Attempting to fix permissions...")
+		logging.Warnf("WARNING: %s has permissions %o, should be %o", path, actualPerms, expectedPerms)
+		logging.Warnf("Attempting to fix permissions...")
 
 		if err := os.Chmod(path, expectedPerms); err != nil {
 			return fmt.Errorf("failed to set permissions: %w", err)
 		}
 
-		log.Printf("✓ Fixed permissions for %s", path)
+		logging.Infof("✓ Fixed permissions for %s", path)
 	}
 
 	return nil
@@ -35,12 +36,12 @@ func CheckFilePermissions(path string, expectedPerms os.FileMode) error {
 func EnsureSecurePermissions(configPath, dbPath string) {
 	// Config file should be 0600 (owner read/write only)
 	if err := CheckFilePermissions(configPath, 0600); err != nil {
-		log.Printf("Warning: Could not secure config file permissions: %v", err)
+		logging.Warnf("Warning: Could not secure config file permissions: %v", err)
 	}
 
 	// Database file should be 0600 (owner read/write only)
 	if err := CheckFilePermissions(dbPath, 0600); err != nil {
-		log.Printf("Warning: Could not secure database file permissions: %v", err)
+		logging.Warnf("Warning: Could not secure database file permissions: %v", err)
 	}
 
 	// WAL and SHM files too
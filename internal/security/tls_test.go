@@ -0,0 +1,27 @@
+package security
+
+import (
+	"testing"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, err := SelfSignedCertificate([]string{"localhost", "*.localhost", "127.0.0.1", "fazt.test", "*.fazt.test"})
+	if err != nil {
+		t.Fatalf("SelfSignedCertificate failed: %v", err)
+	}
+
+	for _, host := range []string{"localhost", "blog.localhost", "127.0.0.1", "fazt.test", "app.fazt.test"} {
+		if err := cert.Leaf.VerifyHostname(host); err != nil {
+			t.Errorf("certificate does not cover %s: %v", host, err)
+		}
+	}
+	if err := cert.Leaf.VerifyHostname("example.com"); err == nil {
+		t.Error("certificate should not cover example.com")
+	}
+
+	// Regenerated on every call
+	other, _ := SelfSignedCertificate([]string{"localhost"})
+	if other.Leaf.SerialNumber.Cmp(cert.Leaf.SerialNumber) == 0 {
+		t.Error("two certificates share a serial number")
+	}
+}
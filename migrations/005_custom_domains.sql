@@ -0,0 +1,10 @@
+-- Migration 005: Custom Domains
+
+-- Maps external hostnames (e.g. www.mybrand.com via CNAME) to hosted sites
+CREATE TABLE IF NOT EXISTS custom_domains (
+    hostname TEXT PRIMARY KEY,  -- lowercase, no port
+    site_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_domains_site_id ON custom_domains(site_id);
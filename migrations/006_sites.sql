@@ -0,0 +1,13 @@
+-- Migration 006: Site Metadata
+
+-- Sites were implicit (GROUP BY files.site_id); this table holds per-site state
+CREATE TABLE IF NOT EXISTS sites (
+    site_id TEXT PRIMARY KEY,
+    enabled BOOLEAN NOT NULL DEFAULT 1,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Backfill existing sites
+INSERT OR IGNORE INTO sites (site_id)
+SELECT DISTINCT site_id FROM files;
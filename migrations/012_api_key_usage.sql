@@ -0,0 +1,5 @@
+-- Migration 012: API Key Usage
+
+-- Where each key was last used from, for spotting leaked keys
+ALTER TABLE api_keys ADD COLUMN last_used_ip TEXT;
+ALTER TABLE api_keys ADD COLUMN last_used_user_agent TEXT;
@@ -0,0 +1,6 @@
+-- Migration 013: API Key Prefix
+
+-- New tokens look like fazt_<prefix>_<secret>; the prefix finds the row without
+-- bcrypt-comparing every key. Legacy keys keep a NULL prefix.
+ALTER TABLE api_keys ADD COLUMN key_prefix TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_prefix ON api_keys(key_prefix);
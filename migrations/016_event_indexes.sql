@@ -0,0 +1,14 @@
+-- Migration 016: Event Indexes
+
+-- Per-domain event lists are filtered by domain and ordered by created_at.
+-- The composite also serves domain-only lookups, so it replaces idx_events_domain.
+CREATE INDEX IF NOT EXISTS idx_events_domain_created_at ON events(domain, created_at);
+DROP INDEX IF EXISTS idx_events_domain;
+
+-- Redirect stats filter on source_type and path over a date range.
+-- It also serves source_type-only lookups, so it replaces idx_events_source_type.
+CREATE INDEX IF NOT EXISTS idx_events_source_path_created_at ON events(source_type, path, created_at);
+DROP INDEX IF EXISTS idx_events_source_type;
+
+-- redirects.slug is already UNIQUE, which SQLite enforces with its own index
+DROP INDEX IF EXISTS idx_redirects_slug;
@@ -0,0 +1,13 @@
+-- Migration 017: Tracking Tokens
+
+-- Public per-domain tokens for /track. They are embedded in page source, so
+-- they're stored as-is; a token only lets pages record events for its domain.
+CREATE TABLE IF NOT EXISTS tracking_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token TEXT NOT NULL UNIQUE,
+    domain TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tracking_tokens_domain ON tracking_tokens(domain);
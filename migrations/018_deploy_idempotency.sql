@@ -0,0 +1,9 @@
+-- Migration 018: Deploy Idempotency Keys
+
+-- A retried deploy resends its Idempotency-Key header, and the deployment
+-- recorded under that key is returned instead of deploying again. Keys are
+-- scoped to the API key that sent them.
+ALTER TABLE deployments ADD COLUMN idempotency_key TEXT;
+ALTER TABLE deployments ADD COLUMN api_key_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_deployments_idempotency_key ON deployments(idempotency_key);
@@ -0,0 +1,10 @@
+-- Migration 019: Site Aliases
+
+-- Lets a subdomain serve another site's files without copying them
+CREATE TABLE IF NOT EXISTS site_aliases (
+    alias TEXT PRIMARY KEY,  -- the subdomain that is served
+    site_id TEXT NOT NULL,   -- the site whose files it serves
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_site_aliases_site_id ON site_aliases(site_id);
@@ -0,0 +1,4 @@
+-- Migration 021: Redirect Versions
+
+-- Bumped on every update, so concurrent edits can be detected (If-Match)
+ALTER TABLE redirects ADD COLUMN version INTEGER NOT NULL DEFAULT 1;